@@ -0,0 +1,121 @@
+package kbd
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// Action is something done in response to a key, such as a hotkey.
+type Action func() error
+
+// ErrNoBacklight is returned by Brightness when no backlight device exists.
+var ErrNoBacklight = errors.New("kbd: no backlight found in /sys/class/backlight")
+
+// Brightness returns an Action that changes the brightness of the first
+// backlight in `/sys/class/backlight/` by percent (of the maximum), which may
+// be negative. The result is clamped to the valid range. If the process may
+// not write the brightness, it is set through systemd-logind, which allows it
+// for the user of the active session.
+func Brightness(percent int) Action {
+	return func() error {
+		dirs, _ := filepath.Glob("/sys/class/backlight/*")
+		if len(dirs) == 0 {
+			return ErrNoBacklight
+		}
+		dir := dirs[0]
+
+		max, err := readSysInt(filepath.Join(dir, "max_brightness"))
+		if err != nil {
+			return err
+		}
+		cur, err := readSysInt(filepath.Join(dir, "brightness"))
+		if err != nil {
+			return err
+		}
+
+		step := max * percent / 100
+		switch { // at least 1 for a small max_brightness, such as 7
+		case step == 0 && percent > 0:
+			step = 1
+		case step == 0 && percent < 0:
+			step = -1
+		}
+		cur += step
+		if cur < 0 {
+			cur = 0
+		}
+		if cur > max {
+			cur = max
+		}
+		err = ioutil.WriteFile(filepath.Join(dir, "brightness"),
+			[]byte(strconv.Itoa(cur)), 0644)
+		if os.IsPermission(err) {
+			err = loginCall("/org/freedesktop/login1/session/auto",
+				"org.freedesktop.login1.Session.SetBrightness",
+				"backlight", filepath.Base(dir), uint32(cur))
+		}
+		return err
+	}
+}
+
+func readSysInt(path string) (int, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(strings.TrimSpace(string(b)))
+}
+
+// Suspend returns an Action that suspends the system through systemd-logind.
+func Suspend() Action {
+	return logind("Suspend")
+}
+
+// Hibernate returns an Action that hibernates the system through
+// systemd-logind.
+func Hibernate() Action {
+	return logind("Hibernate")
+}
+
+// PowerOff returns an Action that shuts down the system through
+// systemd-logind.
+func PowerOff() Action {
+	return logind("PowerOff")
+}
+
+// logind calls method on the logind Manager over the system bus. logind
+// checks with polkit that the caller is allowed to, asking the user to
+// authenticate if needed, so the process needs no privileges of its own.
+func logind(method string) Action {
+	return func() error {
+		return loginCall("/org/freedesktop/login1", "org.freedesktop.login1.Manager."+method, true)
+	}
+}
+
+// loginCall calls method of the logind object path over the system bus, and
+// waits for it to finish.
+func loginCall(path dbus.ObjectPath, method string, args ...interface{}) error {
+	conn, err := dbus.SystemBus()
+	if err != nil {
+		return err
+	}
+	return conn.Object("org.freedesktop.login1", path).Call(method, 0, args...).Err
+}
+
+// HardwareActions returns Actions for the brightness and power keys found on
+// many keyboards and laptops.
+func HardwareActions() map[KeyCode]Action {
+	return map[KeyCode]Action{
+		KeyBRIGHTNESSUP:   Brightness(10),
+		KeyBRIGHTNESSDOWN: Brightness(-10),
+		KeySLEEP:          Suspend(),
+		KeySUSPEND:        Hibernate(),
+		KeyPOWER:          PowerOff(),
+	}
+}
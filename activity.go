@@ -0,0 +1,81 @@
+package kbd
+
+import (
+	"context"
+
+	"golang.org/x/sys/unix"
+)
+
+// WaitForActivity blocks until kb, which must be started, reads a key event
+// (including a repeat) and has applied and delivered it, and so lets idle
+// programs sleep without polling IsDown. It returns ctx.Err() if ctx is done
+// first, and ErrStopped if kb stops. It doesn't consume kb's Event()
+// channel.
+func (kb *Keyboard) WaitForActivity(ctx context.Context) error {
+	kb.mu.Lock()
+	if kb.closed || kb.events == nil {
+		kb.mu.Unlock()
+		return ErrStopped
+	}
+	if kb.activity == nil {
+		kb.activity = make(chan struct{})
+	}
+	activity := kb.activity
+	kb.mu.Unlock()
+
+	select {
+	case <-activity:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	kb.mu.Lock()
+	defer kb.mu.Unlock()
+	if kb.closed {
+		return ErrStopped
+	}
+	return nil
+}
+
+// ReadyFd returns a file descriptor that becomes readable when kb has read
+// key events and applied and delivered them, for programs with their own
+// poll or epoll loop, such as C or SDL programs, that can't wait on a
+// channel. Once it is readable, the program should read from it until it
+// would block (it is non-blocking) to reset it, and then receive from
+// Frames() or Event() without blocking, or look at the key state with
+// IsDown. It also becomes readable when kb's read loop ends.
+// Every call returns the same descriptor, which is closed by Close.
+func (kb *Keyboard) ReadyFd() (int, error) {
+	kb.mu.Lock()
+	defer kb.mu.Unlock()
+	if kb.ready[0] == 0 {
+		var p [2]int
+		if err := unix.Pipe2(p[:], unix.O_NONBLOCK|unix.O_CLOEXEC); err != nil {
+			return -1, err
+		}
+		kb.ready = p
+	}
+	return kb.ready[0], nil
+}
+
+// closeReady closes the descriptors of ReadyFd.
+func (kb *Keyboard) closeReady() {
+	kb.mu.Lock()
+	defer kb.mu.Unlock()
+	if kb.ready[0] != 0 {
+		unix.Close(kb.ready[0])
+		unix.Close(kb.ready[1])
+		kb.ready = [2]int{}
+	}
+}
+
+// wakeWaiters ends the waits of WaitForActivity and makes ReadyFd readable.
+// kb.mu must be held.
+func (kb *Keyboard) wakeWaiters() {
+	if kb.activity != nil {
+		close(kb.activity)
+		kb.activity = nil
+	}
+	if kb.ready[1] != 0 {
+		unix.Write(kb.ready[1], []byte{0}) // a full pipe is readable anyway
+	}
+}
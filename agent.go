@@ -0,0 +1,100 @@
+package kbd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+func init() {
+	RegisterBackend("ssh", func(path string) (Backend, error) {
+		return OpenSSH(path)
+	})
+}
+
+// AgentCommand is the command OpenSSH runs on the remote host.
+var AgentCommand = "kbd-agent"
+
+// RunAgent writes every event read from b to w in the package's wire
+// format, until reading or writing fails, and returns the error. It is the
+// loop of `kbd-agent`, which streams the key events of a remote host, such
+// as a headless device, over the stdout of an SSH session, to be read with
+// an EventReader or OpenSSH.
+func RunAgent(b Backend, w io.Writer) error {
+	ew := NewEventWriter(w)
+	for {
+		event, err := b.ReadEvent()
+		if err != nil {
+			return err
+		}
+		if err := ew.Write(event); err != nil {
+			return err
+		}
+	}
+}
+
+// sshAgent is a Backend reading the events streamed by an agent over SSH.
+type sshAgent struct {
+	*EventReader
+	cmd  *exec.Cmd
+	host string
+
+	once sync.Once
+}
+
+// OpenSSH runs AgentCommand with args on host over ssh, which must log in
+// without asking for a password, such as with a key, and returns a Backend
+// reading the events it streams (see RunAgent). The events' Device is host.
+// The agent reads the devices given in args, or every device, and needs read
+// access to them on host. ssh's errors go to stderr. Close ends the session.
+// The "ssh" Backend opens host this way, where host may be followed by
+// devices separated by spaces. A host starting with '-' is refused, so that
+// it can't be taken for an option of ssh; args are quoted for the remote
+// shell.
+func OpenSSH(host string, args ...string) (Backend, error) {
+	if fields := strings.Fields(host); len(fields) > 1 {
+		host, args = fields[0], append(fields[1:], args...)
+	}
+	if host == "" || strings.HasPrefix(host, "-") {
+		return nil, fmt.Errorf("kbd: bad ssh host %q", host)
+	}
+	command := AgentCommand
+	for _, arg := range args {
+		command += " " + shellQuote(arg)
+	}
+	cmd := exec.Command("ssh", "-T", "-e", "none", "--", host, command)
+	cmd.Stderr = os.Stderr
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	return &sshAgent{EventReader: NewEventReader(out), cmd: cmd, host: host}, nil
+}
+
+// shellQuote quotes s for a POSIX shell.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func (s *sshAgent) ReadEvent() (Event, error) {
+	event, err := s.EventReader.ReadEvent()
+	if err == nil {
+		event.Device = s.host
+	}
+	return event, err
+}
+
+// Close ends the SSH session.
+func (s *sshAgent) Close() error {
+	s.once.Do(func() {
+		s.cmd.Process.Kill()
+		s.cmd.Wait()
+	})
+	return nil
+}
@@ -0,0 +1,90 @@
+package kbd
+
+import (
+	"sync"
+	"time"
+)
+
+// ClockAligner maps the timestamp of an event onto a common clock. It is
+// given the event and the time it arrived, read from the monotonic clock.
+// Devices may stamp their events with different clocks, or with the same
+// clock at different latencies (a Bluetooth keyboard's events are older than
+// they look), so timestamps from different devices can't be compared as they
+// are.
+type ClockAligner interface {
+	Align(event Event, arrival time.Time) time.Time
+}
+
+// OffsetAligner is a ClockAligner that estimates, for each device, the offset
+// between the device's clock and the arrival clock as the smallest difference
+// seen between an event's arrival and its timestamp, which is the event with
+// the least delivery latency. The offset is allowed to creep upwards slowly,
+// to follow clocks that drift apart.
+type OffsetAligner struct {
+	mu      sync.Mutex
+	offsets map[string]time.Duration
+	base    time.Time // first arrival, giving aligned times a monotonic reading
+}
+
+// offsetCreep is the fraction of the difference by which an offset estimate
+// moves towards a larger sample.
+const offsetCreep = 1024
+
+// Align returns the timestamp of event plus the offset of its device,
+// carrying a monotonic clock reading.
+func (a *OffsetAligner) Align(event Event, arrival time.Time) time.Time {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.offsets == nil {
+		a.offsets = map[string]time.Duration{}
+		a.base = arrival
+	}
+	sample := arrival.Sub(event.Time)
+	offset, ok := a.offsets[event.Device]
+	switch {
+	case !ok || sample < offset:
+		offset = sample
+	default:
+		offset += (sample - offset) / offsetCreep
+	}
+	a.offsets[event.Device] = offset
+	return a.base.Add(event.Time.Add(offset).Sub(a.base))
+}
+
+// aligned is a Backend that aligns the timestamps of another.
+type aligned struct {
+	b    Backend
+	a    ClockAligner
+	last time.Time
+}
+
+// Align returns a Backend whose events have their Time aligned to a single
+// monotonic clock by a, or by an OffsetAligner if a is nil, for recording
+// from several devices (such as with a Multiplexer) in a consistent order.
+// Aligned times never go backwards: an event aligned to before the previous
+// one is given the previous one's time. Event.Timeval keeps the device's own
+// timestamp.
+func Align(b Backend, a ClockAligner) Backend {
+	if a == nil {
+		a = &OffsetAligner{}
+	}
+	return &aligned{b: b, a: a}
+}
+
+func (al *aligned) ReadEvent() (Event, error) {
+	event, err := al.b.ReadEvent()
+	if err != nil {
+		return event, err
+	}
+	t := al.a.Align(event, time.Now())
+	if t.Before(al.last) {
+		t = al.last
+	}
+	al.last = t
+	event.Time = t
+	return event, nil
+}
+
+func (al *aligned) Close() error {
+	return al.b.Close()
+}
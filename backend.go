@@ -0,0 +1,94 @@
+package kbd
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Event is a single key event read from a Backend.
+type Event struct {
+	Time    time.Time
+	Timeval Timeval // the kernel's timestamp, if read from an evdev device
+	Code    KeyCode
+	Value   int32  // Release, Press, or Repeat
+	Device  string // path of the device that produced the event, if known
+
+	// Initial is set on the press events a Keyboard reports for keys that
+	// were already down when it was created, rather than pressed since.
+	Initial bool
+
+	// Synthetic is set on the events a Keyboard makes up rather than reads,
+	// such as the releases of SetReleaseTimeout.
+	Synthetic bool
+
+	// Seq numbers the events a Keyboard applies to its key state, in the
+	// order they are applied, starting at 1. It is 0 for events not (yet)
+	// applied by a Keyboard, such as those returned by a Backend, and for
+	// repeats, which don't change key state.
+	Seq uint64
+}
+
+// Backend is a source of key events. Implementations allow a Keyboard to
+// read from devices other than the evdev files in `/dev/input/`.
+type Backend interface {
+	// ReadEvent blocks until the next key event is available.
+	ReadEvent() (Event, error)
+	// Close releases any resources held by the Backend.
+	Close() error
+}
+
+// BackendFunc opens the device at path as a Backend.
+type BackendFunc func(path string) (Backend, error)
+
+var (
+	backendsMu sync.Mutex
+	backends   = map[string]BackendFunc{}
+)
+
+// RegisterBackend makes a Backend available by name to OpenBackend. It panics
+// if open is nil or if RegisterBackend is called twice with the same name.
+func RegisterBackend(name string, open BackendFunc) {
+	backendsMu.Lock()
+	defer backendsMu.Unlock()
+	if open == nil {
+		panic("kbd: RegisterBackend open func is nil")
+	}
+	if _, dup := backends[name]; dup {
+		panic(fmt.Sprintf("kbd: RegisterBackend called twice for %q", name))
+	}
+	backends[name] = open
+}
+
+// OpenDevice opens the device at path with the Backend registered as name.
+// path may be a label, as "label:NAME" (see ResolveDevice); if several
+// devices have the label, the first is opened.
+func OpenDevice(name, path string) (Backend, error) {
+	backendsMu.Lock()
+	open, ok := backends[name]
+	backendsMu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("kbd: unknown backend %q", name)
+	}
+	paths, err := ResolveDevice(path)
+	if err != nil {
+		return nil, err
+	}
+	return open(paths[0])
+}
+
+// Backends returns the names of the registered backends.
+func Backends() []string {
+	backendsMu.Lock()
+	defer backendsMu.Unlock()
+	names := make([]string, 0, len(backends))
+	for name := range backends {
+		names = append(names, name)
+	}
+	return names
+}
+
+// errClosedBackend is returned by wrapping Backends read after the wrapped
+// Backend has already returned an error.
+var errClosedBackend = errors.New("kbd: read from failed backend")
@@ -0,0 +1,90 @@
+package kbd
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ErrNoSpeaker is returned by OpenSpeaker when no device can play tones.
+var ErrNoSpeaker = errors.New("kbd: no device in /dev/input can play tones")
+
+// sndTone is SND_TONE, the sound code of a tone of a given frequency.
+const sndTone = 0x02
+
+// eviocgBitSND is EVIOCGBIT(EV_SND), which reads the sounds supported.
+var eviocgBitSND = eviocg(0x20+eventSND, 1)
+
+// Speaker plays tones on a device that supports EV_SND tones, usually the PC
+// speaker ("PC Speaker" in `/proc/bus/input/devices`).
+type Speaker struct {
+	file *os.File
+}
+
+// OpenSpeaker opens the first device in `/dev/input/` that can play tones.
+// It needs write access to the device.
+func OpenSpeaker() (*Speaker, error) {
+	paths, _ := filepath.Glob("/dev/input/event*")
+	for _, path := range paths {
+		f, err := os.OpenFile(path, os.O_WRONLY, 0)
+		if err != nil {
+			continue
+		}
+		var bits [1]byte
+		if ioctlBytes(f.Fd(), eviocgBitSND, bits[:]) == nil && bits[0]&(1<<sndTone) != 0 {
+			return &Speaker{file: f}, nil
+		}
+		f.Close()
+	}
+	return nil, ErrNoSpeaker
+}
+
+// Tone starts a tone of freq Hz, or stops it if freq is 0.
+func (s *Speaker) Tone(freq int) error {
+	var buf [inputEventSize]byte
+	tv := TimevalOf(time.Now())
+	raw := inputEvent{Sec: tv.Sec, Usec: tv.Usec, Kind: eventSND, Code: sndTone, Value: uint32(freq)}
+	raw.encode(buf[:])
+	_, err := s.file.Write(buf[:])
+	return err
+}
+
+// Beep plays a tone of freq Hz for d, and returns when it ends.
+func (s *Speaker) Beep(freq int, d time.Duration) error {
+	if err := s.Tone(freq); err != nil {
+		return err
+	}
+	time.Sleep(d)
+	return s.Tone(0)
+}
+
+// Close stops any tone and closes the device.
+func (s *Speaker) Close() error {
+	s.Tone(0)
+	return s.file.Close()
+}
+
+// Beep plays a tone of freq Hz for d on the PC speaker, and returns when it
+// ends. If there is no speaker, or it may not be used, the terminal's bell
+// is rung instead, which the terminal may show rather than sound. It opens
+// the device each time; to beep often, use OpenSpeaker.
+func Beep(freq int, d time.Duration) error {
+	s, err := OpenSpeaker()
+	if err != nil {
+		return bell()
+	}
+	defer s.Close()
+	return s.Beep(freq, d)
+}
+
+// bell rings the bell of the terminal at `/dev/tty`.
+func bell() error {
+	tty, err := os.OpenFile("/dev/tty", os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	defer tty.Close()
+	_, err = tty.Write([]byte("\a"))
+	return err
+}
@@ -0,0 +1,187 @@
+package kbd
+
+import (
+	"bytes"
+	"os"
+	"sync"
+
+	"golang.org/x/sys/unix"
+)
+
+// L2CAP channels (PSMs) of the Bluetooth HID profile.
+const (
+	psmHIDControl   = 0x11
+	psmHIDInterrupt = 0x13
+)
+
+// Bluetooth HID transaction types, in the high nibble of the header byte.
+const (
+	hidpHandshake   = 0x00
+	hidpControl     = 0x10
+	hidpGetReport   = 0x40
+	hidpGetProtocol = 0x60
+	hidpGetIdle     = 0x80
+	hidpData        = 0xa0
+	hidpDataInput   = 0xa1
+)
+
+// BluetoothHID presents a keyboard to a Bluetooth host, such as a tablet or
+// console, and sends it key state as HID boot protocol reports. It speaks the
+// HID profile over L2CAP directly, so BlueZ's own input plugin must be
+// disabled (`bluetoothd -P input`) and an SDP record for a keyboard must be
+// registered with the adapter for hosts to discover it.
+type BluetoothHID struct {
+	ctrlListen, intrListen int
+
+	mu   sync.Mutex
+	ctrl *os.File
+	intr *os.File
+	last []byte
+}
+
+// ListenBluetoothHID listens for connections to the HID channels on all
+// Bluetooth adapters.
+func ListenBluetoothHID() (*BluetoothHID, error) {
+	ctrl, err := listenL2CAP(psmHIDControl)
+	if err != nil {
+		return nil, err
+	}
+	intr, err := listenL2CAP(psmHIDInterrupt)
+	if err != nil {
+		unix.Close(ctrl)
+		return nil, err
+	}
+	return &BluetoothHID{ctrlListen: ctrl, intrListen: intr}, nil
+}
+
+func listenL2CAP(psm uint16) (int, error) {
+	fd, err := unix.Socket(unix.AF_BLUETOOTH, unix.SOCK_SEQPACKET, unix.BTPROTO_L2CAP)
+	if err != nil {
+		return -1, err
+	}
+	err = unix.Bind(fd, &unix.SockaddrL2{PSM: psm})
+	if err == nil {
+		err = unix.Listen(fd, 1)
+	}
+	if err != nil {
+		unix.Close(fd)
+		return -1, err
+	}
+	return fd, nil
+}
+
+// Accept waits for a host to connect to both channels. Any previous
+// connection is closed.
+func (h *BluetoothHID) Accept() error {
+	ctrl, _, err := unix.Accept(h.ctrlListen)
+	if err != nil {
+		return err
+	}
+	intr, _, err := unix.Accept(h.intrListen)
+	if err != nil {
+		unix.Close(ctrl)
+		return err
+	}
+
+	h.mu.Lock()
+	h.disconnect()
+	h.ctrl = os.NewFile(uintptr(ctrl), "hid-control")
+	h.intr = os.NewFile(uintptr(intr), "hid-interrupt")
+	h.last = nil
+	h.mu.Unlock()
+
+	go h.control(h.ctrl)
+	return nil
+}
+
+// control answers the host's requests on the control channel.
+func (h *BluetoothHID) control(ctrl *os.File) {
+	buf := make([]byte, 64)
+	for {
+		n, err := ctrl.Read(buf)
+		if err != nil || n == 0 {
+			return
+		}
+
+		h.mu.Lock()
+		var reply []byte
+		switch buf[0] & 0xf0 {
+		case hidpControl:
+			if buf[0] == hidpControl|0x05 { // virtual cable unplug
+				h.disconnect()
+				h.mu.Unlock()
+				return
+			}
+		case hidpGetReport:
+			reply = append([]byte{hidpDataInput}, h.current()...)
+		case hidpGetProtocol:
+			reply = []byte{hidpData, 0} // boot protocol
+		case hidpGetIdle:
+			reply = []byte{hidpData, 0}
+		default: // SET_* requests
+			reply = []byte{hidpHandshake} // successful
+		}
+		h.mu.Unlock()
+
+		if reply != nil {
+			ctrl.Write(reply)
+		}
+	}
+}
+
+// current returns the last report sent. h.mu must be held.
+func (h *BluetoothHID) current() []byte {
+	if h.last == nil {
+		return bootReport(nil)
+	}
+	return h.last
+}
+
+// Report sends a report to the host with the keys in pressed held down and
+// all others released, like HIDGadget.Report.
+func (h *BluetoothHID) Report(pressed []KeyCode) error {
+	report := bootReport(pressed)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.intr == nil {
+		return os.ErrClosed
+	}
+	if bytes.Equal(report, h.last) {
+		return nil
+	}
+	_, err := h.intr.Write(append([]byte{hidpDataInput}, report...))
+	if err == nil {
+		h.last = report
+	}
+	return err
+}
+
+// Forward sends the key state of kb to the host each time it changes, until
+// kb stops or a write fails. kb must already be started.
+func (h *BluetoothHID) Forward(kb *Keyboard) error {
+	for range kb.Event() {
+		if err := h.Report(kb.Pressed()); err != nil {
+			return err
+		}
+	}
+	return h.Report(nil)
+}
+
+// disconnect closes the connection to the host. h.mu must be held.
+func (h *BluetoothHID) disconnect() {
+	if h.ctrl != nil {
+		h.ctrl.Close()
+		h.intr.Close()
+		h.ctrl, h.intr = nil, nil
+	}
+}
+
+// Close disconnects the host and stops listening.
+func (h *BluetoothHID) Close() error {
+	h.mu.Lock()
+	h.disconnect()
+	h.mu.Unlock()
+	unix.Close(h.intrListen)
+	return unix.Close(h.ctrlListen)
+}
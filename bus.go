@@ -0,0 +1,317 @@
+package kbd
+
+import (
+	"reflect"
+	"sync"
+)
+
+// KeyEvent is published on a Keyboard's Bus for each key event read,
+// including repeats.
+type KeyEvent struct {
+	Event
+}
+
+// DeviceEvent is published on a Keyboard's Bus when a device is added to or
+// removed from its Backend, if the Backend is a DeviceReporter. Err is set if
+// the device was removed because it failed, for example by being unplugged.
+type DeviceEvent struct {
+	Path  string
+	Added bool
+	Err   error
+}
+
+// ErrorEvent is published on a Keyboard's Bus when its read loop ends with
+// an error.
+type ErrorEvent struct {
+	Err error
+}
+
+// DeviceReporter is implemented by Backends that read from a changing set of
+// devices, such as a Multiplexer. A Keyboard passes its Bus to
+// ReportDevices, so DeviceEvents are published on it.
+type DeviceReporter interface {
+	ReportDevices(b *Bus)
+}
+
+// BusPublisher is implemented by Backends, usually stages wrapping another
+// Backend, that publish values of their own, such as TypingStarted. A
+// Keyboard passes its Bus to PublishOn each time it starts. Stages should
+// pass it on to the Backend they wrap, if it is a BusPublisher too.
+type BusPublisher interface {
+	PublishOn(b *Bus)
+}
+
+// Bus delivers values of several kinds, such as Frame, KeyEvent, DeviceEvent,
+// LockEvent and ErrorEvent, to subscribers that choose the kinds they want.
+// The kind of a value is its type. Every Keyboard has a Bus, which its
+// Subscriptions are subscribed to; others can be created with NewBus. With
+// Go 1.21 or later, SubscribeTo and Handle deliver a single kind with its own
+// type, without type assertions.
+type Bus struct {
+	mu     sync.Mutex
+	subs   map[*outlet]bool
+	closed bool
+}
+
+// NewBus creates a Bus with no subscribers.
+func NewBus() *Bus {
+	return &Bus{subs: map[*outlet]bool{}}
+}
+
+// channel is the channel an outlet sends on, whatever its element type.
+type channel interface {
+	trySend(v interface{}) bool
+	tryReceive() (interface{}, bool)
+	send(v interface{}, done <-chan struct{}) // until done is closed
+	close()
+}
+
+// anyChannel is a channel of values of any kind, as BusSubscriptions have.
+type anyChannel chan interface{}
+
+func (c anyChannel) trySend(v interface{}) bool {
+	select { // non-blocking channel send
+	case c <- v:
+		return true
+	default:
+		return false
+	}
+}
+
+func (c anyChannel) tryReceive() (interface{}, bool) {
+	select { // non-blocking channel recieve to "drain" channel
+	case v := <-c:
+		return v, true
+	default:
+		return nil, false
+	}
+}
+
+func (c anyChannel) send(v interface{}, done <-chan struct{}) {
+	select {
+	case c <- v:
+	case <-done:
+	}
+}
+
+func (c anyChannel) close() { close(c) }
+
+// outlet is a subscriber of a Bus: it delivers the values of the kinds it
+// subscribed to on its channel, with its Backpressure policy. Subscriptions,
+// BusSubscriptions and the channels of SubscribeTo are outlets with channels
+// of different types.
+type outlet struct {
+	bus    *Bus
+	c      channel
+	kinds  map[reflect.Type]bool // all if empty
+	policy Backpressure
+
+	mu      sync.Mutex
+	closed  bool
+	dropped uint64
+	done    chan struct{}  // closed by close, to end a Block send
+	sending sync.WaitGroup // Block sends in progress
+}
+
+// subscribe adds an outlet sending on c the values of kinds, given as
+// example values, to b. It is closed at once if b is.
+func (b *Bus) subscribe(c channel, policy Backpressure, kinds ...interface{}) *outlet {
+	o := &outlet{
+		bus:    b,
+		c:      c,
+		kinds:  map[reflect.Type]bool{},
+		policy: policy,
+		done:   make(chan struct{}),
+	}
+	for _, kind := range kinds {
+		o.kinds[reflect.TypeOf(kind)] = true
+	}
+	subscribers.add(1) // until o is closed
+
+	b.mu.Lock()
+	closed := b.closed
+	if !closed {
+		b.subs[o] = true
+	}
+	b.mu.Unlock()
+	if closed {
+		o.close()
+	}
+	return o
+}
+
+// BusSubscription is a channel of the values published on a Bus of the kinds
+// subscribed to, with its own buffer and Backpressure policy (Coalesce
+// merges Frames, and acts like DropOldest for other kinds).
+type BusSubscription struct {
+	C <-chan interface{}
+
+	o *outlet
+}
+
+// Subscribe returns a subscription to the values published on b whose kind
+// is that of one of kinds, given as example values (such as KeyEvent{}), or
+// to all values if kinds is empty. The subscription buffers up to size
+// values (at least 1), and handles a full buffer according to policy. Its
+// channel is closed when b is closed, or the subscription is.
+func (b *Bus) Subscribe(size int, policy Backpressure, kinds ...interface{}) *BusSubscription {
+	if size < 1 {
+		size = 1
+	}
+	c := make(chan interface{}, size)
+	return &BusSubscription{C: c, o: b.subscribe(anyChannel(c), policy, kinds...)}
+}
+
+// Publish delivers v to the subscribers of its kind. It blocks only if a
+// subscriber with the Block policy is full.
+func (b *Bus) Publish(v interface{}) {
+	kind := reflect.TypeOf(v)
+	b.mu.Lock()
+	subs := make([]*outlet, 0, len(b.subs))
+	for o := range b.subs {
+		if len(o.kinds) == 0 || o.kinds[kind] {
+			subs = append(subs, o)
+		}
+	}
+	b.mu.Unlock()
+	for _, o := range subs {
+		o.send(v)
+	}
+}
+
+// Close closes the channels of all subscriptions. Later subscriptions are
+// closed at once, and later values are dropped.
+func (b *Bus) Close() {
+	b.mu.Lock()
+	subs := b.subs
+	b.subs = map[*outlet]bool{}
+	b.closed = true
+	b.mu.Unlock()
+	for o := range subs {
+		o.close()
+	}
+}
+
+// Dropped returns the number of values the subscription has discarded
+// because its channel was full.
+func (s *BusSubscription) Dropped() uint64 {
+	return s.o.droppedCount()
+}
+
+// Close ends the subscription and closes its channel.
+func (s *BusSubscription) Close() {
+	s.o.unsubscribe()
+}
+
+func (o *outlet) droppedCount() uint64 {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.dropped
+}
+
+// unsubscribe removes o from its Bus and closes it.
+func (o *outlet) unsubscribe() {
+	o.bus.mu.Lock()
+	delete(o.bus.subs, o)
+	o.bus.mu.Unlock()
+	o.close()
+}
+
+func (o *outlet) close() {
+	o.mu.Lock()
+	if o.closed {
+		o.mu.Unlock()
+		return
+	}
+	o.closed = true
+	close(o.done)
+	o.mu.Unlock()
+	subscribers.add(-1)
+	o.sending.Wait() // no more sends on o.c
+	o.c.close()
+}
+
+// send delivers v according to the outlet's policy.
+func (o *outlet) send(v interface{}) {
+	o.mu.Lock()
+	if o.closed {
+		o.mu.Unlock()
+		return
+	}
+	if o.c.trySend(v) {
+		o.mu.Unlock()
+		return
+	}
+
+	switch o.policy {
+	case DropOldest, Coalesce:
+		if old, ok := o.c.tryReceive(); ok {
+			prev, ok1 := old.(Frame)
+			next, ok2 := v.(Frame)
+			if ok1 && ok2 && o.policy == Coalesce {
+				v = coalesce(prev, next)
+			}
+			o.dropped++
+		}
+		if !o.c.trySend(v) {
+			o.dropped++
+		}
+	case DropNewest:
+		o.dropped++
+	case Block:
+		o.sending.Add(1)
+		o.mu.Unlock()
+		o.c.send(v, o.done)
+		o.sending.Done()
+		return
+	}
+	o.mu.Unlock()
+}
+
+// Bus returns kb's Bus, on which it publishes each Frame of key changes
+// applied (see Subscribe), a KeyEvent for each key event read, a LockEvent
+// for each change of a lock, DeviceEvents if its Backend is a
+// DeviceReporter, the values of a BusPublisher Backend, and an ErrorEvent if
+// reading fails. The Bus is closed when kb's read loop ends; if kb is
+// started again, it gets a new Bus.
+func (kb *Keyboard) Bus() *Bus {
+	kb.mu.Lock()
+	defer kb.mu.Unlock()
+	return kb.bus
+}
+
+// startBus gives kb a new Bus if the last one was closed, and passes it to
+// the Backend if it is a DeviceReporter or BusPublisher.
+func (kb *Keyboard) startBus() {
+	kb.mu.Lock()
+	kb.bus.mu.Lock()
+	if kb.bus.closed {
+		kb.bus.mu.Unlock()
+		kb.bus = NewBus()
+	} else {
+		kb.bus.mu.Unlock()
+	}
+	b := kb.bus
+	kb.mu.Unlock()
+	if dr, ok := kb.backend.(DeviceReporter); ok {
+		dr.ReportDevices(b)
+	}
+	if bp, ok := kb.backend.(BusPublisher); ok {
+		bp.PublishOn(b)
+	}
+}
+
+// publishBus publishes the events of frame, taking those that changed key
+// state from changes, which carry their Seq, and then locks, on kb's Bus.
+func (kb *Keyboard) publishBus(frame, changes Frame, locks []LockEvent) {
+	b := kb.Bus()
+	for _, event := range frame {
+		if event.Value != Repeat {
+			event, changes = changes[0], changes[1:]
+		}
+		b.Publish(KeyEvent{event})
+	}
+	for _, l := range locks {
+		b.Publish(l)
+	}
+}
@@ -0,0 +1,63 @@
+//go:build go1.21
+
+// The module's go directive predates generics, so this file needs Go 1.21,
+// which lets a file's build constraint raise its language version.
+
+package kbd
+
+// typedChannel is the channel of SubscribeTo.
+type typedChannel[T any] chan T
+
+func (c typedChannel[T]) trySend(v interface{}) bool {
+	select { // non-blocking channel send
+	case c <- v.(T):
+		return true
+	default:
+		return false
+	}
+}
+
+func (c typedChannel[T]) tryReceive() (interface{}, bool) {
+	select { // non-blocking channel recieve to "drain" channel
+	case v := <-c:
+		return v, true
+	default:
+		return nil, false
+	}
+}
+
+func (c typedChannel[T]) send(v interface{}, done <-chan struct{}) {
+	select {
+	case c <- v.(T):
+	case <-done:
+	}
+}
+
+func (c typedChannel[T]) close() { close(c) }
+
+// SubscribeTo is like Bus.Subscribe for the single kind T, such as KeyEvent,
+// but delivers values on a channel of T. T must not be an interface type.
+// The channel is closed when b is closed, or after cancel is called.
+func SubscribeTo[T any](b *Bus, size int, policy Backpressure) (c <-chan T, cancel func()) {
+	if size < 1 {
+		size = 1
+	}
+	var kind T
+	typed := make(chan T, size)
+	o := b.subscribe(typedChannel[T](typed), policy, kind)
+	return typed, o.unsubscribe
+}
+
+// Handle calls f, from a goroutine of its own, with each value of kind T
+// published on b, until the returned BusSubscription is closed. T must not
+// be an interface type.
+func Handle[T any](b *Bus, size int, policy Backpressure, f func(T)) *BusSubscription {
+	var kind T
+	s := b.Subscribe(size, policy, kind)
+	go func() {
+		for v := range s.C {
+			f(v.(T))
+		}
+	}()
+	return s
+}
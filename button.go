@@ -0,0 +1,203 @@
+package kbd
+
+import (
+	"sync"
+	"time"
+)
+
+// ButtonAction is a gesture recognized by a Button.
+type ButtonAction int
+
+// Actions reported by a Button.
+const (
+	ButtonPress       ButtonAction = iota // a single short press
+	ButtonDoublePress                     // two short presses in quick succession
+	ButtonHold                            // pressed for at least HoldTime
+)
+
+func (a ButtonAction) String() string {
+	switch a {
+	case ButtonPress:
+		return "press"
+	case ButtonDoublePress:
+		return "double press"
+	case ButtonHold:
+		return "hold"
+	}
+	return "unknown"
+}
+
+// ButtonEvent is a gesture performed on one key of a Button.
+type ButtonEvent struct {
+	Key    KeyCode
+	Action ButtonAction
+}
+
+// Button is a simplified interface to devices with only one or a few keys,
+// such as foot pedals and presentation clickers. Rather than key states, it
+// reports gestures: presses, double presses, and holds. A Button reads its
+// device directly and doesn't need the terminal.
+type Button struct {
+	HoldTime   time.Duration // press duration for ButtonHold; 500ms if 0
+	DoubleTime time.Duration // maximum gap within ButtonDoublePress; 300ms if 0
+
+	mu     sync.Mutex
+	b      Backend
+	keys   map[KeyCode]bool
+	states map[KeyCode]*buttonState
+	events chan ButtonEvent
+	closed bool
+	err    error
+}
+
+type buttonState struct {
+	down   bool
+	held   bool
+	second bool // the current press is the second of a double press
+	hold   *time.Timer
+	double *time.Timer
+}
+
+// OpenButton opens the evdev device at path and begins reading gestures made
+// with keys. If no keys are given, all keys of the device are used.
+func OpenButton(path string, keys ...KeyCode) (*Button, error) {
+	b, err := openEvdev(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewButton(b, keys...), nil
+}
+
+// NewButton is like OpenButton, but reads events from b.
+func NewButton(b Backend, keys ...KeyCode) *Button {
+	btn := &Button{
+		b:      b,
+		keys:   map[KeyCode]bool{},
+		states: map[KeyCode]*buttonState{},
+		events: make(chan ButtonEvent, 16),
+	}
+	for _, key := range keys {
+		btn.keys[key] = true
+	}
+
+	go func() {
+		for {
+			event, err := b.ReadEvent()
+			if err != nil {
+				btn.mu.Lock()
+				btn.stop()
+				btn.err = err
+				close(btn.events)
+				btn.closed = true
+				btn.mu.Unlock()
+				return
+			}
+			if len(btn.keys) == 0 || btn.keys[event.Code] {
+				btn.handle(event)
+			}
+		}
+	}()
+	return btn
+}
+
+// Events returns the channel on which gestures are delivered. Gestures are
+// dropped if the channel's buffer is full. The channel is closed when the
+// Button is closed or reading fails.
+func (btn *Button) Events() <-chan ButtonEvent {
+	return btn.events
+}
+
+// Err reads the error that ended reading.
+func (btn *Button) Err() error {
+	btn.mu.Lock()
+	defer btn.mu.Unlock()
+	return btn.err
+}
+
+// Close closes the device.
+func (btn *Button) Close() error {
+	return btn.b.Close()
+}
+
+func (btn *Button) handle(event Event) {
+	btn.mu.Lock()
+	defer btn.mu.Unlock()
+
+	hold, double := btn.HoldTime, btn.DoubleTime
+	if hold <= 0 {
+		hold = 500 * time.Millisecond
+	}
+	if double <= 0 {
+		double = 300 * time.Millisecond
+	}
+
+	key := event.Code
+	s, ok := btn.states[key]
+	if !ok {
+		s = &buttonState{}
+		btn.states[key] = s
+	}
+
+	switch event.Value {
+	case Press:
+		if s.down {
+			return
+		}
+		s.down = true
+		s.held = false
+		if s.double != nil && s.double.Stop() {
+			s.second = true
+		}
+		s.hold = time.AfterFunc(hold, func() {
+			btn.mu.Lock()
+			defer btn.mu.Unlock()
+			if s.down && !s.held {
+				s.held = true
+				s.second = false
+				btn.send(ButtonEvent{key, ButtonHold})
+			}
+		})
+
+	case Release:
+		if !s.down {
+			return
+		}
+		s.down = false
+		s.hold.Stop()
+		switch {
+		case s.held:
+		case s.second:
+			s.second = false
+			btn.send(ButtonEvent{key, ButtonDoublePress})
+		default:
+			s.double = time.AfterFunc(double, func() {
+				btn.mu.Lock()
+				defer btn.mu.Unlock()
+				btn.send(ButtonEvent{key, ButtonPress})
+			})
+		}
+	}
+}
+
+// send delivers e without blocking. btn.mu must be held.
+func (btn *Button) send(e ButtonEvent) {
+	if btn.closed {
+		return
+	}
+	select {
+	case btn.events <- e:
+	default:
+	}
+}
+
+// stop cancels all pending timers. btn.mu must be held.
+func (btn *Button) stop() {
+	for _, s := range btn.states {
+		if s.hold != nil {
+			s.hold.Stop()
+		}
+		if s.double != nil {
+			s.double.Stop()
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package kbd
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrCaptureLimit is returned by Err when the Keyboard stopped because the
+// limit set with SetMaxCapture was reached.
+var ErrCaptureLimit = errors.New("kbd: maximum capture duration reached")
+
+// OnCapture sets f to be called with true when the Keyboard starts reading
+// key events, and with false when it stops, whether because of Stop, an
+// error, or the limit set with SetMaxCapture. Programs that record or stream
+// keystrokes can use it to show an indicator while keys are captured. f must
+// not block.
+func (kb *Keyboard) OnCapture(f func(capturing bool)) {
+	kb.mu.Lock()
+	defer kb.mu.Unlock()
+	kb.onCapture = f
+}
+
+// SetMaxCapture limits how long the Keyboard reads key events after Start.
+// When d has elapsed, the Keyboard stops as if Stop had been called, no
+// further events are delivered, and Err returns ErrCaptureLimit. A d of 0
+// (the default) sets no limit. It applies from the next call to Start.
+func (kb *Keyboard) SetMaxCapture(d time.Duration) {
+	kb.mu.Lock()
+	defer kb.mu.Unlock()
+	kb.maxCapture = d
+}
+
+// setCapturing records whether events are being captured, calling the
+// OnCapture function if that changed.
+func (kb *Keyboard) setCapturing(on bool) {
+	kb.mu.Lock()
+	changed := kb.capturing != on
+	kb.capturing = on
+	f := kb.onCapture
+	if kb.captureTimer != nil {
+		kb.captureTimer.Stop()
+		kb.captureTimer = nil
+	}
+	if on && kb.maxCapture > 0 {
+		kb.captureTimer = time.AfterFunc(kb.maxCapture, func() {
+			kb.mu.Lock()
+			kb.err = ErrCaptureLimit
+			kb.mu.Unlock()
+			kb.Stop()
+		})
+	}
+	kb.mu.Unlock()
+
+	if changed && f != nil {
+		f(on)
+	}
+}
@@ -0,0 +1,142 @@
+package kbd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ChordDict is a dictionary of chords, sets of keys pressed together, and
+// the text they stand for, as used in stenography.
+type ChordDict struct {
+	text map[string]string // by chordKey
+	keys map[KeyCode]bool  // every key in a chord
+}
+
+// NewChordDict creates a ChordDict from entries, which map chords to text.
+// A chord is written as key names joined by "+", in any order, with names
+// as for ParseCombo: "s+t+k" and "k+t+s" are the same chord.
+func NewChordDict(entries map[string]string) (*ChordDict, error) {
+	d := &ChordDict{text: map[string]string{}, keys: map[KeyCode]bool{}}
+	for chord, text := range entries {
+		var keys []KeyCode
+		for _, part := range strings.Split(chord, "+") {
+			name := strings.ToLower(strings.TrimSpace(part))
+			key, ok := lookupKey(name)
+			if !ok || name == "" {
+				return nil, fmt.Errorf("kbd: unknown key %q in chord %q", part, chord)
+			}
+			keys = append(keys, key)
+		}
+		d.Add(keys, text)
+	}
+	return d, nil
+}
+
+// Add adds the chord of keys, in any order, with text.
+func (d *ChordDict) Add(keys []KeyCode, text string) {
+	for _, key := range keys {
+		d.keys[key] = true
+	}
+	d.text[chordKey(keys)] = text
+}
+
+// Lookup returns the text of the chord of keys, in any order.
+func (d *ChordDict) Lookup(keys []KeyCode) (string, bool) {
+	text, ok := d.text[chordKey(keys)]
+	return text, ok
+}
+
+// chordKey returns the map key of the chord of keys.
+func chordKey(keys []KeyCode) string {
+	sorted := append([]KeyCode(nil), keys...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	var b strings.Builder
+	for i, key := range sorted {
+		if i > 0 && key == sorted[i-1] {
+			continue
+		}
+		fmt.Fprintf(&b, "%d,", key)
+	}
+	return b.String()
+}
+
+// ChordEvent is a chord read by a Chorder.
+type ChordEvent struct {
+	Time  time.Time // time the last key of the chord was released
+	Keys  []KeyCode // keys of the chord, in order of KeyCode
+	Text  string    // text of the chord in the dictionary
+	Found bool      // whether the chord is in the dictionary
+}
+
+// Chorder is a Backend that wraps another Backend and reads chords: the keys
+// pressed from when one key of the dictionary goes down until all are up
+// again, as a stenography machine does. Keys in the dictionary's chords are
+// removed from the event stream and delivered as ChordEvents on Chords();
+// other keys are passed through unchanged. Chords work best on NKRO
+// keyboards, since other keyboards drop some keys pressed together.
+type Chorder struct {
+	b      Backend
+	dict   *ChordDict
+	chords chan ChordEvent
+	down   map[KeyCode]bool // chord keys held
+	chord  map[KeyCode]bool // keys of the chord being read
+}
+
+// NewChorder creates a Chorder reading from b and looking chords up in dict.
+func NewChorder(b Backend, dict *ChordDict) *Chorder {
+	return &Chorder{
+		b:      b,
+		dict:   dict,
+		chords: make(chan ChordEvent, 16),
+		down:   map[KeyCode]bool{},
+		chord:  map[KeyCode]bool{},
+	}
+}
+
+// Chords returns a channel on which ChordEvents are delivered, including
+// those of chords not in the dictionary. Chords are dropped if the channel's
+// buffer is full.
+func (c *Chorder) Chords() <-chan ChordEvent {
+	return c.chords
+}
+
+func (c *Chorder) ReadEvent() (Event, error) {
+	for {
+		event, err := c.b.ReadEvent()
+		if err != nil || !c.dict.keys[event.Code] {
+			return event, err
+		}
+
+		switch event.Value {
+		case Press:
+			c.down[event.Code] = true
+			c.chord[event.Code] = true
+		case Release:
+			delete(c.down, event.Code)
+			if len(c.down) == 0 && len(c.chord) > 0 {
+				c.send(event.Time)
+			}
+		}
+	}
+}
+
+// send delivers the chord read, and starts a new one.
+func (c *Chorder) send(t time.Time) {
+	keys := make([]KeyCode, 0, len(c.chord))
+	for key := range c.chord {
+		keys = append(keys, key)
+		delete(c.chord, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	text, found := c.dict.Lookup(keys)
+	select { // non-blocking channel send
+	case c.chords <- ChordEvent{Time: t, Keys: keys, Text: text, Found: found}:
+	default:
+	}
+}
+
+func (c *Chorder) Close() error {
+	return c.b.Close()
+}
@@ -0,0 +1,108 @@
+package kbd
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// ErrNoClipboard is returned by Paste when no clipboard tool is found.
+var ErrNoClipboard = errors.New("kbd: no clipboard tool found (wl-clipboard or xclip)")
+
+// pasteDelay is how long Paste waits for the focused application to read the
+// clipboard before restoring it.
+const pasteDelay = 300 * time.Millisecond
+
+// clipboard reads and writes the session's clipboard with external tools:
+// `wl-copy` and `wl-paste` on Wayland, `xclip` on X11.
+type clipboard struct {
+	get, set []string
+}
+
+func sessionClipboard() (clipboard, error) {
+	var c clipboard
+	switch {
+	case os.Getenv("WAYLAND_DISPLAY") != "":
+		c = clipboard{get: []string{"wl-paste", "--no-newline"}, set: []string{"wl-copy"}}
+	case os.Getenv("DISPLAY") != "":
+		c = clipboard{
+			get: []string{"xclip", "-selection", "clipboard", "-o"},
+			set: []string{"xclip", "-selection", "clipboard"},
+		}
+	default:
+		return c, ErrNoClipboard
+	}
+	if _, err := exec.LookPath(c.set[0]); err != nil {
+		return c, ErrNoClipboard
+	}
+	return c, nil
+}
+
+func (c clipboard) read() (string, error) {
+	out, err := exec.Command(c.get[0], c.get[1:]...).Output()
+	return string(out), err
+}
+
+// write puts text on the clipboard. The tools stay in the background to
+// serve it until the clipboard is replaced.
+func (c clipboard) write(text string) error {
+	cmd := exec.Command(c.set[0], c.set[1:]...)
+	cmd.Stdin = strings.NewReader(text)
+	return cmd.Run()
+}
+
+// Paste enters text by placing it on the clipboard and pressing Ctrl+V,
+// which is much faster than typing long strings. The previous (text)
+// contents of the clipboard are restored afterwards. It needs `wl-copy` and
+// `wl-paste` on Wayland or `xclip` on X11, and the paste shortcut of most
+// terminals is Ctrl+Shift+V instead, for which use PasteWith.
+func (v *Virtual) Paste(text string) error {
+	return v.PasteWith(text, Combo{Mods: [4]KeyCode{modCtrl: KeyLEFTCTRL}, Key: KeyV})
+}
+
+// PasteWith is like Paste, but presses the paste shortcut c.
+func (v *Virtual) PasteWith(text string, c Combo) error {
+	clip, err := sessionClipboard()
+	if err != nil {
+		return err
+	}
+	old, readErr := clip.read()
+	if err := clip.write(text); err != nil {
+		return err
+	}
+
+	err = v.tapCombo(c)
+	if readErr == nil {
+		time.Sleep(pasteDelay) // let the application read the clipboard
+		if e := clip.write(old); err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// tapCombo presses the modifiers of c, taps its key, and releases the
+// modifiers. Key groups stand for their first member.
+func (v *Virtual) tapCombo(c Combo) error {
+	var held []KeyCode
+	var err error
+	for _, mod := range c.Mods {
+		if mod == 0 {
+			continue
+		}
+		mod = Members(mod)[0]
+		if err = v.Press(mod); err != nil {
+			break
+		}
+		held = append(held, mod)
+	}
+	if err == nil {
+		err = v.Tap(Members(c.Key)[0])
+	}
+	for i := len(held) - 1; i >= 0; i-- {
+		v.Release(held[i])
+	}
+	return err
+}
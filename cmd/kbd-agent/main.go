@@ -0,0 +1,70 @@
+// Command kbd-agent streams the key events of the host it runs on to its
+// stdout, in the wire format of kbd.EventWriter, so that the keys pressed on
+// a headless device can be watched from another machine over SSH:
+//
+//	ssh -T device kbd-agent | consumer
+//
+// or from Go, with kbd.OpenSSH("device") or the "ssh" Backend. It reads the
+// evdev devices given, or every device in `/dev/input/` it can open if none
+// are, and stops when its stdout is closed, such as when the SSH session
+// ends. Errors are written to stderr.
+//
+// With -grab, the devices are grabbed, so that the keys reach only the
+// consumer. With -redact, the keys are redacted (see kbd.Redact).
+//
+// Usage:
+//
+//	kbd-agent [-grab] [-redact] [DEVICE...]
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/quillaja/kbd"
+)
+
+func main() {
+	grab := flag.Bool("grab", false, "grab the devices")
+	redact := flag.Bool("redact", false, "redact the keys")
+	flag.Parse()
+	if err := run(flag.Args(), *grab, *redact); err != nil {
+		fmt.Fprintln(os.Stderr, "kbd-agent:", err)
+		os.Exit(1)
+	}
+}
+
+func run(paths []string, grab, redact bool) error {
+	m, err := kbd.NewMultiplexer()
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+	if len(paths) == 0 {
+		all, _ := filepath.Glob("/dev/input/event*")
+		for _, path := range all {
+			m.Add(path) // devices that can't be opened are skipped
+		}
+		if len(m.Devices()) == 0 {
+			return kbd.ErrNoDevices
+		}
+	}
+	for _, path := range paths {
+		if err := m.Add(path); err != nil {
+			return err
+		}
+	}
+	if grab {
+		if err := m.Grab(true); err != nil {
+			return err
+		}
+	}
+
+	var b kbd.Backend = m
+	if redact {
+		b = kbd.Redacted(m)
+	}
+	return kbd.RunAgent(b, os.Stdout)
+}
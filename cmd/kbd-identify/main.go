@@ -0,0 +1,159 @@
+// Command kbd-identify reports the keys pressed on the keyboards of the
+// system: their code and name, as used in kbd configs, their scancode and
+// the device that sent them, to help write configs for keyboards with
+// unusual keys. It asks for one key at a time until interrupted, or until
+// -n keys have been identified.
+//
+// Keys with the code 0 have a scancode but no code; they can be given one
+// with a kbd.ScancodeMapper, such as with `setkeycodes` or a hwdb entry.
+//
+// With -label, the device of the first key pressed is given the label, so
+// that configs can refer to it as "label:NAME" however the kernel numbers
+// it (see kbd.Labels). The labels file needs to be writable for that.
+//
+// With -udev, kbd-identify prints a udev rule for the device of the first
+// key pressed instead, giving -group access to it and the stable symlink
+// `/dev/input/NAME`, and with -uinput, access to `/dev/uinput` as well (see
+// kbd.GenerateUdevRule). With -install, the rule is installed as
+// 70-kbd-NAME.rules, which needs root once; afterwards the members of the
+// group can use the device without sudo.
+//
+// With -rollover, kbd-identify tests the key rollover of the keyboard given,
+// or of the keyboard of the first key pressed: it asks for more and more
+// keys to be held at once, and reports how many the keyboard registers, and
+// the keys that jam or ghost (see kbd.RolloverTest). The keyboard is grabbed
+// during the test, so that held keys such as Ctrl+C do nothing.
+//
+// It needs read access to the devices in `/dev/input/`. The devices are not
+// grabbed, so the keys still do whatever they do.
+//
+// Usage:
+//
+//	kbd-identify [-n COUNT] [-timeout DURATION] [DEVICE...]
+//	kbd-identify -label NAME [-labels FILE] [DEVICE...]
+//	kbd-identify -udev NAME [-group GROUP] [-uinput] [-install] [DEVICE...]
+//	kbd-identify -rollover [DEVICE]
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+
+	"github.com/pkg/term"
+	"github.com/quillaja/kbd"
+)
+
+func main() {
+	count := flag.Int("n", 0, "identify `count` keys, or keys until interrupted if 0")
+	timeout := flag.Duration("timeout", 0, "give up after `duration` without a key press")
+	label := flag.String("label", "", "label the device of the key pressed as `name`, and exit")
+	flag.StringVar(&kbd.LabelsPath, "labels", kbd.LabelsPath, "device labels `file`")
+	udev := flag.String("udev", "", "print a udev rule for the device of the key pressed, linked as /dev/input/`name`, and exit")
+	group := flag.String("group", "input", "give `group` access to the device in the udev rule")
+	uinput := flag.Bool("uinput", false, "give the group access to /dev/uinput in the udev rule too")
+	install := flag.Bool("install", false, "install the udev rule instead of printing it")
+	rollover := flag.Bool("rollover", false, "test the key rollover of the keyboard, and exit")
+	flag.Parse()
+	if *label != "" || *udev != "" {
+		*count = 1
+	}
+	if *udev != "" { // check the names before a key is pressed
+		if _, err := kbd.GenerateUdevRule(kbd.DeviceInfo{}, *group, *udev); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+	labels, err := kbd.LoadLabels(kbd.LabelsPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	// keep the keys from being echoed, and restore the terminal on ^C
+	if tty, err := term.Open("/dev/tty"); err == nil {
+		term.CBreakMode(tty)
+		defer tty.Restore()
+		interrupt := make(chan os.Signal, 1)
+		signal.Notify(interrupt, os.Interrupt)
+		go func() {
+			<-interrupt
+			tty.Restore()
+			os.Exit(1)
+		}()
+	}
+
+	if *rollover {
+		if err := testRollover(flag.Args(), *timeout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+		return
+	}
+
+	for i := 0; *count == 0 || i < *count; i++ {
+		fmt.Println("Press a key...")
+		k, err := kbd.IdentifyKey(flag.Args(), *timeout)
+		if errors.Is(err, kbd.ErrIdentifyTimeout) {
+			fmt.Println("No key pressed.")
+			return
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		if *udev == "" {
+			report(k, labels)
+		}
+		if *label != "" {
+			labels.Set(*label, k.Device)
+			if err := labels.Save(kbd.LabelsPath); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			fmt.Printf("Labeled %s as %s.\n", k.Device.Path, *label)
+		}
+		if *udev != "" {
+			rules, err := kbd.GenerateUdevRule(k.Device, *group, *udev)
+			if err == nil && *uinput {
+				var rule string
+				rule, err = kbd.UinputUdevRule(*group)
+				rules += rule
+			}
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			if !*install {
+				fmt.Print(rules)
+				return
+			}
+			if err := kbd.InstallUdevRule("70-kbd-"+*udev+".rules", rules); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			fmt.Printf("Installed a udev rule for %s as /dev/input/%s.\n", k.Device.Path, *udev)
+		}
+	}
+}
+
+// report prints what is known about k.
+func report(k kbd.KeyInfo, labels *kbd.Labels) {
+	fmt.Printf("  code      %d (%#x)\n", k.Code, uint16(k.Code))
+	fmt.Printf("  name      %s\n", k.Name())
+	if k.HasScancode {
+		fmt.Printf("  scancode  %#x\n", uint32(k.Scancode))
+	} else {
+		fmt.Printf("  scancode  not reported\n")
+	}
+	fmt.Printf("  device    %s (%s", k.Device.Path, k.Device.Name)
+	if k.Device.Vendor != 0 || k.Device.Product != 0 {
+		fmt.Printf(", %04x:%04x", k.Device.Vendor, k.Device.Product)
+	}
+	fmt.Printf(")\n")
+	if label, ok := labels.Label(k.Device); ok {
+		fmt.Printf("  label     %s\n", label)
+	}
+	fmt.Println()
+}
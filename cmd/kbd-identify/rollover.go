@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/quillaja/kbd"
+)
+
+// testRollover runs a kbd.RolloverTest on the keyboard at paths[0], or on
+// the keyboard of the first key pressed if paths is empty, and prints the
+// report. Each key is waited for up to timeout, or 5 seconds if 0.
+func testRollover(paths []string, timeout time.Duration) error {
+	path := ""
+	if len(paths) > 0 {
+		path = paths[0]
+	} else {
+		fmt.Println("Press a key on the keyboard to test...")
+		k, err := kbd.IdentifyKey(nil, 0)
+		if err != nil {
+			return err
+		}
+		path = k.Device.Path
+		time.Sleep(500 * time.Millisecond) // for the key to be released
+	}
+	b, err := kbd.OpenDevice("evdev", path)
+	if err != nil {
+		return err
+	}
+	kb := kbd.NewHeadless(b)
+	defer kb.Close()
+	if err := kb.Grab(true); err != nil {
+		return err
+	}
+	defer kb.Grab(false)
+	if err := kb.Start(); err != nil {
+		return err
+	}
+
+	fmt.Printf("Testing %s. Hold each key asked for until the end of its row;\n", path)
+	fmt.Printf("if a key seems to do nothing, keep holding it until the test moves on.\n\n")
+	r, err := kb.TestRollover(kbd.RolloverTest{
+		Timeout: timeout,
+		Prompt: func(held []kbd.KeyCode, next kbd.KeyCode) {
+			if len(held) == 0 {
+				fmt.Printf("Press %s", keyName(next))
+			} else {
+				fmt.Printf(", %s", keyName(next))
+			}
+		},
+		Release: func() {
+			fmt.Printf("\nRelease all keys.\n")
+		},
+	})
+	fmt.Println()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("\nRollover: %d keys", r.Rollover)
+	if r.NKRO {
+		fmt.Printf(" (no key jammed; likely NKRO)")
+	}
+	fmt.Printf(", at most %d held\n", r.Max)
+	for _, jam := range r.Jams {
+		fmt.Printf("  jammed: %s with %s held\n", keyName(jam.Key), keyNames(jam.Held))
+	}
+	for _, g := range r.Ghosts {
+		fmt.Printf("  ghosted: %s with %s held\n", keyNames(g.Ghost), keyNames(g.Held))
+	}
+	return nil
+}
+
+func keyName(key kbd.KeyCode) string {
+	return strings.ToLower(key.String())
+}
+
+func keyNames(keys []kbd.KeyCode) string {
+	if len(keys) == 0 {
+		return "nothing"
+	}
+	names := make([]string, len(keys))
+	for i, key := range keys {
+		names[i] = keyName(key)
+	}
+	return strings.Join(names, "+")
+}
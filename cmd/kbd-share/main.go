@@ -0,0 +1,150 @@
+// Command kbd-share shares the keyboard of one machine with another, like a
+// software KVM switch (see kbd.Share). On the machine whose keyboard is
+// shared, it reads the evdev devices given, or every device in
+// `/dev/input/` it can open if none are, and pressing the toggle key (Scroll
+// Lock by default) switches between typing there and typing on the remote
+// machine:
+//
+//	kbd-share -send HOST:PORT [-toggle KEY] [DEVICE...]
+//
+// While the remote machine is the target, the devices are grabbed, and the
+// keys are sent to the kbd-share receiving them there, which types them on a
+// virtual keyboard created with uinput:
+//
+//	kbd-share -receive ADDRESS
+//
+// The keys are sent unencrypted and unauthenticated, so the receiver should
+// listen on localhost only, with the sender connecting through an SSH tunnel
+// (ssh -L PORT:localhost:PORT remote). Keys held when the connection ends are
+// released. Errors are written to stderr.
+//
+// Usage:
+//
+//	kbd-share -send HOST:PORT [-toggle KEY] [DEVICE...]
+//	kbd-share -receive ADDRESS
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+
+	"github.com/quillaja/kbd"
+)
+
+func main() {
+	send := flag.String("send", "", "send the keys to the kbd-share receiving at `address`")
+	receive := flag.String("receive", "", "type the keys received at `address`")
+	toggle := flag.String("toggle", "scrolllock", "switch targets with `key`")
+	flag.Parse()
+
+	var err error
+	switch {
+	case (*send == "") == (*receive == ""):
+		err = errors.New("one of -send or -receive is needed")
+	case *send != "":
+		err = runSend(*send, *toggle, flag.Args())
+	default:
+		err = runReceive(*receive)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "kbd-share:", err)
+		os.Exit(1)
+	}
+}
+
+// runSend shares the keyboards at paths with the receiver at addr until
+// reading them or sending to it fails.
+func runSend(addr, toggle string, paths []string) error {
+	c, err := kbd.ParseCombo(toggle)
+	if err != nil {
+		return err
+	}
+	m, err := kbd.NewMultiplexer()
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+	if len(paths) == 0 {
+		all, _ := filepath.Glob("/dev/input/event*")
+		for _, path := range all {
+			m.Add(path) // devices that can't be opened are skipped
+		}
+		if len(m.Devices()) == 0 {
+			return kbd.ErrNoDevices
+		}
+	}
+	for _, path := range paths {
+		if err := m.Add(path); err != nil {
+			return err
+		}
+	}
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	s := kbd.NewShare(m, c.Key, kbd.NewEventWriter(conn))
+	defer m.Grab(false)
+	for {
+		// The keys typed locally reach the system without kbd-share.
+		if _, err := s.ReadEvent(); err != nil {
+			return err
+		}
+	}
+}
+
+// runReceive types the keys of each sender connecting to addr, one at a
+// time.
+func runReceive(addr string) error {
+	v, err := kbd.NewVirtual("kbd-share")
+	if err != nil {
+		return err
+	}
+	defer v.Close()
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	defer ln.Close()
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return err
+		}
+		h := &held{b: kbd.NewEventReader(conn), down: map[kbd.KeyCode]bool{}}
+		err = v.Inject(h)
+		for key := range h.down {
+			v.Release(key)
+		}
+		conn.Close()
+		fmt.Fprintf(os.Stderr, "kbd-share: %s: %v\n", conn.RemoteAddr(), err)
+	}
+}
+
+// held is a Backend that notes the keys held by the events read from b, so
+// that they can be released when the connection ends.
+type held struct {
+	b    kbd.Backend
+	down map[kbd.KeyCode]bool
+}
+
+func (h *held) ReadEvent() (kbd.Event, error) {
+	event, err := h.b.ReadEvent()
+	if err == nil {
+		if event.Value == kbd.Release {
+			delete(h.down, event.Code)
+		} else {
+			h.down[event.Code] = true
+		}
+	}
+	return event, err
+}
+
+func (h *held) Close() error {
+	return h.b.Close()
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/quillaja/kbd"
+	"golang.org/x/sys/unix"
+)
+
+// A broker shares key events with other programs over a unix socket, so they
+// needn't open the devices themselves. A client connects, sends
+//
+//	subscribe KEYS...
+//
+// with keys named as in a policy, and reads "ok" or "error: MESSAGE". After
+// "ok", the client receives its keys as a stream in the wire format of
+// kbd.EventReader. Keys are only sent if the policy allows them for the
+// client, identified by the uid and gid of its process, which the kernel
+// reports (SO_PEERCRED) and the client can't forge. The socket is created
+// with mode 0660, so only its owner and group may connect at all. Events are
+// dropped for clients that don't keep up.
+type broker struct {
+	mu      sync.Mutex
+	policy  *policy
+	clients map[*brokerClient]bool
+	dropped int // events not sent to slow clients
+}
+
+type brokerClient struct {
+	client
+	keys   policyRule // the keys subscribed to
+	events chan kbd.Event
+}
+
+func newBroker() *broker {
+	return &broker{policy: &policy{}, clients: map[*brokerClient]bool{}}
+}
+
+// setPolicy replaces the policy, which applies at once to all clients.
+func (b *broker) setPolicy(p *policy) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.policy = p
+}
+
+// stats returns the number of clients, and of events dropped for them.
+func (b *broker) stats() (clients, dropped int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.clients), b.dropped
+}
+
+// publish sends event to the clients that subscribed to and may receive it.
+func (b *broker) publish(event kbd.Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for c := range b.clients {
+		if !c.keys.hasKey(event.Code) || !b.policy.allows(c.client, event.Code) {
+			continue
+		}
+		select { // non-blocking channel send
+		case c.events <- event:
+		default:
+			b.dropped++
+		}
+	}
+}
+
+// serve accepts clients on ln.
+func (b *broker) serve(ln net.Listener) {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			logf(prioErr, "broker socket: %v", err)
+			return
+		}
+		go b.handle(conn.(*net.UnixConn))
+	}
+}
+
+// handle serves a client connected on conn.
+func (b *broker) handle(conn *net.UnixConn) {
+	defer conn.Close()
+	id, err := peer(conn)
+	if err != nil {
+		logf(prioWarning, "broker client: %v", err)
+		return
+	}
+
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		return
+	}
+	fields := strings.Fields(line)
+	if len(fields) < 2 || fields[0] != "subscribe" {
+		fmt.Fprintln(conn, "error: expected subscribe KEYS...")
+		return
+	}
+	keys, err := parseRule(append([]string{"allow", "any"}, fields[1:]...))
+	if err != nil {
+		fmt.Fprintln(conn, "error:", err)
+		return
+	}
+	fmt.Fprintln(conn, "ok")
+
+	c := &brokerClient{client: id, keys: keys, events: make(chan kbd.Event, 64)}
+	b.mu.Lock()
+	b.clients[c] = true
+	b.mu.Unlock()
+
+	go func() { // notice the client hanging up, or conn being closed
+		buf := make([]byte, 64)
+		for {
+			if _, err := conn.Read(buf); err != nil {
+				b.mu.Lock()
+				delete(b.clients, c)
+				close(c.events) // no more publishing to c
+				b.mu.Unlock()
+				return
+			}
+		}
+	}()
+	w := kbd.NewEventWriter(conn)
+	for event := range c.events {
+		if err := w.Write(event); err != nil {
+			return
+		}
+	}
+}
+
+// listenBroker creates the broker's socket at path, readable and writable by
+// its owner and group only. The umask is set while it is created, so that it
+// is never more open than that.
+func listenBroker(path string) (net.Listener, error) {
+	os.Remove(path) // left over from a previous run
+	old := unix.Umask(0117)
+	ln, err := net.Listen("unix", path)
+	unix.Umask(old)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, 0660); err != nil {
+		ln.Close()
+		return nil, err
+	}
+	return ln, nil
+}
+
+// peer returns the identity of the process connected on conn.
+func peer(conn *net.UnixConn) (client, error) {
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return client{}, err
+	}
+	var cred *unix.Ucred
+	raw.Control(func(fd uintptr) {
+		cred, err = unix.GetsockoptUcred(int(fd), unix.SOL_SOCKET, unix.SO_PEERCRED)
+	})
+	if err != nil {
+		return client{}, err
+	}
+	return client{uid: int(cred.Uid), gid: int(cred.Gid), groups: peerGroups(int(cred.Pid))}, nil
+}
+
+// peerGroups returns the supplementary groups of the process pid, read from
+// the Groups line of /proc/PID/status, or none if it can't be read.
+func peerGroups(pid int) []int {
+	b, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/status", pid))
+	if err != nil {
+		return nil
+	}
+	var groups []int
+	for _, line := range strings.Split(string(b), "\n") {
+		if !strings.HasPrefix(line, "Groups:") {
+			continue
+		}
+		for _, f := range strings.Fields(line[len("Groups:"):]) {
+			if gid, err := strconv.Atoi(f); err == nil {
+				groups = append(groups, gid)
+			}
+		}
+	}
+	return groups
+}
+
+// tap is a Backend that publishes the events read from another Backend on
+// the daemon's broker, and records them for the HTTP API.
+type tap struct {
+	kbd.Backend
+	d *daemon
+}
+
+func (t *tap) ReadEvent() (kbd.Event, error) {
+	event, err := t.Backend.ReadEvent()
+	if err == nil {
+		t.d.broker.publish(event)
+		t.d.record(event)
+	}
+	return event, err
+}
+
+// Check checks the tapped Backend, if it is a kbd.HealthChecker.
+func (t *tap) Check() error {
+	if hc, ok := t.Backend.(kbd.HealthChecker); ok {
+		return hc.Check()
+	}
+	return nil
+}
+
+// SetReadDeadline sets the read deadline of the tapped Backend, if it is a
+// kbd.Deadliner.
+func (t *tap) SetReadDeadline(deadline time.Time) error {
+	if d, ok := t.Backend.(kbd.Deadliner); ok {
+		return d.SetReadDeadline(deadline)
+	}
+	return kbd.ErrNoDeadline
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/quillaja/kbd"
+)
+
+// check reports problems in cfg that kbd.LoadConfig accepts, as they are not
+// errors, but make rules do nothing or more than intended:
+//
+//   - bindings that are shadowed by the toggle combo, or that no key can
+//     trigger since their keys are remapped to others;
+//   - bindings that overlap, so that one combo runs several actions;
+//   - profiles that no binding switches to, other than the startup one.
+//
+// Bindings are matched as at runtime, with kbd.Hotkeys. It writes one line
+// per problem to w, and returns how many there were.
+func check(cfg *kbd.Config, w io.Writer) int {
+	var problems []string
+	report := func(pos kbd.ConfigPos, format string, args ...interface{}) {
+		problems = append(problems, pos.String()+": "+fmt.Sprintf(format, args...))
+	}
+
+	switchedTo := map[string]bool{cfg.Profile: true}
+	for _, p := range cfg.Profiles {
+		for _, spec := range p.Bindings {
+			if spec.Action[0] == "profile" {
+				switchedTo[spec.Action[1]] = true
+			}
+		}
+	}
+
+	for _, name := range sortedProfiles(cfg.Profiles) {
+		p := cfg.Profiles[name]
+		if !switchedTo[name] {
+			pos := kbd.ConfigPos{File: cfg.Files[0]}
+			if len(p.Bindings) > 0 {
+				pos = p.Bindings[0].Pos
+			}
+			report(pos, "profile %s is only reachable with control commands", name)
+		}
+
+		h, index := profileHotkeys(p)
+		for i, spec := range p.Bindings {
+			if spec.Combo == cfg.Toggle {
+				report(spec.Pos, "bind %s is shadowed by the toggle combo", spec.Combo)
+				continue
+			}
+			if key, ok := unreachable(p, spec.Combo); ok {
+				report(spec.Pos, "bind %s can't be pressed: %s is remapped, and no key is mapped to it",
+					spec.Combo, strings.ToLower(key.String()))
+				continue
+			}
+			state, key := comboKeys(spec.Combo)
+			for _, b := range h.Match(state, key) {
+				if j := index[b]; j < i { // each pair once
+					other := p.Bindings[j]
+					report(spec.Pos, "bind %s overlaps bind %s at %s: both run", spec.Combo, other.Combo, other.Pos)
+				}
+			}
+		}
+	}
+
+	for _, msg := range problems {
+		fmt.Fprintln(w, msg)
+	}
+	return len(problems)
+}
+
+// explain writes to w what pressing each of combos in turn, on the keyboards
+// read by kbdbind, does with the startup profile of cfg: the keys the
+// profile's remaps turn it into, and the bindings it triggers. Dual keys
+// count as held when used as modifiers, and as tapped otherwise.
+func explain(cfg *kbd.Config, combos []kbd.Combo, w io.Writer) {
+	p := startupProfile(cfg)
+	h, index := profileHotkeys(p)
+	for _, c := range combos {
+		fmt.Fprintf(w, "%s:\n", c)
+		if c == cfg.Toggle {
+			fmt.Fprintf(w, "\ttoggles all bindings and remaps; the key is not passed on\n")
+			continue
+		}
+
+		physical, key := comboKeys(c)
+		var names []string
+		var keys []kbd.KeyCode
+		changed := false
+		for _, k := range append(physical.Pressed(), key) {
+			to := remapped(p, k, k != key)
+			changed = changed || to != k
+			keys = append(keys, to)
+			names = append(names, strings.ToLower(to.String()))
+		}
+		if changed {
+			fmt.Fprintf(w, "\tis remapped to %s\n", strings.Join(names, "+"))
+		}
+
+		key = keys[len(keys)-1]
+		matched := h.Match(kbd.NewState(keys[:len(keys)-1]...), key)
+		if len(matched) == 0 {
+			fmt.Fprintf(w, "\ttriggers no binding of profile %s\n", cfg.Profile)
+		}
+		for _, b := range matched {
+			spec := p.Bindings[index[b]]
+			fmt.Fprintf(w, "\truns %s (%s: bind %s)\n", strings.Join(spec.Action, " "), spec.Pos, spec.Combo)
+		}
+	}
+}
+
+// profileHotkeys returns Hotkeys with the bindings of p, with Actions that
+// do nothing, and the index of each binding in p.Bindings.
+func profileHotkeys(p *kbd.Profile) (*kbd.Hotkeys, map[*kbd.Binding]int) {
+	h := kbd.NewHotkeys()
+	index := map[*kbd.Binding]int{}
+	for i, spec := range p.Bindings {
+		index[h.Bind(spec.Combo, func() error { return nil })] = i
+	}
+	return h, index
+}
+
+// comboKeys returns the keys that press c: its modifiers, held, and its key.
+// Groups, such as "ctrl", are pressed with their first member.
+func comboKeys(c kbd.Combo) (kbd.State, kbd.KeyCode) {
+	var mods []kbd.KeyCode
+	for _, mod := range c.Mods {
+		if mod != 0 {
+			mods = append(mods, kbd.Members(mod)[0])
+		}
+	}
+	return kbd.NewState(mods...), kbd.Members(c.Key)[0]
+}
+
+// remapped returns the key that p turns a press of key into, held as a
+// modifier if held.
+func remapped(p *kbd.Profile, key kbd.KeyCode, held bool) kbd.KeyCode {
+	if d, ok := p.Duals[key]; ok {
+		if held {
+			return d.Hold
+		}
+		return d.Tap
+	}
+	if to, ok := p.Remaps[key]; ok {
+		return to
+	}
+	return key
+}
+
+// unreachable returns a key of c that no key is remapped to, if c has one.
+func unreachable(p *kbd.Profile, c kbd.Combo) (kbd.KeyCode, bool) {
+	keys := append([]kbd.KeyCode{c.Key}, c.Mods[:]...)
+	for _, want := range keys {
+		if want == 0 {
+			continue
+		}
+		reachable := false
+		for _, k := range kbd.Members(want) {
+			_, dual := p.Duals[k]
+			_, remap := p.Remaps[k]
+			reachable = reachable || (!dual && !remap)
+		}
+		for _, to := range p.Remaps {
+			reachable = reachable || kbd.Matches(want, to)
+		}
+		for _, d := range p.Duals {
+			reachable = reachable || kbd.Matches(want, d.Tap) || kbd.Matches(want, d.Hold)
+		}
+		if !reachable {
+			return want, true
+		}
+	}
+	return 0, false
+}
+
+func sortedProfiles(profiles map[string]*kbd.Profile) []string {
+	names := make([]string, 0, len(profiles))
+	for name := range profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
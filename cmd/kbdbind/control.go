@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// controlListener returns the socket for control commands: the socket passed
+// by systemd if any, otherwise a unix socket at path if path is not empty,
+// readable and writable by its owner only, as kbdbind.socket has it. The
+// umask is set while it is created, as for the broker's socket.
+func controlListener(path string) (net.Listener, error) {
+	ln, err := activatedListener()
+	if ln != nil || err != nil || path == "" {
+		return ln, err
+	}
+	os.Remove(path) // left over from a previous run
+	old := unix.Umask(0177)
+	ln, err = net.Listen("unix", path)
+	unix.Umask(old)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		ln.Close()
+		return nil, err
+	}
+	return ln, nil
+}
+
+// serveControl accepts connections on ln and runs the commands sent on them.
+func (d *daemon) serveControl(ln net.Listener) {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			logf(prioErr, "control socket: %v", err)
+			return
+		}
+		go d.control(conn)
+	}
+}
+
+// control runs the commands read from conn, writing a reply for each.
+func (d *daemon) control(conn net.Conn) {
+	defer conn.Close()
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		fmt.Fprintln(conn, d.command(fields[0], fields[1:]))
+	}
+}
+
+// command runs a control command and returns the reply.
+func (d *daemon) command(name string, args []string) string {
+	switch {
+	case name == "reload" && len(args) == 0:
+		d.requestReload()
+		return "ok"
+
+	case name == "profile" && len(args) == 1:
+		d.mu.Lock()
+		ok := d.cfg.Profiles[args[0]] != nil
+		d.mu.Unlock()
+		if !ok {
+			return "error: no profile " + args[0]
+		}
+		d.setProfile(args[0])
+		return "ok"
+
+	case name == "status" && len(args) == 0:
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		if d.resume != nil {
+			return "profile " + d.profile + " disabled"
+		}
+		return "profile " + d.profile
+
+	case name == "app" && len(args) <= 1:
+		d.setApp(strings.Join(args, ""))
+		return "ok"
+
+	case (name == "enable" || name == "disable") && len(args) == 0:
+		d.toggle.SetEnabled(name == "enable")
+		return "ok"
+	}
+	return "error: unknown command " + strings.Join(append([]string{name}, args...), " ")
+}
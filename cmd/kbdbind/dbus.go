@@ -0,0 +1,254 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+	"github.com/quillaja/kbd"
+)
+
+// The D-Bus service, object and interface.
+const (
+	dbusName  = "org.quillaja.kbd"
+	dbusPath  = "/org/quillaja/kbd"
+	dbusIface = "org.quillaja.kbd"
+)
+
+// dbusIntrospection describes the org.quillaja.kbd interface. Keys and
+// combos are named as for kbd.ParseCombo.
+//
+// Bindings added with AddBinding run no action; they only send the Hotkey
+// signal, to the client that added them alone. They stay bound until removed
+// by that client or until kbdbind exits, across profile changes and reloads.
+// On the session bus, the bindings of the config emit the Hotkey signal too,
+// to every client; on the system bus they don't, since every client of the
+// bus would receive it.
+//
+// On the system bus, callers of IsDown, Pressed and Bindings must be
+// authorized by polkit for org.quillaja.kbd.read, callers of RemoveBinding
+// for org.quillaja.kbd.bind, and callers of AddBinding for both, since a
+// binding of a single key reads that key.
+//
+// The Enabled signal is emitted when all bindings and remaps are disabled
+// or enabled again, by the toggle combo or a control command.
+const dbusIntrospection = `
+<interface name="org.quillaja.kbd">
+	<method name="IsDown">
+		<arg name="key" type="s" direction="in"/>
+		<arg name="down" type="b" direction="out"/>
+	</method>
+	<method name="Pressed">
+		<arg name="keys" type="as" direction="out"/>
+	</method>
+	<method name="AddBinding">
+		<arg name="combo" type="s" direction="in"/>
+		<arg name="id" type="u" direction="out"/>
+	</method>
+	<method name="RemoveBinding">
+		<arg name="id" type="u" direction="in"/>
+	</method>
+	<method name="Bindings">
+		<arg name="bindings" type="a(us)" direction="out"/>
+	</method>
+	<signal name="Hotkey">
+		<arg name="combo" type="s"/>
+	</signal>
+	<signal name="Enabled">
+		<arg name="enabled" type="b"/>
+	</signal>
+</interface>`
+
+// dbusService is the object exported on D-Bus. Its exported methods are the
+// methods of the interface.
+type dbusService struct {
+	d      *daemon
+	conn   *dbus.Conn
+	system bool // on the system bus, where callers are checked with polkit
+
+	mu       sync.Mutex
+	next     uint32
+	bindings map[uint32]clientBinding
+}
+
+// clientBinding is a binding added with AddBinding.
+type clientBinding struct {
+	b      *kbd.Binding
+	sender dbus.Sender // the client that added it, and receives its signal
+}
+
+// dbusBinding is a binding as returned by Bindings.
+type dbusBinding struct {
+	ID    uint32
+	Combo string
+}
+
+// serveDBus connects to bus, "system" or "session", and provides the
+// org.quillaja.kbd service.
+func (d *daemon) serveDBus(bus string) error {
+	var conn *dbus.Conn
+	var err error
+	switch bus {
+	case "system":
+		conn, err = dbus.ConnectSystemBus()
+	case "session":
+		conn, err = dbus.ConnectSessionBus()
+	default:
+		return fmt.Errorf("unknown bus %q", bus)
+	}
+	if err != nil {
+		return err
+	}
+
+	s := &dbusService{
+		d:        d,
+		conn:     conn,
+		system:   bus == "system",
+		bindings: map[uint32]clientBinding{},
+	}
+	err = conn.Export(s, dbusPath, dbusIface)
+	if err == nil {
+		node := "<node>" + dbusIntrospection + introspect.IntrospectDataString + "</node>"
+		err = conn.Export(introspect.Introspectable(node), dbusPath,
+			"org.freedesktop.DBus.Introspectable")
+	}
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	reply, err := conn.RequestName(dbusName, dbus.NameFlagDoNotQueue)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	if reply != dbus.RequestNameReplyPrimaryOwner {
+		conn.Close()
+		return fmt.Errorf("D-Bus name %s is already taken", dbusName)
+	}
+	d.bus = s
+	return nil
+}
+
+// Polkit actions for callers on the system bus, from org.quillaja.kbd.policy.
+const (
+	polkitRead = "org.quillaja.kbd.read"
+	polkitBind = "org.quillaja.kbd.bind"
+)
+
+// authorize checks that the caller sender may perform the polkit action
+// actionID.
+func (s *dbusService) authorize(sender dbus.Sender, actionID string) *dbus.Error {
+	if !s.system {
+		return nil // the session bus only has the user's own programs
+	}
+	if err := kbd.AuthorizeBusName(actionID, string(sender)); err != nil {
+		return dbus.NewError("org.freedesktop.DBus.Error.AccessDenied", []interface{}{err.Error()})
+	}
+	return nil
+}
+
+// signal returns an Action that emits the Hotkey signal for c, if kbdbind is
+// on the session bus, and runs a.
+func (d *daemon) signal(c kbd.Combo, a kbd.Action) kbd.Action {
+	return func() error {
+		if d.bus != nil && !d.bus.system {
+			d.bus.conn.Emit(dbusPath, dbusIface+".Hotkey", c.String())
+		}
+		return a()
+	}
+}
+
+// hotkeyTo returns an Action that sends the Hotkey signal for c to sender
+// alone.
+func (s *dbusService) hotkeyTo(sender dbus.Sender, c kbd.Combo) kbd.Action {
+	return func() error {
+		msg := &dbus.Message{
+			Type: dbus.TypeSignal,
+			Headers: map[dbus.HeaderField]dbus.Variant{
+				dbus.FieldPath:        dbus.MakeVariant(dbus.ObjectPath(dbusPath)),
+				dbus.FieldInterface:   dbus.MakeVariant(dbusIface),
+				dbus.FieldMember:      dbus.MakeVariant("Hotkey"),
+				dbus.FieldDestination: dbus.MakeVariant(string(sender)),
+				dbus.FieldSignature:   dbus.MakeVariant(dbus.SignatureOf(c.String())),
+			},
+			Body: []interface{}{c.String()},
+		}
+		return s.conn.Send(msg, nil).Err
+	}
+}
+
+func (s *dbusService) IsDown(sender dbus.Sender, key string) (bool, *dbus.Error) {
+	if err := s.authorize(sender, polkitRead); err != nil {
+		return false, err
+	}
+	c, err := kbd.ParseCombo(key)
+	if err != nil {
+		return false, dbus.MakeFailedError(err)
+	}
+	return c.Down(s.d.kb), nil
+}
+
+func (s *dbusService) Pressed(sender dbus.Sender) ([]string, *dbus.Error) {
+	if err := s.authorize(sender, polkitRead); err != nil {
+		return nil, err
+	}
+	names := []string{}
+	for _, key := range s.d.kb.Pressed() {
+		names = append(names, key.String())
+	}
+	return names, nil
+}
+
+func (s *dbusService) AddBinding(sender dbus.Sender, combo string) (uint32, *dbus.Error) {
+	if err := s.authorize(sender, polkitBind); err != nil {
+		return 0, err
+	}
+	if err := s.authorize(sender, polkitRead); err != nil {
+		return 0, err
+	}
+	c, err := kbd.ParseCombo(combo)
+	if err != nil {
+		return 0, dbus.MakeFailedError(err)
+	}
+	b := s.d.hotkeys.Bind(c, s.hotkeyTo(sender, c))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.next++
+	s.bindings[s.next] = clientBinding{b: b, sender: sender}
+	return s.next, nil
+}
+
+func (s *dbusService) RemoveBinding(sender dbus.Sender, id uint32) *dbus.Error {
+	if err := s.authorize(sender, polkitBind); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cb, ok := s.bindings[id]
+	if !ok || cb.sender != sender {
+		return dbus.MakeFailedError(fmt.Errorf("no binding %d", id))
+	}
+	cb.b.Unbind()
+	delete(s.bindings, id)
+	return nil
+}
+
+func (s *dbusService) Bindings(sender dbus.Sender) ([]dbusBinding, *dbus.Error) {
+	if err := s.authorize(sender, polkitRead); err != nil {
+		return nil, err
+	}
+	return s.list(), nil
+}
+
+// list returns the bindings added by clients.
+func (s *dbusService) list() []dbusBinding {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := []dbusBinding{}
+	for id, cb := range s.bindings {
+		list = append(list, dbusBinding{ID: id, Combo: cb.b.Combo.String()})
+	}
+	return list
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/quillaja/kbd"
+)
+
+// startupProfile returns the profile of cfg that kbdbind starts with, which
+// is empty, as when activated, if cfg has none.
+func startupProfile(cfg *kbd.Config) *kbd.Profile {
+	if p := cfg.Profiles[cfg.Profile]; p != nil {
+		return p
+	}
+	return &kbd.Profile{} // no profile: nothing bound or remapped
+}
+
+// dryRun replays the events recorded at path (in the format of
+// kbd.EventWriter, or from stdin if path is "-") through the remaps and
+// bindings of cfg's startup profile, and the toggle combo, and writes what
+// kbdbind would do with them to w: the events it would emit, and the
+// actions it would run. No device is opened and no action is run. Events
+// are replayed with their recorded timing, as dual keys depend on it, so
+// this takes as long as the recording.
+func dryRun(cfg *kbd.Config, path string, w io.Writer) error {
+	in := os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		in = f
+	}
+	p := startupProfile(cfg)
+	out := &dryRunOutput{w: w, start: time.Now()}
+
+	hotkeys := kbd.NewHotkeys()
+	for _, spec := range p.Bindings {
+		msg := fmt.Sprintf("run %s (%s: bind %s)", strings.Join(spec.Action, " "), spec.Pos, spec.Combo)
+		hotkeys.Bind(spec.Combo, func() error {
+			out.printf("%s", msg)
+			return nil
+		})
+	}
+
+	var remap *kbd.Remapper
+	var resume func()
+	toggle := kbd.NewToggle(&paced{Backend: kbd.NewEventReader(in)}, cfg.Toggle, func(enabled bool) {
+		keys, duals := p.Remaps, p.Duals
+		if enabled {
+			out.printf("toggle: enabled")
+			resume()
+		} else {
+			out.printf("toggle: disabled")
+			resume = hotkeys.Suppress()
+			keys, duals = nil, nil
+		}
+		remap.SetKeys(keys)
+		remap.SetDual(duals, cfg.TappingTerm)
+	})
+	remap, err := kbd.NewRemapper(toggle, nil, p.Remaps)
+	if err != nil {
+		return err
+	}
+	remap.SetDual(p.Duals, cfg.TappingTerm)
+
+	kb := kbd.NewHeadless(&dryRunTap{Backend: remap, out: out})
+	if err := kb.Start(); err != nil {
+		return err
+	}
+	hotkeys.Run(kb)
+	if err := kb.Err(); err != io.EOF {
+		return err
+	}
+	return nil
+}
+
+// paced is a Backend returning the events of a recording with the same
+// intervals as when they were recorded, as if they were being typed.
+type paced struct {
+	kbd.Backend
+	first, start time.Time
+}
+
+func (p *paced) ReadEvent() (kbd.Event, error) {
+	event, err := p.Backend.ReadEvent()
+	if err != nil {
+		return event, err
+	}
+	if p.start.IsZero() {
+		p.first, p.start = event.Time, time.Now()
+	}
+	time.Sleep(time.Until(p.start.Add(event.Time.Sub(p.first))))
+	event.Time = time.Now()
+	return event, nil
+}
+
+// dryRunTap is a Backend printing the events that kbdbind would emit.
+type dryRunTap struct {
+	kbd.Backend
+	out *dryRunOutput
+}
+
+func (t *dryRunTap) ReadEvent() (kbd.Event, error) {
+	event, err := t.Backend.ReadEvent()
+	if err == nil {
+		t.out.printf("%s %s", event.Code, valueNames[event.Value])
+	}
+	return event, err
+}
+
+var valueNames = map[int32]string{kbd.Press: "press", kbd.Release: "release", kbd.Repeat: "repeat"}
+
+// dryRunOutput writes the lines of a dry run, with the time since it
+// started.
+type dryRunOutput struct {
+	mu    sync.Mutex
+	w     io.Writer
+	start time.Time
+}
+
+func (o *dryRunOutput) printf(format string, args ...interface{}) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	fmt.Fprintf(o.w, "%8.3fs  %s\n", time.Since(o.start).Seconds(), fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"mime"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/quillaja/kbd"
+)
+
+// recentEvents is how many recent events the HTTP API keeps.
+const recentEvents = 64
+
+// The HTTP API serves JSON:
+//
+//	GET  /state      the active profile, whether it is enabled, the profiles,
+//	                 pressed keys and devices
+//	GET  /bindings   the bindings of the active profile and of D-Bus clients,
+//	                 without the commands of exec actions, which may hold
+//	                 secrets
+//	GET  /events     recent key events, passed through kbd.Redact
+//	POST /profile    make the profile given by "name" in the JSON body, such
+//	                 as {"name": "work"}, active, or deactivate all bindings
+//	                 and remaps if it is empty
+//	GET  /metrics    metrics in the Prometheus text format
+//
+// Every request must carry the token in the file given by -http-token, as
+// "Authorization: Bearer TOKEN". Requests a web browser makes for a page
+// are refused even so: those with an Origin header, and those whose Host
+// is a name other than localhost, as sent after DNS rebinding.
+func (d *daemon) serveHTTP(ln net.Listener, token string) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/state", d.httpState)
+	mux.HandleFunc("/bindings", d.httpBindings)
+	mux.HandleFunc("/events", d.httpEvents)
+	mux.HandleFunc("/profile", d.httpProfile)
+	mux.Handle("/metrics", kbd.MetricsHandler())
+	if err := http.Serve(ln, httpAuth(mux, token)); err != nil {
+		logf(prioErr, "HTTP API: %v", err)
+	}
+}
+
+// httpToken returns the token in the file at path, creating the file with a
+// random token, readable by its owner only, if it doesn't exist.
+func httpToken(path string) (string, error) {
+	b, err := ioutil.ReadFile(path)
+	if err == nil {
+		token := strings.TrimSpace(string(b))
+		if token == "" {
+			return "", &os.PathError{Op: "read", Path: path, Err: os.ErrInvalid}
+		}
+		return token, nil
+	}
+	if !os.IsNotExist(err) {
+		return "", err
+	}
+	var raw [32]byte
+	if _, err := rand.Read(raw[:]); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(raw[:])
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return "", err
+	}
+	_, err = f.WriteString(token + "\n")
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return token, err
+}
+
+// httpAuth wraps h to refuse requests without token, and requests made by a
+// web browser for a page.
+func httpAuth(h http.Handler, token string) http.Handler {
+	want := []byte("Bearer " + token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Origin") != "" || !localHost(r.Host) {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		got := []byte(r.Header.Get("Authorization"))
+		if subtle.ConstantTimeCompare(got, want) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// localHost reports whether host, the Host of a request, is an IP address
+// or localhost, rather than a name that may resolve to anything.
+func localHost(host string) bool {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return host == "localhost" || net.ParseIP(strings.Trim(host, "[]")) != nil
+}
+
+// record keeps event, redacted, for the HTTP API.
+func (d *daemon) record(event kbd.Event) {
+	d.recentMu.Lock()
+	defer d.recentMu.Unlock()
+	if len(d.recent) == recentEvents {
+		d.recent = d.recent[1:]
+	}
+	d.recent = append(d.recent, kbd.Redact(event))
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func (d *daemon) httpState(w http.ResponseWriter, r *http.Request) {
+	var state struct {
+		Profile  string   `json:"profile"`
+		Enabled  bool     `json:"enabled"`
+		Profiles []string `json:"profiles"`
+		Pressed  []string `json:"pressed"`
+		Devices  []string `json:"devices"`
+	}
+	d.mu.Lock()
+	state.Profile = d.profile
+	state.Enabled = d.resume == nil
+	for name := range d.cfg.Profiles {
+		state.Profiles = append(state.Profiles, name)
+	}
+	d.mu.Unlock()
+	state.Pressed = []string{}
+	for _, key := range d.kb.Pressed() {
+		state.Pressed = append(state.Pressed, kbd.Redact(kbd.Event{Code: key}).Code.String())
+	}
+	state.Devices = d.mux.Devices()
+	writeJSON(w, state)
+}
+
+func (d *daemon) httpBindings(w http.ResponseWriter, r *http.Request) {
+	type binding struct {
+		Combo  string `json:"combo"`
+		Action string `json:"action"`
+		Source string `json:"source"` // "profile" or "dbus"
+	}
+	list := []binding{}
+	d.mu.Lock()
+	if p := d.cfg.Profiles[d.profile]; p != nil {
+		for _, spec := range d.withApp(p).Bindings {
+			action := strings.Join(spec.Action, " ")
+			if spec.Action[0] == "exec" {
+				action = "exec"
+			}
+			list = append(list, binding{spec.Combo.String(), action, "profile"})
+		}
+	}
+	d.mu.Unlock()
+	if d.bus != nil {
+		for _, b := range d.bus.list() {
+			list = append(list, binding{b.Combo, "", "dbus"})
+		}
+	}
+	writeJSON(w, list)
+}
+
+func (d *daemon) httpEvents(w http.ResponseWriter, r *http.Request) {
+	type event struct {
+		Time  time.Time `json:"time"`
+		Key   string    `json:"key"`
+		Value int32     `json:"value"`
+	}
+	d.recentMu.Lock()
+	list := make([]event, len(d.recent))
+	for i, e := range d.recent {
+		list[i] = event{e.Time, e.Code.String(), e.Value}
+	}
+	d.recentMu.Unlock()
+	writeJSON(w, list)
+}
+
+func (d *daemon) httpProfile(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "use POST", http.StatusMethodNotAllowed)
+		return
+	}
+	if typ, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); typ != "application/json" {
+		http.Error(w, "send application/json", http.StatusUnsupportedMediaType)
+		return
+	}
+	var req struct {
+		Name string `json:"name"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	name := req.Name
+	d.mu.Lock()
+	ok := name == "" || d.cfg.Profiles[name] != nil
+	d.mu.Unlock()
+	if !ok {
+		http.Error(w, "no profile "+name, http.StatusNotFound)
+		return
+	}
+	d.setProfile(name)
+	writeJSON(w, map[string]string{"profile": name})
+}
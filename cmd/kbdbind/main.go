@@ -0,0 +1,534 @@
+// Command kbdbind is a daemon that runs actions when hotkeys are pressed and
+// remaps keys, as set in a config file (see kbd.Config for the format). It
+// reloads the config when it receives SIGHUP or when a config file changes.
+// It reads the configured devices exclusively and re-emits their keys,
+// remapped, on a virtual keyboard, so it must be able to open `/dev/uinput`.
+//
+// A device-profile directive remaps the keys of a device with another
+// profile than the active one; its bindings still come from the active
+// profile, and switching profiles leaves the device's remaps alone.
+//
+// The bindings and remaps of an [app] section are applied on top of the
+// active profile's while the application has focus. kbdbind can't tell which
+// application has focus, so it is told with the control command "app", such
+// as by a window manager hook.
+//
+// If -control is given, or systemd passes a socket (socket activation),
+// kbdbind accepts commands on it, one per line:
+//
+//	reload          reload the config
+//	profile NAME    make the profile NAME active
+//	status          print the active profile, and "disabled" if disabled
+//	enable          enable the bindings and remaps
+//	disable         disable them, as the toggle combo does
+//	app NAME        apply the [app NAME] section, if any, as NAME has focus
+//	app             apply no [app] section
+//
+// The toggle combo of the config (ctrl+alt+pause unless set otherwise)
+// disables every binding and remap, and enables them again. The combo's key
+// is not passed on, so it is safe to use even with a broken config. If even
+// that fails, holding both Ctrl keys and Backspace for 3 seconds (the panic
+// key; see kbd.OnPanicKey) releases the devices and makes kbdbind exit
+// successfully, so that systemd doesn't restart it.
+//
+// If -dbus is given, kbdbind provides the D-Bus service org.quillaja.kbd
+// (see dbus.go) on the system or session bus.
+//
+// kbdbind needs no root privileges, only access to the devices and
+// `/dev/uinput`. The suspend, hibernate and poweroff actions go through
+// systemd-logind, and brightness falls back to it, which check with polkit
+// that the user is allowed to perform them.
+//
+// If -broker is given, kbdbind shares key events with other programs on a
+// unix socket, limited for each program by the policy file given by -policy
+// (see broker.go and policy.go).
+//
+// If -http is given, kbdbind serves an HTTP API on that address (see
+// http.go). Requests must carry the token in the file given by -http-token,
+// which is created with a random token if it doesn't exist. The address
+// should still be on localhost.
+//
+// If -state is given, kbdbind keeps the active profile, the locks and
+// whether it is disabled in that file, and restores them when it starts
+// again (see state.go).
+//
+// If -dry-run is given, kbdbind opens no device: it replays the events
+// recorded in that file ("-" for stdin), in the wire format of
+// kbd.EventWriter as the broker sends them, through the startup profile,
+// prints the events it would emit and the actions it would run, and exits
+// (see dryrun.go).
+//
+// `kbdbind check` checks the config and exits: it reports bindings that
+// can't be pressed or that overlap, and profiles that can't be switched to,
+// and exits with status 1 if there are any (see check.go). `kbdbind explain
+// COMBO...` shows what pressing each combo does with the startup profile:
+// the keys it is remapped to and the bindings it runs.
+//
+// If -guardian is given, kbdbind starts a helper process that releases the
+// devices and destroys the virtual keyboard even if kbdbind is killed (see
+// kbd.StartGuardian).
+//
+// Under systemd, kbdbind reports readiness and watchdog pings with sd_notify
+// (use Type=notify) and logs to the journal with priorities.
+//
+// Usage:
+//
+//	kbdbind [-config FILE] [-control PATH] [-dbus system|session]
+//	        [-broker PATH [-policy FILE]] [-http ADDRESS -http-token FILE]
+//	        [-state FILE] [-guardian]
+//	kbdbind [-config FILE] -dry-run RECORDING
+//	kbdbind [-config FILE] check
+//	kbdbind [-config FILE] explain COMBO...
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/quillaja/kbd"
+)
+
+func main() {
+	path := flag.String("config", "/etc/kbd/kbd.conf", "config `file`")
+	control := flag.String("control", "", "listen for commands on the unix socket `path`")
+	bus := flag.String("dbus", "", "provide the D-Bus service on the `bus` \"system\" or \"session\"")
+	brokerPath := flag.String("broker", "", "share key events on the unix socket `path`")
+	httpAddr := flag.String("http", "", "serve the HTTP API on `address`, such as 127.0.0.1:7070")
+	httpTokenPath := flag.String("http-token", "", "HTTP API token `file`, created if missing")
+	policyPath := flag.String("policy", "/etc/kbd/policy.conf", "broker policy `file`")
+	statePath := flag.String("state", "", "keep state across restarts in `file`")
+	dryRunPath := flag.String("dry-run", "", "print what would be done with the events recorded in `file`, and exit")
+	guardian := flag.Bool("guardian", false, "release the devices with a helper process, even if killed")
+	flag.Parse()
+	setupLogging()
+	kbd.OnPanicKey(func() {
+		logf(prioErr, "panic key held: released all devices, exiting")
+		os.Exit(0)
+	})
+	if *guardian {
+		if err := kbd.StartGuardian(); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	cfg, err := kbd.LoadConfig(*path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	switch {
+	case flag.Arg(0) == "check" && flag.NArg() == 1:
+		if check(cfg, os.Stdout) > 0 {
+			os.Exit(1)
+		}
+		return
+	case flag.Arg(0) == "explain" && flag.NArg() > 1:
+		var combos []kbd.Combo
+		for _, arg := range flag.Args()[1:] {
+			c, err := kbd.ParseCombo(arg)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(2)
+			}
+			combos = append(combos, c)
+		}
+		explain(cfg, combos, os.Stdout)
+		return
+	case flag.NArg() > 0:
+		flag.Usage()
+		os.Exit(2)
+	}
+	if *dryRunPath != "" {
+		if err := dryRun(cfg, *dryRunPath, os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	d, err := newDaemon(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer d.Close()
+	if *statePath != "" {
+		d.statePath = *statePath
+		if err := d.restoreState(); err != nil {
+			logf(prioWarning, "restoring state: %v", err)
+		}
+	}
+	if *brokerPath != "" {
+		d.policyPath = *policyPath
+		p, err := loadPolicy(*policyPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		d.broker.setPolicy(p)
+		ln, err := listenBroker(*brokerPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		go d.broker.serve(ln)
+	}
+	if *bus != "" {
+		if err := d.serveDBus(*bus); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if *httpAddr != "" {
+		if *httpTokenPath == "" {
+			log.Fatal("-http needs -http-token")
+		}
+		token, err := httpToken(*httpTokenPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		ln, err := net.Listen("tcp", *httpAddr)
+		if err != nil {
+			log.Fatal(err)
+		}
+		go d.serveHTTP(ln, token)
+		kbd.RegisterMetric("kbdbind_broker_clients", "Clients connected to the broker.", "gauge",
+			func() float64 { n, _ := d.broker.stats(); return float64(n) })
+		kbd.RegisterMetric("kbdbind_broker_dropped_total", "Events not sent to slow broker clients.", "counter",
+			func() float64 { _, n := d.broker.stats(); return float64(n) })
+	}
+	go d.watchConfig(*path)
+	go watchdog(d.healthy)
+
+	ln, err := controlListener(*control)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if ln != nil {
+		go d.serveControl(ln)
+	}
+	if err := d.Run(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// daemon is a running kbdbind.
+type daemon struct {
+	mux     *kbd.Multiplexer
+	toggle  *kbd.Toggle
+	virtual *kbd.Virtual
+	remap   *kbd.Remapper
+	kb      *kbd.Keyboard
+	hotkeys *kbd.Hotkeys
+	runner  *kbd.Runner
+	plugins map[string]*kbd.Plugin
+
+	reloads chan struct{} // requests to reload the config
+	bus     *dbusService  // nil if not on D-Bus
+	broker  *broker
+
+	policyPath string // broker policy file, reloaded with the config
+	statePath  string // state file; none if ""
+
+	recentMu sync.Mutex
+	recent   []kbd.Event // the last recentEvents events, redacted
+
+	mu      sync.Mutex
+	cfg     *kbd.Config
+	profile string
+	app     string // application with focus, whose [app] section applies
+	bound   []*kbd.Binding
+	resume  func() // ends the suppression of the hotkeys; nil if enabled
+}
+
+// newDaemon opens the devices of cfg and activates its startup profile.
+func newDaemon(cfg *kbd.Config) (*daemon, error) {
+	if len(cfg.Devices) == 0 {
+		return nil, fmt.Errorf("kbdbind: no devices configured")
+	}
+	d := &daemon{
+		cfg:     cfg,
+		hotkeys: kbd.NewHotkeys(),
+		reloads: make(chan struct{}, 1),
+		broker:  newBroker(),
+	}
+	d.hotkeys.ErrorHandler = func(c kbd.Combo, err error) {
+		logf(prioErr, "%v: %v", c, err)
+	}
+	d.setRunner(cfg)
+	d.setPlugins(cfg)
+
+	var err error
+	if d.mux, err = kbd.NewMultiplexer(); err != nil {
+		return nil, err
+	}
+	for _, dev := range cfg.Devices {
+		if err := d.mux.Add(dev); err != nil {
+			d.mux.Close()
+			return nil, err
+		}
+	}
+	if d.virtual, err = kbd.NewVirtual("kbdbind"); err != nil {
+		d.mux.Close()
+		return nil, err
+	}
+	d.toggle = kbd.NewToggle(d.mux, cfg.Toggle, d.toggled)
+	if d.remap, err = kbd.NewRemapper(d.toggle, d.virtual, nil); err != nil {
+		d.virtual.Close()
+		d.mux.Close()
+		return nil, err
+	}
+	d.kb = kbd.NewHeadless(&tap{Backend: d.remap, d: d})
+	if err := d.kb.OnPoll(healthPoll, nil); err != nil {
+		d.virtual.Close()
+		d.mux.Close()
+		return nil, err
+	}
+	d.setProfile(cfg.Profile)
+	return d, nil
+}
+
+// setRunner replaces the Runner for exec actions with one set up by cfg.
+// Commands already running are left to finish.
+func (d *daemon) setRunner(cfg *kbd.Config) {
+	d.runner = kbd.NewRunner(cfg.ExecLimit, cfg.ExecTimeout)
+	d.runner.ErrorHandler = func(command string, err error) {
+		logf(prioWarning, "%s: %v", command, err)
+	}
+}
+
+// setPlugins stops the running plugins and starts those of cfg. Plugins that
+// fail to start are logged, and their actions fail.
+func (d *daemon) setPlugins(cfg *kbd.Config) {
+	for _, p := range d.plugins {
+		p.Close()
+	}
+	d.plugins = map[string]*kbd.Plugin{}
+	for name, command := range cfg.Plugins {
+		p, err := kbd.StartPlugin(command[0], command[1:]...)
+		if err != nil {
+			logf(prioErr, "plugin %s: %v", name, err)
+			continue
+		}
+		name := name
+		p.SetErrorHandler(func(action string, err error) {
+			logf(prioWarning, "plugin %s: %s: %v", name, action, err)
+		})
+		d.plugins[name] = p
+	}
+}
+
+// healthPoll is how often the read loop of an idle keyboard polls, so that
+// healthy can tell it from one that is stuck.
+const healthPoll = 5 * time.Second
+
+// healthy checks the keyboard for the watchdog: its read loop must have read
+// or polled within a few healthPoll. Having no devices is not an error,
+// since they may be plugged in later.
+func (d *daemon) healthy() error {
+	err := d.kb.HealthCheck(3 * healthPoll)
+	if errors.Is(err, kbd.ErrNoDevices) {
+		return nil
+	}
+	return err
+}
+
+// Run handles hotkeys until reading the devices fails.
+func (d *daemon) Run() error {
+	if err := d.kb.Start(); err != nil {
+		return err
+	}
+	go d.saveLocks(d.kb.Bus().Subscribe(4, kbd.DropOldest, kbd.LockEvent{}))
+	notify("READY=1")
+	d.hotkeys.Run(d.kb)
+	notify("STOPPING=1")
+	return d.kb.Err()
+}
+
+// setProfile replaces the active bindings and remaps with those of the
+// profile named name, or removes them all if name is "".
+func (d *daemon) setProfile(name string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.activate(name)
+}
+
+// activate is setProfile with d.mu held.
+func (d *daemon) activate(name string) {
+	p := d.cfg.Profiles[name]
+	if name == "" {
+		p = &kbd.Profile{} // no profile: nothing bound or remapped
+	}
+	if p == nil {
+		logf(prioErr, "no profile %q", name)
+		return
+	}
+	p = d.withApp(p)
+	bindings := make([]kbd.Binding, len(p.Bindings))
+	for i, spec := range p.Bindings {
+		bindings[i] = kbd.Binding{
+			Combo:  spec.Combo,
+			Action: d.signal(spec.Combo, d.action(spec.Combo, spec.Action)),
+
+			Cooldown:    spec.Cooldown,
+			Repeat:      spec.Repeat,
+			RepeatDelay: spec.RepeatDelay,
+		}
+	}
+	d.bound = d.hotkeys.Replace(d.bound, bindings)
+	d.profile = name
+	d.setRemaps()
+	d.saveState()
+}
+
+// withApp returns p with the [app] section of the application with focus,
+// if any, applied on top: its bindings replace those of p with the same
+// combo, and its remaps and dual keys those of p for the same key. d.mu must
+// be held.
+func (d *daemon) withApp(p *kbd.Profile) *kbd.Profile {
+	app := d.cfg.Apps[d.app]
+	if app == nil {
+		return p
+	}
+	merged := &kbd.Profile{
+		Name:   p.Name,
+		Remaps: map[kbd.KeyCode]kbd.KeyCode{},
+		Duals:  map[kbd.KeyCode]kbd.DualKey{},
+	}
+	overridden := map[kbd.Combo]bool{}
+	for _, spec := range app.Bindings {
+		overridden[spec.Combo] = true
+	}
+	for _, spec := range p.Bindings {
+		if !overridden[spec.Combo] {
+			merged.Bindings = append(merged.Bindings, spec)
+		}
+	}
+	merged.Bindings = append(merged.Bindings, app.Bindings...)
+	for _, q := range []*kbd.Profile{p, app} {
+		for from, to := range q.Remaps {
+			merged.Remaps[from] = to
+		}
+		for key, dual := range q.Duals {
+			merged.Duals[key] = dual
+		}
+	}
+	return merged
+}
+
+// setApp applies the [app] section of the application name, which has
+// focus, or none if name is "".
+func (d *daemon) setApp(name string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if name == d.app {
+		return
+	}
+	d.app = name
+	d.activate(d.profile)
+}
+
+// setRemaps applies the remaps of the active profile, and of the profiles
+// of devices with a device-profile, or none if d is disabled. d.mu must be
+// held.
+func (d *daemon) setRemaps() {
+	p := d.cfg.Profiles[d.profile]
+	if p == nil || d.resume != nil {
+		p = &kbd.Profile{}
+	} else {
+		p = d.withApp(p)
+	}
+	d.remap.SetKeys(p.Remaps)
+	d.remap.SetDual(p.Duals, d.cfg.TappingTerm)
+
+	d.remap.ClearDevices()
+	if d.resume != nil {
+		return
+	}
+	for dev, name := range d.cfg.DeviceProfiles {
+		paths, err := kbd.ResolveDevice(dev)
+		if err != nil {
+			logf(prioWarning, "device-profile %s: %v", dev, err)
+			continue
+		}
+		dp := d.cfg.Profiles[name]
+		for _, path := range paths {
+			d.remap.SetDevice(path, dp.Remaps, dp.Duals)
+		}
+	}
+}
+
+// toggled disables or enables all bindings and remaps, as the toggle combo
+// was pressed, and reports it.
+func (d *daemon) toggled(enabled bool) {
+	d.mu.Lock()
+	if enabled && d.resume != nil {
+		d.resume()
+		d.resume = nil
+	} else if !enabled && d.resume == nil {
+		d.resume = d.hotkeys.Suppress()
+	}
+	d.setRemaps()
+	d.saveState()
+	d.mu.Unlock()
+
+	if enabled {
+		logf(prioInfo, "bindings and remaps enabled")
+	} else {
+		logf(prioWarning, "bindings and remaps disabled")
+	}
+	if d.bus != nil {
+		d.bus.conn.Emit(dbusPath, dbusIface+".Enabled", enabled)
+	}
+}
+
+// action returns the Action for the binding of c to an action and its
+// arguments, which have been validated by kbd.LoadConfig.
+func (d *daemon) action(c kbd.Combo, spec []string) kbd.Action {
+	switch spec[0] {
+	case "exec":
+		return d.runner.Command(strings.Join(spec[1:], " "), c, d.kb)
+	case "brightness":
+		percent, _ := strconv.Atoi(spec[1])
+		return kbd.Brightness(percent)
+	case "suspend":
+		return kbd.Suspend()
+	case "hibernate":
+		return kbd.Hibernate()
+	case "poweroff":
+		return kbd.PowerOff()
+	case "signal":
+		sig, _ := kbd.ParseSignal(spec[2])
+		if pid, err := strconv.Atoi(spec[1]); err == nil {
+			return kbd.Signal(pid, sig)
+		}
+		return kbd.SignalPidFile(spec[1], sig)
+	case "profile":
+		name := spec[1]
+		return func() error {
+			d.setProfile(name)
+			return nil
+		}
+	case "none":
+		return func() error { return nil }
+	}
+
+	parts := strings.SplitN(spec[0], ".", 2)
+	p := d.plugins[parts[0]]
+	if p == nil {
+		return func() error { return fmt.Errorf("plugin %s is not running", parts[0]) }
+	}
+	return p.Action(parts[1], spec[1:], c, d.kb)
+}
+
+// Close releases the devices and the virtual keyboard, and stops the plugins.
+func (d *daemon) Close() error {
+	for _, p := range d.plugins {
+		p.Close()
+	}
+	err := d.kb.Close()
+	d.virtual.Close()
+	return err
+}
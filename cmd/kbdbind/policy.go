@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/quillaja/kbd"
+)
+
+// A policy controls which keys each broker client may receive. It is read
+// from a file with one rule per line; blank lines and text following a '#'
+// are ignored. A rule is
+//
+//	allow WHO KEYS...
+//
+// where WHO is "uid=N", "gid=N" or "any", and KEYS are key names (as for
+// kbd.ParseCombo, including groups such as ctrl), "media" for the media and
+// volume keys, or "all". "gid=N" matches clients whose primary group, or one
+// of whose supplementary groups, is N. A client may receive a key if any
+// rule matching it allows the key. Clients matching no rule receive nothing.
+// For example:
+//
+//	allow uid=0 all
+//	allow gid=63 media
+//	allow any volumeup volumedown mute
+//
+// Clients are matched by the uid and gid the kernel reports for them, and
+// the supplementary groups of their process when they connect, not by their
+// executable, which any process can pretend to be.
+type policy struct {
+	rules []policyRule
+}
+
+type policyRule struct {
+	uid, gid int // -1 for any
+	all      bool
+	keys     []kbd.KeyCode
+}
+
+// mediaKeys are the keys allowed by "media" in a policy.
+var mediaKeys = []kbd.KeyCode{
+	kbd.KeyMUTE, kbd.KeyVOLUMEDOWN, kbd.KeyVOLUMEUP,
+	kbd.KeyPLAYPAUSE, kbd.KeyPLAYCD, kbd.KeyPLAY, kbd.KeyPAUSECD,
+	kbd.KeySTOPCD, kbd.KeyNEXTSONG, kbd.KeyPREVIOUSSONG,
+	kbd.KeyFASTFORWARD, kbd.KeyREWIND,
+}
+
+// client identifies a broker client.
+type client struct {
+	uid, gid int
+	groups   []int // supplementary groups
+}
+
+// inGroup reports whether gid is c's primary group or one of its
+// supplementary groups.
+func (c client) inGroup(gid int) bool {
+	if c.gid == gid {
+		return true
+	}
+	for _, g := range c.groups {
+		if g == gid {
+			return true
+		}
+	}
+	return false
+}
+
+// allows reports whether the policy lets c receive key.
+func (p *policy) allows(c client, key kbd.KeyCode) bool {
+	for _, r := range p.rules {
+		if (r.uid >= 0 && r.uid != c.uid) || (r.gid >= 0 && !c.inGroup(r.gid)) {
+			continue
+		}
+		if r.hasKey(key) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasKey reports whether the keys of r include key.
+func (r *policyRule) hasKey(key kbd.KeyCode) bool {
+	if r.all {
+		return true
+	}
+	for _, k := range r.keys {
+		if kbd.Matches(k, key) {
+			return true
+		}
+	}
+	return false
+}
+
+// loadPolicy reads the policy file at path. Errors are reported as
+// kbd.ConfigErrors.
+func loadPolicy(path string) (*policy, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	p := &policy{}
+	var errs kbd.ConfigErrors
+	scanner := bufio.NewScanner(f)
+	pos := kbd.ConfigPos{File: path}
+	for scanner.Scan() {
+		pos.Line++
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		r, err := parseRule(fields)
+		if err != nil {
+			errs = append(errs, &kbd.ConfigError{Pos: pos, Msg: err.Error()})
+			continue
+		}
+		p.rules = append(p.rules, r)
+	}
+	if err := scanner.Err(); err != nil {
+		errs = append(errs, &kbd.ConfigError{Pos: pos, Msg: err.Error()})
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return p, nil
+}
+
+func parseRule(fields []string) (policyRule, error) {
+	r := policyRule{uid: -1, gid: -1}
+	if fields[0] != "allow" || len(fields) < 3 {
+		return r, fmt.Errorf("rules must be: allow WHO KEYS...")
+	}
+
+	who := fields[1]
+	var err error
+	switch {
+	case who == "any":
+	case strings.HasPrefix(who, "uid="):
+		r.uid, err = strconv.Atoi(who[4:])
+	case strings.HasPrefix(who, "gid="):
+		r.gid, err = strconv.Atoi(who[4:])
+	case strings.HasPrefix(who, "exe="):
+		return r, fmt.Errorf("clients can't be matched by executable, which can be forged; use uid= or gid=")
+	default:
+		err = fmt.Errorf("unknown client %q", who)
+	}
+	if err != nil || r.uid < -1 || r.gid < -1 || (who != "any" && r.uid < 0 && r.gid < 0) {
+		return r, fmt.Errorf("bad client %q", who)
+	}
+
+	for _, name := range fields[2:] {
+		switch name {
+		case "all":
+			r.all = true
+		case "media":
+			r.keys = append(r.keys, mediaKeys...)
+		default:
+			c, err := kbd.ParseCombo(name)
+			if err != nil || c != (kbd.Combo{Key: c.Key}) {
+				return r, fmt.Errorf("unknown key %q", name)
+			}
+			r.keys = append(r.keys, c.Key)
+		}
+	}
+	return r, nil
+}
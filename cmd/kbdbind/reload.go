@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/quillaja/kbd"
+	"golang.org/x/sys/unix"
+)
+
+// reloadDelay is how long to wait after a config file changes before
+// reloading, so that an editor can finish writing it.
+const reloadDelay = 200 * time.Millisecond
+
+// watchConfig reloads the config at path on SIGHUP and when any of the files
+// it was read from change. Directories are watched rather than files, as many
+// editors replace a file instead of writing it.
+func (d *daemon) watchConfig(path string) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			d.requestReload()
+		}
+	}()
+
+	var w *watcher
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC)
+	if err != nil {
+		logf(prioWarning, "not watching config files: %v", err)
+	} else {
+		w = &watcher{fd: fd, dirs: map[int]string{}}
+		w.watch(d.configFiles())
+		go w.run(func() { time.AfterFunc(reloadDelay, d.requestReload) })
+	}
+
+	for range d.reloads {
+		d.reload(path)
+		if w != nil {
+			w.watch(d.configFiles()) // includes may have changed
+		}
+	}
+}
+
+// requestReload asks watchConfig to reload the config.
+func (d *daemon) requestReload() {
+	select { // non-blocking channel send; a reload is already pending
+	case d.reloads <- struct{}{}:
+	default:
+	}
+}
+
+// reload loads the config at path and applies it. If the config is invalid,
+// the errors are logged and the current config is kept. Devices that are in
+// both configs stay open and grabbed, so no events are lost.
+func (d *daemon) reload(path string) {
+	notify("RELOADING=1")
+	defer notify("READY=1")
+	cfg, err := kbd.LoadConfig(path)
+	if err != nil {
+		logf(prioErr, "not reloading config:\n%v", err)
+		return
+	}
+	if d.policyPath != "" {
+		p, err := loadPolicy(d.policyPath)
+		if err != nil {
+			logf(prioErr, "not reloading policy:\n%v", err)
+		} else {
+			d.broker.setPolicy(p)
+		}
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	want := map[string]bool{}
+	for _, dev := range cfg.Devices {
+		paths, err := kbd.ResolveDevice(dev)
+		if err != nil {
+			logf(prioErr, "%v", err)
+		}
+		for _, path := range paths {
+			want[path] = true
+		}
+	}
+	have := map[string]bool{}
+	for _, dev := range d.mux.Devices() {
+		have[dev] = true
+		if !want[dev] {
+			d.mux.Remove(dev)
+		}
+	}
+	for dev := range want {
+		if !have[dev] {
+			if err := d.mux.Add(dev); err != nil {
+				logf(prioErr, "%v", err)
+			}
+		}
+	}
+
+	d.cfg = cfg
+	d.setRunner(cfg)
+	d.setPlugins(cfg)
+	d.toggle.SetCombo(cfg.Toggle)
+	profile := d.profile
+	if cfg.Profiles[profile] == nil {
+		profile = cfg.Profile
+	}
+	d.activate(profile)
+	logf(prioInfo, "reloaded config %s", path)
+}
+
+// configFiles returns the files of the current config, and the policy file.
+func (d *daemon) configFiles() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	files := append([]string(nil), d.cfg.Files...)
+	if d.policyPath != "" {
+		files = append(files, d.policyPath)
+	}
+	return files
+}
+
+// watcher watches the directories of config files with inotify.
+type watcher struct {
+	fd    int
+	mu    sync.Mutex
+	dirs  map[int]string // watch descriptor to directory
+	files map[string]bool
+}
+
+// watch replaces the watched files with files.
+func (w *watcher) watch(files []string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.files = map[string]bool{}
+	dirs := map[string]bool{}
+	for _, f := range files {
+		abs, err := filepath.Abs(f)
+		if err != nil {
+			continue
+		}
+		w.files[abs] = true
+		dirs[filepath.Dir(abs)] = true
+	}
+	for wd, dir := range w.dirs {
+		if !dirs[dir] {
+			unix.InotifyRmWatch(w.fd, uint32(wd))
+			delete(w.dirs, wd)
+		}
+	}
+	for dir := range dirs {
+		const mask = unix.IN_CLOSE_WRITE | unix.IN_MOVED_TO | unix.IN_CREATE | unix.IN_DELETE
+		wd, err := unix.InotifyAddWatch(w.fd, dir, mask)
+		if err != nil {
+			logf(prioWarning, "not watching %s: %v", dir, err)
+			continue
+		}
+		w.dirs[wd] = dir
+	}
+}
+
+// run calls changed each time a watched file changes.
+func (w *watcher) run(changed func()) {
+	buf := make([]byte, 4096)
+	for {
+		n, err := unix.Read(w.fd, buf)
+		if err == unix.EINTR {
+			continue
+		}
+		if err != nil {
+			logf(prioWarning, "not watching config files: %v", err)
+			return
+		}
+		for b := buf[:n]; len(b) >= unix.SizeofInotifyEvent; {
+			wd := int32(binary.LittleEndian.Uint32(b)) // struct inotify_event
+			size := unix.SizeofInotifyEvent + int(binary.LittleEndian.Uint32(b[12:]))
+			if size > len(b) {
+				break
+			}
+			name := b[unix.SizeofInotifyEvent:size]
+			b = b[size:]
+			if i := bytes.IndexByte(name, 0); i >= 0 {
+				name = name[:i] // the name is padded with NULs
+			}
+			w.mu.Lock()
+			dir, ok := w.dirs[int(wd)]
+			ok = ok && w.files[filepath.Join(dir, string(name))]
+			w.mu.Unlock()
+			if ok {
+				changed()
+			}
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/quillaja/kbd"
+)
+
+// The state file keeps what the user changed while kbdbind was running, so
+// that a restart, or a crash, doesn't reset it. It has one item per line:
+//
+//	profile NAME         the active profile
+//	app NAME             the application with focus, whose [app] section is
+//	                     layered on the profile
+//	locks CapsLock ...   the locks that are on
+//	disabled             bindings and remaps are disabled by the toggle
+//
+// The locks are turned on or off as saved by tapping the lock keys on the
+// virtual keyboard, since the console or display server keeps their state.
+//
+// Unknown lines are ignored, so that older versions can read newer files.
+
+// stateLocks are the locks kept in the state file, by name.
+var stateLocks = []kbd.Lock{kbd.NumLock, kbd.CapsLock, kbd.ScrollLock}
+
+// savedState is the content of a state file.
+type savedState struct {
+	profile  string
+	app      string
+	locks    kbd.Lock
+	disabled bool
+}
+
+// loadState reads the state file at path. A missing file is an empty state.
+func loadState(path string) (savedState, error) {
+	var st savedState
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return st, nil
+	}
+	if err != nil {
+		return st, err
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		switch {
+		case len(fields) == 2 && fields[0] == "profile":
+			st.profile = fields[1]
+		case len(fields) == 2 && fields[0] == "app":
+			st.app = fields[1]
+		case len(fields) >= 1 && fields[0] == "locks":
+			for _, name := range fields[1:] {
+				for _, l := range stateLocks {
+					if l.String() == name {
+						st.locks |= l
+					}
+				}
+			}
+		case len(fields) == 1 && fields[0] == "disabled":
+			st.disabled = true
+		}
+	}
+	return st, nil
+}
+
+// restoreState makes the state saved in d.statePath current: the profile,
+// if the config still has it, the application with focus, the locks, and
+// whether d is disabled.
+func (d *daemon) restoreState() error {
+	st, err := loadState(d.statePath)
+	if err != nil {
+		return err
+	}
+	d.kb.SetLocks(st.locks)
+	go d.restoreLocks(st.locks)
+	d.mu.Lock()
+	ok := st.profile != "" && d.cfg.Profiles[st.profile] != nil
+	d.app = st.app
+	d.mu.Unlock()
+	if ok {
+		d.setProfile(st.profile)
+	}
+	if st.disabled {
+		d.toggle.SetEnabled(false)
+	}
+	return nil
+}
+
+// virtualSettle is how long the system is given to pick up the virtual
+// keyboard once its device file exists.
+const virtualSettle = 500 * time.Millisecond
+
+// restoreLocks turns the locks on or off as in l, with the virtual keyboard,
+// once the system has picked it up.
+func (d *daemon) restoreLocks(l kbd.Lock) {
+	cur, err := d.mux.Locks()
+	if err != nil {
+		logf(prioWarning, "restoring locks: %v", err)
+		return
+	}
+	if cur == l {
+		return
+	}
+	path, err := d.virtual.Path()
+	for i := 0; err == nil && i < 50; i++ { // wait for udev to create the file
+		if _, err = os.Stat(path); !os.IsNotExist(err) {
+			break
+		}
+		err = nil
+		time.Sleep(100 * time.Millisecond)
+	}
+	time.Sleep(virtualSettle)
+	if err == nil {
+		err = d.virtual.SetLocks(cur, l)
+	}
+	if err != nil {
+		logf(prioWarning, "restoring locks: %v", err)
+	}
+}
+
+// saveState writes the current state to d.statePath, if set. It replaces
+// the file with a new one, so that a crash while writing leaves the old one.
+// d.mu must be held.
+func (d *daemon) saveState() {
+	if d.statePath == "" {
+		return
+	}
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "profile %s\n", d.profile)
+	if d.app != "" {
+		fmt.Fprintf(&buf, "app %s\n", d.app)
+	}
+	locks := d.kb.State().Locks
+	buf.WriteString("locks")
+	for _, l := range stateLocks {
+		if locks&l != 0 {
+			buf.WriteString(" " + l.String())
+		}
+	}
+	buf.WriteString("\n")
+	if d.resume != nil {
+		buf.WriteString("disabled\n")
+	}
+
+	tmp := d.statePath + ".new"
+	err := ioutil.WriteFile(tmp, buf.Bytes(), 0644)
+	if err == nil {
+		err = os.Rename(tmp, d.statePath)
+	}
+	if err != nil {
+		logf(prioWarning, "saving state: %v", err)
+	}
+}
+
+// saveLocks saves the state each time a lock changes, until kb stops.
+func (d *daemon) saveLocks(sub *kbd.BusSubscription) {
+	for range sub.C {
+		d.mu.Lock()
+		d.saveState()
+		d.mu.Unlock()
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// Log priorities, from syslog.
+const (
+	prioErr     = 3
+	prioWarning = 4
+	prioInfo    = 6
+)
+
+// journal is set when stderr is connected to the systemd journal.
+var journal bool
+
+// setupLogging detects if stderr is connected to the journal, in which case
+// messages are prefixed with their priority and not timestamped.
+func setupLogging() {
+	var dev, ino uint64
+	_, err := fmt.Sscanf(os.Getenv("JOURNAL_STREAM"), "%d:%d", &dev, &ino)
+	if err != nil {
+		return
+	}
+	var st unix.Stat_t
+	if unix.Fstat(2, &st) == nil && uint64(st.Dev) == dev && uint64(st.Ino) == ino {
+		journal = true
+		log.SetFlags(0)
+	}
+}
+
+// logf logs a message with priority prio.
+func logf(prio int, format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	if journal {
+		msg = strings.Replace(msg, "\n", "\n<"+strconv.Itoa(prio)+">", -1)
+		msg = "<" + strconv.Itoa(prio) + ">" + msg
+	}
+	log.Print(msg)
+}
+
+// notify sends state to systemd, if it started kbdbind with Type=notify.
+func notify(state string) {
+	addr := os.Getenv("NOTIFY_SOCKET")
+	if addr == "" {
+		return
+	}
+	if addr[0] == '@' {
+		addr = "\x00" + addr[1:] // abstract socket
+	}
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: addr, Net: "unixgram"})
+	if err != nil {
+		logf(prioWarning, "sd_notify: %v", err)
+		return
+	}
+	defer conn.Close()
+	conn.Write([]byte(state))
+}
+
+// watchdog pings the systemd watchdog, if it is enabled, at half its timeout.
+// Pings are skipped while healthy reports an error, so that systemd restarts
+// a daemon whose read loop has died.
+func watchdog(healthy func() error) {
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return
+	}
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return
+	}
+	for range time.Tick(time.Duration(usec) * time.Microsecond / 2) {
+		if err := healthy(); err != nil {
+			logf(prioErr, "health check: %v", err)
+			continue
+		}
+		notify("WATCHDOG=1")
+	}
+}
+
+// listenFdsStart is the first file descriptor passed by socket activation.
+const listenFdsStart = 3
+
+// activatedListener returns the first socket passed by systemd socket
+// activation, or nil if there is none.
+func activatedListener() (net.Listener, error) {
+	if os.Getenv("LISTEN_PID") != strconv.Itoa(os.Getpid()) {
+		return nil, nil
+	}
+	n, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || n < 1 {
+		return nil, nil
+	}
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_FDNAMES")
+	for fd := listenFdsStart; fd < listenFdsStart+n; fd++ {
+		unix.CloseOnExec(fd)
+	}
+	f := os.NewFile(listenFdsStart, "LISTEN_FD_3")
+	defer f.Close() // net.FileListener dups the fd
+	return net.FileListener(f)
+}
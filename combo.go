@@ -0,0 +1,216 @@
+package kbd
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Modifier kinds, the indexes of Combo.Mods.
+const (
+	modCtrl = iota
+	modAlt
+	modShift
+	modMeta
+)
+
+// modGroups are the key groups for each modifier kind.
+var modGroups = [4]KeyCode{AnyCtrl, AnyAlt, AnyShift, AnyMeta}
+
+// Combo is a key combination: Key pressed while the modifiers are held.
+type Combo struct {
+	// Mods are the required Ctrl, Alt, Shift, and Meta modifiers, in that
+	// order. Each is either a group (AnyCtrl), a key for one side
+	// (KeyLEFTCTRL), or 0 if the modifier must not be held.
+	Mods [4]KeyCode
+	Key  KeyCode
+}
+
+// modifierNames maps the names of modifiers accepted by ParseCombo to keys.
+var modifierNames = map[string]KeyCode{
+	"ctrl": AnyCtrl, "control": AnyCtrl, "ctl": AnyCtrl,
+	"lctrl": KeyLEFTCTRL, "leftctrl": KeyLEFTCTRL, "rctrl": KeyRIGHTCTRL, "rightctrl": KeyRIGHTCTRL,
+	"alt": AnyAlt, "option": AnyAlt, "opt": AnyAlt,
+	"lalt": KeyLEFTALT, "leftalt": KeyLEFTALT, "ralt": KeyRIGHTALT, "rightalt": KeyRIGHTALT, "altgr": KeyRIGHTALT,
+	"shift":  AnyShift,
+	"lshift": KeyLEFTSHIFT, "leftshift": KeyLEFTSHIFT, "rshift": KeyRIGHTSHIFT, "rightshift": KeyRIGHTSHIFT,
+	"meta": AnyMeta, "super": AnyMeta, "win": AnyMeta, "windows": AnyMeta, "cmd": AnyMeta, "command": AnyMeta, "mod4": AnyMeta,
+	"lmeta": KeyLEFTMETA, "leftmeta": KeyLEFTMETA, "lsuper": KeyLEFTMETA, "lwin": KeyLEFTMETA,
+	"rmeta": KeyRIGHTMETA, "rightmeta": KeyRIGHTMETA, "rsuper": KeyRIGHTMETA, "rwin": KeyRIGHTMETA,
+}
+
+// keyAliases are names for keys accepted by ParseCombo in addition to their
+// constant names.
+var keyAliases = map[string]KeyCode{
+	"return": KeyENTER, "escape": KeyESC, "del": KeyDELETE, "ins": KeyINSERT,
+	"pgup": KeyPAGEUP, "pgdn": KeyPAGEDOWN, "pagedn": KeyPAGEDOWN, "bs": KeyBACKSPACE,
+	"caps": KeyCAPSLOCK, "printscreen": KeySYSRQ, "prtsc": KeySYSRQ, "menu": KeyCOMPOSE,
+	"arrowup": KeyUP, "arrowdown": KeyDOWN, "arrowleft": KeyLEFT, "arrowright": KeyRIGHT,
+}
+
+// modKind returns the modifier kind of key, and false if it isn't a modifier.
+func modKind(key KeyCode) (int, bool) {
+	for kind, group := range modGroups {
+		if Matches(group, key) {
+			return kind, true
+		}
+	}
+	return 0, false
+}
+
+// ParseCombo parses a combination such as "ctrl+shift+f5" or "Super+Return".
+// Names are case insensitive. Modifiers may be given generically (ctrl, alt,
+// shift, super, and aliases like cmd or win) to match either side, or for one
+// side (lctrl, ralt, altgr). The key may be the name of any KeyCode constant
+// without its "Key" prefix, a common alias (return, escape, pgup), or the
+// character it types on a US keyboard ("/", "a").
+func ParseCombo(s string) (Combo, error) {
+	var c Combo
+	parts := strings.Split(s, "+")
+	for i, part := range parts {
+		name := strings.ToLower(strings.TrimSpace(part))
+		if name == "" {
+			return Combo{}, fmt.Errorf("kbd: empty key in combo %q", s)
+		}
+
+		if i < len(parts)-1 {
+			mod, ok := modifierNames[name]
+			if !ok {
+				return Combo{}, fmt.Errorf("kbd: unknown modifier %q in combo %q", part, s)
+			}
+			kind, _ := modKind(mod)
+			c.Mods[kind] = mod
+			continue
+		}
+
+		key, ok := lookupKey(name)
+		if !ok {
+			return Combo{}, fmt.Errorf("kbd: unknown key %q in combo %q", part, s)
+		}
+		c.Key = key
+	}
+	return c, nil
+}
+
+// lookupKey finds the key with the lowercase name.
+func lookupKey(name string) (KeyCode, bool) {
+	if key, ok := keyAliases[name]; ok {
+		return key, true
+	}
+	if key, ok := modifierNames[name]; ok {
+		return key, true
+	}
+	for key, n := range keyNames {
+		if strings.ToLower(n) == name {
+			return key, true
+		}
+	}
+	if r := []rune(name); len(r) == 1 {
+		for key, runes := range KeymapUS.keys {
+			if runes[0] == r[0] && key < KeyKP7 { // prefer the main keys over the keypad
+				return key, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// Down reports whether c is held on kb: its key and required modifiers are
+// down, and no other modifiers are.
+func (c Combo) Down(kb *Keyboard) bool {
+	return kb.IsDown(c.Key) && c.modsHeld(kb.IsDown)
+}
+
+// DownIn reports whether c was held in s, as Down does for a Keyboard.
+func (c Combo) DownIn(s State) bool {
+	return s.IsDown(c.Key) && c.modsHeld(s.IsDown)
+}
+
+// modsHeld reports whether exactly the modifiers of c are held, according
+// to isDown, which is given groups as well as keys.
+func (c Combo) modsHeld(isDown func(KeyCode) bool) bool {
+	for kind, mod := range c.Mods {
+		if mod == 0 {
+			if !Matches(modGroups[kind], c.Key) && isDown(modGroups[kind]) {
+				return false
+			}
+		} else if !isDown(mod) {
+			return false
+		}
+	}
+	return true
+}
+
+// String returns c in the form accepted by ParseCombo, such as
+// "ctrl+shift+f5".
+func (c Combo) String() string {
+	var parts []string
+	for _, mod := range c.Mods {
+		if mod != 0 {
+			parts = append(parts, comboName(mod))
+		}
+	}
+	return strings.Join(append(parts, comboName(c.Key)), "+")
+}
+
+// comboName returns the name ParseCombo accepts for key.
+func comboName(key KeyCode) string {
+	for _, name := range []string{"ctrl", "lctrl", "rctrl", "alt", "lalt", "ralt",
+		"shift", "lshift", "rshift", "super", "lsuper", "rsuper"} {
+		if modifierNames[name] == key {
+			return name
+		}
+	}
+	return strings.ToLower(key.String())
+}
+
+// displayNames are user facing names for keys that don't type a character.
+var displayNames = map[KeyCode]string{
+	AnyCtrl: "Ctrl", AnyAlt: "Alt", AnyShift: "Shift", AnyMeta: "Super", AnyEnter: "Enter",
+	KeyLEFTCTRL: "Left Ctrl", KeyRIGHTCTRL: "Right Ctrl",
+	KeyLEFTALT: "Left Alt", KeyRIGHTALT: "AltGr",
+	KeyLEFTSHIFT: "Left Shift", KeyRIGHTSHIFT: "Right Shift",
+	KeyLEFTMETA: "Left Super", KeyRIGHTMETA: "Right Super",
+
+	KeyESC: "Esc", KeyENTER: "Enter", KeyKPENTER: "Num Enter", KeyBACKSPACE: "Backspace",
+	KeyTAB: "Tab", KeySPACE: "Space", KeyCAPSLOCK: "Caps Lock", KeyNUMLOCK: "Num Lock",
+	KeySCROLLLOCK: "Scroll Lock", KeySYSRQ: "Print Screen", KeyPAUSE: "Pause",
+	KeyINSERT: "Insert", KeyDELETE: "Delete", KeyHOME: "Home", KeyEND: "End",
+	KeyPAGEUP: "Page Up", KeyPAGEDOWN: "Page Down",
+	KeyUP: "Up", KeyDOWN: "Down", KeyLEFT: "Left", KeyRIGHT: "Right", KeyCOMPOSE: "Menu",
+	KeyMUTE: "Mute", KeyVOLUMEUP: "Volume Up", KeyVOLUMEDOWN: "Volume Down",
+	KeyPLAYPAUSE: "Play/Pause", KeyNEXTSONG: "Next Track", KeyPREVIOUSSONG: "Previous Track",
+}
+
+// DisplayString returns c as it should be shown to users, such as
+// "Ctrl+Shift+Ü". Keys that type a character are shown as that character in
+// the layout of keymap (DefaultKeymap() if nil).
+func (c Combo) DisplayString(keymap *Keymap) string {
+	var parts []string
+	for _, mod := range c.Mods {
+		if mod != 0 {
+			parts = append(parts, displayName(mod, keymap))
+		}
+	}
+	return strings.Join(append(parts, displayName(c.Key, keymap)), "+")
+}
+
+// displayName returns the user facing name of key.
+func displayName(key KeyCode, keymap *Keymap) string {
+	if name, ok := displayNames[key]; ok {
+		return name
+	}
+	if keymap == nil {
+		keymap = DefaultKeymap()
+	}
+	if r, ok := keymap.Rune(key, false); ok && r > ' ' {
+		if strings.HasPrefix(key.String(), "KP") {
+			return "Num " + string(r)
+		}
+		return strings.ToUpper(string(r))
+	}
+	name := key.String()
+	if strings.HasPrefix(name, "KeyCode(") || len(name) <= 3 {
+		return name // unnamed, or short like F5
+	}
+	return name[:1] + strings.ToLower(name[1:])
+}
@@ -0,0 +1,581 @@
+package kbd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config is the configuration shared by the daemon tools, such as kbdbind.
+// It is read from a text file with one directive per line; blank lines and
+// comments, from a '#' starting a word to the end of the line, are ignored.
+// Words are separated by blanks, and may be quoted with '...' or "...", or
+// escaped with a backslash, to contain blanks, quotes or a leading '#'. The
+// format is:
+//
+//	# Top level directives, which may also appear in any section.
+//	include other.conf           # read another file, relative to this one
+//	device /dev/input/event3     # a device to read; may be repeated
+//	device label:work            # the devices labeled work (see Labels)
+//	device-profile label:laptop plain  # remap a device with another profile
+//	profile default              # the profile active at startup
+//	exec-timeout 30s             # kill exec actions running longer
+//	exec-limit 4                 # run at most 4 exec actions at once
+//	tapping-term 200ms           # how long a dual key may be held for a tap
+//	toggle ctrl+alt+pause        # turn all bindings and remaps off and on
+//	plugin mqtt /usr/lib/kbd/mqtt --broker localhost  # start a Plugin
+//
+//	[profile default]            # a named set of bindings and remaps
+//	bind ctrl+alt+t exec xterm   # run an action when a combo is pressed
+//	bind volumeup repeat=100ms exec pactl set-sink-volume @DEFAULT_SINK@ +5%
+//	remap capslock esc           # replace one key with another
+//	dual capslock esc ctrl       # a key tapped as one key and held as another
+//
+//	[app firefox]                # bindings and remaps for one application,
+//	bind ctrl+q none             # applied on top of the active profile
+//
+// Combos are written as for ParseCombo and keys as the last part of a combo.
+// A combo may be followed by options of its binding:
+//
+//	cooldown=DURATION      the least time between runs (see Binding)
+//	repeat=DURATION        run again this often while held (see WithRepeat)
+//	repeat-delay=DURATION  first run again this long after the press
+//
+// The actions are:
+//
+//	exec COMMAND...      run COMMAND, the rest of the line as written, with
+//	                     `sh -c` (see Runner)
+//	brightness PERCENT   change the backlight brightness, e.g. +10 or -10
+//	suspend              suspend the system
+//	hibernate            hibernate the system
+//	poweroff             shut down the system
+//	profile NAME         make the profile NAME active
+//	signal TARGET SIG    send the signal SIG, such as USR1, to TARGET, which is
+//	                     a positive pid or the path of a file holding one
+//	none                 do nothing (to disable a binding from a profile)
+//	NAME.ACTION ARGS...  run ACTION of the plugin NAME with ARGS
+type Config struct {
+	Devices  []string
+	Profile  string // profile active at startup; "default" if not set
+	Profiles map[string]*Profile
+	Apps     map[string]*Profile
+	Files    []string // the files read, including included files
+
+	// DeviceProfiles maps devices (paths or labels) to the profiles whose
+	// remaps apply to their keys instead of the active profile's; see
+	// Remapper.SetDevice. Bindings always come from the active profile.
+	DeviceProfiles map[string]string
+
+	ExecTimeout time.Duration // how long exec actions may run; unlimited if 0
+	ExecLimit   int           // how many exec actions may run at once; unlimited if 0
+	TappingTerm time.Duration // for dual keys; see Remapper.SetDual
+	Toggle      Combo         // see Toggle; DefaultToggle if not set, none if "none"
+
+	Plugins map[string][]string // plugin names to their command and arguments
+}
+
+// Profile is a set of bindings and remaps in a Config.
+type Profile struct {
+	Name     string
+	Bindings []BindingSpec
+	Remaps   map[KeyCode]KeyCode
+	Duals    map[KeyCode]DualKey
+}
+
+// BindingSpec is a binding in a Config.
+type BindingSpec struct {
+	Combo  Combo
+	Action []string // action name and arguments; exec has one, the command
+	Pos    ConfigPos
+
+	Cooldown    time.Duration // see Binding
+	Repeat      time.Duration
+	RepeatDelay time.Duration
+}
+
+// ConfigPos is a position in a config file.
+type ConfigPos struct {
+	File string
+	Line int
+}
+
+func (p ConfigPos) String() string {
+	if p.Line == 0 {
+		return p.File
+	}
+	return fmt.Sprintf("%s:%d", p.File, p.Line)
+}
+
+// ConfigError is an error at a position in a config file.
+type ConfigError struct {
+	Pos ConfigPos
+	Msg string
+}
+
+func (e *ConfigError) Error() string {
+	return e.Pos.String() + ": " + e.Msg
+}
+
+// ConfigErrors is the list of errors found while loading a config.
+type ConfigErrors []*ConfigError
+
+func (e ConfigErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// configActions are the actions allowed in bindings, with their number of
+// arguments. -1 means one or more.
+var configActions = map[string]int{
+	"exec":       -1,
+	"brightness": 1,
+	"suspend":    0,
+	"hibernate":  0,
+	"poweroff":   0,
+	"profile":    1,
+	"signal":     2,
+	"none":       0,
+}
+
+// LoadConfig reads and validates the config file at path, along with any
+// files it includes. If the config is invalid, the error is a ConfigErrors
+// listing every problem found.
+func LoadConfig(path string) (*Config, error) {
+	l := &configLoader{
+		c: &Config{
+			Profiles:       map[string]*Profile{},
+			Apps:           map[string]*Profile{},
+			Plugins:        map[string][]string{},
+			Toggle:         DefaultToggle,
+			DeviceProfiles: map[string]string{},
+		},
+		reading:    map[string]bool{},
+		devProfPos: map[string]ConfigPos{},
+	}
+	l.load(path, ConfigPos{})
+	l.validate()
+	if len(l.errs) > 0 {
+		return nil, l.errs
+	}
+	if l.c.Profile == "" {
+		l.c.Profile = "default"
+	}
+	return l.c, nil
+}
+
+type configLoader struct {
+	c       *Config
+	errs    ConfigErrors
+	reading map[string]bool // files being read, to detect include cycles
+	profPos ConfigPos       // position of the "profile" directive
+	section *Profile
+
+	devProfPos map[string]ConfigPos // positions of "device-profile" directives
+}
+
+func (l *configLoader) errorf(pos ConfigPos, format string, args ...interface{}) {
+	l.errs = append(l.errs, &ConfigError{Pos: pos, Msg: fmt.Sprintf(format, args...)})
+}
+
+// load reads the file at path, included from pos.
+func (l *configLoader) load(path string, from ConfigPos) {
+	abs, err := filepath.Abs(path)
+	if err == nil && l.reading[abs] {
+		l.errorf(from, "include cycle: %s", path)
+		return
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		if from.File == "" {
+			l.errs = append(l.errs, &ConfigError{Pos: ConfigPos{File: path}, Msg: err.Error()})
+		} else {
+			l.errorf(from, "%v", err)
+		}
+		return
+	}
+	defer f.Close()
+	l.c.Files = append(l.c.Files, path)
+	l.reading[abs] = true
+	defer delete(l.reading, abs)
+
+	section := l.section
+	l.section = nil // included files start outside any section
+	defer func() { l.section = section }()
+
+	scanner := bufio.NewScanner(f)
+	pos := ConfigPos{File: path}
+	for scanner.Scan() {
+		pos.Line++
+		line, err := splitConfigLine(scanner.Text())
+		if err != nil {
+			l.errorf(pos, "%v", err)
+			continue
+		}
+		if len(line.words) > 0 {
+			l.directive(pos, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		l.errorf(pos, "%v", err)
+	}
+}
+
+// configLine is a line of a config file, split into words.
+type configLine struct {
+	words  []string
+	starts []int  // offset of each word in text
+	text   string // the line, without any comment
+}
+
+// rest returns the text of the line from word i on, as written.
+func (line configLine) rest(i int) string {
+	return strings.TrimSpace(line.text[line.starts[i]:])
+}
+
+// splitConfigLine splits a line of a config file into words, removing
+// quotes, escapes and any comment.
+func splitConfigLine(text string) (configLine, error) {
+	var line configLine
+	var word strings.Builder
+	inWord := false
+	var quote byte // the quote of the quoted text being read, if any
+	i := 0
+scan:
+	for ; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case quote == '\'' && c == '\'', quote == '"' && c == '"':
+			quote = 0
+		case quote == '"' && c == '\\' && i+1 < len(text) && (text[i+1] == '"' || text[i+1] == '\\'):
+			i++
+			word.WriteByte(text[i])
+		case quote != 0:
+			word.WriteByte(c)
+		case c == ' ' || c == '\t':
+			if inWord {
+				line.words = append(line.words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		case !inWord && c == '#':
+			break scan
+		default:
+			if !inWord {
+				line.starts = append(line.starts, i)
+				inWord = true
+			}
+			switch c {
+			case '\'', '"':
+				quote = c
+			case '\\':
+				if i+1 < len(text) {
+					i++
+					word.WriteByte(text[i])
+				}
+			default:
+				word.WriteByte(c)
+			}
+		}
+	}
+	if quote != 0 {
+		return configLine{}, fmt.Errorf("unterminated %c quote", quote)
+	}
+	if inWord {
+		line.words = append(line.words, word.String())
+	}
+	line.text = text[:i]
+	return line, nil
+}
+
+// directive handles one line of a config file.
+func (l *configLoader) directive(pos ConfigPos, line configLine) {
+	fields := line.words
+	if strings.HasPrefix(fields[0], "[") {
+		l.sectionHeader(pos, strings.Join(fields, " "))
+		return
+	}
+
+	args := fields[1:]
+	switch fields[0] {
+	case "include":
+		if len(args) != 1 {
+			l.errorf(pos, "include needs one file")
+			return
+		}
+		inc := args[0]
+		if !filepath.IsAbs(inc) {
+			inc = filepath.Join(filepath.Dir(pos.File), inc)
+		}
+		l.load(inc, pos)
+
+	case "device":
+		if len(args) != 1 {
+			l.errorf(pos, "device needs one path")
+			return
+		}
+		l.c.Devices = append(l.c.Devices, args[0])
+
+	case "device-profile":
+		if len(args) != 2 {
+			l.errorf(pos, "device-profile needs a device and a profile")
+			return
+		}
+		l.c.DeviceProfiles[args[0]] = args[1]
+		l.devProfPos[args[0]] = pos
+
+	case "exec-timeout":
+		var err error
+		if len(args) == 1 {
+			l.c.ExecTimeout, err = time.ParseDuration(args[0])
+		}
+		if len(args) != 1 || err != nil || l.c.ExecTimeout < 0 {
+			l.errorf(pos, "exec-timeout needs a duration, such as 30s")
+		}
+
+	case "exec-limit":
+		var err error
+		if len(args) == 1 {
+			l.c.ExecLimit, err = strconv.Atoi(args[0])
+		}
+		if len(args) != 1 || err != nil || l.c.ExecLimit < 0 {
+			l.errorf(pos, "exec-limit needs a number")
+		}
+
+	case "tapping-term":
+		var err error
+		if len(args) == 1 {
+			l.c.TappingTerm, err = time.ParseDuration(args[0])
+		}
+		if len(args) != 1 || err != nil || l.c.TappingTerm < 0 {
+			l.errorf(pos, "tapping-term needs a duration, such as 200ms")
+		}
+
+	case "toggle":
+		if len(args) != 1 {
+			l.errorf(pos, "toggle needs a combo, or none")
+			return
+		}
+		if args[0] == "none" {
+			l.c.Toggle = Combo{}
+			return
+		}
+		combo, err := ParseCombo(args[0])
+		if err != nil {
+			l.errorf(pos, "%s", strings.TrimPrefix(err.Error(), "kbd: "))
+			return
+		}
+		l.c.Toggle = combo
+
+	case "plugin":
+		if len(args) < 2 || strings.Contains(args[0], ".") {
+			l.errorf(pos, "plugin needs a name (without dots) and a command")
+			return
+		}
+		l.c.Plugins[args[0]] = args[1:]
+
+	case "profile":
+		if len(args) != 1 {
+			l.errorf(pos, "profile needs one name")
+			return
+		}
+		l.c.Profile = args[0]
+		l.profPos = pos
+
+	case "bind":
+		if l.section == nil {
+			l.errorf(pos, "bind outside of a [profile] or [app] section")
+			return
+		}
+		if len(args) < 2 {
+			l.errorf(pos, "bind needs a combo and an action")
+			return
+		}
+		combo, err := ParseCombo(args[0])
+		if err != nil {
+			l.errorf(pos, "%s", strings.TrimPrefix(err.Error(), "kbd: "))
+			return
+		}
+		spec := BindingSpec{Combo: combo, Pos: pos}
+		first := 1 // word of the action in args
+		for ; first < len(args) && strings.Contains(args[first], "="); first++ {
+			if !l.bindOption(pos, &spec, args[first]) {
+				return
+			}
+		}
+		if spec.RepeatDelay > 0 && spec.Repeat == 0 {
+			l.errorf(pos, "repeat-delay needs repeat")
+			return
+		}
+		// The options are dropped, leaving the combo and the action.
+		args = append(args[:1:1], args[first:]...)
+		if len(args) < 2 {
+			l.errorf(pos, "bind needs a combo and an action")
+			return
+		}
+		if strings.Contains(args[1], ".") { // a plugin's action, checked in validate
+			spec.Action = args[1:]
+			l.section.Bindings = append(l.section.Bindings, spec)
+			return
+		}
+		n, ok := configActions[args[1]]
+		if !ok {
+			l.errorf(pos, "unknown action %q", args[1])
+			return
+		}
+		if (n >= 0 && len(args)-2 != n) || (n < 0 && len(args) < 3) {
+			l.errorf(pos, "wrong number of arguments for action %q", args[1])
+			return
+		}
+		if args[1] == "signal" {
+			if _, err := ParseSignal(args[3]); err != nil {
+				l.errorf(pos, "%s", strings.TrimPrefix(err.Error(), "kbd: "))
+				return
+			}
+			if pid, err := strconv.Atoi(args[2]); err == nil && pid <= 0 {
+				l.errorf(pos, "signal needs a positive pid, not %d", pid)
+				return
+			}
+		}
+		if args[1] == "brightness" {
+			if _, err := strconv.Atoi(args[2]); err != nil {
+				l.errorf(pos, "brightness needs a percentage, not %q", args[2])
+				return
+			}
+		}
+		spec.Action = args[1:]
+		if args[1] == "exec" { // passed to the shell as written
+			spec.Action = []string{"exec", line.rest(first + 2)}
+		}
+		l.section.Bindings = append(l.section.Bindings, spec)
+
+	case "remap":
+		if l.section == nil {
+			l.errorf(pos, "remap outside of a [profile] or [app] section")
+			return
+		}
+		if len(args) != 2 {
+			l.errorf(pos, "remap needs two keys")
+			return
+		}
+		from, ok1 := lookupKey(strings.ToLower(args[0]))
+		to, ok2 := lookupKey(strings.ToLower(args[1]))
+		if !ok1 || !ok2 || from > keyMax || to > keyMax {
+			l.errorf(pos, "remap needs two keys, not %q and %q", args[0], args[1])
+			return
+		}
+		l.section.Remaps[from] = to
+
+	case "dual":
+		if l.section == nil {
+			l.errorf(pos, "dual outside of a [profile] or [app] section")
+			return
+		}
+		if len(args) != 3 {
+			l.errorf(pos, "dual needs a key, a key for taps and a key for holds")
+			return
+		}
+		var keys [3]KeyCode
+		for i, arg := range args {
+			key, ok := lookupKey(strings.ToLower(arg))
+			if ok && key > keyMax && i > 0 {
+				key = Members(key)[0] // "ctrl" holds the left Ctrl
+			}
+			if !ok || key > keyMax {
+				l.errorf(pos, "unknown key %q", arg)
+				return
+			}
+			keys[i] = key
+		}
+		l.section.Duals[keys[0]] = DualKey{Tap: keys[1], Hold: keys[2]}
+
+	default:
+		l.errorf(pos, "unknown directive %q", fields[0])
+	}
+}
+
+// bindOption sets the option of a binding written as NAME=VALUE in spec,
+// and reports whether it is valid.
+func (l *configLoader) bindOption(pos ConfigPos, spec *BindingSpec, option string) bool {
+	kv := strings.SplitN(option, "=", 2)
+	var d *time.Duration
+	switch kv[0] {
+	case "cooldown":
+		d = &spec.Cooldown
+	case "repeat":
+		d = &spec.Repeat
+	case "repeat-delay":
+		d = &spec.RepeatDelay
+	default:
+		l.errorf(pos, "unknown binding option %q", kv[0])
+		return false
+	}
+	v, err := time.ParseDuration(kv[1])
+	if err != nil || v <= 0 {
+		l.errorf(pos, "%s needs a positive duration, such as 200ms", kv[0])
+		return false
+	}
+	*d = v
+	return true
+}
+
+// sectionHeader handles a "[kind name]" line.
+func (l *configLoader) sectionHeader(pos ConfigPos, header string) {
+	if !strings.HasSuffix(header, "]") {
+		l.errorf(pos, "malformed section header %q", header)
+		return
+	}
+	fields := strings.Fields(header[1 : len(header)-1])
+	if len(fields) != 2 {
+		l.errorf(pos, "section header needs a kind and a name, as in [profile default]")
+		return
+	}
+
+	var sections map[string]*Profile
+	switch fields[0] {
+	case "profile":
+		sections = l.c.Profiles
+	case "app":
+		sections = l.c.Apps
+	default:
+		l.errorf(pos, "unknown section kind %q", fields[0])
+		return
+	}
+	p, ok := sections[fields[1]]
+	if !ok { // sections of the same name are merged
+		p = &Profile{Name: fields[1], Remaps: map[KeyCode]KeyCode{}, Duals: map[KeyCode]DualKey{}}
+		sections[fields[1]] = p
+	}
+	l.section = p
+}
+
+// validate checks references between parts of the config.
+func (l *configLoader) validate() {
+	if l.c.Profile != "" && l.c.Profiles[l.c.Profile] == nil {
+		l.errorf(l.profPos, "no [profile %s] section", l.c.Profile)
+	}
+	for dev, name := range l.c.DeviceProfiles {
+		if l.c.Profiles[name] == nil {
+			l.errorf(l.devProfPos[dev], "no [profile %s] section", name)
+		}
+	}
+	for _, sections := range []map[string]*Profile{l.c.Profiles, l.c.Apps} {
+		for _, p := range sections {
+			for _, b := range p.Bindings {
+				if b.Action[0] == "profile" && l.c.Profiles[b.Action[1]] == nil {
+					l.errorf(b.Pos, "no [profile %s] section", b.Action[1])
+				}
+				name := strings.SplitN(b.Action[0], ".", 2)[0]
+				if name != b.Action[0] && l.c.Plugins[name] == nil {
+					l.errorf(b.Pos, "no plugin %q", name)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package kbd
+
+import "time"
+
+// BindOption sets an option of a Binding when it is bound.
+type BindOption func(*Binding)
+
+// WithCooldown sets the Cooldown of a Binding: the least time between runs
+// of its Action.
+func WithCooldown(d time.Duration) BindOption {
+	return func(b *Binding) { b.Cooldown = d }
+}
+
+// WithRepeat sets the Repeat and RepeatDelay of a Binding, so that its
+// Action runs again every interval while its Combo is held, starting delay
+// (or interval, if 0) after it is pressed.
+func WithRepeat(interval, delay time.Duration) BindOption {
+	return func(b *Binding) { b.Repeat, b.RepeatDelay = interval, delay }
+}
+
+// withOptions applies opts to b, which isn't bound yet, and returns it.
+func withOptions(b *Binding, opts []BindOption) *Binding {
+	for _, opt := range opts {
+		opt(b)
+	}
+	return b
+}
+
+// cool reports whether b is past its Cooldown, and if so records that it
+// runs now.
+func (h *Hotkeys) cool(b *Binding) bool {
+	if b.Cooldown <= 0 {
+		return true
+	}
+	now := time.Now()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if !b.last.IsZero() && now.Sub(b.last) < b.Cooldown {
+		return false
+	}
+	b.last = now
+	return true
+}
+
+// repeat runs b's Action every b.Repeat while its Combo is held on kb,
+// unless it is already repeating. Repeating stops if h is suppressed.
+func (h *Hotkeys) repeat(kb *Keyboard, b *Binding) {
+	h.mu.Lock()
+	if h.repeating[b] {
+		h.mu.Unlock()
+		return
+	}
+	if h.repeating == nil {
+		h.repeating = map[*Binding]bool{}
+	}
+	h.repeating[b] = true
+	h.mu.Unlock()
+
+	delay := b.RepeatDelay
+	if delay <= 0 {
+		delay = b.Repeat
+	}
+	go func() {
+		defer func() {
+			h.mu.Lock()
+			delete(h.repeating, b)
+			h.mu.Unlock()
+		}()
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		for range timer.C {
+			kb.mu.Lock()
+			ended := kb.closed
+			kb.mu.Unlock()
+			if ended || !b.Combo.Down(kb) || !h.bound(b) || h.Suppressed() {
+				return
+			}
+			h.run([]*Binding{b})
+			timer.Reset(b.Repeat)
+		}
+	}()
+}
+
+// bound reports whether b is still bound in h.
+func (h *Hotkeys) bound(b *Binding) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, x := range h.bindings {
+		if x == b {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,182 @@
+package kbd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrNoLayout is returned by DetectLayout when no layout can be found.
+var ErrNoLayout = errors.New("kbd: can't detect the keyboard layout")
+
+// layoutSources find the session's keyboard layout, in order of preference.
+var layoutSources = []func() string{
+	func() string { return os.Getenv("XKB_DEFAULT_LAYOUT") }, // Wayland compositors
+	gnomeLayout,
+	x11Layout,
+	func() string { return fileValue("/etc/default/keyboard", `^XKBLAYOUT="?([^"]*)"?`) },
+	func() string { return fileValue("/etc/X11/xorg.conf.d/00-keyboard.conf", `"XkbLayout"\s+"([^"]*)"`) },
+	func() string { return fileValue("/etc/vconsole.conf", `^KEYMAP="?([a-z]+)`) },
+}
+
+// DetectLayout returns the XKB name, such as "us" or "de", of the active
+// keyboard layout. It is found from the graphical session (from Wayland's
+// XKB_DEFAULT_LAYOUT, GNOME's input sources, or X11 with `setxkbmap`), or
+// else from the system's configuration as used on the console. If several
+// layouts are configured, the first is returned.
+func DetectLayout() (string, error) {
+	for _, source := range layoutSources {
+		if layout := firstLayout(source()); layout != "" {
+			return layout, nil
+		}
+	}
+	return "", ErrNoLayout
+}
+
+// DetectKeymap returns the Keymap for the layout found by DetectLayout.
+func DetectKeymap() (*Keymap, error) {
+	layout, err := DetectLayout()
+	if err != nil {
+		return nil, err
+	}
+	if m := LookupKeymap(layout); m != nil {
+		return m, nil
+	}
+	return nil, fmt.Errorf("kbd: no Keymap for layout %q", layout)
+}
+
+var defaultKeymap struct {
+	once sync.Once
+	m    *Keymap
+}
+
+// DefaultKeymap returns the Keymap used when none is given, such as by
+// ScanDecoder and Combo.DisplayString. It starts out as the Keymap found by
+// DetectKeymap, or KeymapUS if none is found, and can be changed with Switch
+// or WatchLayout.
+func DefaultKeymap() *Keymap {
+	defaultKeymap.once.Do(func() {
+		detected, err := DetectKeymap()
+		if err != nil {
+			detected = KeymapUS
+		}
+		m := &Keymap{}
+		m.Switch(detected.Name)
+		defaultKeymap.m = m
+	})
+	return defaultKeymap.m
+}
+
+// LayoutChanged is sent by WatchLayout when the keyboard layout changes.
+type LayoutChanged struct {
+	Old, New string // XKB layout names
+}
+
+// WatchLayout checks the session's keyboard layout with DetectLayout every
+// interval until stop is closed. When it changes, DefaultKeymap() is switched
+// to the new layout (if there is a Keymap for it) and a LayoutChanged is sent
+// on the returned channel, which is closed once stop is. Changes are dropped
+// if they are not received before the next one.
+//
+// Layouts toggled within an X11 layout list (such as "us,de" with a group
+// switching hotkey) can't be seen, as `setxkbmap` reports only the list.
+func WatchLayout(interval time.Duration, stop <-chan struct{}) <-chan LayoutChanged {
+	changes := make(chan LayoutChanged, 1)
+	go func() {
+		defer close(changes)
+		current := DefaultKeymap().Layout()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+			layout, err := DetectLayout()
+			if err != nil || layout == current {
+				continue
+			}
+			DefaultKeymap().Switch(layout) // unknown layouts keep the old Keymap
+			change := LayoutChanged{Old: current, New: layout}
+			current = layout
+			select { // non-blocking channel recieve to "drain" channel
+			case <-changes:
+			default:
+			}
+			changes <- change
+		}
+	}()
+	return changes
+}
+
+// firstLayout returns the first layout in an XKB layout list such as
+// "de(nodeadkeys),us", without its variant.
+func firstLayout(layouts string) string {
+	layout := strings.TrimSpace(strings.Split(layouts, ",")[0])
+	if i := strings.IndexByte(layout, '('); i >= 0 {
+		layout = layout[:i]
+	}
+	return layout
+}
+
+// gnomeLayout reads the current one of GNOME's input sources, which is the
+// first of the most recently used.
+func gnomeLayout() string {
+	if !strings.Contains(os.Getenv("XDG_CURRENT_DESKTOP"), "GNOME") {
+		return ""
+	}
+	out, err := exec.Command("gsettings", "get", "org.gnome.desktop.input-sources", "mru-sources").Output()
+	if err != nil || !strings.Contains(string(out), "xkb") {
+		out, err = exec.Command("gsettings", "get", "org.gnome.desktop.input-sources", "sources").Output()
+	}
+	if err != nil {
+		return ""
+	}
+	// The output looks like: [('xkb', 'de'), ('xkb', 'us')]
+	m := regexp.MustCompile(`\('xkb', '([^']*)'\)`).FindSubmatch(out)
+	if m == nil {
+		return ""
+	}
+	return strings.Replace(string(m[1]), "+", "(", 1) // "de+nodeadkeys"
+}
+
+// x11Layout asks the X server for its layout.
+func x11Layout() string {
+	if os.Getenv("DISPLAY") == "" {
+		return ""
+	}
+	out, err := exec.Command("setxkbmap", "-query").Output()
+	if err != nil {
+		return ""
+	}
+	return matchLines(strings.NewReader(string(out)), `^layout:\s*(\S+)`)
+}
+
+// fileValue returns the first submatch of pattern in the file at path.
+func fileValue(path, pattern string) string {
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	return matchLines(f, pattern)
+}
+
+func matchLines(r io.Reader, pattern string) string {
+	re := regexp.MustCompile(pattern)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if m := re.FindStringSubmatch(strings.TrimSpace(scanner.Text())); m != nil {
+			return m[1]
+		}
+	}
+	return ""
+}
@@ -0,0 +1,66 @@
+package kbd
+
+import "os"
+
+func init() {
+	RegisterBackend("dial", func(path string) (Backend, error) {
+		return OpenDial(path, KeyVOLUMEUP, KeyVOLUMEDOWN)
+	})
+}
+
+// Dial is a Backend reading a knob, such as a USB volume knob or the dial of
+// a macro pad, from its evdev device. Each detent the knob is turned
+// (REL_DIAL, or REL_WHEEL for knobs that pose as a mouse wheel) is read as a
+// press and release of Up or Down, so that knobs work with the same Hotkeys
+// and Remappers as keys. The knob's own keys, such as a button pressed by
+// pushing it, are read as they are. The "dial" Backend opens devices with
+// the volume keys as Up and Down.
+type Dial struct {
+	Up, Down KeyCode // keys for turning clockwise (or up), and back
+
+	d       *evdev
+	pending []Event
+}
+
+// OpenDial opens the knob device at path, with up and down as its keys.
+func OpenDial(path string, up, down KeyCode) (*Dial, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Dial{Up: up, Down: down, d: &evdev{file: f}}, nil
+}
+
+func (d *Dial) ReadEvent() (Event, error) {
+	for len(d.pending) == 0 {
+		raw, err := d.d.read()
+		if err != nil {
+			return Event{}, err
+		}
+		switch {
+		case raw.Kind == eventKEY:
+			return d.d.event(raw), nil
+		case raw.Kind == eventREL && (raw.Code == relDial || raw.Code == relWheel):
+			n := int32(raw.Value)
+			key := d.Up
+			if n < 0 {
+				n, key = -n, d.Down
+			}
+			event := d.d.event(raw)
+			event.Code = key
+			for ; n > 0; n-- {
+				event.Value = Press
+				d.pending = append(d.pending, event)
+				event.Value = Release
+				d.pending = append(d.pending, event)
+			}
+		}
+	}
+	event := d.pending[0]
+	d.pending = d.pending[1:]
+	return event, nil
+}
+
+func (d *Dial) Close() error {
+	return d.d.Close()
+}
@@ -0,0 +1,215 @@
+package kbd
+
+import (
+	"encoding/binary"
+	"io"
+	"os"
+	"time"
+)
+
+func init() {
+	RegisterBackend("evdev", openEvdev)
+}
+
+type inputEvent struct {
+	Sec   int64
+	Usec  int64
+	Kind  uint16
+	Code  uint16
+	Value uint32
+}
+
+// longSize is the size of a C long, and of the kernel's __kernel_ulong_t.
+const longSize = 4 << (^uint(0) >> 63)
+
+// inputEventSize is the size of an input_event read from an evdev device.
+// Its time is two longs: 4 bytes each on 32-bit ABIs, 8 on 64-bit ones.
+const inputEventSize = 2*longSize + 8
+
+// decodeInputEvent decodes the input_event at the start of b. On 32-bit ABIs
+// the seconds are read as unsigned, as the kernel writes them since 5.0, so
+// that times after 2038 decode correctly.
+func decodeInputEvent(b []byte) inputEvent {
+	return decodeInputEventLong(b, longSize)
+}
+
+// decodeInputEventLong decodes the input_event at the start of b, for an ABI
+// whose longs are long bytes.
+func decodeInputEventLong(b []byte, long int) inputEvent {
+	var raw inputEvent
+	if long == 8 {
+		raw.Sec = int64(binary.LittleEndian.Uint64(b[0:]))
+		raw.Usec = int64(binary.LittleEndian.Uint64(b[8:]))
+	} else {
+		raw.Sec = int64(binary.LittleEndian.Uint32(b[0:]))
+		raw.Usec = int64(binary.LittleEndian.Uint32(b[4:]))
+	}
+	b = b[2*long:]
+	raw.Kind = binary.LittleEndian.Uint16(b[0:])
+	raw.Code = binary.LittleEndian.Uint16(b[2:])
+	raw.Value = binary.LittleEndian.Uint32(b[4:])
+	return raw
+}
+
+// encode encodes raw as an input_event at the start of b.
+func (raw inputEvent) encode(b []byte) {
+	raw.encodeLong(b, longSize)
+}
+
+// encodeLong encodes raw as an input_event at the start of b, for an ABI
+// whose longs are long bytes.
+func (raw inputEvent) encodeLong(b []byte, long int) {
+	if long == 8 {
+		binary.LittleEndian.PutUint64(b[0:], uint64(raw.Sec))
+		binary.LittleEndian.PutUint64(b[8:], uint64(raw.Usec))
+	} else {
+		binary.LittleEndian.PutUint32(b[0:], uint32(raw.Sec))
+		binary.LittleEndian.PutUint32(b[4:], uint32(raw.Usec))
+	}
+	b = b[2*long:]
+	binary.LittleEndian.PutUint16(b[0:], raw.Kind)
+	binary.LittleEndian.PutUint16(b[2:], raw.Code)
+	binary.LittleEndian.PutUint32(b[4:], raw.Value)
+}
+
+// evdev reads key events from a device file in `/dev/input/`.
+type evdev struct {
+	file *os.File
+
+	frame   Frame            // frame being read, kept if a read times out
+	dropped bool             // frame is being discarded after SYN_DROPPED
+	down    map[KeyCode]bool // keys held, as of the frames returned
+}
+
+func openEvdev(path string) (Backend, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	maskEvents(f.Fd(), nil) // only a saving, so errors don't matter
+	d := &evdev{file: f, down: map[KeyCode]bool{}}
+	keys, _ := d.KeyState() // keys held already, for resync
+	for _, key := range keys {
+		d.down[key] = true
+	}
+	return d, nil
+}
+
+func (d *evdev) ReadEvent() (Event, error) {
+	for {
+		raw, err := d.read()
+		if err != nil {
+			return Event{}, err
+		}
+		if raw.Kind == eventKEY { // ignore everything but key events
+			return d.event(raw), nil
+		}
+	}
+}
+
+// ReadFrame reads the key events up to the next SYN_REPORT. If the kernel
+// reports that events were dropped (SYN_DROPPED), the incomplete frame is
+// discarded, and once the drop ends the keys held are read with EVIOCGKEY:
+// the next frame has Synthetic presses and releases of the keys whose state
+// changed in the events dropped.
+func (d *evdev) ReadFrame() (Frame, error) {
+	for {
+		raw, err := d.read()
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case raw.Kind == eventSYN && raw.Code == synDropped:
+			d.dropped = true
+			d.frame = d.frame[:0]
+			kernelDrops.inc()
+		case raw.Kind == eventSYN && raw.Code == synReport:
+			frame := d.frame
+			d.frame = nil
+			if d.dropped {
+				d.dropped = false
+				frame = d.resync()
+			}
+			if len(frame) > 0 {
+				d.track(frame)
+				return frame, nil
+			}
+		case raw.Kind == eventKEY && !d.dropped:
+			d.frame = append(d.frame, d.event(raw))
+		}
+	}
+}
+
+// track notes the keys held after frame.
+func (d *evdev) track(frame Frame) {
+	if d.down == nil {
+		d.down = map[KeyCode]bool{}
+	}
+	trackDown(d.down, frame)
+}
+
+// resync returns a frame of Synthetic events bringing the keys held, as of
+// the frames returned, up to date with the device, after events were
+// dropped.
+func (d *evdev) resync() Frame {
+	keys, err := d.KeyState()
+	if err != nil {
+		return nil // the next read fails too, if the device is gone
+	}
+	return resyncFrame(d.down, keys, d.file.Name())
+}
+
+// trackDown updates down, the keys held on a device, with frame.
+func trackDown(down map[KeyCode]bool, frame Frame) {
+	for _, event := range frame {
+		if event.Value == Release {
+			delete(down, event.Code)
+		} else {
+			down[event.Code] = true
+		}
+	}
+}
+
+// resyncFrame returns a frame of Synthetic events of device changing the keys
+// held from down to keys, read after the kernel dropped events.
+func resyncFrame(down map[KeyCode]bool, keys []KeyCode, device string) Frame {
+	now := time.Now()
+	held := map[KeyCode]bool{}
+	var frame Frame
+	for _, key := range keys {
+		held[key] = true
+		if !down[key] {
+			frame = append(frame, Event{Time: now, Code: key, Value: Press, Device: device, Synthetic: true})
+		}
+	}
+	for key := range down {
+		if !held[key] {
+			frame = append(frame, Event{Time: now, Code: key, Value: Release, Device: device, Synthetic: true})
+		}
+	}
+	return frame
+}
+
+func (d *evdev) read() (inputEvent, error) {
+	var b [inputEventSize]byte
+	if _, err := io.ReadFull(d.file, b[:]); err != nil {
+		return inputEvent{}, err
+	}
+	return decodeInputEvent(b[:]), nil
+}
+
+func (d *evdev) event(raw inputEvent) Event {
+	tv := Timeval{Sec: raw.Sec, Usec: raw.Usec}
+	return Event{
+		Time:    tv.Time(),
+		Timeval: tv,
+		Code:    KeyCode(raw.Code),
+		Value:   int32(raw.Value),
+		Device:  d.file.Name(),
+	}
+}
+
+func (d *evdev) Close() error {
+	panicGrabbed(d, 0, false)
+	return d.file.Close()
+}
@@ -0,0 +1,67 @@
+package kbd
+
+import (
+	"bytes"
+	"testing"
+)
+
+// inputEventFixtures are input_events as the kernel writes them, on 32-bit
+// and 64-bit ABIs: KEY_A (30) pressed at 2 seconds and 3 microseconds, and a
+// key released at 0xFFFFFFF0 seconds, after 2038, which 32-bit ABIs write as
+// an unsigned long.
+var inputEventFixtures = []struct {
+	long int
+	raw  inputEvent
+	b    []byte
+}{
+	{4, inputEvent{Sec: 2, Usec: 3, Kind: 1, Code: 30, Value: 1}, []byte{
+		0x02, 0x00, 0x00, 0x00,
+		0x03, 0x00, 0x00, 0x00,
+		0x01, 0x00, 0x1e, 0x00, 0x01, 0x00, 0x00, 0x00,
+	}},
+	{4, inputEvent{Sec: 0xFFFFFFF0, Usec: 999999, Kind: 1, Code: 0x2a, Value: 0}, []byte{
+		0xf0, 0xff, 0xff, 0xff,
+		0x3f, 0x42, 0x0f, 0x00,
+		0x01, 0x00, 0x2a, 0x00, 0x00, 0x00, 0x00, 0x00,
+	}},
+	{8, inputEvent{Sec: 2, Usec: 3, Kind: 1, Code: 30, Value: 1}, []byte{
+		0x02, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x03, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x01, 0x00, 0x1e, 0x00, 0x01, 0x00, 0x00, 0x00,
+	}},
+	{8, inputEvent{Sec: 0xFFFFFFF0, Usec: 999999, Kind: 1, Code: 0x2a, Value: 0}, []byte{
+		0xf0, 0xff, 0xff, 0xff, 0x00, 0x00, 0x00, 0x00,
+		0x3f, 0x42, 0x0f, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x01, 0x00, 0x2a, 0x00, 0x00, 0x00, 0x00, 0x00,
+	}},
+}
+
+func TestDecodeInputEvent(t *testing.T) {
+	for _, f := range inputEventFixtures {
+		if got := decodeInputEventLong(f.b, f.long); got != f.raw {
+			t.Errorf("%d-byte longs: decoded % x as %+v, want %+v", f.long, f.b, got, f.raw)
+		}
+		if f.long == longSize {
+			if got := decodeInputEvent(f.b); got != f.raw {
+				t.Errorf("decoded % x as %+v, want %+v", f.b, got, f.raw)
+			}
+		}
+	}
+}
+
+func TestEncodeInputEvent(t *testing.T) {
+	for _, f := range inputEventFixtures {
+		b := make([]byte, len(f.b))
+		f.raw.encodeLong(b, f.long)
+		if !bytes.Equal(b, f.b) {
+			t.Errorf("%d-byte longs: encoded %+v as % x, want % x", f.long, f.raw, b, f.b)
+		}
+		if f.long == longSize {
+			b := make([]byte, inputEventSize)
+			f.raw.encode(b)
+			if !bytes.Equal(b, f.b) {
+				t.Errorf("encoded %+v as % x, want % x", f.raw, b, f.b)
+			}
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package kbd
+
+import "time"
+
+// ReadKey waits up to timeout (forever if 0) for a key to be pressed on kb,
+// which must be started, and returns it. ErrTimeout is returned if no key is
+// pressed in time, and ErrStopped if kb stops. It reads the key events
+// applied while it waits, so a key tapped quickly is returned too, even
+// though it is already released.
+func (kb *Keyboard) ReadKey(timeout time.Duration) (KeyCode, error) {
+	sub := kb.Subscribe(16, DropNewest)
+	defer sub.Close()
+	event, err := readPress(sub, timeout)
+	return event.Code, err
+}
+
+// readPress waits up to timeout (forever if 0) for a key press on sub, and
+// returns it. Errors are as for ReadKey.
+func readPress(sub *Subscription, timeout time.Duration) (Event, error) {
+	var deadline <-chan time.Time
+	if timeout > 0 {
+		t := time.NewTimer(timeout)
+		defer t.Stop()
+		deadline = t.C
+	}
+
+	for {
+		select {
+		case frame, ok := <-sub.C:
+			if !ok {
+				return Event{}, ErrStopped
+			}
+			for _, event := range frame {
+				if event.Value == Press {
+					return event, nil
+				}
+			}
+		case <-deadline:
+			return Event{}, ErrTimeout
+		}
+	}
+}
+
+// WaitFor waits up to timeout (forever if 0) for one of combos to be pressed
+// on kb, and returns the one pressed. Other keys are ignored. Errors are as
+// for ReadKey.
+func (kb *Keyboard) WaitFor(timeout time.Duration, combos ...Combo) (Combo, error) {
+	sub := kb.Subscribe(16, DropNewest)
+	defer sub.Close()
+	var end time.Time
+	if timeout > 0 {
+		end = time.Now().Add(timeout)
+	}
+	for {
+		var left time.Duration
+		if timeout > 0 {
+			if left = time.Until(end); left <= 0 {
+				return Combo{}, ErrTimeout
+			}
+		}
+		event, err := readPress(sub, left)
+		if err != nil {
+			return Combo{}, err
+		}
+		for _, c := range combos {
+			if Matches(c.Key, event.Code) && c.modsHeld(kb.IsDown) {
+				return c, nil
+			}
+		}
+	}
+}
+
+// Expect scripts interactive flows, in the manner of the expect tool: it
+// waits for keys to be pressed on a Keyboard and types responses on a Virtual
+// keyboard. It is meant for kiosk provisioning and for acceptance tests of
+// terminal programs, for example:
+//
+//	e := &kbd.Expect{Keyboard: kb, Virtual: v, Timeout: time.Minute}
+//	err := e.Run(
+//		kbd.ExpectStep{When: "enter", Type: "admin\n"},
+//		kbd.ExpectStep{When: "ctrl+y", Type: "yes\n"},
+//	)
+type Expect struct {
+	Keyboard *Keyboard
+	Virtual  *Virtual
+	Timeout  time.Duration  // maximum wait for each step; unlimited if 0
+	Profile  *TypingProfile // how to type responses; as fast as possible if nil
+}
+
+// ExpectStep is a step run by Expect.Run.
+type ExpectStep struct {
+	When string // combo to wait for, as for ParseCombo; don't wait if empty
+	Type string // text to type once it is pressed
+}
+
+// Run runs steps in order. It stops at the first error, such as ErrTimeout if
+// a combo isn't pressed within Timeout.
+func (e *Expect) Run(steps ...ExpectStep) error {
+	for _, step := range steps {
+		if step.When != "" {
+			c, err := ParseCombo(step.When)
+			if err != nil {
+				return err
+			}
+			if err := e.Expect(c); err != nil {
+				return err
+			}
+		}
+		if err := e.Send(step.Type); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Expect waits for c to be pressed.
+func (e *Expect) Expect(c Combo) error {
+	_, err := e.Keyboard.WaitFor(e.Timeout, c)
+	return err
+}
+
+// Send types s.
+func (e *Expect) Send(s string) error {
+	if e.Profile != nil {
+		return e.Virtual.TypeWith(s, e.Profile)
+	}
+	return e.Virtual.Type(s)
+}
@@ -0,0 +1,125 @@
+package kbd
+
+import (
+	"errors"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// ErrUnsupported is matched, with errors.Is, by the errors of calls that
+// need a Feature the Backend lacks. Such errors are UnsupportedErrors.
+var ErrUnsupported = errors.New("kbd: not supported by the backend")
+
+// Feature is a set of capabilities that a Backend may have, as reported by
+// Features, so that programs can check for them up front and do without
+// them, rather than fail when they are used.
+type Feature uint
+
+// Features.
+const (
+	FeatureGrab     Feature = 1 << iota // exclusive use of the device (Grabber)
+	FeatureInject                       // keys can be sent to the system with a Virtual keyboard
+	FeatureLocks                        // the lock LEDs can be read (LockReader)
+	FeatureKeyUp                        // release events are reported, not only presses
+	FeatureKeyState                     // the keys held can be read (KeyStateReader)
+	FeatureDeadline                     // reads can time out (Deadliner), as OnPoll needs
+	FeatureMask                         // the kernel can filter events (EventMasker)
+	FeatureFrames                       // events are grouped as reported (FrameReader)
+	FeatureHealth                       // the device can be checked (HealthChecker)
+)
+
+var featureNames = []string{"grab", "inject", "locks", "key-up", "key-state", "deadline", "mask", "frames", "health"}
+
+// String returns the names of the features in f, joined by '|', such as
+// "grab|key-up".
+func (f Feature) String() string {
+	var names []string
+	for i, name := range featureNames {
+		if f&(1<<uint(i)) != 0 {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return "none"
+	}
+	return strings.Join(names, "|")
+}
+
+// FeatureReporter is implemented by Backends that know their features better
+// than Features can tell from the interfaces they implement, such as stages
+// that implement Grab by forwarding it to the Backend they wrap, or Backends
+// that can't report releases.
+type FeatureReporter interface {
+	Features() Feature
+}
+
+// Features returns the features of b: those it reports if it is a
+// FeatureReporter, and otherwise those of the interfaces it implements, and
+// FeatureKeyUp, as most Backends report releases. FeatureInject depends on
+// the system rather than b: it is set if `/dev/uinput` is writable.
+func Features(b Backend) Feature {
+	var f Feature
+	if fr, ok := b.(FeatureReporter); ok {
+		f = fr.Features()
+	} else {
+		f = FeatureKeyUp
+		if _, ok := b.(Grabber); ok {
+			f |= FeatureGrab
+		}
+		if _, ok := b.(LockReader); ok {
+			f |= FeatureLocks
+		}
+		if _, ok := b.(KeyStateReader); ok {
+			f |= FeatureKeyState
+		}
+		if _, ok := b.(Deadliner); ok {
+			f |= FeatureDeadline
+		}
+		if _, ok := b.(EventMasker); ok {
+			f |= FeatureMask
+		}
+		if _, ok := b.(FrameReader); ok {
+			f |= FeatureFrames
+		}
+		if _, ok := b.(HealthChecker); ok {
+			f |= FeatureHealth
+		}
+	}
+	if unix.Access("/dev/uinput", unix.W_OK) == nil {
+		f |= FeatureInject
+	}
+	return f
+}
+
+// Features returns the features of kb's Backend (see Features).
+func (kb *Keyboard) Features() Feature {
+	return Features(kb.backend)
+}
+
+// UnsupportedError is returned by calls that need a Feature the Backend
+// lacks. It matches ErrUnsupported.
+type UnsupportedError struct {
+	Op      string // the call, such as "Grab"
+	Feature Feature
+}
+
+func (e *UnsupportedError) Error() string {
+	return "kbd: " + e.Op + " needs the " + e.Feature.String() + " feature, which the backend lacks"
+}
+
+// Is reports whether target is ErrUnsupported.
+func (e *UnsupportedError) Is(target error) bool {
+	return target == ErrUnsupported
+}
+
+// Grab takes (or releases) exclusive use of kb's devices, so that their
+// events are seen by no other program. It fails with an UnsupportedError if
+// kb's Backend is not a Grabber.
+func (kb *Keyboard) Grab(grab bool) error {
+	g, ok := kb.backend.(Grabber)
+	if !ok {
+		return &UnsupportedError{Op: "Grab", Feature: FeatureGrab}
+	}
+	return g.Grab(grab)
+}
@@ -0,0 +1,165 @@
+package kbd
+
+import (
+	"io"
+	"time"
+)
+
+// Cue is a kind of feedback given by a Feedback stage.
+type Cue int
+
+// Cues.
+const (
+	CuePress Cue = iota // a key was pressed
+	CueError            // something failed, such as a hotkey's Action
+)
+
+func (c Cue) String() string {
+	switch c {
+	case CuePress:
+		return "press"
+	case CueError:
+		return "error"
+	}
+	return "Cue(?)"
+}
+
+// FeedbackSink gives audible, visual or haptic feedback for a Cue. It
+// may take a while, such as the length of a tone; the Feedback stage calls
+// it from its own goroutine.
+type FeedbackSink interface {
+	Cue(c Cue) error
+}
+
+// FeedbackFunc is a FeedbackSink that calls a function.
+type FeedbackFunc func(c Cue) error
+
+// Cue calls f(c).
+func (f FeedbackFunc) Cue(c Cue) error {
+	return f(c)
+}
+
+// Feedback is a Backend that confirms each key press read from another
+// Backend with a Cue to its sinks, such as a tick on the PC speaker, for
+// users who can't see or feel that a key registered. Errors can be cued
+// with Error, for example from a Hotkeys ErrorHandler. Cues are given one
+// at a time in the background, so reading isn't slowed; cues arriving
+// while the last is still being given are dropped, except that an error
+// replaces a pending press. Errors from the sinks are ignored.
+type Feedback struct {
+	b     Backend
+	sinks []FeedbackSink
+	cues  chan Cue
+	done  chan struct{}
+}
+
+// NewFeedback creates a Feedback stage reading from b and giving cues to
+// sinks.
+func NewFeedback(b Backend, sinks ...FeedbackSink) *Feedback {
+	f := &Feedback{
+		b:     b,
+		sinks: sinks,
+		cues:  make(chan Cue, 1),
+		done:  make(chan struct{}),
+	}
+	go f.run()
+	return f
+}
+
+func (f *Feedback) run() {
+	for {
+		select {
+		case c := <-f.cues:
+			for _, sink := range f.sinks {
+				sink.Cue(c)
+			}
+		case <-f.done:
+			return
+		}
+	}
+}
+
+func (f *Feedback) ReadEvent() (Event, error) {
+	event, err := f.b.ReadEvent()
+	if err == nil && event.Value == Press {
+		f.cue(CuePress)
+	}
+	return event, err
+}
+
+// Error cues an error.
+func (f *Feedback) Error() {
+	f.cue(CueError)
+}
+
+func (f *Feedback) cue(c Cue) {
+	if c == CueError {
+		select { // non-blocking channel recieve to "drain" channel
+		case <-f.cues:
+		default:
+		}
+	}
+	select { // non-blocking channel send
+	case f.cues <- c:
+	default:
+	}
+}
+
+// Close stops giving cues and closes the underlying Backend.
+func (f *Feedback) Close() error {
+	close(f.done)
+	return f.b.Close()
+}
+
+// ToneSink plays a short high tick for CuePress and a longer low tone for
+// CueError on a Speaker, or with Beep if Speaker is nil, which falls back
+// to the terminal's bell.
+type ToneSink struct {
+	Speaker *Speaker
+}
+
+func (t ToneSink) Cue(c Cue) error {
+	freq, d := 2000, 5*time.Millisecond
+	if c == CueError {
+		freq, d = 220, 150*time.Millisecond
+	}
+	if t.Speaker == nil {
+		return Beep(freq, d)
+	}
+	return t.Speaker.Beep(freq, d)
+}
+
+// FlashSink flashes a terminal, by briefly reversing its colors, for a
+// visual cue: briefly for CuePress and longer for CueError.
+type FlashSink struct {
+	W io.Writer // terminal to flash
+}
+
+func (fl FlashSink) Cue(c Cue) error {
+	d := 30 * time.Millisecond
+	if c == CueError {
+		d = 200 * time.Millisecond
+	}
+	if _, err := io.WriteString(fl.W, "\x1b[?5h"); err != nil { // DECSCNM: reverse video
+		return err
+	}
+	time.Sleep(d)
+	_, err := io.WriteString(fl.W, "\x1b[?5l")
+	return err
+}
+
+// HapticSink plays Effects for the Cues it has one for.
+type HapticSink struct {
+	Press, Error *Effect
+}
+
+func (h HapticSink) Cue(c Cue) error {
+	e := h.Press
+	if c == CueError {
+		e = h.Error
+	}
+	if e == nil {
+		return nil
+	}
+	return e.Play(1)
+}
@@ -0,0 +1,76 @@
+package kbd
+
+// Frame is a group of key events that a device reported together, between
+// two SYN_REPORT markers. Keys in a frame changed simultaneously, as happens
+// when several keys are pressed at once on an NKRO keyboard.
+type Frame []Event
+
+// Codes for EV_SYN events.
+const (
+	synReport  = 0
+	synDropped = 3
+)
+
+// FrameReader is implemented by Backends that can group their events into
+// Frames. A Keyboard reads frames from such a Backend and applies each one to
+// its key state atomically; events from other Backends are each treated as a
+// frame of their own.
+type FrameReader interface {
+	ReadFrame() (Frame, error)
+}
+
+// frameReader returns a function reading Frames from b.
+func frameReader(b Backend) func() (Frame, error) {
+	if fr, ok := b.(FrameReader); ok {
+		return fr.ReadFrame
+	}
+	return func() (Frame, error) {
+		event, err := b.ReadEvent()
+		if err != nil {
+			return nil, err
+		}
+		return Frame{event}, nil
+	}
+}
+
+// Frames returns a channel from which the most recent Frame of key changes
+// can be obtained, as an alternative to Event(). Key repeats are omitted. Like
+// Event(), it is valid after Start() and a Frame not yet received is replaced
+// by the next one.
+//
+// Frames are delivered in the order they were applied, so the Seq of their
+// events always increases. A gap between the last Seq of one Frame and the
+// first Seq of the next means that Frames in between were replaced before
+// being received; the current key state can then be read with IsDown.
+func (kb *Keyboard) Frames() <-chan Frame {
+	return kb.frames
+}
+
+// Seq returns the Seq of the last event applied to kb's key state, or 0 if
+// none has been. Programs that poll IsDown can compare it between polls to
+// learn how many key changes happened in between.
+func (kb *Keyboard) Seq() uint64 {
+	kb.mu.Lock()
+	defer kb.mu.Unlock()
+	return kb.seq
+}
+
+// sendFrame delivers frame on the frames channel, replacing any Frame that
+// has not yet been received, and to kb's Subscriptions.
+func (kb *Keyboard) sendFrame(frame Frame) {
+	kb.publish(frame)
+	kb.mu.Lock()
+	defer kb.mu.Unlock()
+	if kb.closed {
+		return
+	}
+	select { // non-blocking channel recieve to "drain" channel
+	case <-kb.frames:
+		eventsDropped.inc()
+	default:
+	}
+	select { // non-blocking channel send
+	case kb.frames <- frame:
+	default:
+	}
+}
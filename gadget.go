@@ -0,0 +1,88 @@
+package kbd
+
+import (
+	"bytes"
+	"os"
+)
+
+// HIDGadget writes key state to a USB gadget HID function, such as
+// `/dev/hidg0`, configured as a boot protocol keyboard. With it a device
+// running in USB gadget mode, like a Raspberry Pi Zero, can pass a keyboard
+// through to the USB host it is plugged into.
+type HIDGadget struct {
+	file *os.File
+	last []byte
+}
+
+// OpenHIDGadget opens the gadget HID device at path.
+func OpenHIDGadget(path string) (*HIDGadget, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		return nil, err
+	}
+	return &HIDGadget{file: f}, nil
+}
+
+// Report sends a report to the host with the keys in pressed held down and
+// all others released. If more than 6 non-modifier keys are pressed, the
+// report signals rollover, as a real keyboard would. Keys without a HID usage
+// are ignored. Nothing is sent if the report is the same as the last.
+func (g *HIDGadget) Report(pressed []KeyCode) error {
+	report := bootReport(pressed)
+	if bytes.Equal(report, g.last) {
+		return nil
+	}
+	_, err := g.file.Write(report)
+	if err == nil {
+		g.last = report
+	}
+	return err
+}
+
+// bootReport builds a boot protocol keyboard report with the keys in pressed
+// held down.
+func bootReport(pressed []KeyCode) []byte {
+	report := make([]byte, BootKeyboard.Size)
+	n := 0
+	for _, key := range pressed {
+		usage, ok := hidUsage[key]
+		if !ok {
+			continue
+		}
+		if usage >= 0xe0 && usage <= 0xe7 { // modifier
+			report[0] |= 1 << (usage - 0xe0)
+			continue
+		}
+		if n == BootKeyboard.KeyCount {
+			for i := 0; i < n; i++ {
+				report[BootKeyboard.Keys+i] = hidErrorRollOver
+			}
+			break
+		}
+		report[BootKeyboard.Keys+n] = usage
+		n++
+	}
+	return report
+}
+
+// Forward sends the key state of kb to the host each time it changes, until
+// kb stops or a write fails. kb must already be started. Since each report
+// carries the complete key state, keys are never left stuck on the host even
+// if events are missed.
+func (g *HIDGadget) Forward(kb *Keyboard) error {
+	for range kb.Event() {
+		if err := g.Report(kb.Pressed()); err != nil {
+			return err
+		}
+	}
+	return g.Report(nil) // release everything
+}
+
+// Close releases all keys on the host and closes the device.
+func (g *HIDGadget) Close() error {
+	err := g.Report(nil)
+	if err2 := g.file.Close(); err == nil {
+		err = err2
+	}
+	return err
+}
@@ -0,0 +1,210 @@
+package kbd
+
+import "sort"
+
+// KeyRect is the physical position and size of a key on a Layout, measured in
+// key units: the width of a letter key. X and Y are the top left corner of the
+// key, from the top left corner of the keyboard.
+type KeyRect struct {
+	Code       KeyCode
+	X, Y, W, H float64
+}
+
+// Layout is the physical arrangement of the keys on a keyboard, for drawing
+// keyboard visualizations.
+type Layout struct {
+	Name          string
+	Width, Height float64
+	Keys          []KeyRect
+}
+
+// Key returns the position of code on the layout, and false if the layout
+// doesn't have the key.
+func (l *Layout) Key(code KeyCode) (KeyRect, bool) {
+	for _, k := range l.Keys {
+		if k.Code == code {
+			return k, true
+		}
+	}
+	return KeyRect{}, false
+}
+
+// Highlight returns the position on the layout of each key that is down on
+// kb. Keys that aren't on the layout are omitted.
+func (l *Layout) Highlight(kb *Keyboard) []KeyRect {
+	var rects []KeyRect
+	for _, code := range kb.Pressed() {
+		if k, ok := l.Key(code); ok {
+			rects = append(rects, k)
+		}
+	}
+	return rects
+}
+
+// Pressed returns the keys that are down, in order of KeyCode.
+func (kb *Keyboard) Pressed() []KeyCode {
+	kb.mu.Lock()
+	var keys []KeyCode
+	for key, down := range kb.keys {
+		if down {
+			keys = append(keys, key)
+		}
+	}
+	kb.mu.Unlock()
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	return keys
+}
+
+// keySpec is a key in a row of a layout definition. A code of 0 is a gap.
+type keySpec struct {
+	code KeyCode
+	w, h float64 // size; h of 0 means 1
+}
+
+// keyRow is a row of keys starting at x, y.
+type keyRow struct {
+	x, y float64
+	keys []keySpec
+}
+
+// u returns 1u keys for codes.
+func u(codes ...KeyCode) []keySpec {
+	specs := make([]keySpec, len(codes))
+	for i, code := range codes {
+		specs[i] = keySpec{code: code, w: 1}
+	}
+	return specs
+}
+
+// row joins key specs into one slice.
+func row(parts ...[]keySpec) []keySpec {
+	var specs []keySpec
+	for _, p := range parts {
+		specs = append(specs, p...)
+	}
+	return specs
+}
+
+// buildLayout lays out rows left to right and computes the layout's size.
+func buildLayout(name string, rows []keyRow) *Layout {
+	l := &Layout{Name: name}
+	for _, r := range rows {
+		x := r.x
+		for _, k := range r.keys {
+			h := k.h
+			if h == 0 {
+				h = 1
+			}
+			if k.code != 0 {
+				l.Keys = append(l.Keys, KeyRect{Code: k.code, X: x, Y: r.y, W: k.w, H: h})
+				if x+k.w > l.Width {
+					l.Width = x + k.w
+				}
+				if r.y+h > l.Height {
+					l.Height = r.y + h
+				}
+			}
+			x += k.w
+		}
+	}
+	return l
+}
+
+// Rows of the parts of a keyboard, with the main block starting at y = 1.5.
+var (
+	functionRows = []keyRow{
+		{0, 0, row(u(KeyESC), []keySpec{{w: 1}}, u(KeyF1, KeyF2, KeyF3, KeyF4),
+			[]keySpec{{w: 0.5}}, u(KeyF5, KeyF6, KeyF7, KeyF8),
+			[]keySpec{{w: 0.5}}, u(KeyF9, KeyF10, KeyF11, KeyF12),
+			[]keySpec{{w: 0.25}}, u(KeySYSRQ, KeySCROLLLOCK, KeyPAUSE))},
+	}
+
+	ansiRows = []keyRow{
+		{0, 1.5, row(u(KeyGRAVE, Key1, Key2, Key3, Key4, Key5, Key6, Key7, Key8, Key9, Key0,
+			KeyMINUS, KeyEQUAL), []keySpec{{KeyBACKSPACE, 2, 0}})},
+		{0, 2.5, row([]keySpec{{KeyTAB, 1.5, 0}}, u(KeyQ, KeyW, KeyE, KeyR, KeyT, KeyY, KeyU,
+			KeyI, KeyO, KeyP, KeyLEFTBRACE, KeyRIGHTBRACE), []keySpec{{KeyBACKSLASH, 1.5, 0}})},
+		{0, 3.5, row([]keySpec{{KeyCAPSLOCK, 1.75, 0}}, u(KeyA, KeyS, KeyD, KeyF, KeyG, KeyH,
+			KeyJ, KeyK, KeyL, KeySEMICOLON, KeyAPOSTROPHE), []keySpec{{KeyENTER, 2.25, 0}})},
+		{0, 4.5, row([]keySpec{{KeyLEFTSHIFT, 2.25, 0}}, u(KeyZ, KeyX, KeyC, KeyV, KeyB, KeyN,
+			KeyM, KeyCOMMA, KeyDOT, KeySLASH), []keySpec{{KeyRIGHTSHIFT, 2.75, 0}})},
+		{0, 5.5, []keySpec{{KeyLEFTCTRL, 1.25, 0}, {KeyLEFTMETA, 1.25, 0}, {KeyLEFTALT, 1.25, 0},
+			{KeySPACE, 6.25, 0}, {KeyRIGHTALT, 1.25, 0}, {KeyRIGHTMETA, 1.25, 0},
+			{KeyCOMPOSE, 1.25, 0}, {KeyRIGHTCTRL, 1.25, 0}}},
+	}
+
+	// The ISO Enter key is L shaped; it's approximated by its lower part
+	// extended up through both rows.
+	isoRows = []keyRow{
+		ansiRows[0],
+		{0, 2.5, row([]keySpec{{KeyTAB, 1.5, 0}}, u(KeyQ, KeyW, KeyE, KeyR, KeyT, KeyY, KeyU,
+			KeyI, KeyO, KeyP, KeyLEFTBRACE, KeyRIGHTBRACE), []keySpec{{w: 0.25}, {KeyENTER, 1.25, 2}})},
+		{0, 3.5, row([]keySpec{{KeyCAPSLOCK, 1.75, 0}}, u(KeyA, KeyS, KeyD, KeyF, KeyG, KeyH,
+			KeyJ, KeyK, KeyL, KeySEMICOLON, KeyAPOSTROPHE, KeyBACKSLASH))},
+		{0, 4.5, row([]keySpec{{KeyLEFTSHIFT, 1.25, 0}}, u(Key102ND, KeyZ, KeyX, KeyC, KeyV, KeyB,
+			KeyN, KeyM, KeyCOMMA, KeyDOT, KeySLASH), []keySpec{{KeyRIGHTSHIFT, 2.75, 0}})},
+		ansiRows[4],
+	}
+
+	jisRows = []keyRow{
+		{0, 1.5, u(KeyZENKAKUHANKAKU, Key1, Key2, Key3, Key4, Key5, Key6, Key7, Key8, Key9, Key0,
+			KeyMINUS, KeyEQUAL, KeyYEN, KeyBACKSPACE)},
+		isoRows[1],
+		isoRows[2],
+		{0, 4.5, row([]keySpec{{KeyLEFTSHIFT, 2.25, 0}}, u(KeyZ, KeyX, KeyC, KeyV, KeyB, KeyN,
+			KeyM, KeyCOMMA, KeyDOT, KeySLASH, KeyRO), []keySpec{{KeyRIGHTSHIFT, 1.75, 0}})},
+		{0, 5.5, []keySpec{{KeyLEFTCTRL, 1.25, 0}, {KeyLEFTMETA, 1.25, 0}, {KeyLEFTALT, 1.25, 0},
+			{KeyMUHENKAN, 1.25, 0}, {KeySPACE, 2.5, 0}, {KeyHENKAN, 1.25, 0},
+			{KeyKATAKANAHIRAGANA, 1.25, 0}, {KeyRIGHTALT, 1.25, 0}, {KeyRIGHTMETA, 1.25, 0},
+			{KeyCOMPOSE, 1.25, 0}, {KeyRIGHTCTRL, 1.25, 0}}},
+	}
+
+	navRows = []keyRow{
+		{15.25, 1.5, u(KeyINSERT, KeyHOME, KeyPAGEUP)},
+		{15.25, 2.5, u(KeyDELETE, KeyEND, KeyPAGEDOWN)},
+		{16.25, 4.5, u(KeyUP)},
+		{15.25, 5.5, u(KeyLEFT, KeyDOWN, KeyRIGHT)},
+	}
+
+	numpadRows = []keyRow{
+		{18.5, 1.5, u(KeyNUMLOCK, KeyKPSLASH, KeyKPASTERISK, KeyKPMINUS)},
+		{18.5, 2.5, row(u(KeyKP7, KeyKP8, KeyKP9), []keySpec{{KeyKPPLUS, 1, 2}})},
+		{18.5, 3.5, u(KeyKP4, KeyKP5, KeyKP6)},
+		{18.5, 4.5, row(u(KeyKP1, KeyKP2, KeyKP3), []keySpec{{KeyKPENTER, 1, 2}})},
+		{18.5, 5.5, []keySpec{{KeyKP0, 2, 0}, {KeyKPDOT, 1, 0}}},
+	}
+)
+
+// rows joins groups of rows, moving each up by dy.
+func rows(dy float64, groups ...[]keyRow) []keyRow {
+	var all []keyRow
+	for _, g := range groups {
+		for _, r := range g {
+			r.y -= dy
+			all = append(all, r)
+		}
+	}
+	return all
+}
+
+// Layouts for common keyboards.
+var (
+	LayoutANSI = buildLayout("ansi", rows(0, functionRows, ansiRows, navRows, numpadRows)) // full size (104 key) US
+	LayoutISO  = buildLayout("iso", rows(0, functionRows, isoRows, navRows, numpadRows))   // full size (105 key) European
+	LayoutJIS  = buildLayout("jis", rows(0, functionRows, jisRows, navRows, numpadRows))   // full size (109 key) Japanese
+	LayoutTKL  = buildLayout("tkl", rows(0, functionRows, ansiRows, navRows))              // tenkeyless (87 key) US
+	Layout60   = buildLayout("60", rows(1.5, ansiRows))                                    // 60% (61 key) US
+)
+
+var layouts = []*Layout{LayoutANSI, LayoutISO, LayoutJIS, LayoutTKL, Layout60}
+
+// Geometry returns the Layout with the given name: "ansi", "iso", "jis",
+// "tkl", or "60". It returns nil if there is no such layout.
+func Geometry(name string) *Layout {
+	for _, l := range layouts {
+		if l.Name == name {
+			return l
+		}
+	}
+	return nil
+}
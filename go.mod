@@ -3,6 +3,7 @@ module github.com/quillaja/kbd
 go 1.13
 
 require (
+	github.com/godbus/dbus/v5 v5.1.0
 	github.com/pkg/term v0.0.0-20190109203006-aa71e9d9e942
-	golang.org/x/sys v0.0.0-20191003212358-c178f38b412c // indirect
+	golang.org/x/sys v0.0.0-20191003212358-c178f38b412c
 )
@@ -0,0 +1,172 @@
+package kbd
+
+import (
+	"errors"
+	"os"
+	"time"
+)
+
+// GPIOMatrix describes a key matrix wired to the GPIO lines of a single
+// chip, such as the header of a Raspberry Pi. Rows are read as inputs with
+// pull-ups and columns are driven low one at a time, so each switch should
+// connect a row to a column (with a diode if more than two keys may be held
+// at once).
+type GPIOMatrix struct {
+	Chip     string        // path of the chip, e.g. "/dev/gpiochip0"
+	Rows     []uint32      // line offsets of the rows
+	Cols     []uint32      // line offsets of the columns
+	Keys     [][]KeyCode   // KeyCode for each switch, indexed Keys[row][col]
+	Interval time.Duration // time between scans; 10ms if 0
+}
+
+// GPIO character device uAPI (v1), from "linux/gpio.h".
+const (
+	gpioMaxLines = 64
+
+	gpioHandleRequestInput     = 1 << 0
+	gpioHandleRequestOutput    = 1 << 1
+	gpioHandleRequestActiveLow = 1 << 2
+	gpioHandleRequestOpenDrain = 1 << 3
+	gpioHandleRequestPullUp    = 1 << 5
+
+	gpioGetLineHandleIoctl       = 0xc16cb403
+	gpioHandleGetLineValuesIoctl = 0xc040b408
+	gpioHandleSetLineValuesIoctl = 0xc040b409
+)
+
+type gpioHandleRequest struct {
+	LineOffsets   [gpioMaxLines]uint32
+	Flags         uint32
+	DefaultValues [gpioMaxLines]uint8
+	ConsumerLabel [32]byte
+	Lines         uint32
+	Fd            int32
+}
+
+type gpioHandleData struct {
+	Values [gpioMaxLines]uint8
+}
+
+// gpioMatrix is a Backend that scans a GPIOMatrix.
+type gpioMatrix struct {
+	m       GPIOMatrix
+	rows    *os.File
+	cols    *os.File
+	state   [][]bool
+	pending []Event
+}
+
+// OpenGPIOMatrix requests the lines of m from the GPIO chip and returns a
+// Backend that scans them for key changes. Use New to create a Keyboard
+// from it.
+func OpenGPIOMatrix(m GPIOMatrix) (Backend, error) {
+	if len(m.Rows) == 0 || len(m.Rows) > gpioMaxLines ||
+		len(m.Cols) == 0 || len(m.Cols) > gpioMaxLines {
+		return nil, errors.New("kbd: GPIOMatrix must have 1-64 rows and columns")
+	}
+	if len(m.Keys) != len(m.Rows) {
+		return nil, errors.New("kbd: GPIOMatrix Keys must have a row for each of Rows")
+	}
+	for _, row := range m.Keys {
+		if len(row) != len(m.Cols) {
+			return nil, errors.New("kbd: GPIOMatrix Keys must have a column for each of Cols")
+		}
+	}
+	if m.Interval <= 0 {
+		m.Interval = 10 * time.Millisecond
+	}
+
+	chip, err := os.Open(m.Chip)
+	if err != nil {
+		return nil, err
+	}
+	defer chip.Close()
+
+	g := &gpioMatrix{m: m}
+	g.rows, err = requestLines(chip, m.Rows,
+		gpioHandleRequestInput|gpioHandleRequestActiveLow|gpioHandleRequestPullUp)
+	if err != nil {
+		return nil, err
+	}
+	g.cols, err = requestLines(chip, m.Cols,
+		gpioHandleRequestOutput|gpioHandleRequestActiveLow|gpioHandleRequestOpenDrain)
+	if err != nil {
+		g.rows.Close()
+		return nil, err
+	}
+
+	g.state = make([][]bool, len(m.Rows))
+	for r := range g.state {
+		g.state[r] = make([]bool, len(m.Cols))
+	}
+	return g, nil
+}
+
+// requestLines gets a handle for the lines at offsets from chip.
+func requestLines(chip *os.File, offsets []uint32, flags uint32) (*os.File, error) {
+	req := gpioHandleRequest{
+		Flags: flags,
+		Lines: uint32(len(offsets)),
+	}
+	copy(req.LineOffsets[:], offsets)
+	copy(req.ConsumerLabel[:], "kbd")
+
+	err := ioctlData(chip.Fd(), gpioGetLineHandleIoctl, &req)
+	if err != nil {
+		return nil, err
+	}
+	return os.NewFile(uintptr(req.Fd), chip.Name()), nil
+}
+
+func (g *gpioMatrix) ReadEvent() (Event, error) {
+	for len(g.pending) == 0 {
+		time.Sleep(g.m.Interval)
+		if err := g.scan(); err != nil {
+			return Event{}, err
+		}
+	}
+	event := g.pending[0]
+	g.pending = g.pending[1:]
+	return event, nil
+}
+
+// scan activates each column in turn, reads the rows, and queues an Event for
+// every switch that changed since the last scan.
+func (g *gpioMatrix) scan() error {
+	var cols, rows gpioHandleData
+	for c := range g.m.Cols {
+		cols = gpioHandleData{}
+		cols.Values[c] = 1
+		err := ioctlData(g.cols.Fd(), gpioHandleSetLineValuesIoctl, &cols)
+		if err != nil {
+			return err
+		}
+		err = ioctlData(g.rows.Fd(), gpioHandleGetLineValuesIoctl, &rows)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		for r := range g.m.Rows {
+			down := rows.Values[r] == 1
+			if down == g.state[r][c] {
+				continue
+			}
+			g.state[r][c] = down
+			event := Event{Time: now, Code: g.m.Keys[r][c], Value: Release}
+			if down {
+				event.Value = Press
+			}
+			g.pending = append(g.pending, event)
+		}
+	}
+	return nil
+}
+
+func (g *gpioMatrix) Close() error {
+	err := g.cols.Close()
+	if err2 := g.rows.Close(); err == nil {
+		err = err2
+	}
+	return err
+}
@@ -0,0 +1,53 @@
+package kbd
+
+// keyMax is the highest KeyCode the kernel can report (KEY_MAX).
+const keyMax = 0x2ff
+
+// Key groups are logical keys standing for any of several physical keys, so
+// that either side's modifier, or either Enter key, can be used alike. They
+// are numbered above the kernel's KEY_MAX, so they never appear in events.
+const (
+	AnyShift KeyCode = 0x300 + iota
+	AnyCtrl
+	AnyAlt
+	AnyMeta
+	AnyEnter
+)
+
+var keyGroups = map[KeyCode][]KeyCode{
+	AnyShift: {KeyLEFTSHIFT, KeyRIGHTSHIFT},
+	AnyCtrl:  {KeyLEFTCTRL, KeyRIGHTCTRL},
+	AnyAlt:   {KeyLEFTALT, KeyRIGHTALT},
+	AnyMeta:  {KeyLEFTMETA, KeyRIGHTMETA},
+	AnyEnter: {KeyENTER, KeyKPENTER},
+}
+
+func init() {
+	keyNames[AnyShift] = "SHIFT"
+	keyNames[AnyCtrl] = "CTRL"
+	keyNames[AnyAlt] = "ALT"
+	keyNames[AnyMeta] = "META"
+	keyNames[AnyEnter] = "ANYENTER"
+}
+
+// Members returns the keys in the group key, or just key itself if it isn't
+// a group.
+func Members(key KeyCode) []KeyCode {
+	if members, ok := keyGroups[key]; ok {
+		return members
+	}
+	return []KeyCode{key}
+}
+
+// Matches reports whether key is group, or a member of it.
+func Matches(group, key KeyCode) bool {
+	if group == key {
+		return true
+	}
+	for _, k := range Members(group) {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,168 @@
+package kbd
+
+import (
+	"encoding/binary"
+	"errors"
+	"os"
+	"os/exec"
+	"os/signal"
+
+	"golang.org/x/sys/unix"
+)
+
+// ErrGuardianStarted is returned by StartGuardian if it was already called.
+var ErrGuardianStarted = errors.New("kbd: guardian already started")
+
+// guardianEnv marks the guardian process in its environment.
+const guardianEnv = "KBD_GUARDIAN"
+
+// Messages to the guardian: an operation byte and a little-endian id. Keep
+// messages carry the fd of the grabbed or uinput device.
+const (
+	guardianGrab   = 'g' // keep the grabbed device, and release the grab
+	guardianUinput = 'u' // keep the uinput device, and destroy it
+	guardianForgot = 'f' // close the device kept with the id
+	guardianMsg    = 9
+)
+
+func init() {
+	if os.Getenv(guardianEnv) == "1" {
+		os.Exit(runGuardian(3))
+	}
+}
+
+// StartGuardian starts a helper process, the guardian, that releases the
+// program's grabs and destroys its uinput devices (Virtual and VirtualMouse)
+// when the program exits, however it exits. The kernel does that itself
+// when the last copy of a device's file descriptor is closed, but copies
+// inherited by child processes that outlive the program would keep the
+// keyboard grabbed, and the user locked out, until they exit as well.
+//
+// The guardian is the program itself, started again from `/proc/self/exe`;
+// it never gets to main, as this package's init runs it instead. It holds a
+// copy of each device and waits for the program's end of a socket to be
+// closed, which happens even if the program is killed with SIGKILL. It
+// ignores SIGINT, SIGTERM and SIGHUP, which are usually sent to the whole
+// process group or service, so that it outlives the program.
+//
+// StartGuardian should be called before devices are opened, or at least
+// before Harden, which forbids starting programs. Devices grabbed and
+// created before it is called are handed to the guardian too.
+func StartGuardian() error {
+	panicState.mu.Lock()
+	defer panicState.mu.Unlock()
+	if panicState.guardian >= 0 {
+		return ErrGuardianStarted
+	}
+	fds, err := unix.Socketpair(unix.AF_UNIX, unix.SOCK_SEQPACKET|unix.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return err
+	}
+	theirs := os.NewFile(uintptr(fds[1]), "guardian")
+	defer theirs.Close()
+
+	cmd := exec.Command("/proc/self/exe")
+	cmd.Env = append(os.Environ(), guardianEnv+"=1")
+	cmd.ExtraFiles = []*os.File{theirs} // fd 3
+	if err := cmd.Start(); err != nil {
+		unix.Close(fds[0])
+		return err
+	}
+	go cmd.Wait() // reap it, should it die first
+
+	panicState.guardian = fds[0]
+	for owner, fd := range panicState.grabbed {
+		guardianKeep(owner, fd, guardianGrab)
+	}
+	for owner, fd := range panicState.uinput {
+		guardianKeep(owner, fd, guardianUinput)
+	}
+	return nil
+}
+
+// guardianKeep hands the device fd of owner to the guardian, if there is
+// one, to be undone as op says. panicState.mu must be held.
+func guardianKeep(owner interface{}, fd uintptr, op byte) {
+	if _, ok := panicState.ids[owner]; ok || panicState.guardian < 0 {
+		return
+	}
+	panicState.next++
+	panicState.ids[owner] = panicState.next
+	guardianSend(op, panicState.next, unix.UnixRights(int(fd)))
+}
+
+// guardianForget tells the guardian to close its copy of the device of
+// owner, which the program has released or destroyed itself.
+// panicState.mu must be held.
+func guardianForget(owner interface{}) {
+	id, ok := panicState.ids[owner]
+	if !ok {
+		return
+	}
+	delete(panicState.ids, owner)
+	guardianSend(guardianForgot, id, nil)
+}
+
+// guardianSend sends a message to the guardian. If that fails the guardian
+// is gone, so it is forgotten. panicState.mu must be held.
+func guardianSend(op byte, id uint64, oob []byte) {
+	var msg [guardianMsg]byte
+	msg[0] = op
+	binary.LittleEndian.PutUint64(msg[1:], id)
+	if err := unix.Sendmsg(panicState.guardian, msg[:], oob, nil, 0); err != nil {
+		unix.Close(panicState.guardian)
+		panicState.guardian = -1
+		panicState.ids = map[interface{}]uint64{}
+	}
+}
+
+// runGuardian is the guardian, reading messages from the socket fd until the
+// program closes it, and returns its exit status.
+func runGuardian(fd int) int {
+	signal.Ignore(os.Interrupt, unix.SIGTERM, unix.SIGHUP)
+	type device struct {
+		fd int
+		op byte
+	}
+	devices := map[uint64]device{}
+
+	msg := make([]byte, guardianMsg)
+	oob := make([]byte, unix.CmsgSpace(4))
+	for {
+		n, oobn, _, _, err := unix.Recvmsg(fd, msg, oob, unix.MSG_CMSG_CLOEXEC)
+		if err == unix.EINTR {
+			continue
+		}
+		if err != nil || n == 0 {
+			break // the program is gone
+		}
+		if n != guardianMsg {
+			continue
+		}
+		id := binary.LittleEndian.Uint64(msg[1:])
+		var fds []int
+		if cmsgs, err := unix.ParseSocketControlMessage(oob[:oobn]); err == nil && len(cmsgs) == 1 {
+			fds, _ = unix.ParseUnixRights(&cmsgs[0])
+		}
+		switch {
+		case msg[0] == guardianForgot:
+			if d, ok := devices[id]; ok {
+				unix.Close(d.fd)
+				delete(devices, id)
+			}
+		case len(fds) == 1:
+			devices[id] = device{fds[0], msg[0]}
+		}
+	}
+
+	for _, d := range devices {
+		switch d.op {
+		case guardianGrab:
+			ioctlInt(uintptr(d.fd), eviocGrab, 0)
+		case guardianUinput:
+			ioctlInt(uintptr(d.fd), uiDevDestroy, 0)
+		}
+		unix.Close(d.fd)
+	}
+	return 0
+}
@@ -0,0 +1,140 @@
+package kbd
+
+import (
+	"encoding/binary"
+	"errors"
+	"os"
+	"sync"
+	"time"
+)
+
+// ErrNoRumble is returned by OpenHaptics when the device can't play rumble
+// effects.
+var ErrNoRumble = errors.New("kbd: device has no rumble force feedback")
+
+// ffRumble is FF_RUMBLE, the force feedback effect of a vibration motor.
+const ffRumble = 0x50
+
+// Force feedback ioctls: EVIOCSFF uploads an effect and EVIOCRMFF removes
+// it. EVIOCGBIT(EV_FF) reads the effects supported.
+var (
+	eviocsFF    = ioc(iocWrite, 'E', 0x80, ffEffectSize)
+	eviocrmFF   = ioc(iocWrite, 'E', 0x81, 4)
+	eviocgBitFF = eviocg(0x20+eventFF, (ffMax+8)/8)
+)
+
+// ffMax is FF_MAX, the highest force feedback code.
+const ffMax = 0x7f
+
+// ffEffectSize is the size of struct ff_effect: 16 bytes of header and a
+// union whose largest member, ff_periodic_effect, ends in a pointer.
+const ffEffectSize = 40 + longSize
+
+// Haptics plays rumble effects on a device with a vibration motor, such as
+// a keyboard or gamepad with haptic feedback, for example to confirm a
+// hotkey. It needs write access to the device. Effects are stored in the
+// device, which holds a limited number of them, until they are removed or
+// the Haptics closed.
+type Haptics struct {
+	file *os.File
+
+	mu      sync.Mutex
+	effects map[int16]bool
+}
+
+// Effect is a rumble effect uploaded to a device by Haptics.Rumble.
+type Effect struct {
+	h  *Haptics
+	id int16
+}
+
+// OpenHaptics opens the evdev device at path for playing effects. It fails
+// with ErrNoRumble if the device can't play them.
+func OpenHaptics(path string) (*Haptics, error) {
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	var bits [(ffMax + 8) / 8]byte
+	err = ioctlBytes(f.Fd(), eviocgBitFF, bits[:])
+	if err == nil && bits[ffRumble/8]&(1<<(ffRumble%8)) == 0 {
+		err = ErrNoRumble
+	}
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &Haptics{file: f, effects: map[int16]bool{}}, nil
+}
+
+// Rumble uploads a rumble effect lasting d, with the strength of the strong
+// (low frequency) and weak (high frequency) motors from 0 to 0xffff. Devices
+// with a single motor use whichever they map to it.
+func (h *Haptics) Rumble(strong, weak uint16, d time.Duration) (*Effect, error) {
+	ms := d / time.Millisecond
+	if ms > 0x7fff {
+		ms = 0x7fff
+	}
+
+	// struct ff_effect, with an ff_rumble_effect in its union at offset 16
+	var buf [ffEffectSize]byte
+	binary.LittleEndian.PutUint16(buf[0:], ffRumble) // type
+	binary.LittleEndian.PutUint16(buf[2:], 0xffff)   // id -1: a new effect
+	binary.LittleEndian.PutUint16(buf[10:], uint16(ms))
+	binary.LittleEndian.PutUint16(buf[16:], strong)
+	binary.LittleEndian.PutUint16(buf[18:], weak)
+	if err := ioctlBytes(h.file.Fd(), eviocsFF, buf[:]); err != nil {
+		return nil, err
+	}
+
+	id := int16(binary.LittleEndian.Uint16(buf[2:])) // set by the kernel
+	h.mu.Lock()
+	h.effects[id] = true
+	h.mu.Unlock()
+	return &Effect{h: h, id: id}, nil
+}
+
+// Play plays the effect count times, without waiting for it to end.
+func (e *Effect) Play(count int) error {
+	return e.h.write(e.id, int32(count))
+}
+
+// Stop stops the effect if it is playing.
+func (e *Effect) Stop() error {
+	return e.h.write(e.id, 0)
+}
+
+// Remove removes the effect from the device, freeing its slot.
+func (e *Effect) Remove() error {
+	e.h.mu.Lock()
+	delete(e.h.effects, e.id)
+	e.h.mu.Unlock()
+	return ioctlInt(e.h.file.Fd(), eviocrmFF, uintptr(e.id))
+}
+
+// Action returns an Action that plays the effect once, for use as (or in) a
+// hotkey's Action.
+func (e *Effect) Action() Action {
+	return func() error { return e.Play(1) }
+}
+
+// write writes an EV_FF event for the effect id with value, which plays the
+// effect value times, or stops it if value is 0.
+func (h *Haptics) write(id int16, value int32) error {
+	var buf [inputEventSize]byte
+	tv := TimevalOf(time.Now())
+	inputEvent{Sec: tv.Sec, Usec: tv.Usec, Kind: eventFF, Code: uint16(id), Value: uint32(value)}.encode(buf[:])
+	_, err := h.file.Write(buf[:])
+	return err
+}
+
+// Close removes the effects uploaded and closes the device.
+func (h *Haptics) Close() error {
+	h.mu.Lock()
+	for id := range h.effects {
+		ioctlInt(h.file.Fd(), eviocrmFF, uintptr(id))
+	}
+	h.effects = nil
+	h.mu.Unlock()
+	return h.file.Close()
+}
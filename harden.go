@@ -0,0 +1,140 @@
+package kbd
+
+import (
+	"errors"
+	"runtime"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// Errors returned by Harden.
+var (
+	ErrHardenUnsupported = errors.New("kbd: Harden is not supported on this architecture")
+	ErrHardenCgo         = errors.New("kbd: Harden can't apply Landlock to programs using cgo")
+	ErrNoLandlock        = errors.New("kbd: Harden can't apply Landlock, which the kernel lacks")
+)
+
+// Harden restricts the process to the files it already has open. It is meant
+// to be called once devices are opened by programs that see every keystroke
+// and often run as root, to limit what a compromised process can do. After
+// Harden returns, the process and its children can't:
+//
+//   - open or create files, if the kernel supports Landlock (Linux 5.13);
+//   - run programs, create sockets or trace other processes (seccomp).
+//
+// Reading and writing already open files, devices and sockets still works.
+// It can't be undone. Note that the Go runtime loads some files lazily, such
+// as the time zone database, which will then fail to load.
+//
+// Landlock can only be applied to programs built without cgo (for example,
+// with CGO_ENABLED=0). Otherwise the seccomp filter is installed and
+// ErrHardenCgo is returned. Likewise, if the kernel doesn't support Landlock
+// or has it disabled, the seccomp filter is installed and ErrNoLandlock is
+// returned, so that programs can decide whether files being openable is
+// acceptable.
+func Harden() error {
+	if auditArch == 0 {
+		return ErrHardenUnsupported
+	}
+	// The seccomp filter is synced to every thread, along with this flag.
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	if err := unix.Prctl(unix.PR_SET_NO_NEW_PRIVS, 1, 0, 0, 0); err != nil {
+		return err
+	}
+	if err := seccomp(); err != nil {
+		return err
+	}
+	return landlock()
+}
+
+// Landlock system calls and constants, from "linux/landlock.h".
+const (
+	sysLandlockCreateRuleset = 444
+	sysLandlockRestrictSelf  = 446
+
+	landlockAccessFSv1 = 1<<13 - 1 // every filesystem access right of ABI 1
+)
+
+// landlock forbids all filesystem access through new file descriptors. It
+// returns ErrNoLandlock if the kernel doesn't support Landlock.
+func landlock() error {
+	attr := struct{ HandledAccessFS uint64 }{landlockAccessFSv1}
+	fd, _, errno := unix.Syscall(sysLandlockCreateRuleset,
+		uintptr(unsafe.Pointer(&attr)), unsafe.Sizeof(attr), 0)
+	if errno == unix.ENOSYS || errno == unix.EOPNOTSUPP {
+		return ErrNoLandlock
+	}
+	if errno != 0 {
+		return errno
+	}
+	defer unix.Close(int(fd))
+	// Landlock applies to a single thread, and Go runs on several.
+	_, _, errno = syscall.AllThreadsSyscall(sysLandlockRestrictSelf, fd, 0, 0)
+	if errno == syscall.ENOTSUP {
+		return ErrHardenCgo
+	}
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// seccomp constants, from "linux/seccomp.h".
+const (
+	seccompSetModeFilter   = 1
+	seccompFilterFlagTSync = 1
+
+	seccompRetKillProcess = 0x80000000
+	seccompRetErrno       = 0x00050000
+	seccompRetAllow       = 0x7fff0000
+)
+
+// hardenDenied are the system calls that fail with EPERM after Harden.
+var hardenDenied = append([]uintptr{
+	unix.SYS_EXECVE,
+	unix.SYS_EXECVEAT,
+	unix.SYS_PTRACE,
+	unix.SYS_PROCESS_VM_READV,
+	unix.SYS_PROCESS_VM_WRITEV,
+	unix.SYS_SOCKET,
+}, hardenDeniedArch...)
+
+// seccomp installs a filter, on every thread, that makes the hardenDenied
+// system calls fail and kills the process if a system call is made with
+// another architecture's calling convention.
+func seccomp() error {
+	stmt := func(code uint16, k uint32) unix.SockFilter {
+		return unix.SockFilter{Code: code, K: k}
+	}
+	jump := func(code uint16, k uint32, jt, jf uint8) unix.SockFilter {
+		return unix.SockFilter{Code: code, Jt: jt, Jf: jf, K: k}
+	}
+
+	n := len(hardenDenied)
+	prog := []unix.SockFilter{
+		stmt(unix.BPF_LD|unix.BPF_W|unix.BPF_ABS, 4), // seccomp_data.arch
+		jump(unix.BPF_JMP|unix.BPF_JEQ|unix.BPF_K, auditArch, 1, 0),
+		stmt(unix.BPF_RET|unix.BPF_K, seccompRetKillProcess),
+		stmt(unix.BPF_LD|unix.BPF_W|unix.BPF_ABS, 0), // seccomp_data.nr
+		// x32 system calls on amd64 have this bit set.
+		jump(unix.BPF_JMP|unix.BPF_JGE|unix.BPF_K, 0x40000000, uint8(n+1), 0),
+	}
+	for i, nr := range hardenDenied {
+		prog = append(prog, jump(unix.BPF_JMP|unix.BPF_JEQ|unix.BPF_K, uint32(nr), uint8(n-i), 0))
+	}
+	prog = append(prog,
+		stmt(unix.BPF_RET|unix.BPF_K, seccompRetAllow),
+		stmt(unix.BPF_RET|unix.BPF_K, seccompRetErrno|uint32(unix.EPERM)),
+	)
+
+	fprog := unix.SockFprog{Len: uint16(len(prog)), Filter: &prog[0]}
+	_, _, errno := unix.Syscall(unix.SYS_SECCOMP, seccompSetModeFilter,
+		seccompFilterFlagTSync, uintptr(unsafe.Pointer(&fprog)))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
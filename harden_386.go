@@ -0,0 +1,7 @@
+package kbd
+
+import "golang.org/x/sys/unix"
+
+const auditArch = 0x40000003 // AUDIT_ARCH_I386
+
+var hardenDeniedArch = []uintptr{unix.SYS_SOCKETCALL}
@@ -0,0 +1,5 @@
+package kbd
+
+const auditArch = 0xc000003e // AUDIT_ARCH_X86_64
+
+var hardenDeniedArch []uintptr
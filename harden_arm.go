@@ -0,0 +1,5 @@
+package kbd
+
+const auditArch = 0x40000028 // AUDIT_ARCH_ARM
+
+var hardenDeniedArch []uintptr
@@ -0,0 +1,5 @@
+package kbd
+
+const auditArch = 0xc00000b7 // AUDIT_ARCH_AARCH64
+
+var hardenDeniedArch []uintptr
@@ -0,0 +1,8 @@
+//go:build !amd64 && !arm64 && !386 && !arm
+// +build !amd64,!arm64,!386,!arm
+
+package kbd
+
+const auditArch = 0 // Harden is unsupported
+
+var hardenDeniedArch []uintptr
@@ -0,0 +1,189 @@
+package kbd
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// Errors reported by HealthCheck and SelfTest.
+var (
+	ErrNotRunning  = errors.New("kbd: read loop is not running")
+	ErrIdle        = errors.New("kbd: no events read within threshold")
+	ErrNoDevices   = errors.New("kbd: no devices")
+	ErrNoRoundTrip = errors.New("kbd: injected event was not read back")
+)
+
+// HealthChecker is implemented by Backends that can check that their device
+// is still usable, for example that it has not been unplugged.
+type HealthChecker interface {
+	Check() error
+}
+
+// eviocgVersion is the EVIOCGVERSION ioctl. It fails with ENODEV once the
+// device is gone, so it is used to check that a device fd is still valid.
+var eviocgVersion = eviocg(0x01, 4)
+
+// Check checks that the device is still present.
+func (d *evdev) Check() error {
+	var version int32
+	return ioctlData(d.file.Fd(), eviocgVersion, &version)
+}
+
+// Check checks that the Multiplexer has at least one device and that every
+// device is still present.
+func (m *Multiplexer) Check() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return os.ErrClosed
+	}
+	if len(m.devices) == 0 {
+		return ErrNoDevices
+	}
+	for fd, path := range m.devices {
+		var version int32
+		if err := ioctlData(uintptr(fd), eviocgVersion, &version); err != nil {
+			return &os.PathError{Op: "check", Path: path, Err: err}
+		}
+	}
+	return nil
+}
+
+// Check checks the Backend t reads from, if it is a HealthChecker.
+func (t *Toggle) Check() error {
+	if hc, ok := t.b.(HealthChecker); ok {
+		return hc.Check()
+	}
+	return nil
+}
+
+// Check checks the Backend r reads from, if it is a HealthChecker.
+func (r *Remapper) Check() error {
+	if hc, ok := r.b.(HealthChecker); ok {
+		return hc.Check()
+	}
+	return nil
+}
+
+// HealthCheck checks that the Keyboard is working: the read loop is running,
+// the device is valid (if the Backend is a HealthChecker), and an event has
+// been read, or a read has timed out for OnPoll, within threshold of now. The
+// read loop is considered to have read an event when it started. A threshold
+// of 0 skips the last check, which should be used for keyboards that may be
+// idle for long periods unless OnPoll is set with an interval shorter than
+// threshold; then a read loop stuck delivering events fails the check too.
+func (kb *Keyboard) HealthCheck(threshold time.Duration) error {
+	kb.mu.Lock()
+	alive := kb.running && !kb.closed && kb.events != nil
+	seen := kb.seen
+	kb.mu.Unlock()
+
+	if !alive {
+		if err := kb.Err(); err != nil {
+			return fmt.Errorf("%w: %v", ErrNotRunning, err)
+		}
+		return ErrNotRunning
+	}
+	if hc, ok := kb.backend.(HealthChecker); ok {
+		if err := hc.Check(); err != nil {
+			return err
+		}
+	}
+	if threshold > 0 && time.Since(seen) > threshold {
+		return fmt.Errorf("%w (last event %v ago)", ErrIdle, time.Since(seen).Round(time.Second))
+	}
+	return nil
+}
+
+// uiGetSysname is the UI_GET_SYSNAME ioctl reading size bytes.
+func uiGetSysname(size uintptr) uintptr {
+	return ioc(iocRead, 'U', 44, size)
+}
+
+// Path returns the evdev device file (in `/dev/input/`) of the virtual
+// keyboard. The file may not exist for a short while after the virtual
+// keyboard is created, until udev has made it.
+func (v *Virtual) Path() (string, error) {
+	var name [64]byte
+	err := ioctlBytes(v.file.Fd(), uiGetSysname(uintptr(len(name))), name[:])
+	if err != nil {
+		return "", err
+	}
+	sys := strings.TrimRight(string(name[:]), "\x00")
+	infos, err := ioutil.ReadDir("/sys/devices/virtual/input/" + sys)
+	if err != nil {
+		return "", err
+	}
+	for _, info := range infos {
+		if strings.HasPrefix(info.Name(), "event") {
+			return "/dev/input/" + info.Name(), nil
+		}
+	}
+	return "", fmt.Errorf("kbd: no event device for %s", sys)
+}
+
+// selfTestKey is the key injected by SelfTest. It is rarely bound to
+// anything, and the test device is grabbed, so no program sees it anyway.
+const selfTestKey = KeyF24
+
+// SelfTest checks that the system can deliver key events to this program: it
+// creates a virtual keyboard, injects a key press, and confirms that the event
+// is read back from the virtual keyboard's evdev device within timeout. The
+// device is grabbed while testing, so the key is seen by no other program.
+// SelfTest needs write access to `/dev/uinput` and read access to
+// `/dev/input/`.
+func SelfTest(timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+
+	v, err := NewVirtual("kbd self-test")
+	if err != nil {
+		return err
+	}
+	defer v.Close()
+
+	path, err := v.Path()
+	if err != nil {
+		return err
+	}
+	var f *os.File
+	for { // wait for udev to create the device file
+		f, err = os.OpenFile(path, os.O_RDONLY|unix.O_NONBLOCK, 0)
+		if err == nil || !os.IsNotExist(err) || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err != nil {
+		return err
+	}
+	d := &evdev{file: f}
+	defer d.Close()
+	if err := d.Grab(true); err != nil {
+		return err
+	}
+	if err := f.SetReadDeadline(deadline); err != nil {
+		return err
+	}
+
+	if err := v.Tap(selfTestKey); err != nil {
+		return err
+	}
+	for {
+		event, err := d.ReadEvent()
+		if os.IsTimeout(err) {
+			return ErrNoRoundTrip
+		}
+		if err != nil {
+			return err
+		}
+		if event.Code == selfTestKey && event.Value == Press {
+			return nil
+		}
+	}
+}
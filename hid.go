@@ -0,0 +1,236 @@
+package kbd
+
+// hidKeyboard maps usage IDs on the HID Keyboard/Keypad page (0x07) to
+// KeyCodes, following the kernel's own table in "hid-input.c".
+var hidKeyboard = map[uint8]KeyCode{
+	0x04: KeyA,
+	0x05: KeyB,
+	0x06: KeyC,
+	0x07: KeyD,
+	0x08: KeyE,
+	0x09: KeyF,
+	0x0a: KeyG,
+	0x0b: KeyH,
+	0x0c: KeyI,
+	0x0d: KeyJ,
+	0x0e: KeyK,
+	0x0f: KeyL,
+	0x10: KeyM,
+	0x11: KeyN,
+	0x12: KeyO,
+	0x13: KeyP,
+	0x14: KeyQ,
+	0x15: KeyR,
+	0x16: KeyS,
+	0x17: KeyT,
+	0x18: KeyU,
+	0x19: KeyV,
+	0x1a: KeyW,
+	0x1b: KeyX,
+	0x1c: KeyY,
+	0x1d: KeyZ,
+	0x1e: Key1,
+	0x1f: Key2,
+	0x20: Key3,
+	0x21: Key4,
+	0x22: Key5,
+	0x23: Key6,
+	0x24: Key7,
+	0x25: Key8,
+	0x26: Key9,
+	0x27: Key0,
+	0x28: KeyENTER,
+	0x29: KeyESC,
+	0x2a: KeyBACKSPACE,
+	0x2b: KeyTAB,
+	0x2c: KeySPACE,
+	0x2d: KeyMINUS,
+	0x2e: KeyEQUAL,
+	0x2f: KeyLEFTBRACE,
+	0x30: KeyRIGHTBRACE,
+	0x31: KeyBACKSLASH,
+	0x32: KeyBACKSLASH,
+	0x33: KeySEMICOLON,
+	0x34: KeyAPOSTROPHE,
+	0x35: KeyGRAVE,
+	0x36: KeyCOMMA,
+	0x37: KeyDOT,
+	0x38: KeySLASH,
+	0x39: KeyCAPSLOCK,
+	0x3a: KeyF1,
+	0x3b: KeyF2,
+	0x3c: KeyF3,
+	0x3d: KeyF4,
+	0x3e: KeyF5,
+	0x3f: KeyF6,
+	0x40: KeyF7,
+	0x41: KeyF8,
+	0x42: KeyF9,
+	0x43: KeyF10,
+	0x44: KeyF11,
+	0x45: KeyF12,
+	0x46: KeySYSRQ,
+	0x47: KeySCROLLLOCK,
+	0x48: KeyPAUSE,
+	0x49: KeyINSERT,
+	0x4a: KeyHOME,
+	0x4b: KeyPAGEUP,
+	0x4c: KeyDELETE,
+	0x4d: KeyEND,
+	0x4e: KeyPAGEDOWN,
+	0x4f: KeyRIGHT,
+	0x50: KeyLEFT,
+	0x51: KeyDOWN,
+	0x52: KeyUP,
+	0x53: KeyNUMLOCK,
+	0x54: KeyKPSLASH,
+	0x55: KeyKPASTERISK,
+	0x56: KeyKPMINUS,
+	0x57: KeyKPPLUS,
+	0x58: KeyKPENTER,
+	0x59: KeyKP1,
+	0x5a: KeyKP2,
+	0x5b: KeyKP3,
+	0x5c: KeyKP4,
+	0x5d: KeyKP5,
+	0x5e: KeyKP6,
+	0x5f: KeyKP7,
+	0x60: KeyKP8,
+	0x61: KeyKP9,
+	0x62: KeyKP0,
+	0x63: KeyKPDOT,
+	0x64: Key102ND,
+	0x65: KeyCOMPOSE,
+	0x66: KeyPOWER,
+	0x67: KeyKPEQUAL,
+	0x68: KeyF13,
+	0x69: KeyF14,
+	0x6a: KeyF15,
+	0x6b: KeyF16,
+	0x6c: KeyF17,
+	0x6d: KeyF18,
+	0x6e: KeyF19,
+	0x6f: KeyF20,
+	0x70: KeyF21,
+	0x71: KeyF22,
+	0x72: KeyF23,
+	0x73: KeyF24,
+	0x74: KeyOPEN,
+	0x75: KeyHELP,
+	0x76: KeyPROPS,
+	0x77: KeyFRONT,
+	0x78: KeySTOP,
+	0x79: KeyAGAIN,
+	0x7a: KeyUNDO,
+	0x7b: KeyCUT,
+	0x7c: KeyCOPY,
+	0x7d: KeyPASTE,
+	0x7e: KeyFIND,
+	0x7f: KeyMUTE,
+	0x80: KeyVOLUMEUP,
+	0x81: KeyVOLUMEDOWN,
+	0x85: KeyKPCOMMA,
+	0x87: KeyRO,
+	0x88: KeyKATAKANAHIRAGANA,
+	0x89: KeyYEN,
+	0x8a: KeyHENKAN,
+	0x8b: KeyMUHENKAN,
+	0x8c: KeyKPJPCOMMA,
+	0x90: KeyHANGEUL,
+	0x91: KeyHANJA,
+	0x92: KeyKATAKANA,
+	0x93: KeyHIRAGANA,
+	0x94: KeyZENKAKUHANKAKU,
+	0x9c: KeyDELETE,
+	0xb6: KeyKPLEFTPAREN,
+	0xb7: KeyKPRIGHTPAREN,
+	0xd8: KeyDELETE,
+	0xe0: KeyLEFTCTRL,
+	0xe1: KeyLEFTSHIFT,
+	0xe2: KeyLEFTALT,
+	0xe3: KeyLEFTMETA,
+	0xe4: KeyRIGHTCTRL,
+	0xe5: KeyRIGHTSHIFT,
+	0xe6: KeyRIGHTALT,
+	0xe7: KeyRIGHTMETA,
+}
+
+// hidUsage maps KeyCodes to their usage ID on the HID keyboard page. Where
+// several usages map to the same KeyCode, the lowest is used.
+var hidUsage = map[KeyCode]uint8{}
+
+func init() {
+	for usage := 0xff; usage >= 0; usage-- {
+		if code, ok := hidKeyboard[uint8(usage)]; ok {
+			hidUsage[code] = uint8(usage)
+		}
+	}
+}
+
+// HID usage pages containing keys.
+const (
+	HIDPageGenericDesktop = 0x01
+	HIDPageKeyboard       = 0x07
+	HIDPageConsumer       = 0x0c
+)
+
+// hidOther maps usages on pages other than the keyboard page to KeyCodes.
+var hidOther = map[[2]uint16]KeyCode{
+	{HIDPageGenericDesktop, 0x81}: KeyPOWER,
+	{HIDPageGenericDesktop, 0x82}: KeySLEEP,
+	{HIDPageGenericDesktop, 0x83}: KeyWAKEUP,
+
+	{HIDPageConsumer, 0x6f}:  KeyBRIGHTNESSUP,
+	{HIDPageConsumer, 0x70}:  KeyBRIGHTNESSDOWN,
+	{HIDPageConsumer, 0xb0}:  KeyPLAYCD,
+	{HIDPageConsumer, 0xb1}:  KeyPAUSECD,
+	{HIDPageConsumer, 0xb2}:  KeyRECORD,
+	{HIDPageConsumer, 0xb3}:  KeyFASTFORWARD,
+	{HIDPageConsumer, 0xb4}:  KeyREWIND,
+	{HIDPageConsumer, 0xb5}:  KeyNEXTSONG,
+	{HIDPageConsumer, 0xb6}:  KeyPREVIOUSSONG,
+	{HIDPageConsumer, 0xb7}:  KeySTOPCD,
+	{HIDPageConsumer, 0xb8}:  KeyEJECTCD,
+	{HIDPageConsumer, 0xcd}:  KeyPLAYPAUSE,
+	{HIDPageConsumer, 0xe2}:  KeyMUTE,
+	{HIDPageConsumer, 0xe9}:  KeyVOLUMEUP,
+	{HIDPageConsumer, 0xea}:  KeyVOLUMEDOWN,
+	{HIDPageConsumer, 0x183}: KeyMEDIA,
+	{HIDPageConsumer, 0x18a}: KeyMAIL,
+	{HIDPageConsumer, 0x192}: KeyCALC,
+	{HIDPageConsumer, 0x194}: KeyFILE,
+	{HIDPageConsumer, 0x221}: KeySEARCH,
+	{HIDPageConsumer, 0x223}: KeyHOMEPAGE,
+	{HIDPageConsumer, 0x224}: KeyBACK,
+	{HIDPageConsumer, 0x225}: KeyFORWARD,
+	{HIDPageConsumer, 0x226}: KeySTOP,
+	{HIDPageConsumer, 0x227}: KeyREFRESH,
+	{HIDPageConsumer, 0x22a}: KeyBOOKMARKS,
+}
+
+// ToHIDUsage returns the HID usage page and ID for key, and false if key has
+// no HID usage.
+func ToHIDUsage(key KeyCode) (page, usage uint16, ok bool) {
+	if u, ok := hidUsage[key]; ok {
+		return HIDPageKeyboard, uint16(u), true
+	}
+	for pu, k := range hidOther {
+		if k == key {
+			return pu[0], pu[1], true
+		}
+	}
+	return 0, 0, false
+}
+
+// FromHIDUsage returns the KeyCode for a HID usage, and false if it has none.
+func FromHIDUsage(page, usage uint16) (KeyCode, bool) {
+	if page == HIDPageKeyboard {
+		if usage > 0xff {
+			return 0, false
+		}
+		key, ok := hidKeyboard[uint8(usage)]
+		return key, ok
+	}
+	key, ok := hidOther[[2]uint16{page, usage}]
+	return key, ok
+}
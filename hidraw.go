@@ -0,0 +1,155 @@
+package kbd
+
+import (
+	"errors"
+	"os"
+	"time"
+)
+
+func init() {
+	RegisterBackend("hidraw", func(path string) (Backend, error) {
+		return OpenHIDRaw(path, BootKeyboard)
+	})
+}
+
+// HIDReport describes the layout of the input reports read from a hidraw
+// device, as given by its report descriptor.
+type HIDReport struct {
+	ID        uint8             // report ID preceding each report, or 0 if the device doesn't use IDs
+	Size      int               // length of a report in bytes, not counting the ID
+	Modifiers int               // offset of the modifier bitmap byte, or -1 if there is none
+	Keys      int               // offset of the first key usage byte
+	KeyCount  int               // number of key usage bytes
+	Usages    map[uint8]KeyCode // usage ID to KeyCode; the HID keyboard page if nil
+}
+
+// BootKeyboard is the report layout of a keyboard using the HID boot
+// protocol. Most barcode scanners and keypads use it.
+var BootKeyboard = HIDReport{
+	Size:      8,
+	Modifiers: 0,
+	Keys:      2,
+	KeyCount:  6,
+}
+
+// hidModifiers are the KeyCodes for the bits of the modifier byte.
+var hidModifiers = [8]KeyCode{
+	KeyLEFTCTRL, KeyLEFTSHIFT, KeyLEFTALT, KeyLEFTMETA,
+	KeyRIGHTCTRL, KeyRIGHTSHIFT, KeyRIGHTALT, KeyRIGHTMETA,
+}
+
+// hidErrorRollOver is reported in every key slot when too many keys are held.
+const hidErrorRollOver = 0x01
+
+// hidraw is a Backend that reads raw HID reports from `/dev/hidraw*`.
+type hidraw struct {
+	file    *os.File
+	report  HIDReport
+	buf     []byte
+	prev    []byte
+	pending []Event
+}
+
+// OpenHIDRaw opens the hidraw device at path and returns a Backend decoding
+// its reports using r. This is for devices, such as point-of-sale keypads,
+// that don't appear as evdev keyboards. Use New to create a Keyboard from it.
+func OpenHIDRaw(path string, r HIDReport) (Backend, error) {
+	if r.Size <= 0 || r.Modifiers < 0 || r.Modifiers >= r.Size || r.Keys < 0 || r.KeyCount < 0 || r.Keys+r.KeyCount > r.Size {
+		return nil, errors.New("kbd: HIDReport offsets must be within Size")
+	}
+	if r.Usages == nil {
+		r.Usages = hidKeyboard
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	size := r.Size
+	if r.ID != 0 {
+		size++
+	}
+	return &hidraw{
+		file:   f,
+		report: r,
+		buf:    make([]byte, size),
+		prev:   make([]byte, r.Size),
+	}, nil
+}
+
+func (h *hidraw) ReadEvent() (Event, error) {
+	for len(h.pending) == 0 {
+		n, err := h.file.Read(h.buf)
+		if err != nil {
+			return Event{}, err
+		}
+		report := h.buf[:n]
+		if h.report.ID != 0 {
+			if n == 0 || report[0] != h.report.ID {
+				continue // some other report
+			}
+			report = report[1:]
+		}
+		if len(report) < h.report.Size {
+			decodeErrors.inc()
+			continue
+		}
+		h.decode(report[:h.report.Size])
+	}
+	event := h.pending[0]
+	h.pending = h.pending[1:]
+	return event, nil
+}
+
+// decode compares report with the previous one and queues an Event for each
+// key released or pressed.
+func (h *hidraw) decode(report []byte) {
+	r := h.report
+	keys := report[r.Keys : r.Keys+r.KeyCount]
+	if r.KeyCount > 0 && keys[0] == hidErrorRollOver {
+		return // key state unknown; wait for a valid report
+	}
+	now := time.Now()
+
+	if r.Modifiers >= 0 {
+		old, cur := h.prev[r.Modifiers], report[r.Modifiers]
+		for bit, code := range hidModifiers {
+			mask := byte(1) << uint(bit)
+			if old&mask == cur&mask {
+				continue
+			}
+			event := Event{Time: now, Code: code, Value: Release}
+			if cur&mask != 0 {
+				event.Value = Press
+			}
+			h.pending = append(h.pending, event)
+		}
+	}
+
+	oldKeys := h.prev[r.Keys : r.Keys+r.KeyCount]
+	for _, usage := range oldKeys {
+		if code, ok := r.Usages[usage]; ok && !containsByte(keys, usage) {
+			h.pending = append(h.pending, Event{Time: now, Code: code, Value: Release})
+		}
+	}
+	for _, usage := range keys {
+		if code, ok := r.Usages[usage]; ok && !containsByte(oldKeys, usage) {
+			h.pending = append(h.pending, Event{Time: now, Code: code, Value: Press})
+		}
+	}
+
+	copy(h.prev, report)
+}
+
+func containsByte(s []byte, b byte) bool {
+	for _, x := range s {
+		if x == b {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *hidraw) Close() error {
+	return h.file.Close()
+}
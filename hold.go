@@ -0,0 +1,64 @@
+package kbd
+
+import "time"
+
+// holdTick is how often a held combo is checked, and its progress reported.
+const holdTick = time.Second / 30
+
+// BindHold arranges for a to be run when c has been held for d, rather than
+// when it is pressed, for Actions that shouldn't be triggered by accident,
+// such as powering off a kiosk. While c is held, progress (if not nil) is
+// called about 30 times a second with the fraction of d elapsed, from 0 to 1,
+// so that a UI can show a "hold to confirm" ring; it is called with 0 if c is
+// released early, or the Keyboard stops reading. progress and a are called
+// from a goroutine of their own.
+func (h *Hotkeys) BindHold(c Combo, d time.Duration, a Action, progress func(p float64)) *Binding {
+	return h.add(&Binding{Combo: c, Action: a, Hold: d, Progress: progress, h: h})
+}
+
+// hold waits for b's Combo to be held on kb for b.Hold, then runs b's
+// Action, unless it is already being held.
+func (h *Hotkeys) hold(kb *Keyboard, b *Binding) {
+	h.mu.Lock()
+	if h.holding[b] {
+		h.mu.Unlock()
+		return
+	}
+	if h.holding == nil {
+		h.holding = map[*Binding]bool{}
+	}
+	h.holding[b] = true
+	h.mu.Unlock()
+
+	progress := b.Progress
+	if progress == nil {
+		progress = func(float64) {}
+	}
+	go func() {
+		defer func() {
+			h.mu.Lock()
+			delete(h.holding, b)
+			h.mu.Unlock()
+		}()
+		start := time.Now()
+		progress(0)
+		tick := time.NewTicker(holdTick)
+		defer tick.Stop()
+		for range tick.C {
+			kb.mu.Lock()
+			ended := kb.closed
+			kb.mu.Unlock()
+			if ended || !b.Combo.Down(kb) {
+				progress(0)
+				return
+			}
+			p := float64(time.Since(start)) / float64(b.Hold)
+			if p >= 1 {
+				progress(1)
+				h.run([]*Binding{b})
+				return
+			}
+			progress(p)
+		}
+	}()
+}
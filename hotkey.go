@@ -0,0 +1,212 @@
+package kbd
+
+import (
+	"sync"
+	"time"
+)
+
+// Hotkeys runs Actions when combinations of keys are pressed on a Keyboard.
+type Hotkeys struct {
+	// ErrorHandler, if not nil, is called with errors returned by Actions.
+	ErrorHandler func(c Combo, err error)
+
+	// WhileSuppressed sets what happens to combos pressed while the Hotkeys
+	// are suppressed; see Suppress.
+	WhileSuppressed SuppressPolicy
+
+	mu         sync.Mutex
+	bindings   []*Binding
+	suppressed int         // number of Suppress calls not yet resumed
+	while      func() bool // set by SuppressWhile
+	queue      []*Binding  // fired while suppressed, for SuppressQueue
+	holding    map[*Binding]bool
+	repeating  map[*Binding]bool
+}
+
+// Binding is a Combo bound to an Action in a Hotkeys registry. Its fields
+// must not be changed once it is bound; a Binding with other options is made
+// by binding again, with BindOptions or with Replace.
+type Binding struct {
+	Combo  Combo
+	Action Action
+
+	// Hold, if not 0, is how long the Combo must be held before Action runs;
+	// see BindHold. Progress, if not nil, is called as it is held.
+	Hold     time.Duration
+	Progress func(p float64)
+
+	// Once, if true, unbinds the Binding when its Action first runs; see
+	// BindOnce.
+	Once bool
+
+	// Cooldown, if not 0, is the least time between runs of Action; presses
+	// (and repeats) within Cooldown of the last run are ignored. It is set
+	// with WithCooldown.
+	Cooldown time.Duration
+
+	// Repeat, if not 0, runs Action again every Repeat while the Combo is
+	// held, starting RepeatDelay (or Repeat, if 0) after it is pressed, as
+	// for a volume key. It doesn't apply to Hold bindings. They are set with
+	// WithRepeat.
+	Repeat      time.Duration
+	RepeatDelay time.Duration
+
+	h    *Hotkeys
+	last time.Time // last run of Action, for Cooldown
+}
+
+// NewHotkeys creates an empty hotkey registry.
+func NewHotkeys() *Hotkeys {
+	return &Hotkeys{}
+}
+
+// Bind arranges for a to be run each time c is pressed, with the options
+// opts. Several Actions may be bound to the same Combo.
+func (h *Hotkeys) Bind(c Combo, a Action, opts ...BindOption) *Binding {
+	return h.add(withOptions(&Binding{Combo: c, Action: a, h: h}, opts))
+}
+
+// add adds b to the bindings and returns it.
+func (h *Hotkeys) add(b *Binding) *Binding {
+	h.mu.Lock()
+	h.bindings = append(h.bindings, b)
+	h.mu.Unlock()
+	return b
+}
+
+// BindString is like Bind, but parses the combo with ParseCombo.
+func (h *Hotkeys) BindString(combo string, a Action, opts ...BindOption) (*Binding, error) {
+	c, err := ParseCombo(combo)
+	if err != nil {
+		return nil, err
+	}
+	return h.Bind(c, a, opts...), nil
+}
+
+// Unbind removes the binding.
+func (b *Binding) Unbind() {
+	b.unbind()
+}
+
+// unbind removes the binding, and reports whether it was bound.
+func (b *Binding) unbind() bool {
+	h := b.h
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for i, x := range h.bindings {
+		if x == b {
+			h.bindings = append(h.bindings[:i], h.bindings[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Replace unbinds old and binds the Combos and Actions of bindings in one
+// step, so that no key press sees only part of the change. It returns the new
+// Bindings.
+func (h *Hotkeys) Replace(old []*Binding, bindings []Binding) []*Binding {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	kept := h.bindings[:0]
+	for _, b := range h.bindings {
+		removed := false
+		for _, o := range old {
+			removed = removed || b == o
+		}
+		if !removed {
+			kept = append(kept, b)
+		}
+	}
+	added := make([]*Binding, len(bindings))
+	for i, b := range bindings {
+		added[i] = &Binding{Combo: b.Combo, Action: b.Action, Hold: b.Hold, Progress: b.Progress, Once: b.Once,
+			Cooldown: b.Cooldown, Repeat: b.Repeat, RepeatDelay: b.RepeatDelay, h: h}
+	}
+	h.bindings = append(kept, added...)
+	return added
+}
+
+// Bindings returns the current bindings.
+func (h *Hotkeys) Bindings() []*Binding {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]*Binding(nil), h.bindings...)
+}
+
+// Run reads key events from kb, running the Actions of matching bindings,
+// until kb stops. kb must already be started. It reads the events from a
+// Subscription of its own, so a key tapped quickly fires its bindings too,
+// even though it is already released.
+func (h *Hotkeys) Run(kb *Keyboard) {
+	sub := kb.Subscribe(64, DropOldest)
+	defer sub.Close()
+	for frame := range sub.C {
+		for _, event := range frame {
+			if event.Value == Press {
+				h.Press(kb, event.Code)
+			}
+		}
+	}
+}
+
+// Press runs the Actions bound to combos completed by the press of key on
+// kb. Run calls it for each press; it is exported for programs that read
+// kb's events themselves.
+func (h *Hotkeys) Press(kb *Keyboard, key KeyCode) {
+	matched := h.match(kb.IsDown, key)
+	if len(matched) == 0 {
+		return
+	}
+	for _, b := range h.unsuppressed(matched) {
+		if b.Hold > 0 {
+			h.hold(kb, b)
+		} else {
+			h.run([]*Binding{b})
+			if b.Repeat > 0 {
+				h.repeat(kb, b)
+			}
+		}
+	}
+}
+
+// run runs the Actions of bindings, unbinding those bound with Once first.
+// A Once binding that was already unbound, by another press that fired it,
+// isn't run again, and neither is a binding within its Cooldown.
+func (h *Hotkeys) run(bindings []*Binding) {
+	for _, b := range bindings {
+		if !h.cool(b) {
+			continue
+		}
+		if b.Once && !b.unbind() {
+			continue
+		}
+		hotkeysFired.inc()
+		if err := b.Action(); err != nil && h.ErrorHandler != nil {
+			h.ErrorHandler(b.Combo, err)
+		}
+	}
+}
+
+// Match returns the bindings whose Actions Press would run for a press of
+// key while the keys down in s are held, in the order they run, without
+// running them. Suppression is not taken into account. It is meant for
+// tools that explain what a combo does.
+func (h *Hotkeys) Match(s State, key KeyCode) []*Binding {
+	s.set(key)
+	return h.match(s.IsDown, key)
+}
+
+// match returns the bindings completed by the press of key, according to
+// isDown.
+func (h *Hotkeys) match(isDown func(KeyCode) bool, key KeyCode) []*Binding {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var matched []*Binding
+	for _, b := range h.bindings {
+		if Matches(b.Combo.Key, key) && b.Combo.modsHeld(isDown) {
+			matched = append(matched, b)
+		}
+	}
+	return matched
+}
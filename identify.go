@@ -0,0 +1,155 @@
+package kbd
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ErrIdentifyTimeout is returned by IdentifyKey if no key is pressed in time.
+var ErrIdentifyTimeout = errors.New("kbd: no key pressed")
+
+// ioctls for a device's identity, from "linux/input.h".
+var (
+	eviocgID   = eviocg(0x02, 8)
+	eviocgName = eviocg(0x06, 256)
+	eviocgUniq = eviocg(0x08, 256)
+)
+
+// mscScan is the MSC_SCAN event code, which precedes a key event with the
+// key's scancode on devices that report it.
+const mscScan = 0x04
+
+// DeviceInfo identifies an input device.
+type DeviceInfo struct {
+	Path    string // such as `/dev/input/event3`
+	Name    string // such as "AT Translated Set 2 keyboard"
+	Vendor  uint16 // USB or Bluetooth vendor ID, if any
+	Product uint16
+	Serial  string // unique ID, such as a serial number or MAC; often empty
+}
+
+// ReadDeviceInfo reads the identity of the evdev device at path.
+func ReadDeviceInfo(path string) (DeviceInfo, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return DeviceInfo{}, err
+	}
+	defer f.Close()
+	return deviceInfo(f)
+}
+
+// deviceInfo reads the identity of the evdev device f.
+func deviceInfo(f *os.File) (DeviceInfo, error) {
+	info := DeviceInfo{Path: f.Name()}
+	var id inputID
+	if err := ioctlData(f.Fd(), eviocgID, &id); err != nil {
+		return info, err
+	}
+	info.Vendor, info.Product = id.Vendor, id.Product
+	var buf [256]byte
+	if ioctlBytes(f.Fd(), eviocgName, buf[:]) == nil {
+		info.Name = cString(buf[:])
+	}
+	buf = [256]byte{}
+	if ioctlBytes(f.Fd(), eviocgUniq, buf[:]) == nil {
+		info.Serial = cString(buf[:])
+	}
+	return info, nil
+}
+
+// cString returns the NUL-terminated string at the start of b.
+func cString(b []byte) string {
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		b = b[:i]
+	}
+	return string(b)
+}
+
+// KeyInfo describes a key press, as found by IdentifyKey.
+type KeyInfo struct {
+	Code        KeyCode  // 0 (KeyRESERVED) for keys the kernel has no code for
+	Scancode    Scancode // see HasScancode
+	HasScancode bool     // whether the device reported the scancode (MSC_SCAN)
+	Device      DeviceInfo
+}
+
+// Name returns the name of the key, as ParseCombo accepts it.
+func (k KeyInfo) Name() string {
+	return comboName(k.Code)
+}
+
+// IdentifyKey waits for a key to be pressed on any of the evdev devices at
+// paths, or on any device in `/dev/input/` if paths is empty, and returns
+// what is known about it, so that configs can be written for keyboards with
+// unusual keys. The devices are not grabbed. Keys with a scancode but no
+// KeyCode can be given one with a ScancodeMapper. If timeout is not 0 and no
+// key is pressed within it, ErrIdentifyTimeout is returned.
+func IdentifyKey(paths []string, timeout time.Duration) (KeyInfo, error) {
+	if len(paths) == 0 {
+		paths, _ = filepath.Glob("/dev/input/event*")
+	}
+	found := make(chan KeyInfo, len(paths))
+	var files []*os.File
+	var err error
+	for _, path := range paths {
+		f, e := os.Open(path)
+		if e != nil {
+			err = e
+			continue
+		}
+		files = append(files, f)
+		go identify(f, found)
+	}
+	defer func() {
+		for _, f := range files {
+			f.Close() // ends the reads
+		}
+	}()
+	if len(files) == 0 {
+		if err == nil {
+			err = ErrNoDevices
+		}
+		return KeyInfo{}, err
+	}
+
+	var expired <-chan time.Time
+	if timeout > 0 {
+		t := time.NewTimer(timeout)
+		defer t.Stop()
+		expired = t.C
+	}
+	select {
+	case k := <-found:
+		return k, nil
+	case <-expired:
+		return KeyInfo{}, ErrIdentifyTimeout
+	}
+}
+
+// identify sends the first key pressed on f to found, with the scancode
+// reported in the same frame, if any.
+func identify(f *os.File, found chan<- KeyInfo) {
+	info, _ := deviceInfo(f)
+	d := &evdev{file: f}
+	var k KeyInfo
+	for {
+		raw, err := d.read()
+		if err != nil {
+			return
+		}
+		switch {
+		case raw.Kind == eventMSC && raw.Code == mscScan:
+			k.Scancode, k.HasScancode = Scancode(raw.Value), true
+		case raw.Kind == eventSYN && raw.Code == synReport:
+			k = KeyInfo{}
+		case raw.Kind == eventKEY && raw.Value == Press:
+			k.Code = KeyCode(raw.Code)
+			k.Device = info
+			found <- k
+			return
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package kbd
+
+import "sort"
+
+// Ignore filters keys out of kb's key state and events from now on, as if
+// they weren't on the device, for example to disable a broken key that fires
+// spuriously. Groups, such as AnyShift, ignore all their keys. Ignored keys
+// that are down are forgotten, so IsDown reports them up, without an event
+// for their release.
+func (kb *Keyboard) Ignore(keys ...KeyCode) {
+	kb.mu.Lock()
+	defer kb.mu.Unlock()
+	for _, key := range expandGroups(keys) {
+		kb.ignored[key] = true
+		delete(kb.keys, key)
+		kb.watch(Event{Code: key, Value: Release})
+	}
+}
+
+// Unignore stops ignoring keys. Their state is unknown until their next
+// event, so they are assumed up.
+func (kb *Keyboard) Unignore(keys ...KeyCode) {
+	kb.mu.Lock()
+	defer kb.mu.Unlock()
+	for _, key := range expandGroups(keys) {
+		delete(kb.ignored, key)
+	}
+}
+
+// Ignored returns the keys being ignored, in order of KeyCode.
+func (kb *Keyboard) Ignored() []KeyCode {
+	kb.mu.Lock()
+	defer kb.mu.Unlock()
+	keys := make([]KeyCode, 0, len(kb.ignored))
+	for key := range kb.ignored {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	return keys
+}
+
+// unignored returns frame without the events of ignored keys.
+func (kb *Keyboard) unignored(frame Frame) Frame {
+	kb.mu.Lock()
+	defer kb.mu.Unlock()
+	if len(kb.ignored) == 0 {
+		return frame
+	}
+	kept := frame[:0:0]
+	for _, event := range frame {
+		if !kb.ignored[event.Code] {
+			kept = append(kept, event)
+		}
+	}
+	return kept
+}
+
+// expandGroups returns keys with each group replaced by its members.
+func expandGroups(keys []KeyCode) []KeyCode {
+	var expanded []KeyCode
+	for _, key := range keys {
+		if members, ok := keyGroups[key]; ok {
+			expanded = append(expanded, members...)
+		} else {
+			expanded = append(expanded, key)
+		}
+	}
+	return expanded
+}
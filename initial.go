@@ -0,0 +1,52 @@
+package kbd
+
+import "time"
+
+// KeyStateReader is implemented by Backends that can report which keys are
+// down. A Keyboard uses it to learn the state of keys held when it is created,
+// such as a modifier held while the program was launched.
+type KeyStateReader interface {
+	KeyState() ([]KeyCode, error)
+}
+
+// KeyState reads the keys that are down with EVIOCGKEY.
+func (d *evdev) KeyState() ([]KeyCode, error) {
+	return keyState(d.file.Fd())
+}
+
+// keyState returns the keys held on the evdev device fd, read with EVIOCGKEY.
+func keyState(fd uintptr) ([]KeyCode, error) {
+	var bits [96]byte // KEY_MAX+1 bits
+	err := ioctlBytes(fd, eviocg(0x18, uintptr(len(bits))), bits[:])
+	if err != nil {
+		return nil, err
+	}
+	var keys []KeyCode
+	for i, b := range bits {
+		for bit := uint(0); bit < 8; bit++ {
+			if b&(1<<bit) != 0 {
+				keys = append(keys, KeyCode(i*8)+KeyCode(bit))
+			}
+		}
+	}
+	return keys, nil
+}
+
+// initialFrame returns a Frame of press events, marked Initial, for the keys
+// that were down when kb was created. They are reported on Frames() when kb
+// first starts, but not on Event(), so that keys held at launch aren't
+// mistaken for fresh presses. kb.mu must not be held.
+func (kb *Keyboard) initialFrame() Frame {
+	kb.mu.Lock()
+	defer kb.mu.Unlock()
+	now := time.Now()
+	var frame Frame
+	for _, key := range kb.initial {
+		if kb.keys[key] { // not released in the meantime
+			kb.seq++
+			frame = append(frame, Event{Time: now, Code: key, Value: Press, Initial: true, Seq: kb.seq})
+		}
+	}
+	kb.initial = nil
+	return frame
+}
@@ -0,0 +1,99 @@
+package kbd
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"runtime"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// ioctl performs the ioctl req on fd with the argument pointed to by arg.
+// It is the only place in the package to pass Go memory to an ioctl; the
+// rest of the package uses the typed wrappers below.
+func ioctl(fd uintptr, req uintptr, arg unsafe.Pointer) error {
+	_, _, errno := unix.Syscall(unix.SYS_IOCTL, fd, req, uintptr(arg))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// ioctlBytes performs the ioctl req on fd, which reads or writes buf.
+func ioctlBytes(fd uintptr, req uintptr, buf []byte) error {
+	if len(buf) == 0 {
+		return ioctl(fd, req, nil)
+	}
+	return ioctl(fd, req, unsafe.Pointer(&buf[0]))
+}
+
+// ioctlData performs the ioctl req on fd with the argument data, a pointer to
+// a fixed size value such as a struct mirroring one from a kernel header.
+// data is encoded for the kernel (little endian, without padding, so struct
+// fields must be laid out to need none) and, if the kernel writes to it,
+// decoded again. Its size must match the size encoded in req.
+func ioctlData(fd uintptr, req uintptr, data interface{}) error {
+	size := binary.Size(data)
+	if size < 0 || uintptr(size) != req>>16&0x3fff {
+		return fmt.Errorf("kbd: ioctl %#x: argument %T has size %d", req, data, size)
+	}
+	var buf bytes.Buffer
+	if err := binary.Write(&buf, binary.LittleEndian, data); err != nil {
+		return err
+	}
+	b := buf.Bytes()
+	if err := ioctlBytes(fd, req, b); err != nil {
+		return err
+	}
+	if req>>30&iocRead != 0 {
+		return binary.Read(bytes.NewReader(b), binary.LittleEndian, data)
+	}
+	return nil
+}
+
+// ioctl request directions, from "asm-generic/ioctl.h".
+const (
+	iocWrite = 1
+	iocRead  = 2
+)
+
+// ioc encodes an ioctl request number like the _IOC macro.
+func ioc(dir, typ, nr, size uintptr) uintptr {
+	return dir<<30 | size<<16 | typ<<8 | nr
+}
+
+// eviocg returns the request for the variable length evdev ioctl nr (for
+// example, 0x18 for EVIOCGKEY) reading size bytes.
+func eviocg(nr, size uintptr) uintptr {
+	return ioc(iocRead, 'E', nr, size)
+}
+
+// ioctlInt performs the ioctl req on fd with an integer argument.
+func ioctlInt(fd uintptr, req uintptr, arg uintptr) error {
+	_, _, errno := unix.Syscall(unix.SYS_IOCTL, fd, req, arg)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// eviocsMask is the EVIOCSMASK ioctl.
+var eviocsMask = ioc(iocWrite, 'E', 0x93, 16)
+
+// ioctlSetMask sets the mask of events of type kind delivered on fd to codes,
+// a bitmap of event codes, with EVIOCSMASK.
+func ioctlSetMask(fd uintptr, kind uint32, codes []byte) error {
+	mask := struct { // struct input_mask
+		Type      uint32
+		CodesSize uint32
+		CodesPtr  uint64
+	}{Type: kind, CodesSize: uint32(len(codes))}
+	if len(codes) > 0 {
+		mask.CodesPtr = uint64(uintptr(unsafe.Pointer(&codes[0])))
+	}
+	err := ioctl(fd, eviocsMask, unsafe.Pointer(&mask))
+	runtime.KeepAlive(codes)
+	return err
+}
@@ -0,0 +1,153 @@
+package kbd
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Keymap translates KeyCodes to the runes they produce in a keyboard layout.
+type Keymap struct {
+	Name string
+
+	mu   sync.RWMutex
+	keys map[KeyCode][2]rune // unshifted and shifted runes
+}
+
+// Rune returns the rune produced by key, with shift held if shift is true.
+// It returns false if key doesn't produce a rune in the layout.
+func (m *Keymap) Rune(key KeyCode, shift bool) (rune, bool) {
+	m.mu.RLock()
+	r, ok := m.keys[key]
+	m.mu.RUnlock()
+	if !ok {
+		return 0, false
+	}
+	if shift {
+		return r[1], true
+	}
+	return r[0], true
+}
+
+// KeymapUS is the standard US QWERTY layout.
+var KeymapUS = &Keymap{
+	Name: "us",
+	keys: map[KeyCode][2]rune{
+		KeyGRAVE: {'`', '~'}, Key1: {'1', '!'}, Key2: {'2', '@'}, Key3: {'3', '#'},
+		Key4: {'4', '$'}, Key5: {'5', '%'}, Key6: {'6', '^'}, Key7: {'7', '&'},
+		Key8: {'8', '*'}, Key9: {'9', '('}, Key0: {'0', ')'}, KeyMINUS: {'-', '_'},
+		KeyEQUAL: {'=', '+'},
+
+		KeyQ: {'q', 'Q'}, KeyW: {'w', 'W'}, KeyE: {'e', 'E'}, KeyR: {'r', 'R'},
+		KeyT: {'t', 'T'}, KeyY: {'y', 'Y'}, KeyU: {'u', 'U'}, KeyI: {'i', 'I'},
+		KeyO: {'o', 'O'}, KeyP: {'p', 'P'}, KeyLEFTBRACE: {'[', '{'},
+		KeyRIGHTBRACE: {']', '}'}, KeyBACKSLASH: {'\\', '|'},
+
+		KeyA: {'a', 'A'}, KeyS: {'s', 'S'}, KeyD: {'d', 'D'}, KeyF: {'f', 'F'},
+		KeyG: {'g', 'G'}, KeyH: {'h', 'H'}, KeyJ: {'j', 'J'}, KeyK: {'k', 'K'},
+		KeyL: {'l', 'L'}, KeySEMICOLON: {';', ':'}, KeyAPOSTROPHE: {'\'', '"'},
+
+		KeyZ: {'z', 'Z'}, KeyX: {'x', 'X'}, KeyC: {'c', 'C'}, KeyV: {'v', 'V'},
+		KeyB: {'b', 'B'}, KeyN: {'n', 'N'}, KeyM: {'m', 'M'}, KeyCOMMA: {',', '<'},
+		KeyDOT: {'.', '>'}, KeySLASH: {'/', '?'},
+
+		KeySPACE: {' ', ' '}, KeyTAB: {'\t', '\t'},
+
+		KeyKP0: {'0', '0'}, KeyKP1: {'1', '1'}, KeyKP2: {'2', '2'}, KeyKP3: {'3', '3'},
+		KeyKP4: {'4', '4'}, KeyKP5: {'5', '5'}, KeyKP6: {'6', '6'}, KeyKP7: {'7', '7'},
+		KeyKP8: {'8', '8'}, KeyKP9: {'9', '9'}, KeyKPDOT: {'.', '.'},
+		KeyKPSLASH: {'/', '/'}, KeyKPASTERISK: {'*', '*'}, KeyKPMINUS: {'-', '-'},
+		KeyKPPLUS: {'+', '+'},
+	},
+}
+
+// KeymapDE is the standard German QWERTZ layout.
+var KeymapDE = &Keymap{
+	Name: "de",
+	keys: map[KeyCode][2]rune{
+		KeyGRAVE: {'^', '°'}, Key1: {'1', '!'}, Key2: {'2', '"'}, Key3: {'3', '§'},
+		Key4: {'4', '$'}, Key5: {'5', '%'}, Key6: {'6', '&'}, Key7: {'7', '/'},
+		Key8: {'8', '('}, Key9: {'9', ')'}, Key0: {'0', '='}, KeyMINUS: {'ß', '?'},
+		KeyEQUAL: {'´', '`'},
+
+		KeyQ: {'q', 'Q'}, KeyW: {'w', 'W'}, KeyE: {'e', 'E'}, KeyR: {'r', 'R'},
+		KeyT: {'t', 'T'}, KeyY: {'z', 'Z'}, KeyU: {'u', 'U'}, KeyI: {'i', 'I'},
+		KeyO: {'o', 'O'}, KeyP: {'p', 'P'}, KeyLEFTBRACE: {'ü', 'Ü'},
+		KeyRIGHTBRACE: {'+', '*'}, KeyBACKSLASH: {'#', '\''},
+
+		KeyA: {'a', 'A'}, KeyS: {'s', 'S'}, KeyD: {'d', 'D'}, KeyF: {'f', 'F'},
+		KeyG: {'g', 'G'}, KeyH: {'h', 'H'}, KeyJ: {'j', 'J'}, KeyK: {'k', 'K'},
+		KeyL: {'l', 'L'}, KeySEMICOLON: {'ö', 'Ö'}, KeyAPOSTROPHE: {'ä', 'Ä'},
+
+		Key102ND: {'<', '>'}, KeyZ: {'y', 'Y'}, KeyX: {'x', 'X'}, KeyC: {'c', 'C'},
+		KeyV: {'v', 'V'}, KeyB: {'b', 'B'}, KeyN: {'n', 'N'}, KeyM: {'m', 'M'},
+		KeyCOMMA: {',', ';'}, KeyDOT: {'.', ':'}, KeySLASH: {'-', '_'},
+
+		KeySPACE: {' ', ' '}, KeyTAB: {'\t', '\t'},
+
+		KeyKP0: {'0', '0'}, KeyKP1: {'1', '1'}, KeyKP2: {'2', '2'}, KeyKP3: {'3', '3'},
+		KeyKP4: {'4', '4'}, KeyKP5: {'5', '5'}, KeyKP6: {'6', '6'}, KeyKP7: {'7', '7'},
+		KeyKP8: {'8', '8'}, KeyKP9: {'9', '9'}, KeyKPDOT: {',', ','},
+		KeyKPSLASH: {'/', '/'}, KeyKPASTERISK: {'*', '*'}, KeyKPMINUS: {'-', '-'},
+		KeyKPPLUS: {'+', '+'},
+	},
+}
+
+// Key returns the key that types r in the layout, and whether shift must be
+// held. It returns false if no key types r. If several do, the lowest
+// KeyCode is returned, so that the main keys are preferred to the keypad.
+func (m *Keymap) Key(r rune) (key KeyCode, shift bool, ok bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for k, runes := range m.keys {
+		for i, x := range runes {
+			if x == r && (!ok || k < key || (k == key && i == 0)) {
+				key, shift, ok = k, i == 1, true
+			}
+		}
+	}
+	return key, shift, ok
+}
+
+// Switch makes m translate keys in the layout name, such as "de", for users
+// who change layouts while a program runs. It is meant for DefaultKeymap(),
+// so that everything using it follows the change; switching a built-in Keymap
+// such as KeymapUS would change it for every user. Name should not be read
+// while Switch may be called; use Layout instead.
+func (m *Keymap) Switch(name string) error {
+	to := LookupKeymap(name)
+	if to == nil {
+		return fmt.Errorf("kbd: no Keymap for layout %q", name)
+	}
+	if to == m {
+		return nil
+	}
+	to.mu.RLock()
+	keys := to.keys
+	to.mu.RUnlock()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.Name = name
+	m.keys = keys
+	return nil
+}
+
+// Layout returns the name of m's layout.
+func (m *Keymap) Layout() string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.Name
+}
+
+var keymaps = []*Keymap{KeymapUS, KeymapDE}
+
+// LookupKeymap returns the Keymap for the XKB layout name, such as "us" or
+// "de". It returns nil if there is no such Keymap.
+func LookupKeymap(name string) *Keymap {
+	for _, m := range keymaps {
+		if m.Name == name {
+			return m
+		}
+	}
+	return nil
+}
@@ -0,0 +1,258 @@
+package kbd
+
+import "strconv"
+
+// String returns the name of the key, as in its constant without the "Key"
+// prefix, such as "ESC" or "LEFTSHIFT".
+func (k KeyCode) String() string {
+	if name, ok := keyNames[k]; ok {
+		return name
+	}
+	return "KeyCode(" + strconv.Itoa(int(k)) + ")"
+}
+
+var keyNames = map[KeyCode]string{
+	KeyRESERVED:         "RESERVED",
+	KeyESC:              "ESC",
+	Key1:                "1",
+	Key2:                "2",
+	Key3:                "3",
+	Key4:                "4",
+	Key5:                "5",
+	Key6:                "6",
+	Key7:                "7",
+	Key8:                "8",
+	Key9:                "9",
+	Key0:                "0",
+	KeyMINUS:            "MINUS",
+	KeyEQUAL:            "EQUAL",
+	KeyBACKSPACE:        "BACKSPACE",
+	KeyTAB:              "TAB",
+	KeyQ:                "Q",
+	KeyW:                "W",
+	KeyE:                "E",
+	KeyR:                "R",
+	KeyT:                "T",
+	KeyY:                "Y",
+	KeyU:                "U",
+	KeyI:                "I",
+	KeyO:                "O",
+	KeyP:                "P",
+	KeyLEFTBRACE:        "LEFTBRACE",
+	KeyRIGHTBRACE:       "RIGHTBRACE",
+	KeyENTER:            "ENTER",
+	KeyLEFTCTRL:         "LEFTCTRL",
+	KeyA:                "A",
+	KeyS:                "S",
+	KeyD:                "D",
+	KeyF:                "F",
+	KeyG:                "G",
+	KeyH:                "H",
+	KeyJ:                "J",
+	KeyK:                "K",
+	KeyL:                "L",
+	KeySEMICOLON:        "SEMICOLON",
+	KeyAPOSTROPHE:       "APOSTROPHE",
+	KeyGRAVE:            "GRAVE",
+	KeyLEFTSHIFT:        "LEFTSHIFT",
+	KeyBACKSLASH:        "BACKSLASH",
+	KeyZ:                "Z",
+	KeyX:                "X",
+	KeyC:                "C",
+	KeyV:                "V",
+	KeyB:                "B",
+	KeyN:                "N",
+	KeyM:                "M",
+	KeyCOMMA:            "COMMA",
+	KeyDOT:              "DOT",
+	KeySLASH:            "SLASH",
+	KeyRIGHTSHIFT:       "RIGHTSHIFT",
+	KeyKPASTERISK:       "KPASTERISK",
+	KeyLEFTALT:          "LEFTALT",
+	KeySPACE:            "SPACE",
+	KeyCAPSLOCK:         "CAPSLOCK",
+	KeyF1:               "F1",
+	KeyF2:               "F2",
+	KeyF3:               "F3",
+	KeyF4:               "F4",
+	KeyF5:               "F5",
+	KeyF6:               "F6",
+	KeyF7:               "F7",
+	KeyF8:               "F8",
+	KeyF9:               "F9",
+	KeyF10:              "F10",
+	KeyNUMLOCK:          "NUMLOCK",
+	KeySCROLLLOCK:       "SCROLLLOCK",
+	KeyKP7:              "KP7",
+	KeyKP8:              "KP8",
+	KeyKP9:              "KP9",
+	KeyKPMINUS:          "KPMINUS",
+	KeyKP4:              "KP4",
+	KeyKP5:              "KP5",
+	KeyKP6:              "KP6",
+	KeyKPPLUS:           "KPPLUS",
+	KeyKP1:              "KP1",
+	KeyKP2:              "KP2",
+	KeyKP3:              "KP3",
+	KeyKP0:              "KP0",
+	KeyKPDOT:            "KPDOT",
+	KeyZENKAKUHANKAKU:   "ZENKAKUHANKAKU",
+	Key102ND:            "102ND",
+	KeyF11:              "F11",
+	KeyF12:              "F12",
+	KeyRO:               "RO",
+	KeyKATAKANA:         "KATAKANA",
+	KeyHIRAGANA:         "HIRAGANA",
+	KeyHENKAN:           "HENKAN",
+	KeyKATAKANAHIRAGANA: "KATAKANAHIRAGANA",
+	KeyMUHENKAN:         "MUHENKAN",
+	KeyKPJPCOMMA:        "KPJPCOMMA",
+	KeyKPENTER:          "KPENTER",
+	KeyRIGHTCTRL:        "RIGHTCTRL",
+	KeyKPSLASH:          "KPSLASH",
+	KeySYSRQ:            "SYSRQ",
+	KeyRIGHTALT:         "RIGHTALT",
+	KeyLINEFEED:         "LINEFEED",
+	KeyHOME:             "HOME",
+	KeyUP:               "UP",
+	KeyPAGEUP:           "PAGEUP",
+	KeyLEFT:             "LEFT",
+	KeyRIGHT:            "RIGHT",
+	KeyEND:              "END",
+	KeyDOWN:             "DOWN",
+	KeyPAGEDOWN:         "PAGEDOWN",
+	KeyINSERT:           "INSERT",
+	KeyDELETE:           "DELETE",
+	KeyMACRO:            "MACRO",
+	KeyMUTE:             "MUTE",
+	KeyVOLUMEDOWN:       "VOLUMEDOWN",
+	KeyVOLUMEUP:         "VOLUMEUP",
+	KeyPOWER:            "POWER",
+	KeyKPEQUAL:          "KPEQUAL",
+	KeyKPPLUSMINUS:      "KPPLUSMINUS",
+	KeyPAUSE:            "PAUSE",
+	KeySCALE:            "SCALE",
+	KeyKPCOMMA:          "KPCOMMA",
+	KeyHANGEUL:          "HANGEUL",
+	KeyHANJA:            "HANJA",
+	KeyYEN:              "YEN",
+	KeyLEFTMETA:         "LEFTMETA",
+	KeyRIGHTMETA:        "RIGHTMETA",
+	KeyCOMPOSE:          "COMPOSE",
+	KeySTOP:             "STOP",
+	KeyAGAIN:            "AGAIN",
+	KeyPROPS:            "PROPS",
+	KeyUNDO:             "UNDO",
+	KeyFRONT:            "FRONT",
+	KeyCOPY:             "COPY",
+	KeyOPEN:             "OPEN",
+	KeyPASTE:            "PASTE",
+	KeyFIND:             "FIND",
+	KeyCUT:              "CUT",
+	KeyHELP:             "HELP",
+	KeyMENU:             "MENU",
+	KeyCALC:             "CALC",
+	KeySETUP:            "SETUP",
+	KeySLEEP:            "SLEEP",
+	KeyWAKEUP:           "WAKEUP",
+	KeyFILE:             "FILE",
+	KeySENDFILE:         "SENDFILE",
+	KeyDELETEFILE:       "DELETEFILE",
+	KeyXFER:             "XFER",
+	KeyPROG1:            "PROG1",
+	KeyPROG2:            "PROG2",
+	KeyWWW:              "WWW",
+	KeyMSDOS:            "MSDOS",
+	KeyCOFFEE:           "COFFEE",
+	KeyROTATE_DISPLAY:   "ROTATE_DISPLAY",
+	KeyCYCLEWINDOWS:     "CYCLEWINDOWS",
+	KeyMAIL:             "MAIL",
+	KeyBOOKMARKS:        "BOOKMARKS",
+	KeyCOMPUTER:         "COMPUTER",
+	KeyBACK:             "BACK",
+	KeyFORWARD:          "FORWARD",
+	KeyCLOSECD:          "CLOSECD",
+	KeyEJECTCD:          "EJECTCD",
+	KeyEJECTCLOSECD:     "EJECTCLOSECD",
+	KeyNEXTSONG:         "NEXTSONG",
+	KeyPLAYPAUSE:        "PLAYPAUSE",
+	KeyPREVIOUSSONG:     "PREVIOUSSONG",
+	KeySTOPCD:           "STOPCD",
+	KeyRECORD:           "RECORD",
+	KeyREWIND:           "REWIND",
+	KeyPHONE:            "PHONE",
+	KeyISO:              "ISO",
+	KeyCONFIG:           "CONFIG",
+	KeyHOMEPAGE:         "HOMEPAGE",
+	KeyREFRESH:          "REFRESH",
+	KeyEXIT:             "EXIT",
+	KeyMOVE:             "MOVE",
+	KeyEDIT:             "EDIT",
+	KeySCROLLUP:         "SCROLLUP",
+	KeySCROLLDOWN:       "SCROLLDOWN",
+	KeyKPLEFTPAREN:      "KPLEFTPAREN",
+	KeyKPRIGHTPAREN:     "KPRIGHTPAREN",
+	KeyNEW:              "NEW",
+	KeyREDO:             "REDO",
+	KeyF13:              "F13",
+	KeyF14:              "F14",
+	KeyF15:              "F15",
+	KeyF16:              "F16",
+	KeyF17:              "F17",
+	KeyF18:              "F18",
+	KeyF19:              "F19",
+	KeyF20:              "F20",
+	KeyF21:              "F21",
+	KeyF22:              "F22",
+	KeyF23:              "F23",
+	KeyF24:              "F24",
+	KeyPLAYCD:           "PLAYCD",
+	KeyPAUSECD:          "PAUSECD",
+	KeyPROG3:            "PROG3",
+	KeyPROG4:            "PROG4",
+	KeyALL_APPLICATIONS: "ALL_APPLICATIONS",
+	KeySUSPEND:          "SUSPEND",
+	KeyCLOSE:            "CLOSE",
+	KeyPLAY:             "PLAY",
+	KeyFASTFORWARD:      "FASTFORWARD",
+	KeyBASSBOOST:        "BASSBOOST",
+	KeyPRINT:            "PRINT",
+	KeyHP:               "HP",
+	KeyCAMERA:           "CAMERA",
+	KeySOUND:            "SOUND",
+	KeyQUESTION:         "QUESTION",
+	KeyEMAIL:            "EMAIL",
+	KeyCHAT:             "CHAT",
+	KeySEARCH:           "SEARCH",
+	KeyCONNECT:          "CONNECT",
+	KeyFINANCE:          "FINANCE",
+	KeySPORT:            "SPORT",
+	KeySHOP:             "SHOP",
+	KeyALTERASE:         "ALTERASE",
+	KeyCANCEL:           "CANCEL",
+	KeyBRIGHTNESSDOWN:   "BRIGHTNESSDOWN",
+	KeyBRIGHTNESSUP:     "BRIGHTNESSUP",
+	KeyMEDIA:            "MEDIA",
+	KeySWITCHVIDEOMODE:  "SWITCHVIDEOMODE",
+	KeyKBDILLUMTOGGLE:   "KBDILLUMTOGGLE",
+	KeyKBDILLUMDOWN:     "KBDILLUMDOWN",
+	KeyKBDILLUMUP:       "KBDILLUMUP",
+	KeySEND:             "SEND",
+	KeyREPLY:            "REPLY",
+	KeyFORWARDMAIL:      "FORWARDMAIL",
+	KeySAVE:             "SAVE",
+	KeyDOCUMENTS:        "DOCUMENTS",
+	KeyBATTERY:          "BATTERY",
+	KeyBLUETOOTH:        "BLUETOOTH",
+	KeyWLAN:             "WLAN",
+	KeyUWB:              "UWB",
+	KeyUNKNOWN:          "UNKNOWN",
+	KeyVIDEO_NEXT:       "VIDEO_NEXT",
+	KeyVIDEO_PREV:       "VIDEO_PREV",
+	KeyBRIGHTNESS_CYCLE: "BRIGHTNESS_CYCLE",
+	KeyBRIGHTNESS_AUTO:  "BRIGHTNESS_AUTO",
+	KeyDISPLAY_OFF:      "DISPLAY_OFF",
+	KeyWWAN:             "WWAN",
+	KeyRFKILL:           "RFKILL",
+	KeyMICMUTE:          "MICMUTE",
+}
@@ -0,0 +1,221 @@
+package kbd
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/term"
+)
+
+func init() {
+	RegisterBackend("kitty", func(path string) (Backend, error) {
+		return OpenKitty(path, nil)
+	})
+}
+
+// ErrNoKittyProtocol is returned by OpenKitty if the terminal doesn't
+// support the kitty keyboard protocol.
+var ErrNoKittyProtocol = errors.New("kbd: terminal doesn't support the kitty keyboard protocol")
+
+// kittyProbeTimeout is how long OpenKitty waits for the terminal to answer.
+const kittyProbeTimeout = time.Second
+
+// kittyReadFlags are the enhancements a kitty Backend asks for: every key,
+// including modifiers, as an escape code, with releases.
+const kittyReadFlags = KittyDisambiguate | KittyEventTypes | KittyAllKeys
+
+// kittyKeys maps the escape codes of termKeys back to their keys, with the
+// codes terminals may send instead of the usual ones.
+var kittyKeys = func() map[termKey]KeyCode {
+	keys := map[termKey]KeyCode{
+		{11, '~'}: KeyF1, {12, '~'}: KeyF2, {14, '~'}: KeyF4,
+		{7, '~'}: KeyHOME, {8, '~'}: KeyEND, {1, 'R'}: KeyF3,
+	}
+	for key, k := range termKeys {
+		keys[k] = key
+	}
+	return keys
+}()
+
+// kitty is a Backend reading the keys typed in a terminal that supports the
+// kitty keyboard protocol.
+type kitty struct {
+	tty    *term.Term
+	r      *bufio.Reader
+	keymap *Keymap
+	path   string
+}
+
+// OpenKitty opens the terminal at path (`/dev/tty` if empty) and returns a
+// Backend reading the keys typed in it with the kitty keyboard protocol,
+// which kitty, foot, WezTerm, Ghostty and recent versions of other
+// terminals support. Unlike evdev devices, this needs no privileges, and
+// unlike plain terminal input, it reports releases and modifiers as keys of
+// their own; but only keys typed while the terminal has focus are seen.
+// Text keys are mapped back to KeyCodes with m, or KeymapUS if m is nil.
+//
+// The terminal is put into cbreak mode, and restored by Close. If it doesn't
+// answer that it supports the protocol within a second, ErrNoKittyProtocol
+// is returned. Create a Keyboard from the Backend with NewHeadless, as it
+// sets up the terminal itself. The "kitty" Backend opens terminals this way.
+func OpenKitty(path string, m *Keymap) (Backend, error) {
+	if path == "" {
+		path = "/dev/tty"
+	}
+	if m == nil {
+		m = KeymapUS
+	}
+	tty, err := term.Open(path, term.CBreakMode, term.ReadTimeout(kittyProbeTimeout))
+	if err != nil {
+		return nil, err
+	}
+	fail := func(err error) (Backend, error) {
+		tty.Restore()
+		tty.Close()
+		return nil, err
+	}
+	// ask for the protocol's flags, then for the device attributes, which
+	// every terminal answers, so that a terminal without the protocol is
+	// known not to have it once the second answer arrives
+	if _, err := tty.Write([]byte("\x1b[?u\x1b[c")); err != nil {
+		return fail(err)
+	}
+	ok, err := probeKitty(tty)
+	if err != nil {
+		return fail(err)
+	}
+	if !ok {
+		return fail(ErrNoKittyProtocol)
+	}
+	if err := tty.SetReadTimeout(0); err != nil {
+		return fail(err)
+	}
+	if _, err := tty.Write([]byte("\x1b[>" + strconv.Itoa(int(kittyReadFlags)) + "u")); err != nil {
+		return fail(err)
+	}
+	return &kitty{tty: tty, r: bufio.NewReader(tty), keymap: m, path: path}, nil
+}
+
+// probeKitty reads the terminal's answers to OpenKitty's queries, and
+// reports whether it answered the kitty protocol's.
+func probeKitty(tty *term.Term) (bool, error) {
+	var buf [64]byte
+	var got []byte
+	supported := false
+	for {
+		n, err := tty.Read(buf[:])
+		if err != nil {
+			return false, err
+		}
+		if n == 0 {
+			return false, nil // timed out
+		}
+		got = append(got, buf[:n]...)
+		r := bufio.NewReader(bytes.NewReader(got))
+		for {
+			params, final, err := readCSI(r)
+			if err != nil {
+				break // the rest hasn't arrived yet
+			}
+			if strings.HasPrefix(params, "?") {
+				switch final {
+				case 'u':
+					supported = true
+				case 'c':
+					return supported, nil
+				}
+			}
+		}
+	}
+}
+
+// readCSI skips to the next control sequence ("ESC [ params final") read
+// from r, and returns its parameters and final byte.
+func readCSI(r *bufio.Reader) (string, byte, error) {
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return "", 0, err
+		}
+		if b != 0x1b {
+			continue
+		}
+		if b, err = r.ReadByte(); err != nil {
+			return "", 0, err
+		}
+		if b != '[' {
+			r.UnreadByte() // may be another ESC
+			continue
+		}
+		var params []byte
+		for {
+			if b, err = r.ReadByte(); err != nil {
+				return "", 0, err
+			}
+			if b >= 0x40 && b <= 0x7e {
+				return string(params), b, nil
+			}
+			params = append(params, b)
+		}
+	}
+}
+
+func (k *kitty) ReadEvent() (Event, error) {
+	for {
+		params, final, err := readCSI(k.r)
+		if err != nil {
+			return Event{}, err
+		}
+		if event, ok := k.decode(params, final); ok {
+			return event, nil
+		}
+	}
+}
+
+// decode returns the Event of the key report "CSI params final", which is
+// "key[:alternates];mods[:type];text", where all but key are optional.
+func (k *kitty) decode(params string, final byte) (Event, bool) {
+	if strings.HasPrefix(params, "?") || strings.HasPrefix(params, ">") {
+		return Event{}, false // answers to queries
+	}
+	fields := strings.Split(params, ";")
+	num := 1
+	if codes := strings.Split(fields[0], ":"); codes[0] != "" {
+		n, err := strconv.Atoi(codes[0])
+		if err != nil {
+			return Event{}, false
+		}
+		num = n
+	}
+	key, ok := kittyKeys[termKey{num, final}]
+	if !ok && final == 'u' {
+		key, _, ok = k.keymap.Key(rune(num))
+	}
+	if !ok {
+		return Event{}, false
+	}
+
+	value := int32(Press)
+	if len(fields) > 1 {
+		if parts := strings.Split(fields[1], ":"); len(parts) > 1 {
+			switch parts[1] {
+			case "2":
+				value = Repeat
+			case "3":
+				value = Release
+			}
+		}
+	}
+	return Event{Time: time.Now(), Code: key, Value: value, Device: k.path}, true
+}
+
+// Close restores the terminal's keyboard mode and settings, and closes it.
+func (k *kitty) Close() error {
+	k.tty.Write([]byte("\x1b[<u"))
+	k.tty.Restore()
+	return k.tty.Close()
+}
@@ -0,0 +1,190 @@
+package kbd
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// LabelsPath is the file of device labels that device paths of the form
+// "label:NAME" are looked up in.
+var LabelsPath = "/etc/kbd/labels.conf"
+
+// labelPrefix starts device paths that are labels.
+const labelPrefix = "label:"
+
+// DeviceLabel is a friendly name given to a device, which stays with it
+// when the kernel numbers devices differently, such as after a reboot or on
+// another machine. A device matches it by its vendor and product IDs, its
+// serial number, if the label has one, and its name, as a USB keyboard often
+// has several devices with the same IDs.
+type DeviceLabel struct {
+	Label   string
+	Vendor  uint16
+	Product uint16
+	Serial  string // matches any device if empty
+	Name    string
+}
+
+// Matches reports whether the device info is labeled by l.
+func (l DeviceLabel) Matches(info DeviceInfo) bool {
+	return info.Vendor == l.Vendor && info.Product == l.Product && info.Name == l.Name &&
+		(l.Serial == "" || info.Serial == l.Serial)
+}
+
+// Labels is a set of DeviceLabels, kept in a file with one label per line:
+//
+//	# LABEL  VENDOR:PRODUCT  SERIAL  NAME
+//	work     046d:c52b       -       Logitech USB Receiver
+//
+// where SERIAL is "-" if any serial number matches. Blank lines and lines
+// starting with '#' are ignored.
+type Labels struct {
+	labels map[string]DeviceLabel
+}
+
+// LoadLabels reads the labels file at path. A missing file has no labels.
+func LoadLabels(path string) (*Labels, error) {
+	l := &Labels{labels: map[string]DeviceLabel{}}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return l, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		dl, ok := parseLabel(text)
+		if !ok {
+			return nil, &ConfigError{Pos: ConfigPos{File: path, Line: line}, Msg: "malformed device label"}
+		}
+		l.labels[dl.Label] = dl
+	}
+	return l, scanner.Err()
+}
+
+// parseLabel parses a line of a labels file. The name is the rest of the
+// line, as device names may have spaces, even several in a row.
+func parseLabel(line string) (DeviceLabel, bool) {
+	var fields [3]string
+	for i := range fields {
+		line = strings.TrimLeft(line, " \t")
+		end := strings.IndexAny(line, " \t")
+		if end < 0 {
+			return DeviceLabel{}, false
+		}
+		fields[i], line = line[:end], line[end:]
+	}
+	ids := strings.SplitN(fields[1], ":", 2)
+	if len(ids) != 2 {
+		return DeviceLabel{}, false
+	}
+	vendor, err1 := strconv.ParseUint(ids[0], 16, 16)
+	product, err2 := strconv.ParseUint(ids[1], 16, 16)
+	if err1 != nil || err2 != nil {
+		return DeviceLabel{}, false
+	}
+	dl := DeviceLabel{Label: fields[0], Vendor: uint16(vendor), Product: uint16(product), Serial: fields[2]}
+	if dl.Serial == "-" {
+		dl.Serial = ""
+	}
+	dl.Name = strings.TrimLeft(line, " \t")
+	return dl, dl.Name != ""
+}
+
+// Save writes the labels to the file at path.
+func (l *Labels) Save(path string) error {
+	var buf bytes.Buffer
+	buf.WriteString("# LABEL  VENDOR:PRODUCT  SERIAL  NAME\n")
+	for _, dl := range l.List() {
+		serial := dl.Serial
+		if serial == "" {
+			serial = "-"
+		}
+		fmt.Fprintf(&buf, "%s %04x:%04x %s %s\n", dl.Label, dl.Vendor, dl.Product, serial, dl.Name)
+	}
+	return ioutil.WriteFile(path, buf.Bytes(), 0644)
+}
+
+// Set labels the device info as label, replacing any device labeled so.
+// The label can't contain spaces.
+func (l *Labels) Set(label string, info DeviceInfo) {
+	l.labels[label] = DeviceLabel{
+		Label:   label,
+		Vendor:  info.Vendor,
+		Product: info.Product,
+		Serial:  info.Serial,
+		Name:    info.Name,
+	}
+}
+
+// Remove removes label.
+func (l *Labels) Remove(label string) {
+	delete(l.labels, label)
+}
+
+// List returns the labels, sorted by label.
+func (l *Labels) List() []DeviceLabel {
+	list := make([]DeviceLabel, 0, len(l.labels))
+	for _, dl := range l.labels {
+		list = append(list, dl)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Label < list[j].Label })
+	return list
+}
+
+// Label returns the label of the device info, if it has one.
+func (l *Labels) Label(info DeviceInfo) (string, bool) {
+	for _, dl := range l.List() {
+		if dl.Matches(info) {
+			return dl.Label, true
+		}
+	}
+	return "", false
+}
+
+// Resolve returns the paths of the devices in `/dev/input/` now labeled
+// label. It fails if the label is unknown or no such device is plugged in.
+func (l *Labels) Resolve(label string) ([]string, error) {
+	dl, ok := l.labels[label]
+	if !ok {
+		return nil, fmt.Errorf("kbd: unknown device label %q", label)
+	}
+	all, _ := filepath.Glob("/dev/input/event*")
+	var paths []string
+	for _, path := range all {
+		if info, err := ReadDeviceInfo(path); err == nil && dl.Matches(info) {
+			paths = append(paths, path)
+		}
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("%w labeled %q", ErrNoDevices, label)
+	}
+	return paths, nil
+}
+
+// ResolveDevice returns the paths of the devices path refers to: path
+// itself, or if it is of the form "label:NAME", the devices labeled NAME in
+// the file at LabelsPath. Open, OpenDevice and Multiplexer.Add resolve
+// paths with it, as does kbdbind for the devices of its config.
+func ResolveDevice(path string) ([]string, error) {
+	if !strings.HasPrefix(path, labelPrefix) {
+		return []string{path}, nil
+	}
+	l, err := LoadLabels(LabelsPath)
+	if err != nil {
+		return nil, err
+	}
+	return l.Resolve(strings.TrimPrefix(path, labelPrefix))
+}
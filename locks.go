@@ -0,0 +1,153 @@
+package kbd
+
+import "strconv"
+
+// Lock is a set of lock key states. The bits match the kernel's LED codes.
+type Lock uint8
+
+// Lock keys.
+const (
+	NumLock Lock = 1 << iota
+	CapsLock
+	ScrollLock
+)
+
+func (l Lock) String() string {
+	switch l {
+	case NumLock:
+		return "NumLock"
+	case CapsLock:
+		return "CapsLock"
+	case ScrollLock:
+		return "ScrollLock"
+	}
+	return "Lock(" + strconv.Itoa(int(l)) + ")"
+}
+
+// lockKeys maps lock keys to the lock they toggle.
+var lockKeys = map[KeyCode]Lock{
+	KeyNUMLOCK:    NumLock,
+	KeyCAPSLOCK:   CapsLock,
+	KeySCROLLLOCK: ScrollLock,
+}
+
+// LockEvent reports that a lock was turned on or off.
+type LockEvent struct {
+	Lock Lock
+	On   bool
+}
+
+// LockReader is implemented by Backends that can report which locks are on.
+// A Keyboard uses it to learn the lock state when it is created; afterwards
+// the state is tracked from presses of the lock keys.
+type LockReader interface {
+	Locks() (Lock, error)
+}
+
+// Locks reads the lock LEDs of the device.
+func (d *evdev) Locks() (Lock, error) {
+	var leds [8]byte
+	err := ioctlBytes(d.file.Fd(), eviocg(0x19, uintptr(len(leds))), leds[:])
+	if err != nil {
+		return 0, err
+	}
+	return Lock(leds[0]) & (NumLock | CapsLock | ScrollLock), nil
+}
+
+// Locks reads the lock LEDs of the devices, and reports the locks lit on any
+// of them.
+func (m *Multiplexer) Locks() (Lock, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.devices) == 0 {
+		return 0, ErrNoDevices
+	}
+	var l Lock
+	var err error
+	for fd := range m.devices {
+		var leds [8]byte
+		if e := ioctlBytes(uintptr(fd), eviocg(0x19, uintptr(len(leds))), leds[:]); e != nil {
+			err = e
+			continue
+		}
+		l |= Lock(leds[0]) & (NumLock | CapsLock | ScrollLock)
+	}
+	if l == 0 && err != nil {
+		return 0, err
+	}
+	return l, nil
+}
+
+// SetLocks turns the locks on or off as in l, by tapping the keys of those
+// that differ from cur, the locks now on. The system (the console or the
+// display server) keeps the lock state and sets the LEDs of the keyboards,
+// so it must have picked up v by then.
+func (v *Virtual) SetLocks(cur, l Lock) error {
+	for key, lock := range lockKeys {
+		if (cur^l)&lock == 0 {
+			continue
+		}
+		if err := v.Tap(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LockChanged returns a channel on which changes to the lock state are
+// delivered. Like Event(), it is valid after Start() and is closed when
+// reading ends. Changes are dropped if the channel's buffer is full.
+func (kb *Keyboard) LockChanged() <-chan LockEvent {
+	return kb.lockEvents
+}
+
+// CapsLockOn reports whether CapsLock is on.
+func (kb *Keyboard) CapsLockOn() bool {
+	return kb.lockOn(CapsLock)
+}
+
+// NumLockOn reports whether NumLock is on.
+func (kb *Keyboard) NumLockOn() bool {
+	return kb.lockOn(NumLock)
+}
+
+// ScrollLockOn reports whether ScrollLock is on.
+func (kb *Keyboard) ScrollLockOn() bool {
+	return kb.lockOn(ScrollLock)
+}
+
+// SetLocks sets the lock state tracked by kb to l, for when it is known
+// better than from the Backend, such as when a daemon restores its state
+// after a restart. The LEDs are not changed, and no LockEvents are sent; to
+// turn the locks on or off, see Virtual.SetLocks.
+func (kb *Keyboard) SetLocks(l Lock) {
+	kb.mu.Lock()
+	kb.locks = l & (NumLock | CapsLock | ScrollLock)
+	kb.mu.Unlock()
+}
+
+func (kb *Keyboard) lockOn(l Lock) bool {
+	kb.mu.Lock()
+	defer kb.mu.Unlock()
+	return kb.locks&l != 0
+}
+
+// trackLock toggles the lock for key, if any, when it's pressed, and returns
+// the change. kb.mu must be held.
+func (kb *Keyboard) trackLock(key KeyCode, value int32) (LockEvent, bool) {
+	l, ok := lockKeys[key]
+	if !ok || value != Press {
+		return LockEvent{}, false
+	}
+	kb.locks ^= l
+	change := LockEvent{Lock: l, On: kb.locks&l != 0}
+	if kb.closed {
+		return change, true
+	}
+	select { // non-blocking channel send
+	case kb.lockEvents <- change:
+	default:
+		eventsDropped.inc()
+	}
+	return change, true
+}
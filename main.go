@@ -31,49 +31,112 @@
 package kbd
 
 import (
-	"encoding/binary"
-	"os"
 	"sync"
+	"time"
 
 	"github.com/pkg/term"
 )
 
-type inputEvent struct {
-	Timeval [16]byte
-	Kind    uint16
-	Code    uint16
-	Value   uint32
-}
-
 // Keyboard allows access to key states.
 type Keyboard struct {
 	mu      sync.Mutex
 	keys    map[KeyCode]bool
-	kbfile  *os.File
+	backend Backend
 	tty     *term.Term
 	events  chan KeyCode
+	closed  bool
 	running bool
 	err     error
+
+	applying sync.Mutex // held while a frame is applied and delivered
+
+	timeout time.Duration
+	timers  map[KeyCode]*time.Timer
+
+	locks      Lock
+	lockEvents chan LockEvent
+
+	frames  chan Frame
+	initial []KeyCode         // keys down when the Keyboard was created
+	presses map[KeyCode]Event // the last press of each key, for Trigger
+	seq     uint64            // Seq of the last key event applied
+	seen    time.Time         // when the last frame was read, or a read timed out for OnPoll
+
+	activity chan struct{} // closed when a frame is read
+	ready    [2]int        // pipe of ReadyFd, written when a frame is read
+
+	pollInterval time.Duration
+	onPoll       func()
+
+	ignored map[KeyCode]bool // keys filtered out by Ignore
+
+	notifiers map[*Notifier]bool
+	bus       *Bus
+
+	onCapture    func(capturing bool)
+	capturing    bool
+	maxCapture   time.Duration
+	captureTimer *time.Timer
 }
 
-// Open will attempt to open the device at path as well as the terminal at
-// `/dev/tty`. An error is returned if either of these fails.
+// Open will attempt to open the device at path, which may be a label such as
+// "label:work" (see ResolveDevice), as well as the terminal at `/dev/tty`.
+// An error is returned if either of these fails.
 func Open(path string) (*Keyboard, error) {
-	var err error
-	kb := &Keyboard{
-		keys: map[KeyCode]bool{},
-	}
+	return OpenBackend("evdev", path)
+}
 
-	kb.tty, err = term.Open("/dev/tty")
+// OpenBackend is like Open, but reads events from the device at path using
+// the Backend registered as name.
+func OpenBackend(name, path string) (*Keyboard, error) {
+	b, err := OpenDevice(name, path)
 	if err != nil {
 		return nil, err
 	}
-	kb.kbfile, err = os.Open(path)
+	kb, err := New(b)
 	if err != nil {
+		b.Close()
 		return nil, err
 	}
+	return kb, nil
+}
 
-	return kb, err
+// New creates a Keyboard that reads events from b, and opens the terminal at
+// `/dev/tty`. An error is returned if the terminal can't be opened.
+func New(b Backend) (*Keyboard, error) {
+	tty, err := term.Open("/dev/tty")
+	if err != nil {
+		return nil, err
+	}
+	kb := NewHeadless(b)
+	kb.tty = tty
+	return kb, nil
+}
+
+// NewHeadless creates a Keyboard that reads events from b without using a
+// terminal, for daemons and services that have none. Keys pressed are not
+// hidden from any terminal.
+func NewHeadless(b Backend) *Keyboard {
+	kb := &Keyboard{
+		keys:    map[KeyCode]bool{},
+		timers:  map[KeyCode]*time.Timer{},
+		ignored: map[KeyCode]bool{},
+		backend: b,
+		bus:     NewBus(),
+	}
+
+	if lr, ok := b.(LockReader); ok {
+		kb.locks, _ = lr.Locks() // unknown locks are assumed off
+	}
+	if kr, ok := b.(KeyStateReader); ok {
+		initial, _ := kr.KeyState() // unknown keys are assumed up
+		for _, key := range initial {
+			kb.keys[key] = true
+			kb.initial = append(kb.initial, key)
+		}
+	}
+
+	return kb
 }
 
 // Start puts the terminal in "cbreak" mode (to prevent key echo) and kicks off
@@ -81,80 +144,222 @@ func Open(path string) (*Keyboard, error) {
 // be put into cbreak mode. Errors affecting (ending) the keyboard event reading loop
 // can be examined with Err().
 func (kb *Keyboard) Start() error {
-	err := term.CBreakMode(kb.tty)
-	if err != nil {
-		return err
+	if kb.tty != nil {
+		if err := term.CBreakMode(kb.tty); err != nil {
+			return err
+		}
 	}
+	kb.mu.Lock()
 	kb.running = true
 	kb.events = make(chan KeyCode)
+	kb.lockEvents = make(chan LockEvent, 4)
+	kb.frames = make(chan Frame, 1)
+	kb.closed = false
+	kb.err = nil
+	kb.seen = time.Now()
+	kb.mu.Unlock()
 
 	// kb.mu.Lock()
 	// kb.keys = make(map[uint16]bool)
 	// kb.mu.Unlock()
 
+	kb.startBus()
+	kb.setCapturing(true)
+	if frame := kb.initialFrame(); len(frame) > 0 {
+		kb.sendFrame(frame)
+	}
+
 	go func() {
-		var event inputEvent
+		read := kb.pollReader(frameReader(kb.backend))
+		var frame Frame
 		var err error
-		for kb.running && err == nil {
+		for kb.isRunning() && err == nil {
 
-			err = binary.Read(kb.kbfile, binary.LittleEndian, &event)
-			if err != nil {
+			frame, err = read()
+			if err != nil || !kb.isRunning() {
 				continue // go to top of loop and end loop
 			}
+			if len(frame) == 0 {
+				kb.mu.Lock()
+				kb.seen = time.Now() // the read loop is alive, if idle
+				kb.mu.Unlock()
+				continue // the read timed out for OnPoll
+			}
 
-			if event.Kind == eventKEY {
-				if event.Value != repeat { // don't change state for repeat codes
-
-					kb.mu.Lock()
-					kb.keys[KeyCode(event.Code)] = event.Value == press // set "true" when key is pressed
-					kb.mu.Unlock()
-
-					select { // non-blocking channel recieve to "drain" channel
-					case <-kb.events:
-					default:
-					}
-					select { // non-blocking channel send
-					case kb.events <- KeyCode(event.Code):
-					default:
-					}
-				}
+			frame = kb.unignored(frame)
+			if len(frame) > 0 {
+				kb.mu.Lock()
+				kb.seen = time.Now()
+				kb.mu.Unlock()
+			}
+			kb.apply(frame)
+			if kb.tty != nil {
+				err = kb.tty.Flush() // remove keypress(es) from stream
 			}
-			err = kb.tty.Flush() // remove keypress(es) from stream
+		}
+		kb.mu.Lock()
+		for key, t := range kb.timers {
+			t.Stop()
+			delete(kb.timers, key)
 		}
 		close(kb.events)
+		close(kb.lockEvents)
+		close(kb.frames)
+		kb.closed = true
+		kb.wakeWaiters()
+		kb.mu.Unlock()
+		kb.closeNotifiers()
+		b := kb.Bus()
+		if err != nil {
+			b.Publish(ErrorEvent{Err: err})
+		}
+		b.Close()
+		kb.setCapturing(false)
 		if err != nil {
 			kb.Stop() // restore the terminal if there's an error
-			kb.err = err
+			kb.mu.Lock()
+			if kb.err == nil { // such as ErrCaptureLimit, which ended the loop
+				kb.err = err
+			}
+			kb.mu.Unlock()
 		}
 	}()
 
 	return nil
 }
 
-// Stop restores the terminal state and stops reading keyboard events.
+// apply applies the key events of frame to kb's key state, all at once, and
+// delivers them: on Event() and Frames(), to Subscriptions and Notifiers,
+// and on the Bus; then it wakes the waiters of WaitForActivity and ReadyFd,
+// which so find the frame applied. Frames are applied one at a time, so that
+// they are delivered in the order of their Seq, including those made up by
+// kb.
+func (kb *Keyboard) apply(frame Frame) {
+	kb.applying.Lock()
+	defer kb.applying.Unlock()
+	kb.applyLocked(frame)
+}
+
+// applyLocked is apply for callers holding kb.applying.
+func (kb *Keyboard) applyLocked(frame Frame) {
+	changes := frame[:0:0]
+	for _, event := range frame {
+		eventsRead.inc()
+		if event.Value != Repeat { // don't change state for repeat codes
+			changes = append(changes, event)
+		}
+	}
+
+	var locks []LockEvent
+	if len(changes) > 0 {
+		kb.mu.Lock() // apply the whole frame at once
+		for i := range changes {
+			kb.seq++
+			changes[i].Seq = kb.seq
+			event := changes[i]
+			kb.keys[event.Code] = event.Value == Press // set "true" when key is pressed
+			if event.Value == Press {
+				if kb.presses == nil {
+					kb.presses = map[KeyCode]Event{}
+				}
+				kb.presses[event.Code] = event
+			}
+			kb.watch(event)
+			if l, ok := kb.trackLock(event.Code, event.Value); ok {
+				locks = append(locks, l)
+			}
+		}
+		kb.mu.Unlock()
+
+		for _, event := range changes {
+			kb.send(event.Code)
+		}
+		kb.sendFrame(changes)
+	}
+	kb.publishBus(frame, changes, locks)
+	if len(frame) > 0 {
+		kb.mu.Lock()
+		kb.wakeWaiters()
+		kb.mu.Unlock()
+	}
+}
+
+// send delivers key on the events channel, replacing any KeyCode that
+// has not yet been received.
+func (kb *Keyboard) send(key KeyCode) {
+	kb.mu.Lock()
+	defer kb.mu.Unlock()
+	if kb.closed {
+		return
+	}
+	select { // non-blocking channel recieve to "drain" channel
+	case <-kb.events:
+		eventsDropped.inc()
+	default:
+	}
+	select { // non-blocking channel send
+	case kb.events <- key:
+	default:
+		eventsDropped.inc()
+	}
+}
+
+// Stop restores the terminal state and stops reading keyboard events. The
+// Keyboard is stopped even if the terminal can't be restored, in which case a
+// *TerminalWarning is returned.
 func (kb *Keyboard) Stop() error {
-	err := kb.tty.Restore()
+	kb.mu.Lock()
 	kb.running = false
-	return err
+	kb.mu.Unlock()
+	kb.setCapturing(false)
+	if kb.tty == nil {
+		return nil
+	}
+	if err := kb.tty.Restore(); err != nil {
+		return &TerminalWarning{Err: err, Recovered: resetTerminal() == nil}
+	}
+	return nil
+}
+
+// isRunning reports whether kb is to keep reading events.
+func (kb *Keyboard) isRunning() bool {
+	kb.mu.Lock()
+	defer kb.mu.Unlock()
+	return kb.running
 }
 
 // Close calls Stop() and also closes files used by the Keyboard.
 func (kb *Keyboard) Close() error {
 	err := kb.Stop()
-	err = kb.kbfile.Close()
-	err = kb.tty.Close()
+	err = kb.backend.Close()
+	kb.closeReady()
+	if kb.tty != nil {
+		err = kb.tty.Close()
+	}
 	return err
 }
 
 // Err reads the error that ended the keyboard event reading loop.
 func (kb *Keyboard) Err() error {
+	kb.mu.Lock()
+	defer kb.mu.Unlock()
 	return kb.err
 }
 
 // IsDown checks if the key is pressed or held (aka repeat).
+// If key is a group, such as AnyShift, it checks if any key in the group is
+// pressed.
 func (kb *Keyboard) IsDown(key KeyCode) bool {
 	kb.mu.Lock()
 	defer kb.mu.Unlock()
+	if members, ok := keyGroups[key]; ok {
+		for _, k := range members {
+			if kb.keys[k] {
+				return true
+			}
+		}
+		return false
+	}
 	return kb.keys[key]
 }
 
@@ -166,9 +371,9 @@ func (kb *Keyboard) Event() <-chan KeyCode {
 
 // Values for key events.
 const (
-	release = 0
-	press   = 1
-	repeat  = 2
+	Release = 0
+	Press   = 1
+	Repeat  = 2
 )
 
 // Types of events available from /dev/input/... files.
@@ -266,4 +471,177 @@ const (
 	KeyF10        KeyCode = 68
 	KeyNUMLOCK    KeyCode = 69
 	KeySCROLLLOCK KeyCode = 70
+
+	KeyKP7              KeyCode = 71
+	KeyKP8              KeyCode = 72
+	KeyKP9              KeyCode = 73
+	KeyKPMINUS          KeyCode = 74
+	KeyKP4              KeyCode = 75
+	KeyKP5              KeyCode = 76
+	KeyKP6              KeyCode = 77
+	KeyKPPLUS           KeyCode = 78
+	KeyKP1              KeyCode = 79
+	KeyKP2              KeyCode = 80
+	KeyKP3              KeyCode = 81
+	KeyKP0              KeyCode = 82
+	KeyKPDOT            KeyCode = 83
+	KeyZENKAKUHANKAKU   KeyCode = 85
+	Key102ND            KeyCode = 86
+	KeyF11              KeyCode = 87
+	KeyF12              KeyCode = 88
+	KeyRO               KeyCode = 89
+	KeyKATAKANA         KeyCode = 90
+	KeyHIRAGANA         KeyCode = 91
+	KeyHENKAN           KeyCode = 92
+	KeyKATAKANAHIRAGANA KeyCode = 93
+	KeyMUHENKAN         KeyCode = 94
+	KeyKPJPCOMMA        KeyCode = 95
+	KeyKPENTER          KeyCode = 96
+	KeyRIGHTCTRL        KeyCode = 97
+	KeyKPSLASH          KeyCode = 98
+	KeySYSRQ            KeyCode = 99
+	KeyRIGHTALT         KeyCode = 100
+	KeyLINEFEED         KeyCode = 101
+	KeyHOME             KeyCode = 102
+	KeyUP               KeyCode = 103
+	KeyPAGEUP           KeyCode = 104
+	KeyLEFT             KeyCode = 105
+	KeyRIGHT            KeyCode = 106
+	KeyEND              KeyCode = 107
+	KeyDOWN             KeyCode = 108
+	KeyPAGEDOWN         KeyCode = 109
+	KeyINSERT           KeyCode = 110
+	KeyDELETE           KeyCode = 111
+	KeyMACRO            KeyCode = 112
+	KeyMUTE             KeyCode = 113
+	KeyVOLUMEDOWN       KeyCode = 114
+	KeyVOLUMEUP         KeyCode = 115
+	KeyPOWER            KeyCode = 116
+	KeyKPEQUAL          KeyCode = 117
+	KeyKPPLUSMINUS      KeyCode = 118
+	KeyPAUSE            KeyCode = 119
+	KeySCALE            KeyCode = 120
+	KeyKPCOMMA          KeyCode = 121
+	KeyHANGEUL          KeyCode = 122
+	KeyHANJA            KeyCode = 123
+	KeyYEN              KeyCode = 124
+	KeyLEFTMETA         KeyCode = 125
+	KeyRIGHTMETA        KeyCode = 126
+	KeyCOMPOSE          KeyCode = 127
+	KeySTOP             KeyCode = 128
+	KeyAGAIN            KeyCode = 129
+	KeyPROPS            KeyCode = 130
+	KeyUNDO             KeyCode = 131
+	KeyFRONT            KeyCode = 132
+	KeyCOPY             KeyCode = 133
+	KeyOPEN             KeyCode = 134
+	KeyPASTE            KeyCode = 135
+	KeyFIND             KeyCode = 136
+	KeyCUT              KeyCode = 137
+	KeyHELP             KeyCode = 138
+	KeyMENU             KeyCode = 139
+	KeyCALC             KeyCode = 140
+	KeySETUP            KeyCode = 141
+	KeySLEEP            KeyCode = 142
+	KeyWAKEUP           KeyCode = 143
+	KeyFILE             KeyCode = 144
+	KeySENDFILE         KeyCode = 145
+	KeyDELETEFILE       KeyCode = 146
+	KeyXFER             KeyCode = 147
+	KeyPROG1            KeyCode = 148
+	KeyPROG2            KeyCode = 149
+	KeyWWW              KeyCode = 150
+	KeyMSDOS            KeyCode = 151
+	KeyCOFFEE           KeyCode = 152
+	KeyROTATE_DISPLAY   KeyCode = 153
+	KeyCYCLEWINDOWS     KeyCode = 154
+	KeyMAIL             KeyCode = 155
+	KeyBOOKMARKS        KeyCode = 156
+	KeyCOMPUTER         KeyCode = 157
+	KeyBACK             KeyCode = 158
+	KeyFORWARD          KeyCode = 159
+	KeyCLOSECD          KeyCode = 160
+	KeyEJECTCD          KeyCode = 161
+	KeyEJECTCLOSECD     KeyCode = 162
+	KeyNEXTSONG         KeyCode = 163
+	KeyPLAYPAUSE        KeyCode = 164
+	KeyPREVIOUSSONG     KeyCode = 165
+	KeySTOPCD           KeyCode = 166
+	KeyRECORD           KeyCode = 167
+	KeyREWIND           KeyCode = 168
+	KeyPHONE            KeyCode = 169
+	KeyISO              KeyCode = 170
+	KeyCONFIG           KeyCode = 171
+	KeyHOMEPAGE         KeyCode = 172
+	KeyREFRESH          KeyCode = 173
+	KeyEXIT             KeyCode = 174
+	KeyMOVE             KeyCode = 175
+	KeyEDIT             KeyCode = 176
+	KeySCROLLUP         KeyCode = 177
+	KeySCROLLDOWN       KeyCode = 178
+	KeyKPLEFTPAREN      KeyCode = 179
+	KeyKPRIGHTPAREN     KeyCode = 180
+	KeyNEW              KeyCode = 181
+	KeyREDO             KeyCode = 182
+	KeyF13              KeyCode = 183
+	KeyF14              KeyCode = 184
+	KeyF15              KeyCode = 185
+	KeyF16              KeyCode = 186
+	KeyF17              KeyCode = 187
+	KeyF18              KeyCode = 188
+	KeyF19              KeyCode = 189
+	KeyF20              KeyCode = 190
+	KeyF21              KeyCode = 191
+	KeyF22              KeyCode = 192
+	KeyF23              KeyCode = 193
+	KeyF24              KeyCode = 194
+	KeyPLAYCD           KeyCode = 200
+	KeyPAUSECD          KeyCode = 201
+	KeyPROG3            KeyCode = 202
+	KeyPROG4            KeyCode = 203
+	KeyALL_APPLICATIONS KeyCode = 204
+	KeySUSPEND          KeyCode = 205
+	KeyCLOSE            KeyCode = 206
+	KeyPLAY             KeyCode = 207
+	KeyFASTFORWARD      KeyCode = 208
+	KeyBASSBOOST        KeyCode = 209
+	KeyPRINT            KeyCode = 210
+	KeyHP               KeyCode = 211
+	KeyCAMERA           KeyCode = 212
+	KeySOUND            KeyCode = 213
+	KeyQUESTION         KeyCode = 214
+	KeyEMAIL            KeyCode = 215
+	KeyCHAT             KeyCode = 216
+	KeySEARCH           KeyCode = 217
+	KeyCONNECT          KeyCode = 218
+	KeyFINANCE          KeyCode = 219
+	KeySPORT            KeyCode = 220
+	KeySHOP             KeyCode = 221
+	KeyALTERASE         KeyCode = 222
+	KeyCANCEL           KeyCode = 223
+	KeyBRIGHTNESSDOWN   KeyCode = 224
+	KeyBRIGHTNESSUP     KeyCode = 225
+	KeyMEDIA            KeyCode = 226
+	KeySWITCHVIDEOMODE  KeyCode = 227
+	KeyKBDILLUMTOGGLE   KeyCode = 228
+	KeyKBDILLUMDOWN     KeyCode = 229
+	KeyKBDILLUMUP       KeyCode = 230
+	KeySEND             KeyCode = 231
+	KeyREPLY            KeyCode = 232
+	KeyFORWARDMAIL      KeyCode = 233
+	KeySAVE             KeyCode = 234
+	KeyDOCUMENTS        KeyCode = 235
+	KeyBATTERY          KeyCode = 236
+	KeyBLUETOOTH        KeyCode = 237
+	KeyWLAN             KeyCode = 238
+	KeyUWB              KeyCode = 239
+	KeyUNKNOWN          KeyCode = 240
+	KeyVIDEO_NEXT       KeyCode = 241
+	KeyVIDEO_PREV       KeyCode = 242
+	KeyBRIGHTNESS_CYCLE KeyCode = 243
+	KeyBRIGHTNESS_AUTO  KeyCode = 244
+	KeyDISPLAY_OFF      KeyCode = 245
+	KeyWWAN             KeyCode = 246
+	KeyRFKILL           KeyCode = 247
+	KeyMICMUTE          KeyCode = 248
 )
@@ -0,0 +1,63 @@
+package kbd
+
+// EventMasker is implemented by Backends that can ask the kernel to deliver
+// only the key events they are interested in. The kernel then doesn't wake
+// the reader for events it would discard, such as the stream of EV_REL
+// events from a keyboard with a built-in trackpad.
+type EventMasker interface {
+	// MaskEvents has the kernel deliver only key events, and only for keys
+	// if it isn't empty. It fails on kernels older than 4.4, which lack
+	// EVIOCSMASK.
+	MaskEvents(keys []KeyCode) error
+}
+
+// maskedTypes are the event types, other than EV_KEY and EV_SYN, that can be
+// masked. Masking them entirely leaves only key and sync events.
+var maskedTypes = []uint32{eventREL, eventABS, eventMSC, eventSW, eventLED, eventSND, eventFF}
+
+// maskEvents sets the event mask of the evdev device fd, as for MaskEvents.
+func maskEvents(fd uintptr, keys []KeyCode) error {
+	for _, kind := range maskedTypes {
+		if err := ioctlSetMask(fd, kind, nil); err != nil {
+			return err
+		}
+	}
+	var codes [(keyMax + 8) / 8]byte
+	if len(keys) == 0 {
+		for i := range codes {
+			codes[i] = 0xff
+		}
+	}
+	for _, key := range keys {
+		if key <= keyMax {
+			codes[key/8] |= 1 << (key % 8)
+		}
+	}
+	return ioctlSetMask(fd, eventKEY, codes[:])
+}
+
+// MaskEvents sets the device's event mask with EVIOCSMASK. Devices opened
+// by the "evdev" Backend already mask events other than key events, if the
+// kernel supports it.
+func (d *evdev) MaskEvents(keys []KeyCode) error {
+	return maskEvents(d.file.Fd(), keys)
+}
+
+// MaskEvents sets the event mask of every device, including devices added
+// later, with EVIOCSMASK. Devices already mask events other than key events,
+// if the kernel supports it. It can't set keys while m mirrors (see Mirror).
+func (m *Multiplexer) MaskEvents(keys []KeyCode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(keys) > 0 && m.mirror != nil {
+		return errMirrorMasked
+	}
+	m.keys = append([]KeyCode(nil), keys...)
+	var err error
+	for fd := range m.devices {
+		if e := maskEvents(uintptr(fd), m.keys); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
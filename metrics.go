@@ -0,0 +1,97 @@
+package kbd
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// metric is a value reported by MetricsHandler.
+type metric struct {
+	help, kind string // kind is "counter" or "gauge"
+	value      func() float64
+}
+
+var (
+	metricsMu sync.Mutex
+	metrics   = map[string]metric{}
+)
+
+// counter is a metric counting events in the package.
+type counter struct{ n uint64 }
+
+func (c *counter) inc() { atomic.AddUint64(&c.n, 1) }
+
+func (c *counter) value() float64 { return float64(atomic.LoadUint64(&c.n)) }
+
+// gauge is a metric that goes up and down.
+type gauge struct{ n int64 }
+
+func (g *gauge) add(d int64) { atomic.AddInt64(&g.n, d) }
+
+func (g *gauge) value() float64 { return float64(atomic.LoadInt64(&g.n)) }
+
+// The package's metrics.
+var (
+	eventsRead     counter
+	eventsDropped  counter
+	kernelDrops    counter
+	deviceFailures counter
+	reconnects     counter
+	hotkeysFired   counter
+	decodeErrors   counter
+	devicesOpen    gauge
+	subscribers    gauge
+)
+
+func init() {
+	RegisterMetric("kbd_events_read_total", "Key events read by Keyboards.", "counter", eventsRead.value)
+	RegisterMetric("kbd_events_dropped_total", "Key events, frames and lock changes not received before the next one.", "counter", eventsDropped.value)
+	RegisterMetric("kbd_kernel_drops_total", "Times the kernel reported dropping events (SYN_DROPPED).", "counter", kernelDrops.value)
+	RegisterMetric("kbd_device_failures_total", "Devices removed from a Multiplexer because reading them failed.", "counter", deviceFailures.value)
+	RegisterMetric("kbd_reconnects_total", "Devices added back to a Multiplexer after reading them failed.", "counter", reconnects.value)
+	RegisterMetric("kbd_hotkeys_fired_total", "Hotkey Actions run.", "counter", hotkeysFired.value)
+	RegisterMetric("kbd_decode_errors_total", "Malformed HID reports, event streams and plugin replies.", "counter", decodeErrors.value)
+	RegisterMetric("kbd_devices_open", "Devices open in Multiplexers.", "gauge", devicesOpen.value)
+	RegisterMetric("kbd_subscribers", "Open subscriptions to Buses, including Keyboards' Subscriptions.", "gauge", subscribers.value)
+}
+
+// RegisterMetric adds a metric to those reported by MetricsHandler, so that
+// programs can report their own, such as a daemon's number of clients. kind
+// is "counter" or "gauge", and value is called for each report.
+func RegisterMetric(name, help, kind string, value func() float64) {
+	metricsMu.Lock()
+	defer metricsMu.Unlock()
+	metrics[name] = metric{help: help, kind: kind, value: value}
+}
+
+// MetricsHandler returns an HTTP handler reporting the package's metrics in
+// the Prometheus text format, usually served at /metrics.
+func MetricsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		writeMetrics(w)
+	})
+}
+
+func writeMetrics(w io.Writer) {
+	metricsMu.Lock()
+	names := make([]string, 0, len(metrics))
+	for name := range metrics {
+		names = append(names, name)
+	}
+	all := make(map[string]metric, len(metrics))
+	for name, m := range metrics {
+		all[name] = m
+	}
+	metricsMu.Unlock()
+
+	sort.Strings(names)
+	for _, name := range names {
+		m := all[name]
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s %g\n", name, m.help, name, m.kind, name, m.value())
+	}
+}
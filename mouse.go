@@ -0,0 +1,174 @@
+package kbd
+
+import (
+	"os"
+	"sync"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// MouseButton is a mouse button, by its code from "input-event-codes.h".
+type MouseButton uint16
+
+// Mouse buttons.
+const (
+	ButtonLeft   MouseButton = 0x110
+	ButtonRight  MouseButton = 0x111
+	ButtonMiddle MouseButton = 0x112
+)
+
+// Relative axes, from "input-event-codes.h".
+const (
+	relX           = 0x00
+	relY           = 0x01
+	relHWheel      = 0x06
+	relDial        = 0x07
+	relWheel       = 0x08
+	relWheelHiRes  = 0x0b
+	relHWheelHiRes = 0x0c
+)
+
+// hiResNotch is the hi-res wheel value of one notch of a classic wheel.
+const hiResNotch = 120
+
+// VirtualMouse is a virtual mouse created through `/dev/uinput`. Its motion
+// and clicks are seen by the whole system as those of a real mouse.
+type VirtualMouse struct {
+	file *os.File
+
+	mu      sync.Mutex
+	partial [2]int32 // hi-res scrolling short of a notch, vertical and horizontal
+}
+
+// NewVirtualMouse creates a virtual mouse named name, with three buttons and
+// a vertical and horizontal wheel, both with hi-res scrolling.
+func NewVirtualMouse(name string) (*VirtualMouse, error) {
+	f, err := os.OpenFile("/dev/uinput", os.O_WRONLY|unix.O_NONBLOCK, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	err = ioctlInt(f.Fd(), uiSetEvBit, eventKEY)
+	for _, b := range []MouseButton{ButtonLeft, ButtonRight, ButtonMiddle} {
+		if err == nil {
+			err = ioctlInt(f.Fd(), uiSetKeyBit, uintptr(b))
+		}
+	}
+	if err == nil {
+		err = ioctlInt(f.Fd(), uiSetEvBit, eventREL)
+	}
+	for _, axis := range []uintptr{relX, relY, relWheel, relHWheel, relWheelHiRes, relHWheelHiRes} {
+		if err == nil {
+			err = ioctlInt(f.Fd(), uiSetRelBit, axis)
+		}
+	}
+	if err == nil {
+		err = uinputCreate(f, name)
+	}
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &VirtualMouse{file: f}, nil
+}
+
+// send emits events followed by a SYN_REPORT.
+func (m *VirtualMouse) send(events ...inputEvent) error {
+	events = append(events, inputEvent{Kind: eventSYN, Code: synReport})
+	buf := make([]byte, len(events)*inputEventSize)
+	tv := TimevalOf(time.Now())
+	for i, raw := range events {
+		raw.Sec, raw.Usec = tv.Sec, tv.Usec
+		raw.encode(buf[i*inputEventSize:])
+	}
+	_, err := m.file.Write(buf)
+	return err
+}
+
+// Move moves the pointer by dx and dy pixels (before the system's pointer
+// acceleration). Positive values move right and down.
+func (m *VirtualMouse) Move(dx, dy int32) error {
+	var events []inputEvent
+	if dx != 0 {
+		events = append(events, inputEvent{Kind: eventREL, Code: relX, Value: uint32(dx)})
+	}
+	if dy != 0 {
+		events = append(events, inputEvent{Kind: eventREL, Code: relY, Value: uint32(dy)})
+	}
+	if len(events) == 0 {
+		return nil
+	}
+	return m.send(events...)
+}
+
+// Scroll turns the wheel by n notches; positive values scroll up.
+func (m *VirtualMouse) Scroll(n int32) error {
+	return m.ScrollHiRes(n * hiResNotch)
+}
+
+// ScrollHorizontal turns the horizontal wheel by n notches; positive values
+// scroll right.
+func (m *VirtualMouse) ScrollHorizontal(n int32) error {
+	return m.ScrollHorizontalHiRes(n * hiResNotch)
+}
+
+// ScrollHiRes turns the wheel by v 120ths of a notch, for smooth scrolling
+// in programs that support hi-res wheels. Programs that don't see a notch
+// once the values add up to one.
+func (m *VirtualMouse) ScrollHiRes(v int32) error {
+	return m.scroll(0, relWheel, relWheelHiRes, v)
+}
+
+// ScrollHorizontalHiRes turns the horizontal wheel by v 120ths of a notch.
+func (m *VirtualMouse) ScrollHorizontalHiRes(v int32) error {
+	return m.scroll(1, relHWheel, relHWheelHiRes, v)
+}
+
+// scroll emits hi-res scrolling of v on the wheel axis hiRes, with the
+// whole notches it adds up to on the classic wheel axis, keeping what is
+// left over in m.partial[i].
+func (m *VirtualMouse) scroll(i int, axis, hiRes uint16, v int32) error {
+	m.mu.Lock()
+	m.partial[i] += v
+	notches := m.partial[i] / hiResNotch
+	m.partial[i] -= notches * hiResNotch
+	m.mu.Unlock()
+
+	events := []inputEvent{{Kind: eventREL, Code: hiRes, Value: uint32(v)}}
+	if notches != 0 {
+		events = append(events, inputEvent{Kind: eventREL, Code: axis, Value: uint32(notches)})
+	}
+	return m.send(events...)
+}
+
+// ScrollAction returns an Action that scrolls n notches, for binding to
+// keys.
+func (m *VirtualMouse) ScrollAction(n int32) Action {
+	return func() error { return m.Scroll(n) }
+}
+
+// Press presses b.
+func (m *VirtualMouse) Press(b MouseButton) error {
+	return m.send(inputEvent{Kind: eventKEY, Code: uint16(b), Value: Press})
+}
+
+// Release releases b.
+func (m *VirtualMouse) Release(b MouseButton) error {
+	return m.send(inputEvent{Kind: eventKEY, Code: uint16(b), Value: Release})
+}
+
+// Click presses and releases b.
+func (m *VirtualMouse) Click(b MouseButton) error {
+	if err := m.Press(b); err != nil {
+		return err
+	}
+	return m.Release(b)
+}
+
+// Close destroys the virtual mouse.
+func (m *VirtualMouse) Close() error {
+	panicCreated(m.file, 0, false)
+	ioctlInt(m.file.Fd(), uiDevDestroy, 0)
+	return m.file.Close()
+}
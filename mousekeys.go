@@ -0,0 +1,274 @@
+package kbd
+
+import (
+	"sync"
+	"time"
+)
+
+// MouseAction is what a key does in a MouseKeys layer.
+type MouseAction int
+
+// Mouse actions. The Move actions move the pointer while the key is held;
+// the Click actions hold a button down while the key is; the Scroll
+// actions turn the wheel one notch per press or repeat.
+const (
+	MoveUp MouseAction = iota
+	MoveDown
+	MoveLeft
+	MoveRight
+	ClickLeft
+	ClickRight
+	ClickMiddle
+	ScrollUp
+	ScrollDown
+)
+
+// MouseKeysIJKL is a MouseKeys layout: I, J, K and L or the arrow keys move
+// the pointer, U and O are the left and right buttons, P the middle button,
+// and Y and H scroll.
+var MouseKeysIJKL = map[KeyCode]MouseAction{
+	KeyI: MoveUp, KeyK: MoveDown, KeyJ: MoveLeft, KeyL: MoveRight,
+	KeyUP: MoveUp, KeyDOWN: MoveDown, KeyLEFT: MoveLeft, KeyRIGHT: MoveRight,
+	KeyU: ClickLeft, KeyO: ClickRight, KeyP: ClickMiddle,
+	KeyY: ScrollUp, KeyH: ScrollDown,
+}
+
+// MouseCurve returns the speed of the pointer, in pixels per second, after
+// a move key has been held for held.
+type MouseCurve func(held time.Duration) float64
+
+// MouseAccel returns a MouseCurve that starts at min pixels per second and
+// speeds up to max over ramp, along a quadratic curve, so that short presses
+// move precisely and long ones cross the screen quickly.
+func MouseAccel(min, max float64, ramp time.Duration) MouseCurve {
+	return func(held time.Duration) float64 {
+		if held >= ramp || ramp <= 0 {
+			return max
+		}
+		f := float64(held) / float64(ramp)
+		return min + (max-min)*f*f
+	}
+}
+
+// mouseTick is how often the pointer is moved while a move key is held.
+const mouseTick = time.Second / 60
+
+// MouseKeys is a Backend that turns keys read from another Backend into the
+// motion and clicks of a VirtualMouse while its layer is active, for
+// keyboard-only use of programs that need a mouse. The layer is active while
+// its Layer key is held, or after SetActive(true). Keys of the layer, and the
+// Layer key, are removed from the event stream; other keys are passed
+// through unchanged.
+type MouseKeys struct {
+	// Layer is the key that activates the layer while held, such as
+	// KeyCAPSLOCK, or 0 to use only SetActive.
+	Layer KeyCode
+	// Keys maps keys to what they do in the layer; MouseKeysIJKL if nil.
+	Keys map[KeyCode]MouseAction
+	// Curve sets the speed of the pointer; MouseAccel(100, 1500, time.Second)
+	// if nil.
+	Curve MouseCurve
+
+	b Backend
+	m *VirtualMouse
+
+	mu       sync.Mutex
+	active   bool                      // set by SetActive
+	layer    bool                      // Layer held
+	moving   map[MouseAction]time.Time // move actions held, and since when
+	down     map[KeyCode]bool          // layer keys held
+	stopped  chan struct{}             // closed to stop the motion goroutine
+	fraction [2]float64                // motion not yet sent, below one pixel
+}
+
+// NewMouseKeys creates a MouseKeys reading from b and moving m.
+func NewMouseKeys(b Backend, m *VirtualMouse) *MouseKeys {
+	return &MouseKeys{
+		b:      b,
+		m:      m,
+		moving: map[MouseAction]time.Time{},
+		down:   map[KeyCode]bool{},
+	}
+}
+
+// SetActive activates or deactivates the layer, regardless of the Layer key.
+func (k *MouseKeys) SetActive(active bool) {
+	k.mu.Lock()
+	k.active = active
+	k.mu.Unlock()
+}
+
+func (k *MouseKeys) ReadEvent() (Event, error) {
+	for {
+		event, err := k.b.ReadEvent()
+		if err != nil || !k.handle(event) {
+			return event, err
+		}
+	}
+}
+
+// handle acts on event if it belongs to the layer, and reports whether it
+// does.
+func (k *MouseKeys) handle(event Event) bool {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.Layer != 0 && event.Code == k.Layer {
+		if event.Value != Repeat {
+			k.layer = event.Value == Press
+		}
+		return true
+	}
+
+	keys := k.Keys
+	if keys == nil {
+		keys = MouseKeysIJKL
+	}
+	action, ok := keys[event.Code]
+	if !ok {
+		return false
+	}
+	// Only presses are taken while the layer is active; repeats and
+	// releases go where their press went, so that a key held when the layer
+	// ends still belongs to it until released, and one held when it starts
+	// is passed through until released.
+	if event.Value == Press {
+		if !k.active && !k.layer {
+			return false
+		}
+	} else if !k.down[event.Code] {
+		return false
+	}
+
+	switch event.Value {
+	case Press:
+		k.down[event.Code] = true
+	case Release:
+		delete(k.down, event.Code)
+	}
+	// another key with the same action, such as I and Up, keeps it going
+	others := k.actionHeld(keys, action, event.Code)
+	switch action {
+	case MoveUp, MoveDown, MoveLeft, MoveRight:
+		if event.Value == Press {
+			if _, ok := k.moving[action]; !ok {
+				k.moving[action] = time.Now()
+			}
+			k.startMoving()
+		} else if event.Value == Release && !others {
+			delete(k.moving, action)
+		}
+	case ClickLeft, ClickRight, ClickMiddle:
+		b := ButtonLeft + MouseButton(action-ClickLeft)
+		if event.Value == Press && !others {
+			k.m.Press(b)
+		} else if event.Value == Release && !others {
+			k.m.Release(b)
+		}
+	case ScrollUp, ScrollDown:
+		if event.Value != Release {
+			n := int32(1)
+			if action == ScrollDown {
+				n = -1
+			}
+			k.m.Scroll(n)
+		}
+	}
+	return true
+}
+
+// actionHeld reports whether a layer key other than key, doing action in
+// keys, is held. k.mu must be held.
+func (k *MouseKeys) actionHeld(keys map[KeyCode]MouseAction, action MouseAction, key KeyCode) bool {
+	for other := range k.down {
+		if other != key && keys[other] == action {
+			return true
+		}
+	}
+	return false
+}
+
+// startMoving starts the goroutine moving the pointer, if it isn't running.
+// It runs until no move key is held. k.mu must be held.
+func (k *MouseKeys) startMoving() {
+	if k.stopped != nil {
+		return
+	}
+	stopped := make(chan struct{})
+	k.stopped = stopped
+	go func() {
+		tick := time.NewTicker(mouseTick)
+		defer tick.Stop()
+		for {
+			select {
+			case <-tick.C:
+			case <-stopped:
+				return
+			}
+			if !k.move() {
+				return
+			}
+		}
+	}()
+}
+
+// move moves the pointer by one tick of motion, and reports whether any move
+// key is still held.
+func (k *MouseKeys) move() bool {
+	k.mu.Lock()
+	if len(k.moving) == 0 {
+		k.stopped = nil
+		k.fraction = [2]float64{}
+		k.mu.Unlock()
+		return false
+	}
+	curve := k.Curve
+	if curve == nil {
+		curve = MouseAccel(100, 1500, time.Second)
+	}
+	now := time.Now()
+	for action, since := range k.moving {
+		d := curve(now.Sub(since)) * mouseTick.Seconds()
+		switch action {
+		case MoveUp:
+			k.fraction[1] -= d
+		case MoveDown:
+			k.fraction[1] += d
+		case MoveLeft:
+			k.fraction[0] -= d
+		case MoveRight:
+			k.fraction[0] += d
+		}
+	}
+	dx, dy := int32(k.fraction[0]), int32(k.fraction[1])
+	k.fraction[0] -= float64(dx)
+	k.fraction[1] -= float64(dy)
+	k.mu.Unlock()
+
+	k.m.Move(dx, dy)
+	return true
+}
+
+// Close stops moving the pointer, releases any buttons held by the layer,
+// and closes the underlying Backend. The VirtualMouse is left open.
+func (k *MouseKeys) Close() error {
+	k.mu.Lock()
+	if k.stopped != nil {
+		close(k.stopped)
+		k.stopped = nil
+	}
+	k.moving = map[MouseAction]time.Time{}
+	keys := k.Keys
+	if keys == nil {
+		keys = MouseKeysIJKL
+	}
+	released := map[MouseAction]bool{}
+	for key := range k.down {
+		if a := keys[key]; a >= ClickLeft && a <= ClickMiddle && !released[a] {
+			k.m.Release(ButtonLeft + MouseButton(a-ClickLeft))
+			released[a] = true
+		}
+	}
+	k.down = map[KeyCode]bool{}
+	k.mu.Unlock()
+	return k.b.Close()
+}
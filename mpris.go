@@ -0,0 +1,69 @@
+package kbd
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// mprisMethods maps media keys to the MPRIS Player method they invoke.
+var mprisMethods = map[KeyCode]string{
+	KeyPLAYPAUSE:    "PlayPause",
+	KeyPLAYCD:       "Play",
+	KeyPLAY:         "Play",
+	KeyPAUSECD:      "Pause",
+	KeySTOPCD:       "Stop",
+	KeyNEXTSONG:     "Next",
+	KeyPREVIOUSSONG: "Previous",
+}
+
+// ErrNoPlayer is returned by MPRIS when no media player is running.
+var ErrNoPlayer = errors.New("kbd: no MPRIS media player found")
+
+// MPRIS controls media players over D-Bus using the MPRIS interface, allowing
+// a program to provide media key support on desktops that lack it. It uses
+// the session bus.
+type MPRIS struct {
+	// Player is the name of the player to control, such as "vlc" or
+	// "spotify". If empty, the first player found on the bus is used.
+	Player string
+}
+
+// Handle invokes the player command for key. It returns false if key isn't a
+// media key. Key releases should not be passed to Handle.
+func (m *MPRIS) Handle(key KeyCode) (bool, error) {
+	method, ok := mprisMethods[key]
+	if !ok {
+		return false, nil
+	}
+
+	conn, err := dbus.SessionBus()
+	if err != nil {
+		return true, err
+	}
+	dest := "org.mpris.MediaPlayer2." + m.Player
+	if m.Player == "" {
+		dest, err = mprisPlayer(conn)
+		if err != nil {
+			return true, err
+		}
+	}
+
+	call := conn.Object(dest, "/org/mpris/MediaPlayer2").Call("org.mpris.MediaPlayer2.Player."+method, 0)
+	return true, call.Err
+}
+
+// mprisPlayer finds the bus name of a running media player.
+func mprisPlayer(conn *dbus.Conn) (string, error) {
+	var names []string
+	if err := conn.BusObject().Call("org.freedesktop.DBus.ListNames", 0).Store(&names); err != nil {
+		return "", err
+	}
+	for _, name := range names {
+		if strings.HasPrefix(name, "org.mpris.MediaPlayer2.") {
+			return name, nil
+		}
+	}
+	return "", ErrNoPlayer
+}
@@ -0,0 +1,562 @@
+package kbd
+
+import (
+	"container/heap"
+	"errors"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// Multiplexer is a Backend that merges the key events of several evdev
+// devices. All devices are serviced from a single epoll loop, run by the
+// goroutine calling ReadEvent, so no goroutine is needed per device. Devices
+// may be added and removed while events are being read, and a device that
+// fails (for example, because it was unplugged) is removed automatically.
+// The same loop runs timers (see AfterFunc), for stages such as debouncing
+// or long-press detection, and control commands (see Do), so that they need
+// no goroutine either.
+type Multiplexer struct {
+	epfd int
+	wake [2]int // pipe used to interrupt epoll_wait
+
+	mu       sync.Mutex
+	devices  map[int]string    // fd to path
+	states   map[int]*muxState // fd to the state of reading it
+	failed   map[string]bool   // paths removed because reading them failed
+	closed   bool
+	reading  bool      // a ReadEvent or ReadFrame is in progress
+	grab     bool      // devices are grabbed, including those added later
+	keys     []KeyCode // keys not masked with EVIOCSMASK; all if empty
+	deadline time.Time // for ReadEvent; none if zero
+	bus      *Bus      // for DeviceEvents
+	mirror   *Virtual  // re-emits the events read; none if nil
+	timers   muxTimers // by deadline
+	commands []func()  // queued by Do
+
+	pending []Frame // frames read, not yet returned
+	buf     []byte
+}
+
+// muxState is the state of reading a device of a Multiplexer.
+type muxState struct {
+	frame   Frame            // frame being read
+	dropped bool             // frame is being discarded after SYN_DROPPED
+	down    map[KeyCode]bool // keys held, as of the frames read
+}
+
+// NewMultiplexer creates a Multiplexer with no devices.
+func NewMultiplexer() (*Multiplexer, error) {
+	epfd, err := unix.EpollCreate1(unix.EPOLL_CLOEXEC)
+	if err != nil {
+		return nil, err
+	}
+	m := &Multiplexer{
+		epfd:    epfd,
+		devices: map[int]string{},
+		states:  map[int]*muxState{},
+		failed:  map[string]bool{},
+		buf:     make([]byte, 64*inputEventSize),
+	}
+	if err := unix.Pipe2(m.wake[:], unix.O_NONBLOCK|unix.O_CLOEXEC); err != nil {
+		unix.Close(epfd)
+		return nil, err
+	}
+	err = unix.EpollCtl(epfd, unix.EPOLL_CTL_ADD, m.wake[0],
+		&unix.EpollEvent{Events: unix.EPOLLIN, Fd: int32(m.wake[0])})
+	if err != nil {
+		m.closeFds()
+		return nil, err
+	}
+	return m, nil
+}
+
+// Add opens the evdev device at path and adds it to the Multiplexer. If path
+// is a label, as "label:NAME" (see ResolveDevice), every device with the
+// label is added.
+func (m *Multiplexer) Add(path string) error {
+	paths, err := ResolveDevice(path)
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		if err := m.add(path); err != nil {
+			return err
+		}
+		m.report(DeviceEvent{Path: path, Added: true})
+	}
+	return nil
+}
+
+func (m *Multiplexer) add(path string) error {
+	fd, err := unix.Open(path, unix.O_RDONLY|unix.O_NONBLOCK|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return &os.PathError{Op: "open", Path: path, Err: err}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		unix.Close(fd)
+		return os.ErrClosed
+	}
+	err = unix.EpollCtl(m.epfd, unix.EPOLL_CTL_ADD, fd,
+		&unix.EpollEvent{Events: unix.EPOLLIN, Fd: int32(fd)})
+	if err != nil {
+		unix.Close(fd)
+		return err
+	}
+	if m.grab {
+		if err := ioctlInt(uintptr(fd), eviocGrab, 1); err != nil {
+			unix.EpollCtl(m.epfd, unix.EPOLL_CTL_DEL, fd, nil)
+			unix.Close(fd)
+			return err
+		}
+		panicGrabbed(muxDevice{m, fd}, uintptr(fd), true)
+	}
+	maskEvents(uintptr(fd), m.keys) // only a saving, unless MaskEvents failed
+	m.devices[fd] = path
+	m.states[fd] = &muxState{down: map[KeyCode]bool{}}
+	for _, key := range m.heldKeys(fd) { // for resync
+		m.states[fd].down[key] = true
+	}
+	devicesOpen.add(1)
+	if m.failed[path] {
+		delete(m.failed, path)
+		reconnects.inc()
+	}
+	return nil
+}
+
+// Grab takes (or releases) exclusive use of every device, including devices
+// added later.
+func (m *Multiplexer) Grab(grab bool) error {
+	var arg uintptr
+	if grab {
+		arg = 1
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.grab = grab
+	var err error
+	for fd := range m.devices {
+		if e := ioctlInt(uintptr(fd), eviocGrab, arg); e != nil {
+			if err == nil {
+				err = e
+			}
+		} else {
+			panicGrabbed(muxDevice{m, fd}, uintptr(fd), grab)
+		}
+	}
+	return err
+}
+
+// Remove removes and closes the device at path.
+func (m *Multiplexer) Remove(path string) {
+	m.mu.Lock()
+	removed := false
+	for fd, p := range m.devices {
+		if p == path {
+			m.remove(fd)
+			removed = true
+		}
+	}
+	m.mu.Unlock()
+	if removed {
+		m.report(DeviceEvent{Path: path})
+	}
+}
+
+// remove closes the device fd. m.mu must be held.
+func (m *Multiplexer) remove(fd int) {
+	unix.EpollCtl(m.epfd, unix.EPOLL_CTL_DEL, fd, nil)
+	panicGrabbed(muxDevice{m, fd}, 0, false)
+	unix.Close(fd)
+	delete(m.devices, fd)
+	delete(m.states, fd)
+	devicesOpen.add(-1)
+}
+
+// heldKeys returns the keys held on the device fd that aren't masked, read
+// with EVIOCGKEY. m.mu must be held.
+func (m *Multiplexer) heldKeys(fd int) []KeyCode {
+	keys, _ := keyState(uintptr(fd))
+	if len(m.keys) == 0 {
+		return keys
+	}
+	var held []KeyCode
+	for _, key := range keys {
+		if containsKey(m.keys, key) {
+			held = append(held, key)
+		}
+	}
+	return held
+}
+
+// ReportDevices publishes a DeviceEvent on b whenever a device is added,
+// removed, or fails.
+func (m *Multiplexer) ReportDevices(b *Bus) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.bus = b
+}
+
+// report publishes event on the Bus given to ReportDevices. m.mu must not be
+// held.
+func (m *Multiplexer) report(event DeviceEvent) {
+	m.mu.Lock()
+	b := m.bus
+	m.mu.Unlock()
+	if b != nil {
+		b.Publish(event)
+	}
+}
+
+// Devices returns the paths of the devices in the Multiplexer.
+func (m *Multiplexer) Devices() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	paths := make([]string, 0, len(m.devices))
+	for _, p := range m.devices {
+		paths = append(paths, p)
+	}
+	return paths
+}
+
+// ReadEvent waits for a key event from any device. It returns os.ErrClosed
+// once the Multiplexer is closed. It must not be called concurrently, nor
+// with ReadFrame.
+func (m *Multiplexer) ReadEvent() (Event, error) {
+	if err := m.wait(); err != nil {
+		return Event{}, err
+	}
+	frame := m.pending[0]
+	event := frame[0]
+	if len(frame) > 1 {
+		m.pending[0] = frame[1:]
+	} else {
+		m.pending = m.pending[1:]
+	}
+	m.mirrorEvents(Frame{event})
+	return event, nil
+}
+
+// ReadFrame reads the key events of a device up to its next SYN_REPORT, as
+// the "evdev" Backend does: if the kernel drops events of a device
+// (SYN_DROPPED), its incomplete frame is discarded, and once the drop ends
+// the keys held on it are read with EVIOCGKEY, so that its next frame has
+// Synthetic presses and releases of the keys whose state changed in the
+// events dropped. Errors are as for ReadEvent.
+func (m *Multiplexer) ReadFrame() (Frame, error) {
+	if err := m.wait(); err != nil {
+		return nil, err
+	}
+	frame := m.pending[0]
+	m.pending = m.pending[1:]
+	m.mirrorEvents(frame)
+	return frame, nil
+}
+
+// wait runs the epoll loop until a frame has been read.
+func (m *Multiplexer) wait() error {
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		return os.ErrClosed
+	}
+	m.reading = true
+	m.mu.Unlock()
+	defer func() {
+		m.mu.Lock()
+		m.reading = false
+		if m.closed {
+			m.closeFds() // Close left this to the reader
+		}
+		m.mu.Unlock()
+	}()
+
+	ready := make([]unix.EpollEvent, 16)
+	for len(m.pending) == 0 {
+		next := m.runScheduled()
+		m.mu.Lock()
+		deadline := m.deadline
+		m.mu.Unlock()
+		timeout := -1
+		if !deadline.IsZero() {
+			left := time.Until(deadline)
+			if left <= 0 {
+				return timeoutError{}
+			}
+			timeout = int((left + time.Millisecond - 1) / time.Millisecond)
+		}
+		if !next.IsZero() {
+			left := int((time.Until(next) + time.Millisecond - 1) / time.Millisecond)
+			if left < 0 {
+				left = 0
+			}
+			if timeout < 0 || left < timeout {
+				timeout = left
+			}
+		}
+
+		n, err := unix.EpollWait(m.epfd, ready, timeout)
+		m.mu.Lock()
+		closed := m.closed
+		m.mu.Unlock()
+		if closed {
+			return os.ErrClosed
+		}
+		if err == unix.EINTR {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		for _, ev := range ready[:n] {
+			if int(ev.Fd) == m.wake[0] {
+				unix.Read(m.wake[0], make([]byte, 16)) // woken by SetReadDeadline, AfterFunc or Do
+			} else {
+				m.read(int(ev.Fd))
+			}
+		}
+	}
+	return nil
+}
+
+// mirrorEvents re-emits frame on the Virtual set by Mirror, if any.
+func (m *Multiplexer) mirrorEvents(frame Frame) {
+	m.mu.Lock()
+	v, b := m.mirror, m.bus
+	m.mu.Unlock()
+	if v == nil {
+		return
+	}
+	for _, event := range frame {
+		if err := v.Send(event.Code, event.Value); err != nil && b != nil {
+			b.Publish(ErrorEvent{Err: err})
+		}
+	}
+}
+
+// Mirror re-emits every key event read from m on the virtual keyboard v, and
+// grabs m's devices, so that other programs, such as screen readers, see the
+// devices merged into the single keyboard v. A Remapper does the same with
+// the keys remapped. Mirror(nil) stops mirroring and releases the devices.
+// Errors emitting events are published as ErrorEvents on the Bus given to
+// ReportDevices. v's device must not be added to m, which would read back
+// the events it emits. Mirror fails while MaskEvents has set keys, as the
+// other keys would be lost: they would be masked, and the devices grabbed.
+func (m *Multiplexer) Mirror(v *Virtual) error {
+	m.mu.Lock()
+	if v != nil && len(m.keys) > 0 {
+		m.mu.Unlock()
+		return errMirrorMasked
+	}
+	m.mirror = v
+	m.mu.Unlock()
+	return m.Grab(v != nil)
+}
+
+// errMirrorMasked is returned by Mirror and MaskEvents, which can't be used
+// together.
+var errMirrorMasked = errors.New("kbd: can't mirror a Multiplexer masking keys")
+
+// read reads all available events from the device fd, and queues the frames
+// they complete. The device is looked up and read with m.mu held, so that it
+// can't be removed, and its fd reused by a device added since, in between.
+func (m *Multiplexer) read(fd int) {
+	m.mu.Lock()
+	path, ok := m.devices[fd]
+	if !ok {
+		m.mu.Unlock()
+		return // removed since epoll_wait returned
+	}
+	st := m.states[fd]
+	var frames []Frame
+	var failed error
+	for {
+		n, err := unix.Read(fd, m.buf)
+		if err == unix.EAGAIN || err == unix.EINTR {
+			break
+		}
+		if err != nil || n == 0 {
+			m.remove(fd)
+			deviceFailures.inc()
+			m.failed[path] = true
+			failed = err
+			if failed == nil {
+				failed = io.EOF
+			}
+			break
+		}
+		for b := m.buf[:n]; len(b) >= inputEventSize; b = b[inputEventSize:] {
+			raw := decodeInputEvent(b)
+			switch {
+			case raw.Kind == eventSYN && raw.Code == synDropped:
+				st.dropped = true
+				st.frame = st.frame[:0]
+				kernelDrops.inc()
+			case raw.Kind == eventSYN && raw.Code == synReport:
+				frame := st.frame
+				st.frame = nil
+				if st.dropped {
+					st.dropped = false
+					frame = resyncFrame(st.down, m.heldKeys(fd), path)
+				}
+				if len(frame) > 0 {
+					trackDown(st.down, frame)
+					frames = append(frames, frame)
+				}
+			case raw.Kind == eventKEY && !st.dropped:
+				tv := Timeval{Sec: raw.Sec, Usec: raw.Usec}
+				st.frame = append(st.frame, Event{
+					Time:    tv.Time(),
+					Timeval: tv,
+					Code:    KeyCode(raw.Code),
+					Value:   int32(raw.Value),
+					Device:  path,
+				})
+			}
+		}
+	}
+	m.mu.Unlock()
+
+	m.pending = append(m.pending, frames...)
+	if failed != nil {
+		m.report(DeviceEvent{Path: path, Err: failed})
+	}
+}
+
+// MuxTimer is a timer run by the epoll loop of a Multiplexer; see AfterFunc.
+type MuxTimer struct {
+	m     *Multiplexer
+	at    time.Time
+	f     func()
+	index int // in m.timers; -1 once run or stopped
+}
+
+// muxTimers is a heap of timers by deadline.
+type muxTimers []*MuxTimer
+
+func (h muxTimers) Len() int           { return len(h) }
+func (h muxTimers) Less(i, j int) bool { return h[i].at.Before(h[j].at) }
+func (h muxTimers) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index, h[j].index = i, j
+}
+func (h *muxTimers) Push(x interface{}) {
+	t := x.(*MuxTimer)
+	t.index = len(*h)
+	*h = append(*h, t)
+}
+func (h *muxTimers) Pop() interface{} {
+	old := *h
+	t := old[len(old)-1]
+	*h = old[:len(old)-1]
+	t.index = -1
+	return t
+}
+
+// AfterFunc arranges for f to be called after d by the goroutine reading
+// from m, between events, as time.AfterFunc does from a goroutine of its
+// own. Stages built on a Multiplexer, such as debouncers or long-press
+// detectors, can so use any number of timers without goroutines, and need
+// no locking against their ReadEvent. f runs only while ReadEvent is being
+// called, as it is by a started Keyboard; it must not call ReadEvent.
+func (m *Multiplexer) AfterFunc(d time.Duration, f func()) *MuxTimer {
+	t := &MuxTimer{m: m, at: time.Now().Add(d), f: f}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	heap.Push(&m.timers, t)
+	m.wakeReader()
+	return t
+}
+
+// Stop prevents the timer from running. It returns false if the timer has
+// already run or been stopped.
+func (t *MuxTimer) Stop() bool {
+	t.m.mu.Lock()
+	defer t.m.mu.Unlock()
+	if t.index < 0 {
+		return false
+	}
+	heap.Remove(&t.m.timers, t.index)
+	return true
+}
+
+// Do queues the control command f to be run by the goroutine reading from
+// m, between events, and returns without waiting for it. Commands run in
+// the order they were queued, before timers due at the same time. As with
+// AfterFunc, f runs only while ReadEvent is being called, and must not call
+// it.
+func (m *Multiplexer) Do(f func()) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.commands = append(m.commands, f)
+	m.wakeReader()
+}
+
+// wakeReader restarts an epoll_wait in progress, so that it sees new
+// timers and commands. m.mu must be held.
+func (m *Multiplexer) wakeReader() {
+	if m.reading && !m.closed {
+		unix.Write(m.wake[1], []byte{0})
+	}
+}
+
+// runScheduled runs the commands queued by Do and the timers that are due,
+// and returns when the next timer is due, or the zero time if there is
+// none. They are run without m.mu held, so they may use m.
+func (m *Multiplexer) runScheduled() time.Time {
+	for {
+		m.mu.Lock()
+		var f func()
+		if len(m.commands) > 0 {
+			f, m.commands = m.commands[0], m.commands[1:]
+		} else if len(m.timers) > 0 && !m.timers[0].at.After(time.Now()) {
+			f = heap.Pop(&m.timers).(*MuxTimer).f
+		}
+		var next time.Time
+		if f == nil && len(m.timers) > 0 {
+			next = m.timers[0].at
+		}
+		m.mu.Unlock()
+		if f == nil {
+			return next
+		}
+		f()
+	}
+}
+
+// Close closes all devices. A blocked ReadEvent returns os.ErrClosed.
+func (m *Multiplexer) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return nil
+	}
+	m.closed = true
+	for fd := range m.devices {
+		m.remove(fd)
+	}
+	for _, t := range m.timers {
+		t.index = -1
+	}
+	m.timers, m.commands = nil, nil
+	if m.reading {
+		unix.Write(m.wake[1], []byte{0}) // the reader closes the rest
+	} else {
+		m.closeFds()
+	}
+	return nil
+}
+
+func (m *Multiplexer) closeFds() {
+	unix.Close(m.wake[0])
+	unix.Close(m.wake[1])
+	unix.Close(m.epfd)
+}
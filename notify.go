@@ -0,0 +1,147 @@
+package kbd
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Changes is the difference in key state between two points in time.
+type Changes struct {
+	Pressed  []KeyCode // keys down that were up before
+	Released []KeyCode // keys up that were down before
+	Seq      uint64    // Seq of the last event included
+}
+
+// Notifier is a low frequency alternative to Frames and Subscriptions for
+// programs that only look at key state, such as status displays on battery
+// powered machines. A value is sent on C at most once per interval, and only
+// after a key has changed, so that an idle keyboard causes no wakeups. The
+// keys changed since the last call of Changes can then be read with Changes.
+type Notifier struct {
+	C <-chan struct{}
+
+	kb       *Keyboard
+	c        chan struct{}
+	interval time.Duration
+
+	mu     sync.Mutex
+	before map[KeyCode]bool // state of keys when they first changed since Changes
+	now    map[KeyCode]bool // current state of the same keys
+	seq    uint64
+	last   time.Time   // when C was last sent on
+	timer  *time.Timer // pending send on C
+	closed bool
+}
+
+// Notify returns a Notifier that wakes its reader at most every interval.
+// Its channel is closed when kb's read loop ends or the Notifier is closed.
+func (kb *Keyboard) Notify(interval time.Duration) *Notifier {
+	c := make(chan struct{}, 1)
+	n := &Notifier{
+		C:        c,
+		kb:       kb,
+		c:        c,
+		interval: interval,
+		before:   map[KeyCode]bool{},
+		now:      map[KeyCode]bool{},
+	}
+
+	kb.mu.Lock()
+	ended := kb.closed
+	if !ended {
+		if kb.notifiers == nil {
+			kb.notifiers = map[*Notifier]bool{}
+		}
+		kb.notifiers[n] = true
+	}
+	kb.mu.Unlock()
+	if ended {
+		n.close()
+	}
+	return n
+}
+
+// Changes returns the keys that changed since the last call (or since the
+// Notifier was created). Keys pressed and released again in between are not
+// included.
+func (n *Notifier) Changes() Changes {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	ch := Changes{Seq: n.seq}
+	for key, down := range n.now {
+		if down == n.before[key] {
+			continue
+		}
+		if down {
+			ch.Pressed = append(ch.Pressed, key)
+		} else {
+			ch.Released = append(ch.Released, key)
+		}
+	}
+	sort.Slice(ch.Pressed, func(i, j int) bool { return ch.Pressed[i] < ch.Pressed[j] })
+	sort.Slice(ch.Released, func(i, j int) bool { return ch.Released[i] < ch.Released[j] })
+	n.before = map[KeyCode]bool{}
+	n.now = map[KeyCode]bool{}
+	return ch
+}
+
+// Close stops the Notifier and closes its channel.
+func (n *Notifier) Close() {
+	n.kb.mu.Lock()
+	delete(n.kb.notifiers, n)
+	n.kb.mu.Unlock()
+	n.close()
+}
+
+func (n *Notifier) close() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.closed {
+		return
+	}
+	n.closed = true
+	if n.timer != nil {
+		n.timer.Stop()
+	}
+	close(n.c)
+}
+
+// update records the changes in frame, and arranges for C to be sent on.
+func (n *Notifier) update(frame Frame) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.closed {
+		return
+	}
+	for _, event := range frame {
+		if _, ok := n.now[event.Code]; !ok {
+			n.before[event.Code] = event.Value != Press
+		}
+		n.now[event.Code] = event.Value == Press
+		n.seq = event.Seq
+	}
+	if n.timer != nil {
+		return // already due
+	}
+	wait := n.interval - time.Since(n.last)
+	if wait < 0 {
+		wait = 0
+	}
+	n.timer = time.AfterFunc(wait, n.wake)
+}
+
+// wake sends on C.
+func (n *Notifier) wake() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.closed {
+		return
+	}
+	n.timer = nil
+	n.last = time.Now()
+	select { // non-blocking channel send
+	case n.c <- struct{}{}:
+	default: // the reader has yet to wake from the last send
+	}
+}
@@ -0,0 +1,122 @@
+package kbd
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// osdModifiers are the modifier keys an OSD shows as part of a combination,
+// in display order.
+var osdModifiers = []struct {
+	name string
+	keys [2]KeyCode
+}{
+	{"Ctrl", [2]KeyCode{KeyLEFTCTRL, KeyRIGHTCTRL}},
+	{"Alt", [2]KeyCode{KeyLEFTALT, KeyRIGHTALT}},
+	{"Super", [2]KeyCode{KeyLEFTMETA, KeyRIGHTMETA}},
+	{"Shift", [2]KeyCode{KeyLEFTSHIFT, KeyRIGHTSHIFT}},
+}
+
+// OSD is a terminal based on-screen display of pressed keys, for use in
+// screencasts. It draws the most recent key presses, with any held modifiers,
+// on the current line of a terminal and clears them after a period without
+// key presses.
+type OSD struct {
+	W       io.Writer     // terminal to draw on
+	Keep    int           // number of presses shown; 8 if 0
+	Timeout time.Duration // inactive time before the display clears; 2s if 0
+
+	mu      sync.Mutex
+	history []string
+	clear   *time.Timer
+}
+
+// Run shows the key presses of kb until its Event() channel is closed. kb must
+// already be started.
+func (o *OSD) Run(kb *Keyboard) {
+	for key := range kb.Event() {
+		if kb.IsDown(key) {
+			o.Show(kb, key)
+		}
+	}
+	o.mu.Lock()
+	if o.clear != nil {
+		o.clear.Stop()
+	}
+	o.mu.Unlock()
+}
+
+// Show adds the press of key to the display, combined with any modifiers held
+// on kb. Presses of the modifiers themselves aren't shown.
+func (o *OSD) Show(kb *Keyboard, key KeyCode) {
+	var parts []string
+	for _, m := range osdModifiers {
+		if key == m.keys[0] || key == m.keys[1] {
+			return
+		}
+		if kb.IsDown(m.keys[0]) || kb.IsDown(m.keys[1]) {
+			parts = append(parts, m.name)
+		}
+	}
+	parts = append(parts, osdName(key))
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	keep, timeout := o.Keep, o.Timeout
+	if keep <= 0 {
+		keep = 8
+	}
+	if timeout <= 0 {
+		timeout = 2 * time.Second
+	}
+
+	o.history = append(o.history, strings.Join(parts, "+"))
+	if len(o.history) > keep {
+		o.history = o.history[len(o.history)-keep:]
+	}
+	o.draw()
+
+	if o.clear != nil {
+		o.clear.Stop()
+	}
+	o.clear = time.AfterFunc(timeout, func() {
+		o.mu.Lock()
+		defer o.mu.Unlock()
+		o.history = o.history[:0]
+		o.draw()
+	})
+}
+
+// draw redraws the current line. o.mu must be held.
+func (o *OSD) draw() {
+	fmt.Fprint(o.W, "\r\x1b[2K", strings.Join(o.history, " "))
+}
+
+// osdName returns a short display name for key.
+func osdName(key KeyCode) string {
+	switch key {
+	case KeySPACE:
+		return "␣"
+	case KeyENTER, KeyKPENTER:
+		return "⏎"
+	case KeyBACKSPACE:
+		return "⌫"
+	case KeyTAB:
+		return "⇥"
+	case KeyUP:
+		return "↑"
+	case KeyDOWN:
+		return "↓"
+	case KeyLEFT:
+		return "←"
+	case KeyRIGHT:
+		return "→"
+	}
+	if r, ok := DefaultKeymap().Rune(key, false); ok && r > ' ' {
+		return strings.ToUpper(string(r))
+	}
+	return key.String()
+}
@@ -0,0 +1,161 @@
+package kbd
+
+import (
+	"sync"
+	"time"
+)
+
+// panicKeys are the keys of the panic key, held together for panicHold.
+var panicKeys = []KeyCode{KeyLEFTCTRL, KeyRIGHTCTRL, KeyBACKSPACE}
+
+const panicHold = 3 * time.Second
+
+// panicPoll is how often the keys held on the grabbed devices are checked for
+// the panic key.
+const panicPoll = 100 * time.Millisecond
+
+// panicState is what the panic key and the guardian undo: the grabs and
+// uinput devices of the whole program. Grabs are keyed by the evdev or
+// muxDevice that took them, and uinput devices by their file, with their fds
+// as values.
+var panicState = struct {
+	mu       sync.Mutex
+	grabbed  map[interface{}]uintptr
+	uinput   map[interface{}]uintptr
+	watching bool // panicWatch is running
+	handler  func()
+
+	guardian int                    // socket to the guardian; -1 if none
+	ids      map[interface{}]uint64 // grabs and uinput devices, for the guardian
+	next     uint64
+}{
+	grabbed:  map[interface{}]uintptr{},
+	uinput:   map[interface{}]uintptr{},
+	guardian: -1,
+	ids:      map[interface{}]uint64{},
+}
+
+// muxDevice is a device of a Multiplexer, as a key of panicState.grabbed.
+type muxDevice struct {
+	m  *Multiplexer
+	fd int
+}
+
+// OnPanicKey sets a function called after the panic key has been used, or
+// none if f is nil. The panic key is an escape hatch, built in so that a
+// buggy remap can't lock the user out of their machine: holding both Ctrl
+// keys and Backspace for 3 seconds, on the devices grabbed by the program,
+// releases every grab it took, destroys its uinput devices (Virtual and
+// VirtualMouse) and resets the controlling terminal, if any, as `stty sane`
+// does. The keys are watched by a goroutine of their own, which asks the
+// kernel which keys are held rather than reading events, so that it works
+// however stuck the Backends and Keyboards reading the devices are. Programs
+// usually exit in f, as their virtual devices are gone.
+func OnPanicKey(f func()) {
+	panicState.mu.Lock()
+	panicState.handler = f
+	panicState.mu.Unlock()
+}
+
+// panicWatch checks the keys held on the grabbed devices for the panic key,
+// every panicPoll until none are grabbed.
+func panicWatch() {
+	t := time.NewTicker(panicPoll)
+	defer t.Stop()
+	var since time.Time // when the panic key was first seen held
+	for range t.C {
+		panicState.mu.Lock()
+		if len(panicState.grabbed) == 0 {
+			panicState.watching = false
+			panicState.mu.Unlock()
+			return
+		}
+		held := panicHeld()
+		panicState.mu.Unlock()
+		switch {
+		case !held:
+			since = time.Time{}
+		case since.IsZero():
+			since = time.Now()
+		case time.Since(since) >= panicHold:
+			since = time.Time{}
+			panicKey()
+		}
+	}
+}
+
+// panicHeld reports whether the keys of the panic key are held, on any of
+// the grabbed devices, with EVIOCGKEY. panicState.mu must be held, so that
+// the fds aren't closed meanwhile.
+func panicHeld() bool {
+	var held [96]byte // KEY_MAX+1 bits, of all the devices
+	for _, fd := range panicState.grabbed {
+		var bits [96]byte
+		if ioctlBytes(fd, eviocg(0x18, uintptr(len(bits))), bits[:]) != nil {
+			continue
+		}
+		for i, b := range bits {
+			held[i] |= b
+		}
+	}
+	for _, k := range panicKeys {
+		if held[k/8]&(1<<(k%8)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// panicKey releases the grabs and destroys the uinput devices, once the
+// panic key has been held long enough.
+func panicKey() {
+	panicState.mu.Lock()
+	for owner, fd := range panicState.grabbed {
+		ioctlInt(fd, eviocGrab, 0)
+		delete(panicState.grabbed, owner)
+		guardianForget(owner)
+	}
+	for owner, fd := range panicState.uinput {
+		ioctlInt(fd, uiDevDestroy, 0)
+		delete(panicState.uinput, owner)
+		guardianForget(owner)
+	}
+	f := panicState.handler
+	panicState.mu.Unlock()
+
+	resetTerminal() // fails if there is no terminal, which is fine
+	if f != nil {
+		f()
+	}
+}
+
+// panicGrabbed records that owner grabbed, or released, the device fd.
+func panicGrabbed(owner interface{}, fd uintptr, grab bool) {
+	panicState.mu.Lock()
+	if grab {
+		panicState.grabbed[owner] = fd
+		guardianKeep(owner, fd, guardianGrab)
+		if !panicState.watching {
+			panicState.watching = true
+			go panicWatch()
+		}
+	} else {
+		delete(panicState.grabbed, owner)
+		guardianForget(owner)
+	}
+	panicState.mu.Unlock()
+}
+
+// panicCreated records that the uinput device of owner was created, or
+// destroyed.
+func panicCreated(owner interface{}, fd uintptr, created bool) {
+	panicState.mu.Lock()
+	if created {
+		panicState.uinput[owner] = fd
+		guardianKeep(owner, fd, guardianUinput)
+	} else {
+		delete(panicState.uinput, owner)
+		guardianForget(owner)
+	}
+	panicState.mu.Unlock()
+}
@@ -0,0 +1,105 @@
+package kbd
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+// Errors returned by PINReader.Read.
+var (
+	ErrTimeout  = errors.New("kbd: timed out waiting for key")
+	ErrCanceled = errors.New("kbd: entry canceled")
+	ErrStopped  = errors.New("kbd: keyboard stopped")
+)
+
+// digits maps the number row and keypad keys to the digit they enter.
+var digits = map[KeyCode]byte{
+	Key0: '0', Key1: '1', Key2: '2', Key3: '3', Key4: '4',
+	Key5: '5', Key6: '6', Key7: '7', Key8: '8', Key9: '9',
+	KeyKP0: '0', KeyKP1: '1', KeyKP2: '2', KeyKP3: '3', KeyKP4: '4',
+	KeyKP5: '5', KeyKP6: '6', KeyKP7: '7', KeyKP8: '8', KeyKP9: '9',
+}
+
+// PINReader collects a numeric code, such as a PIN, from a started Keyboard.
+// Digits are entered with the number row or keypad, Backspace removes the last
+// digit, Enter finishes entry, and ESC cancels it.
+type PINReader struct {
+	Keyboard *Keyboard
+	MaxLen   int           // entry finishes once MaxLen digits are entered; unlimited if 0
+	Timeout  time.Duration // maximum time between key presses; unlimited if 0
+	Mask     rune          // shown in place of each digit; '*' if 0
+
+	// Echo, if not nil, is called with the masked entry each time it changes,
+	// so it can be displayed.
+	Echo func(masked string)
+}
+
+// Read blocks until a code is entered and returns it. ErrTimeout is returned
+// if no key is pressed within Timeout, ErrCanceled if ESC is pressed, and
+// ErrStopped if the Keyboard stops.
+func (p *PINReader) Read() (string, error) {
+	mask := p.Mask
+	if mask == 0 {
+		mask = '*'
+	}
+
+	var code []byte
+	echo := func() {
+		if p.Echo != nil {
+			p.Echo(strings.Repeat(string(mask), len(code)))
+		}
+	}
+
+	for {
+		key, err := p.next()
+		if err != nil {
+			return "", err
+		}
+		if !p.Keyboard.IsDown(key) {
+			continue // only act on presses
+		}
+
+		switch key {
+		case KeyENTER, KeyKPENTER:
+			return string(code), nil
+		case KeyESC:
+			return "", ErrCanceled
+		case KeyBACKSPACE:
+			if len(code) > 0 {
+				code = code[:len(code)-1]
+				echo()
+			}
+		default:
+			d, isDigit := digits[key]
+			if !isDigit {
+				continue
+			}
+			code = append(code, d)
+			echo()
+			if p.MaxLen > 0 && len(code) >= p.MaxLen {
+				return string(code), nil
+			}
+		}
+	}
+}
+
+// next waits up to Timeout for the next key event.
+func (p *PINReader) next() (KeyCode, error) {
+	var timeout <-chan time.Time
+	if p.Timeout > 0 {
+		t := time.NewTimer(p.Timeout)
+		defer t.Stop()
+		timeout = t.C
+	}
+
+	select {
+	case key, ok := <-p.Keyboard.Event():
+		if !ok {
+			return 0, ErrStopped
+		}
+		return key, nil
+	case <-timeout:
+		return 0, ErrTimeout
+	}
+}
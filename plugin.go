@@ -0,0 +1,285 @@
+package kbd
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// Plugin is an external program that provides new kinds of Actions, such as
+// HTTP requests or MQTT messages, so that they can be added to a hotkey
+// daemon without changing it. kbd and the plugin exchange JSON messages, one
+// per line, on the plugin's standard input and output.
+//
+// When it starts, the plugin writes the names of its actions:
+//
+//	{"actions": ["publish", "request"]}
+//
+// Each time one of its Actions runs, kbd writes a request:
+//
+//	{"id": 1, "action": "publish", "args": ["home/light", "toggle"],
+//	 "combo": "ctrl+f5", "key": "F5", "device": "/dev/input/event3"}
+//
+// and the plugin replies, in any order, with the id and an error message,
+// which is empty on success:
+//
+//	{"id": 1, "error": ""}
+//
+// Anything the plugin writes to its standard error is passed through.
+type Plugin struct {
+	actions map[string]bool
+	cmd     *exec.Cmd
+	stdin   io.WriteCloser
+
+	requests chan pluginWrite // to the writer, which writes them to stdin
+	stop     sync.Once        // closes requests
+
+	mu         sync.Mutex
+	next       int
+	pending    map[int]string // ids of requests to their action
+	exited     bool
+	closed     bool
+	handler    func(action string, err error)
+	unreported []pluginError // errors reported before there was a handler
+}
+
+type pluginError struct {
+	action string
+	err    error
+}
+
+// pluginWrite is a request queued for the plugin.
+type pluginWrite struct {
+	action string
+	line   []byte
+}
+
+// maxQueued is how many requests a Plugin queues while the plugin isn't
+// reading them; its Actions fail once that many are queued.
+const maxQueued = 64
+
+// maxUnreported is how many errors a Plugin keeps until it has an error
+// handler.
+const maxUnreported = 16
+
+// PluginHelloTimeout is how long StartPlugin waits for a plugin to list its
+// actions.
+var PluginHelloTimeout = 10 * time.Second
+
+type pluginHello struct {
+	Actions []string `json:"actions"`
+}
+
+type pluginRequest struct {
+	ID     int      `json:"id"`
+	Action string   `json:"action"`
+	Args   []string `json:"args"`
+	Combo  string   `json:"combo"`
+	Key    string   `json:"key"`
+	Device string   `json:"device"`
+}
+
+type pluginReply struct {
+	ID    int    `json:"id"`
+	Error string `json:"error"`
+}
+
+// StartPlugin starts the plugin program name with args, and waits for it to
+// list its actions.
+func StartPlugin(name string, args ...string) (*Plugin, error) {
+	p := &Plugin{
+		cmd:      exec.Command(name, args...),
+		actions:  map[string]bool{},
+		pending:  map[int]string{},
+		requests: make(chan pluginWrite, maxQueued),
+	}
+	p.cmd.Stderr = os.Stderr
+	stdin, err := p.cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := p.cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	p.stdin = stdin
+	if err := p.cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	r := bufio.NewReader(stdout)
+	hello, err := readHello(r)
+	if err != nil {
+		stdin.Close()
+		p.cmd.Process.Kill()
+		p.cmd.Wait()
+		return nil, fmt.Errorf("kbd: plugin %s: bad hello: %v", name, err)
+	}
+	for _, a := range hello.Actions {
+		p.actions[a] = true
+	}
+	go p.read(r)
+	go p.write()
+	return p, nil
+}
+
+// readHello reads the hello of a plugin from r, waiting for it up to
+// PluginHelloTimeout.
+func readHello(r *bufio.Reader) (pluginHello, error) {
+	type result struct {
+		hello pluginHello
+		err   error
+	}
+	done := make(chan result, 1)
+	go func() {
+		var res result
+		var line []byte
+		line, res.err = r.ReadBytes('\n')
+		if res.err == nil {
+			res.err = json.Unmarshal(line, &res.hello)
+		}
+		done <- res
+	}()
+	select {
+	case res := <-done:
+		return res.hello, res.err
+	case <-time.After(PluginHelloTimeout):
+		// The read ends when the plugin is killed.
+		return pluginHello{}, errors.New("timed out")
+	}
+}
+
+// Has reports whether the plugin provides action.
+func (p *Plugin) Has(action string) bool {
+	return p.actions[action]
+}
+
+// Action returns an Action asking the plugin to perform action with args,
+// for the binding of c on kb. The Action doesn't wait for the plugin to
+// finish, nor for the request to be written: requests are queued, and the
+// Action fails if the plugin isn't reading them. Errors are reported to the
+// handler set by SetErrorHandler.
+func (p *Plugin) Action(action string, args []string, c Combo, kb *Keyboard) Action {
+	return func() error {
+		if !p.actions[action] {
+			return fmt.Errorf("kbd: plugin has no action %q", action)
+		}
+		event := kb.Trigger(c)
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		if p.exited || p.closed {
+			return errors.New("kbd: plugin has exited")
+		}
+		p.next++
+		req := pluginRequest{
+			ID:     p.next,
+			Action: action,
+			Args:   args,
+			Combo:  c.String(),
+			Key:    event.Code.String(),
+			Device: event.Device,
+		}
+		b, _ := json.Marshal(req)
+		select { // non-blocking channel send
+		case p.requests <- pluginWrite{action, append(b, '\n')}:
+		default:
+			return errors.New("kbd: plugin is not reading its requests")
+		}
+		p.pending[req.ID] = action
+		return nil
+	}
+}
+
+// write writes the queued requests to the plugin until it is closed, and then
+// closes its standard input.
+func (p *Plugin) write() {
+	for w := range p.requests {
+		if _, err := p.stdin.Write(w.line); err != nil {
+			p.report(w.action, err)
+		}
+	}
+	p.stdin.Close()
+}
+
+// read reads replies until the plugin exits.
+func (p *Plugin) read(r *bufio.Reader) {
+	for {
+		line, err := r.ReadBytes('\n')
+		if err != nil {
+			break
+		}
+		var reply pluginReply
+		if err := json.Unmarshal(line, &reply); err != nil {
+			decodeErrors.inc()
+			p.report("", fmt.Errorf("kbd: bad reply from plugin: %v", err))
+			continue
+		}
+		p.mu.Lock()
+		action := p.pending[reply.ID]
+		delete(p.pending, reply.ID)
+		p.mu.Unlock()
+		if reply.Error != "" {
+			p.report(action, errors.New(reply.Error))
+		}
+	}
+
+	err := p.cmd.Wait()
+	p.mu.Lock()
+	p.exited = true
+	closed := p.closed
+	p.mu.Unlock()
+	p.stop.Do(func() { close(p.requests) }) // ends the writer
+	if err == nil && closed {
+		return // exited as asked
+	}
+	if err == nil {
+		err = errors.New("kbd: plugin exited")
+	}
+	p.report("", err)
+}
+
+// SetErrorHandler sets a function called with the errors reported by the
+// plugin, and when it exits, or none if f is nil. The errors reported before
+// it is set, if any, are passed to it first.
+func (p *Plugin) SetErrorHandler(f func(action string, err error)) {
+	p.mu.Lock()
+	p.handler = f
+	unreported := p.unreported
+	if f != nil {
+		p.unreported = nil
+	}
+	p.mu.Unlock()
+	if f != nil {
+		for _, e := range unreported {
+			f(e.action, e.err)
+		}
+	}
+}
+
+func (p *Plugin) report(action string, err error) {
+	p.mu.Lock()
+	f := p.handler
+	if f == nil && len(p.unreported) < maxUnreported {
+		p.unreported = append(p.unreported, pluginError{action, err})
+	}
+	p.mu.Unlock()
+	if f != nil {
+		f(action, err)
+	}
+}
+
+// Close closes the plugin's standard input, once the requests queued are
+// written, asking it to exit.
+func (p *Plugin) Close() error {
+	p.mu.Lock()
+	p.closed = true
+	p.mu.Unlock()
+	p.stop.Do(func() { close(p.requests) })
+	return nil
+}
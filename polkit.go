@@ -0,0 +1,48 @@
+package kbd
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"strconv"
+)
+
+// ErrNotAuthorized is returned when polkit does not authorize an action.
+var ErrNotAuthorized = errors.New("kbd: not authorized by polkit")
+
+// Authorize asks polkit whether this process may perform the polkit action
+// actionID, such as "org.quillaja.kbd.bind". The user may be asked to
+// authenticate. It uses the `pkcheck` command, and returns ErrNotAuthorized
+// if the action is not authorized.
+//
+// This lets a program run unprivileged, checking for each privileged action
+// that its user is allowed to perform it, rather than running as root.
+func Authorize(actionID string) error {
+	return pkcheck(actionID, "--process", strconv.Itoa(os.Getpid()))
+}
+
+// AuthorizeBusName is like Authorize, but asks about the process owning the
+// unique name on the system bus, such as the caller of a D-Bus method.
+func AuthorizeBusName(actionID, name string) error {
+	return pkcheck(actionID, "--system-bus-name", name)
+}
+
+// Authorized returns an Action that runs a only if this process is authorized
+// for the polkit action actionID.
+func Authorized(actionID string, a Action) Action {
+	return func() error {
+		if err := Authorize(actionID); err != nil {
+			return err
+		}
+		return a()
+	}
+}
+
+func pkcheck(actionID string, subject ...string) error {
+	args := append([]string{"--action-id", actionID, "--allow-user-interaction"}, subject...)
+	err := exec.Command("pkcheck", args...).Run()
+	if e, ok := err.(*exec.ExitError); ok && e.ExitCode() <= 3 {
+		return ErrNotAuthorized // not authorized, or authentication failed
+	}
+	return err
+}
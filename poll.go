@@ -0,0 +1,111 @@
+package kbd
+
+import (
+	"os"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// ErrNoDeadline is returned by OnPoll if the Keyboard's Backend can't time
+// out its reads. It is an UnsupportedError.
+var ErrNoDeadline error = &UnsupportedError{Op: "OnPoll", Feature: FeatureDeadline}
+
+// Deadliner is implemented by Backends whose reads can time out, like an
+// os.File. A read that times out returns an error for which os.IsTimeout is
+// true, and can be retried.
+type Deadliner interface {
+	SetReadDeadline(t time.Time) error
+}
+
+// timeoutError is returned by reads that time out.
+type timeoutError struct{}
+
+func (timeoutError) Error() string { return "kbd: read timed out" }
+func (timeoutError) Timeout() bool { return true }
+
+// SetReadDeadline sets the deadline for reads of the device.
+func (d *evdev) SetReadDeadline(t time.Time) error {
+	return d.file.SetReadDeadline(t)
+}
+
+// SetReadDeadline sets the deadline for ReadEvent, including one in progress.
+// A zero t means no deadline.
+func (m *Multiplexer) SetReadDeadline(t time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return os.ErrClosed
+	}
+	m.deadline = t
+	if m.reading {
+		unix.Write(m.wake[1], []byte{0}) // restart epoll_wait with the new timeout
+	}
+	return nil
+}
+
+// SetReadDeadline sets the deadline for reads of the Backend t reads from, or
+// fails with ErrNoDeadline if it is not a Deadliner.
+func (t *Toggle) SetReadDeadline(deadline time.Time) error {
+	if d, ok := t.b.(Deadliner); ok {
+		return d.SetReadDeadline(deadline)
+	}
+	return ErrNoDeadline
+}
+
+// SetReadDeadline sets the deadline for reads of the Backend r reads from, or
+// fails with ErrNoDeadline if it is not a Deadliner.
+func (r *Remapper) SetReadDeadline(t time.Time) error {
+	if d, ok := r.b.(Deadliner); ok {
+		return d.SetReadDeadline(t)
+	}
+	return ErrNoDeadline
+}
+
+// OnPoll arranges for f to be run by kb's read loop every interval, even
+// when no keys are pressed, so that programs can do housekeeping such as
+// flushing statistics or checking connections without a goroutine of their
+// own. f should return quickly, since events aren't read while it runs. Stop
+// also takes effect within interval, rather than at the next key event. It
+// must be called before Start, and needs a Backend that is a Deadliner; an
+// interval of 0 turns it off.
+func (kb *Keyboard) OnPoll(interval time.Duration, f func()) error {
+	if _, ok := kb.backend.(Deadliner); !ok && interval > 0 {
+		return ErrNoDeadline
+	}
+	kb.mu.Lock()
+	defer kb.mu.Unlock()
+	kb.pollInterval = interval
+	kb.onPoll = f
+	return nil
+}
+
+// pollReader wraps read so that reads time out for OnPoll. A read that times
+// out returns no Frame and no error.
+func (kb *Keyboard) pollReader(read func() (Frame, error)) func() (Frame, error) {
+	kb.mu.Lock()
+	interval, f := kb.pollInterval, kb.onPoll
+	kb.mu.Unlock()
+	d, ok := kb.backend.(Deadliner)
+	if interval <= 0 || !ok {
+		return read
+	}
+
+	next := time.Now().Add(interval)
+	return func() (Frame, error) {
+		if err := d.SetReadDeadline(next); err != nil {
+			return nil, err
+		}
+		frame, err := read()
+		if err != nil && !os.IsTimeout(err) {
+			return nil, err
+		}
+		if now := time.Now(); !now.Before(next) {
+			if f != nil {
+				f()
+			}
+			next = now.Add(interval)
+		}
+		return frame, nil
+	}
+}
@@ -0,0 +1,113 @@
+package kbd
+
+// SlideAction is a high level command sent by a presentation remote.
+type SlideAction int
+
+// Actions reported by a Presenter.
+const (
+	SlideNext  SlideAction = iota // go to the next slide
+	SlidePrev                     // go to the previous slide
+	SlideBlank                    // blank (or unblank) the screen
+	SlideStart                    // start the slideshow
+	SlideStop                     // end the slideshow
+)
+
+func (a SlideAction) String() string {
+	switch a {
+	case SlideNext:
+		return "next"
+	case SlidePrev:
+		return "prev"
+	case SlideBlank:
+		return "blank"
+	case SlideStart:
+		return "start"
+	case SlideStop:
+		return "stop"
+	}
+	return "unknown"
+}
+
+// PresenterKeys maps the keys sent by common presentation remotes to the
+// SlideAction they perform.
+var PresenterKeys = map[KeyCode]SlideAction{
+	KeyPAGEDOWN: SlideNext,
+	KeyRIGHT:    SlideNext,
+	KeyDOWN:     SlideNext,
+	KeySPACE:    SlideNext,
+	KeyPAGEUP:   SlidePrev,
+	KeyLEFT:     SlidePrev,
+	KeyUP:       SlidePrev,
+	KeyB:        SlideBlank,
+	KeyDOT:      SlideBlank,
+	KeyF5:       SlideStart,
+	KeyESC:      SlideStop,
+}
+
+// Presenter translates the keys of a presentation remote into SlideActions,
+// so slide control tools need no knowledge of KeyCodes. Like Button, it reads
+// its device directly and doesn't need the terminal.
+type Presenter struct {
+	b      Backend
+	events chan SlideAction
+	err    error
+}
+
+// OpenPresenter opens the evdev device at path and begins reading
+// SlideActions from it.
+func OpenPresenter(path string) (*Presenter, error) {
+	b, err := openEvdev(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewPresenter(b), nil
+}
+
+// NewPresenter is like OpenPresenter, but reads events from b.
+func NewPresenter(b Backend) *Presenter {
+	p := &Presenter{
+		b:      b,
+		events: make(chan SlideAction, 16),
+	}
+
+	go func() {
+		defer close(p.events)
+		for {
+			event, err := b.ReadEvent()
+			if err != nil {
+				p.err = err
+				return
+			}
+			if event.Value != Press {
+				continue
+			}
+			action, ok := PresenterKeys[event.Code]
+			if !ok {
+				continue
+			}
+			select { // non-blocking channel send
+			case p.events <- action:
+			default:
+			}
+		}
+	}()
+	return p
+}
+
+// Events returns the channel on which SlideActions are delivered. Actions are
+// dropped if the channel's buffer is full. The channel is closed when the
+// Presenter is closed or reading fails.
+func (p *Presenter) Events() <-chan SlideAction {
+	return p.events
+}
+
+// Err reads the error that ended reading. It should only be called after the
+// Events channel is closed.
+func (p *Presenter) Err() error {
+	return p.err
+}
+
+// Close closes the device.
+func (p *Presenter) Close() error {
+	return p.b.Close()
+}
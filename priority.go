@@ -0,0 +1,85 @@
+package kbd
+
+// IsModifier reports whether key is a Ctrl, Shift, Alt, or Meta key.
+func IsModifier(key KeyCode) bool {
+	switch key {
+	case KeyLEFTCTRL, KeyRIGHTCTRL, KeyLEFTSHIFT, KeyRIGHTSHIFT,
+		KeyLEFTALT, KeyRIGHTALT, KeyLEFTMETA, KeyRIGHTMETA:
+		return true
+	}
+	return false
+}
+
+// modifiersFirst is the Backend returned by ModifiersFirst.
+type modifiersFirst struct {
+	b      Backend
+	reads  chan readResult
+	queued []readResult
+}
+
+// ModifiersFirst returns a Backend that reads ahead from b and, among queued
+// events with the same timestamp (those the device reported together), returns
+// modifier events before other keys. Events are never moved ahead of events
+// with an earlier timestamp. This keeps combination detection robust when
+// several keys change at once and the consumer falls behind.
+func ModifiersFirst(b Backend) Backend {
+	m := &modifiersFirst{
+		b:     b,
+		reads: make(chan readResult, 256),
+	}
+	go func() {
+		for {
+			event, err := b.ReadEvent()
+			m.reads <- readResult{event, err}
+			if err != nil {
+				close(m.reads)
+				return
+			}
+		}
+	}()
+	return m
+}
+
+func (m *modifiersFirst) ReadEvent() (Event, error) {
+	if len(m.queued) == 0 {
+		r, ok := <-m.reads
+		if !ok {
+			return Event{}, errClosedBackend
+		}
+		m.queued = append(m.queued, r)
+	}
+	for more := true; more; { // take everything already read
+		select {
+		case r, ok := <-m.reads:
+			if ok {
+				m.queued = append(m.queued, r)
+			} else {
+				more = false
+			}
+		default:
+			more = false
+		}
+	}
+
+	pick := 0
+	first := m.queued[0]
+	if first.err == nil && !IsModifier(first.event.Code) {
+		for i, r := range m.queued {
+			if r.err != nil || !r.event.Time.Equal(first.event.Time) {
+				break
+			}
+			if IsModifier(r.event.Code) {
+				pick = i
+				break
+			}
+		}
+	}
+
+	r := m.queued[pick]
+	m.queued = append(m.queued[:pick], m.queued[pick+1:]...)
+	return r.event, r.err
+}
+
+func (m *modifiersFirst) Close() error {
+	return m.b.Close()
+}
@@ -0,0 +1,169 @@
+package kbd
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+)
+
+// QMK keycodes outside the basic (HID keyboard page) range that have a
+// KeyCode.
+var qmkExtra = map[uint16]KeyCode{
+	0xa5: KeyPOWER,
+	0xa6: KeySLEEP,
+	0xa7: KeyWAKEUP,
+	0xa8: KeyMUTE,
+	0xa9: KeyVOLUMEUP,
+	0xaa: KeyVOLUMEDOWN,
+	0xab: KeyNEXTSONG,
+	0xac: KeyPREVIOUSSONG,
+	0xad: KeySTOPCD,
+	0xae: KeyPLAYPAUSE,
+	0xaf: KeyMEDIA,
+	0xb0: KeyEJECTCD,
+	0xb1: KeyMAIL,
+	0xb2: KeyCALC,
+	0xb3: KeyCOMPUTER,
+	0xb4: KeySEARCH,
+	0xb5: KeyHOMEPAGE,
+	0xb6: KeyBACK,
+	0xb7: KeyFORWARD,
+	0xb8: KeySTOP,
+	0xb9: KeyREFRESH,
+	0xba: KeyBOOKMARKS,
+	0xbb: KeyFASTFORWARD,
+	0xbc: KeyREWIND,
+	0xbd: KeyBRIGHTNESSUP,
+	0xbe: KeyBRIGHTNESSDOWN,
+}
+
+// qmkNames maps QMK keycode names, both full and abbreviated, to keycodes.
+var qmkNames = map[string]uint16{
+	"KC_NO": 0x00, "XXXXXXX": 0x00, "KC_TRANSPARENT": 0x01, "KC_TRNS": 0x01, "_______": 0x01,
+
+	"KC_A": 0x04, "KC_B": 0x05, "KC_C": 0x06, "KC_D": 0x07, "KC_E": 0x08, "KC_F": 0x09,
+	"KC_G": 0x0a, "KC_H": 0x0b, "KC_I": 0x0c, "KC_J": 0x0d, "KC_K": 0x0e, "KC_L": 0x0f,
+	"KC_M": 0x10, "KC_N": 0x11, "KC_O": 0x12, "KC_P": 0x13, "KC_Q": 0x14, "KC_R": 0x15,
+	"KC_S": 0x16, "KC_T": 0x17, "KC_U": 0x18, "KC_V": 0x19, "KC_W": 0x1a, "KC_X": 0x1b,
+	"KC_Y": 0x1c, "KC_Z": 0x1d,
+	"KC_1": 0x1e, "KC_2": 0x1f, "KC_3": 0x20, "KC_4": 0x21, "KC_5": 0x22,
+	"KC_6": 0x23, "KC_7": 0x24, "KC_8": 0x25, "KC_9": 0x26, "KC_0": 0x27,
+
+	"KC_ENTER": 0x28, "KC_ENT": 0x28, "KC_ESCAPE": 0x29, "KC_ESC": 0x29,
+	"KC_BACKSPACE": 0x2a, "KC_BSPC": 0x2a, "KC_TAB": 0x2b, "KC_SPACE": 0x2c, "KC_SPC": 0x2c,
+	"KC_MINUS": 0x2d, "KC_MINS": 0x2d, "KC_EQUAL": 0x2e, "KC_EQL": 0x2e,
+	"KC_LEFT_BRACKET": 0x2f, "KC_LBRC": 0x2f, "KC_RIGHT_BRACKET": 0x30, "KC_RBRC": 0x30,
+	"KC_BACKSLASH": 0x31, "KC_BSLS": 0x31, "KC_NONUS_HASH": 0x32, "KC_NUHS": 0x32,
+	"KC_SEMICOLON": 0x33, "KC_SCLN": 0x33, "KC_QUOTE": 0x34, "KC_QUOT": 0x34,
+	"KC_GRAVE": 0x35, "KC_GRV": 0x35, "KC_COMMA": 0x36, "KC_COMM": 0x36, "KC_DOT": 0x37,
+	"KC_SLASH": 0x38, "KC_SLSH": 0x38, "KC_CAPS_LOCK": 0x39, "KC_CAPS": 0x39,
+
+	"KC_F1": 0x3a, "KC_F2": 0x3b, "KC_F3": 0x3c, "KC_F4": 0x3d, "KC_F5": 0x3e, "KC_F6": 0x3f,
+	"KC_F7": 0x40, "KC_F8": 0x41, "KC_F9": 0x42, "KC_F10": 0x43, "KC_F11": 0x44, "KC_F12": 0x45,
+
+	"KC_PRINT_SCREEN": 0x46, "KC_PSCR": 0x46, "KC_SCROLL_LOCK": 0x47, "KC_SCRL": 0x47,
+	"KC_PAUSE": 0x48, "KC_PAUS": 0x48, "KC_INSERT": 0x49, "KC_INS": 0x49, "KC_HOME": 0x4a,
+	"KC_PAGE_UP": 0x4b, "KC_PGUP": 0x4b, "KC_DELETE": 0x4c, "KC_DEL": 0x4c, "KC_END": 0x4d,
+	"KC_PAGE_DOWN": 0x4e, "KC_PGDN": 0x4e, "KC_RIGHT": 0x4f, "KC_RGHT": 0x4f,
+	"KC_LEFT": 0x50, "KC_DOWN": 0x51, "KC_UP": 0x52,
+
+	"KC_NUM_LOCK": 0x53, "KC_NUM": 0x53, "KC_KP_SLASH": 0x54, "KC_PSLS": 0x54,
+	"KC_KP_ASTERISK": 0x55, "KC_PAST": 0x55, "KC_KP_MINUS": 0x56, "KC_PMNS": 0x56,
+	"KC_KP_PLUS": 0x57, "KC_PPLS": 0x57, "KC_KP_ENTER": 0x58, "KC_PENT": 0x58,
+	"KC_KP_1": 0x59, "KC_P1": 0x59, "KC_KP_2": 0x5a, "KC_P2": 0x5a, "KC_KP_3": 0x5b, "KC_P3": 0x5b,
+	"KC_KP_4": 0x5c, "KC_P4": 0x5c, "KC_KP_5": 0x5d, "KC_P5": 0x5d, "KC_KP_6": 0x5e, "KC_P6": 0x5e,
+	"KC_KP_7": 0x5f, "KC_P7": 0x5f, "KC_KP_8": 0x60, "KC_P8": 0x60, "KC_KP_9": 0x61, "KC_P9": 0x61,
+	"KC_KP_0": 0x62, "KC_P0": 0x62, "KC_KP_DOT": 0x63, "KC_PDOT": 0x63,
+	"KC_NONUS_BACKSLASH": 0x64, "KC_NUBS": 0x64, "KC_APPLICATION": 0x65, "KC_APP": 0x65,
+	"KC_KB_POWER": 0x66, "KC_KP_EQUAL": 0x67, "KC_PEQL": 0x67,
+
+	"KC_F13": 0x68, "KC_F14": 0x69, "KC_F15": 0x6a, "KC_F16": 0x6b, "KC_F17": 0x6c, "KC_F18": 0x6d,
+	"KC_F19": 0x6e, "KC_F20": 0x6f, "KC_F21": 0x70, "KC_F22": 0x71, "KC_F23": 0x72, "KC_F24": 0x73,
+
+	"KC_SYSTEM_POWER": 0xa5, "KC_PWR": 0xa5, "KC_SYSTEM_SLEEP": 0xa6, "KC_SLEP": 0xa6,
+	"KC_SYSTEM_WAKE": 0xa7, "KC_WAKE": 0xa7, "KC_AUDIO_MUTE": 0xa8, "KC_MUTE": 0xa8,
+	"KC_AUDIO_VOL_UP": 0xa9, "KC_VOLU": 0xa9, "KC_AUDIO_VOL_DOWN": 0xaa, "KC_VOLD": 0xaa,
+	"KC_MEDIA_NEXT_TRACK": 0xab, "KC_MNXT": 0xab, "KC_MEDIA_PREV_TRACK": 0xac, "KC_MPRV": 0xac,
+	"KC_MEDIA_STOP": 0xad, "KC_MSTP": 0xad, "KC_MEDIA_PLAY_PAUSE": 0xae, "KC_MPLY": 0xae,
+	"KC_MEDIA_SELECT": 0xaf, "KC_MSEL": 0xaf, "KC_MEDIA_EJECT": 0xb0, "KC_EJCT": 0xb0,
+	"KC_MAIL": 0xb1, "KC_CALCULATOR": 0xb2, "KC_CALC": 0xb2, "KC_MY_COMPUTER": 0xb3, "KC_MYCM": 0xb3,
+	"KC_WWW_SEARCH": 0xb4, "KC_WSCH": 0xb4, "KC_WWW_HOME": 0xb5, "KC_WHOM": 0xb5,
+	"KC_WWW_BACK": 0xb6, "KC_WBAK": 0xb6, "KC_WWW_FORWARD": 0xb7, "KC_WFWD": 0xb7,
+	"KC_WWW_STOP": 0xb8, "KC_WSTP": 0xb8, "KC_WWW_REFRESH": 0xb9, "KC_WREF": 0xb9,
+	"KC_WWW_FAVORITES": 0xba, "KC_WFAV": 0xba, "KC_MEDIA_FAST_FORWARD": 0xbb, "KC_MFFD": 0xbb,
+	"KC_MEDIA_REWIND": 0xbc, "KC_MRWD": 0xbc, "KC_BRIGHTNESS_UP": 0xbd, "KC_BRIU": 0xbd,
+	"KC_BRIGHTNESS_DOWN": 0xbe, "KC_BRID": 0xbe,
+
+	"KC_LEFT_CTRL": 0xe0, "KC_LCTL": 0xe0, "KC_LEFT_SHIFT": 0xe1, "KC_LSFT": 0xe1,
+	"KC_LEFT_ALT": 0xe2, "KC_LALT": 0xe2, "KC_LEFT_GUI": 0xe3, "KC_LGUI": 0xe3,
+	"KC_RIGHT_CTRL": 0xe4, "KC_RCTL": 0xe4, "KC_RIGHT_SHIFT": 0xe5, "KC_RSFT": 0xe5,
+	"KC_RIGHT_ALT": 0xe6, "KC_RALT": 0xe6, "KC_RIGHT_GUI": 0xe7, "KC_RGUI": 0xe7,
+}
+
+// ToQMK returns the QMK keycode for key, and false if QMK has no basic
+// keycode for it.
+func ToQMK(key KeyCode) (uint16, bool) {
+	for code, k := range qmkExtra {
+		if k == key {
+			return code, true
+		}
+	}
+	usage, ok := hidUsage[key]
+	if !ok || (usage > 0xa4 && usage < 0xe0) {
+		return 0, false
+	}
+	return uint16(usage), true
+}
+
+// FromQMK returns the KeyCode for a basic QMK keycode, and false if it
+// doesn't have one. Keycodes with modifiers or special functions, such as
+// layer keys, have no KeyCode.
+func FromQMK(code uint16) (KeyCode, bool) {
+	if key, ok := qmkExtra[code]; ok {
+		return key, true
+	}
+	if code > 0xa4 && code < 0xe0 || code > 0xe7 {
+		return 0, false
+	}
+	key, ok := hidKeyboard[uint8(code)]
+	return key, ok
+}
+
+// ParseQMK returns the KeyCode for a QMK keycode name, such as "KC_ESC" or
+// "KC_LEFT_SHIFT", and false if it has none.
+func ParseQMK(name string) (KeyCode, bool) {
+	code, ok := qmkNames[strings.ToUpper(strings.TrimSpace(name))]
+	if !ok {
+		return 0, false
+	}
+	return FromQMK(code)
+}
+
+// VIAKeymap is a keymap saved by the VIA configurator.
+type VIAKeymap struct {
+	Name            string     `json:"name"`
+	VendorProductID uint32     `json:"vendorProductId"`
+	Layers          [][]string `json:"layers"` // QMK keycode names, in matrix order
+}
+
+// ReadVIAKeymap decodes a VIA keymap from its JSON form.
+func ReadVIAKeymap(r io.Reader) (*VIAKeymap, error) {
+	var m VIAKeymap
+	if err := json.NewDecoder(r).Decode(&m); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// KeyCodes returns the KeyCode of each key on layer, with 0 (KeyRESERVED)
+// for keys without one, such as layer switches and transparent keys.
+func (m *VIAKeymap) KeyCodes(layer int) []KeyCode {
+	if layer < 0 || layer >= len(m.Layers) {
+		return nil
+	}
+	keys := make([]KeyCode, len(m.Layers[layer]))
+	for i, name := range m.Layers[layer] {
+		keys[i], _ = ParseQMK(name)
+	}
+	return keys
+}
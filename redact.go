@@ -0,0 +1,60 @@
+package kbd
+
+import "unicode"
+
+// KeyRedacted replaces the keys hidden by Redact. Like the key groups, it is
+// above the kernel's KEY_MAX, so it never appears in events from devices.
+const KeyRedacted KeyCode = 0x3ff
+
+func init() {
+	keyNames[KeyRedacted] = "REDACTED"
+}
+
+// IsRedacted reports whether Redact hides key: letters, digits, punctuation
+// and space, which is to say keys that type a printable character in a known
+// Keymap. Structural keys, such as Enter, Tab, Backspace, the modifiers, and
+// the arrow and function keys, are not hidden.
+func IsRedacted(key KeyCode) bool {
+	return printable(key)
+}
+
+// printable reports whether key types a printable character in a known
+// Keymap.
+func printable(key KeyCode) bool {
+	for _, m := range keymaps {
+		if r, ok := m.Rune(key, false); ok && unicode.IsPrint(r) {
+			return true
+		}
+	}
+	return false
+}
+
+// Redact returns event with its key replaced by KeyRedacted if IsRedacted
+// hides it. It can be applied to events before they are logged, recorded or
+// sent elsewhere, to diagnose problems without revealing what was typed.
+func Redact(event Event) Event {
+	if IsRedacted(event.Code) {
+		event.Code = KeyRedacted
+	}
+	return event
+}
+
+// redacted is the Backend returned by Redacted.
+type redacted struct {
+	b Backend
+}
+
+// Redacted returns a Backend that reads events from b and passes them through
+// Redact.
+func Redacted(b Backend) Backend {
+	return &redacted{b: b}
+}
+
+func (r *redacted) ReadEvent() (Event, error) {
+	event, err := r.b.ReadEvent()
+	return Redact(event), err
+}
+
+func (r *redacted) Close() error {
+	return r.b.Close()
+}
@@ -0,0 +1,246 @@
+package kbd
+
+import (
+	"sync"
+	"time"
+)
+
+// Remapper is a Backend that replaces keys read from another Backend and
+// emits the result on a Virtual keyboard, so that the whole system sees the
+// remapped keys. The device is grabbed (if the Backend is a Grabber) so the
+// original keys are seen by no other program. ReadEvent returns the remapped
+// events. Keys can also be made dual-function keys with SetDual, and the
+// keys of some devices can be remapped differently with SetDevice.
+type Remapper struct {
+	b Backend
+	v *Virtual
+
+	mu      sync.Mutex
+	keys    map[KeyCode]KeyCode
+	down    map[heldKey]KeyCode // held keys and the keys they were emitted as
+	dual    map[KeyCode]DualKey
+	term    time.Duration
+	devices map[string]deviceMaps // by device path
+	pending *pendingDual          // dual-function key pressed, not yet a tap or hold
+	queue   []readResult          // events emitted, not yet returned by ReadEvent
+}
+
+// NewRemapper creates a Remapper reading from b and emitting on v, replacing
+// each key in keys by its value. If v is nil, nothing is emitted and the
+// remapped events are only returned by ReadEvent, for trying out key maps
+// on recorded events without touching any device.
+func NewRemapper(b Backend, v *Virtual, keys map[KeyCode]KeyCode) (*Remapper, error) {
+	if g, ok := b.(Grabber); ok {
+		if err := g.Grab(true); err != nil {
+			return nil, err
+		}
+	}
+	r := &Remapper{
+		b:       b,
+		v:       v,
+		down:    map[heldKey]KeyCode{},
+		dual:    map[KeyCode]DualKey{},
+		devices: map[string]deviceMaps{},
+	}
+	r.SetKeys(keys)
+	return r, nil
+}
+
+// SetKeys replaces the key map. Keys held down keep the mapping they were
+// pressed with until they are released.
+func (r *Remapper) SetKeys(keys map[KeyCode]KeyCode) {
+	m := make(map[KeyCode]KeyCode, len(keys))
+	for from, to := range keys {
+		m[from] = to
+	}
+	r.mu.Lock()
+	r.keys = m
+	r.mu.Unlock()
+}
+
+// heldKey is a key held down on a device, as the same key may be held on
+// several devices that remap it differently.
+type heldKey struct {
+	device string
+	code   KeyCode
+}
+
+// deviceMaps are the maps of a device set with SetDevice.
+type deviceMaps struct {
+	keys map[KeyCode]KeyCode
+	dual map[KeyCode]DualKey
+}
+
+// SetDevice makes the keys read from the device at path, as in the Device
+// of their Events, remapped by keys and dual instead of the maps set with
+// SetKeys and SetDual, such as to remap an external keyboard and leave the
+// laptop's own untouched (with empty maps). The tapping term is shared.
+func (r *Remapper) SetDevice(path string, keys map[KeyCode]KeyCode, dual map[KeyCode]DualKey) {
+	m := deviceMaps{keys: map[KeyCode]KeyCode{}, dual: map[KeyCode]DualKey{}}
+	for from, to := range keys {
+		m.keys[from] = to
+	}
+	for key, d := range dual {
+		m.dual[key] = d
+	}
+	r.mu.Lock()
+	r.devices[path] = m
+	r.mu.Unlock()
+}
+
+// ClearDevices removes the maps set with SetDevice, so that all devices are
+// remapped alike again.
+func (r *Remapper) ClearDevices() {
+	r.mu.Lock()
+	r.devices = map[string]deviceMaps{}
+	r.mu.Unlock()
+}
+
+func (r *Remapper) ReadEvent() (Event, error) {
+	for {
+		r.mu.Lock()
+		if len(r.queue) > 0 {
+			res := r.queue[0]
+			r.queue = r.queue[1:]
+			r.mu.Unlock()
+			return res.event, res.err
+		}
+		r.mu.Unlock()
+
+		event, err := r.b.ReadEvent()
+		if err != nil {
+			return event, err
+		}
+		r.mu.Lock()
+		r.remap(event)
+		r.mu.Unlock()
+	}
+}
+
+// remap emits the events that event is remapped to, if any. r.mu must be
+// held.
+func (r *Remapper) remap(event Event) {
+	if p := r.pending; p != nil && event.Code != p.key && event.Value == Press {
+		r.resolveHold() // another key pressed: the dual-function key is held
+	}
+
+	to, held := r.down[heldKey{event.Device, event.Code}]
+	if !held {
+		if p := r.pending; p != nil && event.Code == p.key {
+			if event.Value == Release { // released before the tapping term
+				p.timer.Stop()
+				r.pending = nil
+				r.emit(event, p.dual.Tap, Press)
+				r.emit(event, p.dual.Tap, Release)
+			}
+			return // repeats of a pending key are dropped
+		}
+		keys, dual := r.keys, r.dual
+		if m, ok := r.devices[event.Device]; ok {
+			keys, dual = m.keys, m.dual
+		}
+		if d, ok := dual[event.Code]; ok && event.Value == Press {
+			r.pend(event, d)
+			return
+		}
+		to = event.Code
+		if k, ok := keys[event.Code]; ok {
+			to = k
+		}
+	}
+	switch event.Value {
+	case Press:
+		r.down[heldKey{event.Device, event.Code}] = to
+	case Release:
+		delete(r.down, heldKey{event.Device, event.Code})
+	}
+	r.emit(event, to, event.Value)
+}
+
+// emit emits event as key with value, on the Virtual keyboard and from
+// ReadEvent. r.mu must be held.
+func (r *Remapper) emit(event Event, key KeyCode, value int32) {
+	event.Code, event.Value = key, value
+	var err error
+	if r.v != nil {
+		err = r.v.Send(key, value)
+	}
+	r.queue = append(r.queue, readResult{event, err})
+}
+
+// Close releases the grab and closes the underlying Backend. The Virtual
+// keyboard is left open.
+func (r *Remapper) Close() error {
+	r.mu.Lock()
+	if r.pending != nil {
+		r.pending.timer.Stop()
+		r.pending = nil
+	}
+	r.mu.Unlock()
+	if g, ok := r.b.(Grabber); ok {
+		g.Grab(false)
+	}
+	return r.b.Close()
+}
+
+// DualKey is the pair of keys a dual-function key is remapped to: Tap when it
+// is tapped, and Hold when it is held, as with CapsLock as Esc when tapped
+// and Ctrl when held.
+type DualKey struct {
+	Tap, Hold KeyCode
+}
+
+// pendingDual is a dual-function key that was pressed, and is not yet known
+// to be tapped or held.
+type pendingDual struct {
+	key   KeyCode
+	dual  DualKey // as when it was pressed
+	event Event   // the press
+	timer *time.Timer
+}
+
+// SetDual replaces the dual-function keys. A key in keys is tapped if it is
+// released within term of its press (200ms if term is 0), and no other key
+// is pressed in between; otherwise it is held. Keys that are held down keep
+// the mapping they were pressed with until they are released.
+//
+// Once term elapses, the Hold key is pressed on the Virtual keyboard at
+// once, so that it works with mouse clicks, but ReadEvent returns its press
+// only along with the next event read.
+func (r *Remapper) SetDual(keys map[KeyCode]DualKey, term time.Duration) {
+	if term <= 0 {
+		term = 200 * time.Millisecond
+	}
+	m := make(map[KeyCode]DualKey, len(keys))
+	for key, d := range keys {
+		m[key] = d
+	}
+	r.mu.Lock()
+	r.dual = m
+	r.term = term
+	r.mu.Unlock()
+}
+
+// pend starts deciding whether the dual-function key d pressed by event is
+// tapped or held. r.mu must be held.
+func (r *Remapper) pend(event Event, d DualKey) {
+	p := &pendingDual{key: event.Code, dual: d, event: event}
+	p.timer = time.AfterFunc(r.term, func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		if r.pending == p {
+			r.resolveHold()
+		}
+	})
+	r.pending = p
+}
+
+// resolveHold presses the Hold key of the pending dual-function key. r.mu
+// must be held.
+func (r *Remapper) resolveHold() {
+	p := r.pending
+	r.pending = nil
+	p.timer.Stop()
+	r.down[heldKey{p.event.Device, p.key}] = p.dual.Hold
+	r.emit(p.event, p.dual.Hold, Press)
+}
@@ -0,0 +1,138 @@
+package kbd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+func init() {
+	RegisterBackend("remote", func(path string) (Backend, error) {
+		b, err := openEvdev(path)
+		if err != nil {
+			return nil, err
+		}
+		return NewRemote(b), nil
+	})
+}
+
+// IsRemote reports whether the evdev device at path, such as
+// `/dev/input/event5`, is the input device of an infrared receiver handled
+// by the kernel's rc-core, through which remote controls send KEY_* codes.
+func IsRemote(path string) bool {
+	link, err := os.Readlink(filepath.Join("/sys/class/input", filepath.Base(path), "device/device"))
+	return err == nil && strings.HasPrefix(filepath.Base(link), "rc")
+}
+
+// Remote is a Backend for remote controls, such as the IR remote of a home
+// theater PC, which wraps another Backend reading the remote's device. A
+// remote sends a key's code again and again while it is held, and many
+// receivers turn that into a press and release for each code, with gaps of
+// a hundred milliseconds or more in between. Remote joins them up again: a
+// release followed within Gap by a press of the same key is read as a
+// Repeat of a key still held. Releases are therefore read up to Gap late.
+// The "remote" Backend opens evdev devices this way.
+type Remote struct {
+	Gap time.Duration // 250ms if 0
+
+	b        Backend
+	reads    chan readResult
+	done     chan struct{} // closed by Close, to end the reads
+	releases []Event       // releases held back, in order
+	pending  []Event
+	err      error
+}
+
+// NewRemote creates a Remote reading from b.
+func NewRemote(b Backend) *Remote {
+	return &Remote{b: b, done: make(chan struct{})}
+}
+
+func (r *Remote) ReadEvent() (Event, error) {
+	if r.reads == nil {
+		r.reads = make(chan readResult)
+		go func() {
+			for {
+				event, err := r.b.ReadEvent()
+				select {
+				case r.reads <- readResult{event, err}:
+				case <-r.done:
+					return
+				}
+				if err != nil {
+					return
+				}
+			}
+		}()
+	}
+	gap := r.Gap
+	if gap <= 0 {
+		gap = 250 * time.Millisecond
+	}
+
+	for len(r.pending) == 0 && r.err == nil {
+		var timeout <-chan time.Time
+		var t *time.Timer
+		if len(r.releases) > 0 {
+			t = time.NewTimer(time.Until(r.releases[0].Time.Add(gap)))
+			timeout = t.C
+		}
+
+		select {
+		case res := <-r.reads:
+			if res.err != nil {
+				r.err = res.err
+				r.pending = append(r.pending, r.releases...)
+				r.releases = nil
+			} else {
+				r.join(res.event)
+			}
+		case <-timeout:
+			r.pending = append(r.pending, r.releases[0]) // the key wasn't pressed again
+			r.releases = r.releases[1:]
+		case <-r.done:
+			r.err = os.ErrClosed
+		}
+		if t != nil {
+			t.Stop()
+		}
+	}
+	if len(r.pending) == 0 {
+		return Event{}, r.err
+	}
+	event := r.pending[0]
+	r.pending = r.pending[1:]
+	return event, nil
+}
+
+// join handles event, turning a press following a held back release of the
+// same key into a Repeat.
+func (r *Remote) join(event Event) {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	for i, rel := range r.releases {
+		if rel.Code != event.Code {
+			continue
+		}
+		r.releases = append(r.releases[:i], r.releases[i+1:]...)
+		if event.Value == Press {
+			event.Value = Repeat
+		} else {
+			r.pending = append(r.pending, rel) // a release after a release
+		}
+		break
+	}
+	if event.Value == Release {
+		r.releases = append(r.releases, event)
+		return
+	}
+	r.pending = append(r.pending, event)
+}
+
+// Close stops reading and closes the underlying Backend.
+func (r *Remote) Close() error {
+	close(r.done)
+	return r.b.Close()
+}
@@ -0,0 +1,122 @@
+package kbd
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// ErrNoRepeats is returned by MeasureRepeat when the events hold no key
+// repeats to measure.
+var ErrNoRepeats = errors.New("kbd: no key repeats")
+
+// RepeatStats describes the key repeats of a device as measured from its
+// events: the delay between a press and the first repeat, and the interval
+// between repeats. Each is the median of the samples, so that a stray late
+// event doesn't skew it, and comes with the spread of the samples.
+type RepeatStats struct {
+	Delay, DelaySpread       time.Duration // median, and max minus min
+	Interval, IntervalSpread time.Duration
+	Delays, Intervals        int // number of samples of each
+}
+
+// Rate returns the number of repeats per second.
+func (s RepeatStats) Rate() float64 {
+	if s.Interval <= 0 {
+		return 0
+	}
+	return float64(time.Second) / float64(s.Interval)
+}
+
+func (s RepeatStats) String() string {
+	return fmt.Sprintf("delay %v (±%v, %d samples), interval %v (±%v, %d samples), %.1f/s",
+		s.Delay, s.DelaySpread/2, s.Delays, s.Interval, s.IntervalSpread/2, s.Intervals, s.Rate())
+}
+
+// MeasureRepeat measures the key repeats in events, such as events read
+// from a Backend while a key is held down for a few seconds. The times the
+// kernel stamped the events with are used, so the measurement isn't thrown
+// off by the latency of reading them.
+func MeasureRepeat(events []Event) (RepeatStats, error) {
+	var delays, intervals []time.Duration
+	last := map[KeyCode]Event{} // last press or repeat of each held key
+	for _, e := range events {
+		prev, held := last[e.Code]
+		switch {
+		case e.Value == Press:
+			last[e.Code] = e
+		case e.Value == Repeat && held:
+			if prev.Value == Press {
+				delays = append(delays, e.Since(prev))
+			} else {
+				intervals = append(intervals, e.Since(prev))
+			}
+			last[e.Code] = e
+		default:
+			delete(last, e.Code)
+		}
+	}
+	if len(delays) == 0 && len(intervals) == 0 {
+		return RepeatStats{}, ErrNoRepeats
+	}
+
+	var s RepeatStats
+	s.Delay, s.DelaySpread = medianSpread(delays)
+	s.Interval, s.IntervalSpread = medianSpread(intervals)
+	s.Delays, s.Intervals = len(delays), len(intervals)
+	return s, nil
+}
+
+// medianSpread returns the median of ds and the difference between the
+// largest and smallest of them.
+func medianSpread(ds []time.Duration) (median, spread time.Duration) {
+	if len(ds) == 0 {
+		return 0, 0
+	}
+	sort.Slice(ds, func(i, j int) bool { return ds[i] < ds[j] })
+	return ds[len(ds)/2], ds[len(ds)-1] - ds[0]
+}
+
+// RecordRepeat reads events from b until it has seen n repeats of a held key
+// (or the key is released after at least one repeat), and measures them with
+// MeasureRepeat. It is meant to be run while the user holds down a key.
+func RecordRepeat(b Backend, n int) (RepeatStats, error) {
+	var events []Event
+	repeats := 0
+	for repeats < n {
+		e, err := b.ReadEvent()
+		if err != nil {
+			return RepeatStats{}, err
+		}
+		events = append(events, e)
+		if e.Value == Repeat {
+			repeats++
+		} else if e.Value == Release && repeats > 0 {
+			break
+		}
+	}
+	return MeasureRepeat(events)
+}
+
+// RepeatReader is implemented by Backends that can report the key repeat
+// settings of their device: the delay before a held key starts repeating,
+// and the interval between repeats. Comparing them with RepeatStats shows
+// whether the settings are in effect. Repeats generated by a program such as
+// a display server, rather than by the kernel, don't appear in the device's
+// events at all.
+type RepeatReader interface {
+	RepeatSettings() (delay, interval time.Duration, err error)
+}
+
+// eviocgRep is the EVIOCGREP ioctl.
+var eviocgRep = eviocg(0x03, 8)
+
+// RepeatSettings reads the device's repeat settings with EVIOCGREP.
+func (d *evdev) RepeatSettings() (delay, interval time.Duration, err error) {
+	var rep [2]uint32 // REP_DELAY and REP_PERIOD, in milliseconds
+	if err := ioctlData(d.file.Fd(), eviocgRep, &rep); err != nil {
+		return 0, 0, err
+	}
+	return time.Duration(rep[0]) * time.Millisecond, time.Duration(rep[1]) * time.Millisecond, nil
+}
@@ -0,0 +1,165 @@
+package kbd
+
+import (
+	"sync"
+	"time"
+)
+
+// TakeBreak is published by a TypingRhythm when the user has typed long
+// enough without a break to be reminded to take one.
+type TakeBreak struct {
+	Time   time.Time
+	Rest   bool          // a rest break is due, rather than a micro-break
+	Typing time.Duration // time typed since the last break of the kind
+}
+
+// RhythmStats describes the typing of a session: the time since the user
+// last took a rest break.
+type RhythmStats struct {
+	Start    time.Time     // first key of the session
+	Length   time.Duration // from Start to the last key
+	Keys     int           // keys pressed in the session
+	Bursts   int           // bursts of typing in the session
+	Rate     float64       // keys per minute of the last burst
+	PeakRate float64       // keys per minute of the most intense burst
+	Pause    time.Time     // end of the last micro-break, or Start
+
+	// Intervals are the lengths of the last sessions, between rest breaks,
+	// oldest first.
+	Intervals []time.Duration
+}
+
+// maxIntervals is how many session lengths RhythmStats keeps.
+const maxIntervals = 16
+
+// TypingRhythm is a Backend that watches the keys read from another Backend
+// for the rhythm of typing, for tools that help avoid repetitive strain
+// injury (RSI) by reminding the user to take breaks. Typing is split into
+// sessions by rest breaks, pauses of at least RestPause, and within them into
+// bursts, keys pressed less than BurstGap apart; pauses of at least
+// MicroPause are micro-breaks. Stats reports the current session. When the
+// user has typed for MicroEvery without a micro-break, or for RestEvery
+// without a rest break, a TakeBreak is published on the Bus of the Keyboard
+// reading from it (see BusPublisher), and again after each further
+// MicroEvery or RestEvery. Times are those of the events, so recorded events
+// can be analyzed too.
+type TypingRhythm struct {
+	BurstGap   time.Duration // 1s if 0
+	MicroPause time.Duration // 30s if 0
+	MicroEvery time.Duration // 10m if 0
+	RestPause  time.Duration // 5m if 0
+	RestEvery  time.Duration // 50m if 0
+
+	b Backend
+
+	mu        sync.Mutex
+	bus       *Bus
+	stats     RhythmStats
+	last      time.Time // last press
+	burst     time.Time // start of the current burst
+	burstKeys int
+	nextMicro time.Time // when the next micro-break reminder is due
+	nextRest  time.Time
+}
+
+// NewTypingRhythm creates a TypingRhythm reading from b.
+func NewTypingRhythm(b Backend) *TypingRhythm {
+	return &TypingRhythm{b: b}
+}
+
+// PublishOn sets the Bus that TakeBreak is published on.
+func (r *TypingRhythm) PublishOn(b *Bus) {
+	r.mu.Lock()
+	r.bus = b
+	r.mu.Unlock()
+	if bp, ok := r.b.(BusPublisher); ok {
+		bp.PublishOn(b)
+	}
+}
+
+// Stats returns the statistics of the current session.
+func (r *TypingRhythm) Stats() RhythmStats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s := r.stats
+	s.Intervals = append([]time.Duration(nil), s.Intervals...)
+	return s
+}
+
+func (r *TypingRhythm) ReadEvent() (Event, error) {
+	event, err := r.b.ReadEvent()
+	if err == nil && event.Value == Press {
+		r.press(event.Time)
+	}
+	return event, err
+}
+
+// press updates the rhythm for a key pressed at t.
+func (r *TypingRhythm) press(t time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	burstGap := orDefault(r.BurstGap, time.Second)
+	microPause := orDefault(r.MicroPause, 30*time.Second)
+	microEvery := orDefault(r.MicroEvery, 10*time.Minute)
+	restPause := orDefault(r.RestPause, 5*time.Minute)
+	restEvery := orDefault(r.RestEvery, 50*time.Minute)
+
+	s := &r.stats
+	gap := t.Sub(r.last)
+	switch {
+	case r.last.IsZero() || gap >= restPause:
+		if !r.last.IsZero() {
+			s.Intervals = append(s.Intervals, s.Length)
+			if len(s.Intervals) > maxIntervals {
+				s.Intervals = s.Intervals[1:]
+			}
+		}
+		*s = RhythmStats{Start: t, Pause: t, Intervals: s.Intervals}
+		r.nextMicro, r.nextRest = t.Add(microEvery), t.Add(restEvery)
+	case gap >= microPause:
+		s.Pause = t
+		r.nextMicro = t.Add(microEvery)
+	}
+	if r.last.IsZero() || gap >= burstGap {
+		s.Bursts++
+		r.burst, r.burstKeys = t, 0
+	}
+	r.last = t
+	r.burstKeys++
+	s.Keys++
+	s.Length = t.Sub(s.Start)
+	if d := t.Sub(r.burst); r.burstKeys > 1 && d > 0 {
+		s.Rate = float64(r.burstKeys-1) / d.Minutes()
+		if s.Rate > s.PeakRate {
+			s.PeakRate = s.Rate
+		}
+	}
+
+	if !t.Before(r.nextRest) {
+		r.nextRest = r.nextRest.Add(restEvery)
+		r.publish(TakeBreak{Time: t, Rest: true, Typing: t.Sub(s.Start)})
+		r.nextMicro = t.Add(microEvery) // one reminder at a time
+	} else if !t.Before(r.nextMicro) {
+		r.nextMicro = r.nextMicro.Add(microEvery)
+		r.publish(TakeBreak{Time: t, Typing: t.Sub(s.Pause)})
+	}
+}
+
+// publish publishes v on r's Bus, if it has one. r.mu must be held.
+func (r *TypingRhythm) publish(v interface{}) {
+	if r.bus != nil {
+		r.bus.Publish(v)
+	}
+}
+
+func (r *TypingRhythm) Close() error {
+	return r.b.Close()
+}
+
+// orDefault returns d, or def if d is not positive.
+func orDefault(d, def time.Duration) time.Duration {
+	if d <= 0 {
+		return def
+	}
+	return d
+}
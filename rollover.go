@@ -0,0 +1,184 @@
+package kbd
+
+import "time"
+
+// DefaultRolloverSequences are the keys RolloverTest asks for by default:
+// the home row, the top row, modifiers with the bottom row, and squares of
+// keys, which ghost on keyboards whose key matrix has no diodes.
+var DefaultRolloverSequences = [][]KeyCode{
+	{KeyA, KeyS, KeyD, KeyF, KeyJ, KeyK, KeyL, KeySEMICOLON, KeyG, KeyH},
+	{KeyQ, KeyW, KeyE, KeyR, KeyU, KeyI, KeyO, KeyP, KeyT, KeyY},
+	{KeyLEFTSHIFT, KeyLEFTCTRL, KeyLEFTALT, KeyZ, KeyX, KeyC, KeyV, KeyB, KeyN, KeyM},
+	{KeyQ, KeyW, KeyA, KeyS},
+	{KeyE, KeyR, KeyD, KeyF, KeyC, KeyV},
+}
+
+// RolloverTest is a test of how many keys a keyboard registers when held at
+// once, its key rollover, which is often 6 for USB keyboards using the boot
+// protocol (6KRO), lower for cheap ones, and unlimited (NKRO) for others.
+// The user is asked to hold more and more keys of each sequence, until the
+// keyboard fails to register one.
+type RolloverTest struct {
+	Sequences [][]KeyCode   // DefaultRolloverSequences if nil
+	Timeout   time.Duration // how long to wait for each key; 5s if 0
+
+	// Prompt is called to ask the user to press next while holding the keys
+	// held. It should tell them to keep waiting if they already are pressing
+	// next, as a key that isn't registered is only known by the time out.
+	Prompt func(held []KeyCode, next KeyCode)
+	// Release is called to ask the user to release all keys before a
+	// sequence, if any are held.
+	Release func()
+}
+
+// RolloverJam is a key that was pressed but not registered, or that was
+// dropped, while the keys Held were held.
+type RolloverJam struct {
+	Held []KeyCode
+	Key  KeyCode
+}
+
+// RolloverGhost is a set of keys that were registered without having been
+// pressed (or pressed by mistake), while the keys Held were held.
+type RolloverGhost struct {
+	Held  []KeyCode
+	Ghost []KeyCode
+}
+
+// RolloverReport is the result of a RolloverTest.
+type RolloverReport struct {
+	// Rollover is the number of keys the keyboard registered at once in
+	// every sequence: the fewest keys held in any sequence when a key
+	// jammed, or the length of the longest sequence if none did, in which
+	// case the keyboard may well have NKRO.
+	Rollover int
+	Max      int  // most keys registered at once
+	NKRO     bool // no key jammed
+	Jams     []RolloverJam
+	Ghosts   []RolloverGhost
+}
+
+// TestRollover runs t with the keys pressed on kb, which must be started, and
+// returns the report. It consumes kb's Event() channel, and fails only if kb
+// stops.
+func (kb *Keyboard) TestRollover(t RolloverTest) (RolloverReport, error) {
+	if t.Sequences == nil {
+		t.Sequences = DefaultRolloverSequences
+	}
+	if t.Timeout == 0 {
+		t.Timeout = 5 * time.Second
+	}
+	var r RolloverReport
+	r.Rollover = -1
+	longest := 0
+	for _, seq := range t.Sequences {
+		if len(seq) > longest {
+			longest = len(seq)
+		}
+		if err := kb.awaitRelease(t); err != nil {
+			return r, err
+		}
+		held, err := kb.rolloverSequence(t, seq, &r)
+		if err != nil {
+			return r, err
+		}
+		if len(held) > r.Max {
+			r.Max = len(held)
+		}
+		if len(held) < len(seq) && (r.Rollover < 0 || len(held) < r.Rollover) {
+			r.Rollover = len(held)
+		}
+	}
+	r.NKRO = len(r.Jams) == 0
+	if r.Rollover < 0 {
+		r.Rollover = longest
+	}
+	return r, nil
+}
+
+// rolloverSettle is how long TestRollover waits after a key is registered
+// for keys the keyboard reports along with it, such as ghosts.
+const rolloverSettle = 50 * time.Millisecond
+
+// rolloverSequence asks for the keys of seq, records the jams and ghosts in
+// r, and returns the keys held, which are all of seq unless one jammed.
+func (kb *Keyboard) rolloverSequence(t RolloverTest, seq []KeyCode, r *RolloverReport) ([]KeyCode, error) {
+	var held []KeyCode
+	ghosted := map[KeyCode]bool{} // reported already
+	for _, next := range seq {
+		if t.Prompt != nil {
+			t.Prompt(held, next)
+		}
+		end := time.Now().Add(t.Timeout)
+		for {
+			left := time.Until(end)
+			if left <= 0 {
+				r.Jams = append(r.Jams, RolloverJam{Held: held, Key: next})
+				return held, nil
+			}
+			key, err := kb.ReadKey(left)
+			if err == ErrTimeout {
+				continue
+			}
+			if err != nil {
+				return held, err
+			}
+			if key == next {
+				break
+			}
+		}
+		held = append(held[:len(held):len(held)], next)
+
+		time.Sleep(rolloverSettle)
+		down := map[KeyCode]bool{}
+		var ghosts []KeyCode
+		for _, key := range kb.Pressed() {
+			down[key] = true
+			if !containsKey(held, key) && !ghosted[key] {
+				ghosts = append(ghosts, key)
+				ghosted[key] = true
+			}
+		}
+		if len(ghosts) > 0 {
+			r.Ghosts = append(r.Ghosts, RolloverGhost{Held: held, Ghost: ghosts})
+		}
+		for i, key := range held {
+			if !down[key] { // dropped by the keyboard
+				r.Jams = append(r.Jams, RolloverJam{Held: held[:i:i], Key: key})
+				return held[:i:i], nil
+			}
+		}
+	}
+	return held, nil
+}
+
+// awaitRelease asks the user to release all keys, if any are held, and waits
+// for that, or for t.Timeout.
+func (kb *Keyboard) awaitRelease(t RolloverTest) error {
+	if len(kb.Pressed()) == 0 {
+		return nil
+	}
+	if t.Release != nil {
+		t.Release()
+	}
+	end := time.Now().Add(t.Timeout)
+	for len(kb.Pressed()) > 0 && time.Now().Before(end) {
+		select {
+		case _, ok := <-kb.Event():
+			if !ok {
+				return ErrStopped
+			}
+		case <-time.After(rolloverSettle):
+		}
+	}
+	return nil
+}
+
+func containsKey(keys []KeyCode, key KeyCode) bool {
+	for _, k := range keys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,114 @@
+package kbd
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"syscall"
+	"time"
+)
+
+// ErrBusy is returned by the Actions of a Runner when the maximum number of
+// commands are already running.
+var ErrBusy = errors.New("kbd: too many commands running")
+
+// Trigger returns the key press that triggered the binding of c on kb: the
+// last press of a key matching c.Key. Actions run by Hotkeys can use it to
+// find which key, and which device, triggered them; unlike the last event
+// read, it doesn't change as further keys are pressed while the Action waits
+// to run, or runs again while c is held. It is the zero Event if no such key
+// has been pressed.
+func (kb *Keyboard) Trigger(c Combo) Event {
+	kb.mu.Lock()
+	defer kb.mu.Unlock()
+	var trigger Event
+	for key, event := range kb.presses {
+		if Matches(c.Key, key) && event.Seq > trigger.Seq {
+			trigger = event
+		}
+	}
+	return trigger
+}
+
+// Runner runs shell commands as Actions, for hotkey daemons. Each command is
+// run with `sh -c` in the background, with these variables added to its
+// environment:
+//
+//	KBD_COMBO   the combo bound to the command, such as "ctrl+alt+t"
+//	KBD_KEY     the key that triggered it (see Trigger), such as "T"
+//	KBD_DEVICE  the device the key was pressed on
+type Runner struct {
+	// Timeout is how long a command may run before it, and any process it
+	// started, is killed. Unlimited if 0.
+	Timeout time.Duration
+
+	// Env is added to the environment of every command.
+	Env []string
+
+	// ErrorHandler, if not nil, is called when a command fails or times out.
+	ErrorHandler func(command string, err error)
+
+	running chan struct{} // holds a value for each running command
+}
+
+// NewRunner creates a Runner allowing max commands to run at once (unlimited
+// if 0), each for up to timeout (unlimited if 0). Further commands are not
+// run, and their Action returns ErrBusy.
+func NewRunner(max int, timeout time.Duration) *Runner {
+	r := &Runner{Timeout: timeout}
+	if max > 0 {
+		r.running = make(chan struct{}, max)
+	}
+	return r
+}
+
+// Command returns an Action running command for the binding of c on kb.
+func (r *Runner) Command(command string, c Combo, kb *Keyboard) Action {
+	return func() error {
+		if r.running != nil {
+			select { // non-blocking channel send
+			case r.running <- struct{}{}:
+			default:
+				return ErrBusy
+			}
+		}
+
+		event := kb.Trigger(c)
+		cmd := exec.Command("sh", "-c", command)
+		cmd.Env = append(append(os.Environ(), r.Env...),
+			"KBD_COMBO="+c.String(),
+			"KBD_KEY="+event.Code.String(),
+			"KBD_DEVICE="+event.Device)
+		cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true} // to kill its children too
+		if err := cmd.Start(); err != nil {
+			r.done()
+			return err
+		}
+		go r.wait(command, cmd)
+		return nil
+	}
+}
+
+// wait waits for cmd to finish, killing it after Timeout.
+func (r *Runner) wait(command string, cmd *exec.Cmd) {
+	defer r.done()
+	var timer *time.Timer
+	if r.Timeout > 0 {
+		timer = time.AfterFunc(r.Timeout, func() {
+			syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+		})
+	}
+	err := cmd.Wait()
+	if timer != nil && !timer.Stop() { // the timer fired
+		err = errors.New("kbd: command timed out")
+	}
+	if err != nil && r.ErrorHandler != nil {
+		r.ErrorHandler(command, err)
+	}
+}
+
+func (r *Runner) done() {
+	if r.running != nil {
+		<-r.running
+	}
+}
@@ -0,0 +1,174 @@
+package kbd
+
+import "time"
+
+// ScanEvent is a string read from a barcode scanner or similar device that
+// "types" its data as a rapid burst of keys terminated by Enter.
+type ScanEvent struct {
+	Time time.Time // time the terminating Enter was pressed
+	Data string
+}
+
+// ScanDecoder is a Backend that wraps another Backend and separates scanner
+// bursts from human typing. Keys belonging to a burst are removed from the
+// event stream and delivered as a single ScanEvent on Scans(); all other keys
+// are passed through unchanged. Since a burst can only be recognized once it
+// ends, passed-through keys are delayed by up to MaxInterval.
+type ScanDecoder struct {
+	MaxInterval time.Duration // maximum time between keys of a burst; 30ms if 0
+	MinLength   int           // minimum length of a burst's data; 4 if 0
+	Keymap      *Keymap       // used to translate keys to runes; DefaultKeymap() if nil
+
+	b       Backend
+	scans   chan ScanEvent
+	reads   chan readResult
+	buf     []Event
+	data    []rune
+	shift   int
+	swallow map[KeyCode]bool
+	pending []Event
+	err     error
+}
+
+type readResult struct {
+	event Event
+	err   error
+}
+
+// NewScanDecoder creates a ScanDecoder reading from b.
+func NewScanDecoder(b Backend) *ScanDecoder {
+	return &ScanDecoder{
+		b:       b,
+		scans:   make(chan ScanEvent, 16),
+		swallow: map[KeyCode]bool{},
+	}
+}
+
+// Scans returns a channel on which decoded ScanEvents are delivered. Scans
+// are dropped if the channel's buffer is full.
+func (d *ScanDecoder) Scans() <-chan ScanEvent {
+	return d.scans
+}
+
+func (d *ScanDecoder) ReadEvent() (Event, error) {
+	if d.reads == nil {
+		d.reads = make(chan readResult)
+		go func() {
+			for {
+				event, err := d.b.ReadEvent()
+				d.reads <- readResult{event, err}
+				if err != nil {
+					return
+				}
+			}
+		}()
+	}
+	interval := d.MaxInterval
+	if interval <= 0 {
+		interval = 30 * time.Millisecond
+	}
+
+	for len(d.pending) == 0 && d.err == nil {
+		var timeout <-chan time.Time
+		var t *time.Timer
+		if len(d.buf) > 0 {
+			t = time.NewTimer(interval)
+			timeout = t.C
+		}
+
+		select {
+		case r := <-d.reads:
+			if r.err != nil {
+				d.err = r.err
+				d.flush()
+			} else {
+				d.decode(r.event)
+			}
+		case <-timeout:
+			d.flush() // too slow to be a scanner
+		}
+		if t != nil {
+			t.Stop()
+		}
+	}
+	if len(d.pending) == 0 {
+		return Event{}, d.err
+	}
+	return d.next(), nil
+}
+
+func (d *ScanDecoder) next() Event {
+	event := d.pending[0]
+	d.pending = d.pending[1:]
+	return event
+}
+
+// decode adds event to the current burst, if it could be part of one.
+func (d *ScanDecoder) decode(event Event) {
+	if event.Code == KeyLEFTSHIFT || event.Code == KeyRIGHTSHIFT {
+		if event.Value == Press {
+			d.shift++
+		} else if event.Value == Release && d.shift > 0 {
+			d.shift--
+		}
+	}
+	if event.Value == Release && d.swallow[event.Code] {
+		delete(d.swallow, event.Code) // release of a key from a completed scan
+		return
+	}
+
+	keymap := d.Keymap
+	if keymap == nil {
+		keymap = DefaultKeymap()
+	}
+	minLength := d.MinLength
+	if minLength <= 0 {
+		minLength = 4
+	}
+
+	d.buf = append(d.buf, event)
+	switch {
+	case event.Value != Press, event.Code == KeyLEFTSHIFT, event.Code == KeyRIGHTSHIFT:
+	case event.Code == KeyENTER || event.Code == KeyKPENTER:
+		if len(d.data) < minLength {
+			d.flush()
+			return
+		}
+		select {
+		case d.scans <- ScanEvent{Time: event.Time, Data: string(d.data)}:
+		default:
+		}
+		held := map[KeyCode]bool{}
+		for _, e := range d.buf {
+			held[e.Code] = e.Value != Release
+		}
+		for key, down := range held {
+			if down {
+				d.swallow[key] = true
+			}
+		}
+		d.reset()
+	default:
+		r, ok := keymap.Rune(event.Code, d.shift > 0)
+		if !ok {
+			d.flush()
+			return
+		}
+		d.data = append(d.data, r)
+	}
+}
+
+// flush passes the events of the current burst through.
+func (d *ScanDecoder) flush() {
+	d.pending = append(d.pending, d.buf...)
+	d.reset()
+}
+
+func (d *ScanDecoder) reset() {
+	d.buf = d.buf[:0]
+	d.data = d.data[:0]
+}
+
+func (d *ScanDecoder) Close() error {
+	return d.b.Close()
+}
@@ -0,0 +1,95 @@
+package kbd
+
+import (
+	"encoding/binary"
+
+	"golang.org/x/sys/unix"
+)
+
+// Scancode is a code sent by a keyboard for a key, before the kernel's
+// driver translates it to a KeyCode. Scancodes depend on the keyboard: for
+// a USB keyboard, for example, they are HID usages such as 0x70004 for A.
+type Scancode uint32
+
+// KeymapEntry is an entry of a device's scancode to KeyCode map.
+type KeymapEntry struct {
+	Scancode Scancode
+	Code     KeyCode
+}
+
+// ScancodeMapper is implemented by Backends that can change the map from
+// scancodes to KeyCodes in the kernel's driver for their device. Unlike a
+// Remapper, the change applies to every program reading the device, whether
+// it uses this package or not, and stays in effect until the device is
+// unplugged or the system restarted. (To make it permanent, add the
+// scancode and key to the udev hwdb instead.)
+type ScancodeMapper interface {
+	// Keymap returns the device's whole map, if the driver can enumerate it.
+	Keymap() ([]KeymapEntry, error)
+	// Keycode returns the KeyCode that s is translated to.
+	Keycode(s Scancode) (KeyCode, error)
+	// SetKeycode has s translated to code instead. KeyRESERVED disables s.
+	SetKeycode(s Scancode, code KeyCode) error
+}
+
+// inputKeymapEntry mirrors struct input_keymap_entry from "linux/input.h".
+type inputKeymapEntry struct {
+	Flags    uint8
+	Len      uint8
+	Index    uint16
+	Keycode  uint32
+	Scancode [32]byte
+}
+
+// keymapByIndex is INPUT_KEYMAP_BY_INDEX: look up the entry by Index rather
+// than Scancode.
+const keymapByIndex = 1
+
+// EVIOCGKEYCODE_V2 and EVIOCSKEYCODE_V2 ioctls.
+var (
+	eviocgKeycode = eviocg(0x04, 40)
+	eviocsKeycode = ioc(iocWrite, 'E', 0x04, 40)
+)
+
+func newKeymapEntry(s Scancode) inputKeymapEntry {
+	e := inputKeymapEntry{Len: 4}
+	binary.LittleEndian.PutUint32(e.Scancode[:], uint32(s))
+	return e
+}
+
+// Keymap reads the device's map with EVIOCGKEYCODE_V2, by index until the
+// driver reports no more entries.
+func (d *evdev) Keymap() ([]KeymapEntry, error) {
+	var m []KeymapEntry
+	for i := 0; i <= 0xffff; i++ {
+		e := inputKeymapEntry{Flags: keymapByIndex, Index: uint16(i)}
+		err := ioctlData(d.file.Fd(), eviocgKeycode, &e)
+		if err == unix.EINVAL {
+			break // past the last entry
+		}
+		if err != nil {
+			return m, err
+		}
+		m = append(m, KeymapEntry{
+			Scancode: Scancode(binary.LittleEndian.Uint32(e.Scancode[:])),
+			Code:     KeyCode(e.Keycode),
+		})
+	}
+	return m, nil
+}
+
+// Keycode reads the KeyCode for s with EVIOCGKEYCODE_V2.
+func (d *evdev) Keycode(s Scancode) (KeyCode, error) {
+	e := newKeymapEntry(s)
+	if err := ioctlData(d.file.Fd(), eviocgKeycode, &e); err != nil {
+		return 0, err
+	}
+	return KeyCode(e.Keycode), nil
+}
+
+// SetKeycode sets the KeyCode for s with EVIOCSKEYCODE_V2.
+func (d *evdev) SetKeycode(s Scancode, code KeyCode) error {
+	e := newKeymapEntry(s)
+	e.Keycode = uint32(code)
+	return ioctlData(d.file.Fd(), eviocsKeycode, &e)
+}
@@ -0,0 +1,116 @@
+package kbd
+
+import (
+	"runtime"
+	"sync"
+	"time"
+)
+
+// spinBefore is how long before a scheduled time the scheduler stops
+// sleeping and spins, since timers can fire a millisecond or more late.
+const spinBefore = 2 * time.Millisecond
+
+// Scheduled is a sequence of events scheduled on a Virtual keyboard by
+// PressAt or ScheduleSequence.
+type Scheduled struct {
+	cancel chan struct{}
+	once   sync.Once
+	done   chan struct{}
+	err    error
+}
+
+// PressAt presses key at t, without releasing it.
+func (v *Virtual) PressAt(key KeyCode, t time.Time) *Scheduled {
+	return v.schedule([]Event{{Code: key, Value: Press}}, []time.Time{t})
+}
+
+// ReleaseAt releases key at t.
+func (v *Virtual) ReleaseAt(key KeyCode, t time.Time) *Scheduled {
+	return v.schedule([]Event{{Code: key, Value: Release}}, []time.Time{t})
+}
+
+// ScheduleSequence sends events at the same intervals as their Times, with
+// the first at start, as for replaying a recording with precise timing.
+// Times are measured with the monotonic clock, so start should be derived
+// from time.Now(); changes to the system's clock while the sequence runs
+// don't affect it. Each event is sent within a few microseconds of its time,
+// at the cost of keeping a CPU busy for the last 2ms before it.
+func (v *Virtual) ScheduleSequence(events []Event, start time.Time) *Scheduled {
+	times := make([]time.Time, len(events))
+	for i, e := range events {
+		times[i] = start.Add(e.Time.Sub(events[0].Time))
+	}
+	return v.schedule(events, times)
+}
+
+// schedule sends events[i] at times[i], in order.
+func (v *Virtual) schedule(events []Event, times []time.Time) *Scheduled {
+	s := &Scheduled{cancel: make(chan struct{}), done: make(chan struct{})}
+	events = append([]Event(nil), events...)
+	go func() {
+		defer close(s.done)
+		held := map[KeyCode]bool{} // keys pressed by the sequence
+		for i, e := range events {
+			if !sleepUntil(times[i], s.cancel) {
+				for key := range held { // don't leave keys stuck down
+					v.Release(key)
+				}
+				return
+			}
+			if err := v.Send(e.Code, e.Value); err != nil {
+				s.err = err
+				return
+			}
+			switch e.Value {
+			case Press:
+				held[e.Code] = true
+			case Release:
+				delete(held, e.Code)
+			}
+		}
+	}()
+	return s
+}
+
+// sleepUntil waits until t, and reports whether it did, rather than being
+// cancelled. It sleeps until shortly before t, and spins for the rest.
+func sleepUntil(t time.Time, cancel <-chan struct{}) bool {
+	if d := time.Until(t) - spinBefore; d > 0 {
+		timer := time.NewTimer(d)
+		select {
+		case <-timer.C:
+		case <-cancel:
+			timer.Stop()
+			return false
+		}
+	}
+	for time.Now().Before(t) {
+		select {
+		case <-cancel:
+			return false
+		default:
+			runtime.Gosched()
+		}
+	}
+	return true
+}
+
+// Cancel stops sending the events not yet sent, and releases keys the
+// sequence pressed and hasn't released yet.
+func (s *Scheduled) Cancel() {
+	s.once.Do(func() { close(s.cancel) })
+	<-s.done
+}
+
+// Done returns a channel that is closed once the sequence ends: when all its
+// events have been sent, it is cancelled, or sending fails.
+func (s *Scheduled) Done() <-chan struct{} {
+	return s.done
+}
+
+// Err returns the error that ended the sequence, if any, once Done is
+// closed.
+func (s *Scheduled) Err() error {
+	<-s.done
+	return s.err
+}
@@ -0,0 +1,77 @@
+package kbd
+
+import (
+	"sync"
+	"time"
+)
+
+// BindOnce is like Bind, but c runs a only the first time it is pressed,
+// after which the Binding is unbound, as for a prompt waiting for one key.
+func (h *Hotkeys) BindOnce(c Combo, a Action) *Binding {
+	return h.add(&Binding{Combo: c, Action: a, Once: true, h: h})
+}
+
+// Scope is a group of Bindings in a Hotkeys registry that are unbound
+// together by Close, such as those of a modal dialog, which are only wanted
+// while it is open.
+type Scope struct {
+	h *Hotkeys
+
+	mu       sync.Mutex
+	bindings []*Binding
+	closed   bool
+}
+
+// Scope creates an empty Scope of h.
+func (h *Hotkeys) Scope() *Scope {
+	return &Scope{h: h}
+}
+
+// Bind binds c to a with opts in the scope's Hotkeys, as Hotkeys.Bind does.
+func (s *Scope) Bind(c Combo, a Action, opts ...BindOption) *Binding {
+	return s.add(withOptions(&Binding{Combo: c, Action: a}, opts))
+}
+
+// BindString is like Bind, but parses the combo with ParseCombo.
+func (s *Scope) BindString(combo string, a Action, opts ...BindOption) (*Binding, error) {
+	c, err := ParseCombo(combo)
+	if err != nil {
+		return nil, err
+	}
+	return s.Bind(c, a, opts...), nil
+}
+
+// BindOnce binds c to a for one press, as Hotkeys.BindOnce does.
+func (s *Scope) BindOnce(c Combo, a Action) *Binding {
+	return s.add(&Binding{Combo: c, Action: a, Once: true})
+}
+
+// BindHold binds c to a when held for d, as Hotkeys.BindHold does.
+func (s *Scope) BindHold(c Combo, d time.Duration, a Action, progress func(p float64)) *Binding {
+	return s.add(&Binding{Combo: c, Action: a, Hold: d, Progress: progress})
+}
+
+// add binds b in the scope's Hotkeys and records it, unless the scope is
+// closed, in which case b is returned without being bound.
+func (s *Scope) add(b *Binding) *Binding {
+	b.h = s.h
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return b
+	}
+	s.bindings = append(s.bindings, b)
+	return s.h.add(b)
+}
+
+// Close unbinds all the scope's Bindings in one step. Later Binds on the
+// scope bind nothing.
+func (s *Scope) Close() error {
+	s.mu.Lock()
+	bindings := s.bindings
+	s.bindings = nil
+	s.closed = true
+	s.mu.Unlock()
+	s.h.Replace(bindings, nil)
+	return nil
+}
@@ -0,0 +1,193 @@
+package kbd
+
+import (
+	"sync"
+	"time"
+)
+
+// Grabber is implemented by Backends that can take exclusive use of their
+// device, so that its events are seen by no other program.
+type Grabber interface {
+	Grab(grab bool) error
+}
+
+// eviocGrab is the EVIOCGRAB ioctl.
+const eviocGrab = 0x40044590
+
+// Grab takes (or releases) exclusive use of the device with EVIOCGRAB.
+func (d *evdev) Grab(grab bool) error {
+	var arg uintptr
+	if grab {
+		arg = 1
+	}
+	fd := d.file.Fd()
+	if err := ioctlInt(fd, eviocGrab, arg); err != nil {
+		return err
+	}
+	panicGrabbed(d, fd, grab)
+	return nil
+}
+
+// Share is a Backend that wraps another Backend to share its keyboard with a
+// second machine, like a software KVM switch. Pressing the toggle key switches
+// between the local and remote targets. While the remote target is active, the
+// device is grabbed (if the Backend is a Grabber) and events are written to
+// the remote EventWriter instead of being returned by ReadEvent. The remote
+// machine can inject them with Virtual.Inject and an EventReader; the
+// kbd-share command is such a pair.
+//
+// Keys held on one target when switching are released there, with Synthetic
+// releases returned by ReadEvent for the local target, and their repeats and
+// releases are not passed on to the other target, which never saw them
+// pressed.
+type Share struct {
+	b      Backend
+	toggle KeyCode
+	remote *EventWriter
+
+	mu      sync.Mutex
+	active  bool
+	down    map[KeyCode]bool // keys held on the remote target
+	local   map[KeyCode]bool // keys held on the local target
+	stale   map[KeyCode]bool // keys released on the target switched from, still held
+	pending []Event          // releases of the local target's keys
+}
+
+// NewShare creates a Share reading from b, switching targets with toggle and
+// sending events to remote.
+func NewShare(b Backend, toggle KeyCode, remote *EventWriter) *Share {
+	return &Share{
+		b:      b,
+		toggle: toggle,
+		remote: remote,
+		down:   map[KeyCode]bool{},
+		local:  map[KeyCode]bool{},
+		stale:  map[KeyCode]bool{},
+	}
+}
+
+// Remote reports whether the remote target is active.
+func (s *Share) Remote() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.active
+}
+
+func (s *Share) ReadEvent() (Event, error) {
+	for {
+		s.mu.Lock()
+		if len(s.pending) > 0 {
+			event := s.pending[0]
+			s.pending = s.pending[1:]
+			s.mu.Unlock()
+			return event, nil
+		}
+		s.mu.Unlock()
+
+		event, err := s.b.ReadEvent()
+		if err != nil {
+			return event, err
+		}
+
+		if event.Code == s.toggle {
+			if event.Value == Press {
+				if err := s.switchTarget(event); err != nil {
+					return Event{}, err
+				}
+			}
+			continue // the toggle key is never passed on
+		}
+
+		s.mu.Lock()
+		active := s.active
+		pass := s.track(event)
+		s.mu.Unlock()
+		if !pass {
+			continue
+		}
+		if !active {
+			return event, nil
+		}
+		if err := s.remote.Write(event); err != nil {
+			return Event{}, err
+		}
+	}
+}
+
+// track notes the keys held on the active target, and reports whether event
+// should be passed on to it. s.mu must be held.
+func (s *Share) track(event Event) bool {
+	held := s.local
+	if s.active {
+		held = s.down
+	}
+	if event.Value != Press && s.stale[event.Code] {
+		if event.Value == Release {
+			delete(s.stale, event.Code)
+		}
+		return false
+	}
+	delete(s.stale, event.Code)
+	if event.Value == Release {
+		delete(held, event.Code)
+	} else {
+		held[event.Code] = true
+	}
+	return true
+}
+
+// switchTarget toggles between the local and remote targets, releasing the
+// keys held on the target switched from. If the releases can't be written to
+// the remote target, the error is returned and the target stays the same.
+func (s *Share) switchTarget(event Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.active {
+		if err := s.releaseRemote(event); err != nil {
+			return err
+		}
+	}
+	if g, ok := s.b.(Grabber); ok {
+		if err := g.Grab(!s.active); err != nil {
+			return err
+		}
+	}
+	if !s.active {
+		for key := range s.local {
+			s.pending = append(s.pending, Event{Time: event.Time, Code: key, Value: Release, Device: event.Device, Synthetic: true})
+			s.stale[key] = true
+		}
+		s.local = map[KeyCode]bool{}
+	}
+	s.active = !s.active
+	return nil
+}
+
+// releaseRemote writes Synthetic releases of the keys held on the remote
+// target, at the time of event. s.mu must be held.
+func (s *Share) releaseRemote(event Event) error {
+	for key := range s.down {
+		if err := s.remote.Write(Event{Time: event.Time, Code: key, Value: Release, Synthetic: true}); err != nil {
+			return err
+		}
+		delete(s.down, key)
+		s.stale[key] = true
+	}
+	return nil
+}
+
+// Close releases the keys held on the remote target, if it is active, and
+// closes the wrapped Backend.
+func (s *Share) Close() error {
+	s.mu.Lock()
+	var err error
+	if s.active {
+		err = s.releaseRemote(Event{Time: time.Now()})
+	}
+	s.mu.Unlock()
+	if e := s.b.Close(); err == nil {
+		err = e
+	}
+	return err
+}
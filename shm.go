@@ -0,0 +1,235 @@
+package kbd
+
+import (
+	"encoding/binary"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync/atomic"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// Layout of a shared state file. All values are little endian.
+//
+//	offset  size  contents
+//	0       4     magic "KBDS"
+//	4       4     version (1)
+//	8       8     sequence; odd while an update is in progress
+//	16      4     number of ring entries, n
+//	20      4     count of events written; the newest is at (count-1) % n
+//	24      96    key state bitset; bit k%8 of byte k/8 is set if KeyCode k is down
+//	120     16*n  ring of events: time (int64 ns), code (uint16), 2 bytes padding, value (int32)
+//
+// Readers should copy the data they need, then check that the sequence was
+// even and unchanged, retrying otherwise.
+const (
+	shmMagic   = 0x5344424b // "KBDS"
+	shmVersion = 1
+	shmSeq     = 8
+	shmRingLen = 16
+	shmCount   = 20
+	shmKeys    = 24
+	shmRing    = shmKeys + 96
+	shmEntry   = 16
+)
+
+// SharedState is a Backend that wraps another Backend and publishes the key
+// state and a ring of recent events to a shared memory file, typically in
+// `/dev/shm/`. Real-time consumers on the same host, such as audio
+// applications or emulators, can map the file and poll it without system
+// calls or channel operations. See OpenSharedState for reading it from Go.
+type SharedState struct {
+	// Redact, if set, passes events through Redact before publishing them,
+	// so that other processes don't see what was typed. Keys hidden by
+	// Redact are then never shown as down.
+	Redact bool
+
+	b    Backend
+	file *os.File
+	data []byte
+}
+
+// shmMaxRing is the most entries a shared state ring may have.
+const shmMaxRing = 1 << 20
+
+// errNotSharedState is returned by OpenSharedState for files that aren't
+// shared state files.
+var errNotSharedState = errors.New("kbd: not a shared state file")
+
+// NewSharedState creates the shared state file at path with room for ring
+// recent events, and returns a Backend publishing the events read from b.
+// An existing file is replaced rather than overwritten, so that readers that
+// have it mapped keep reading the old one, rather than fault.
+//
+// The file is created with mode 0600, so that only processes of the same
+// user can read it, since it shows what is typed unless Redact is set. To
+// share it with other users, such as the members of an audio group, change
+// its group and mode once it is created.
+func NewSharedState(b Backend, path string, ring int) (*SharedState, error) {
+	if ring <= 0 || ring > shmMaxRing {
+		return nil, errors.New("kbd: shared state ring must have 1 to 1048576 entries")
+	}
+	f, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return nil, err
+	}
+	fail := func(err error) (*SharedState, error) {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, err
+	}
+	size := shmRing + ring*shmEntry
+	if err := f.Truncate(int64(size)); err != nil {
+		return fail(err)
+	}
+	data, err := unix.Mmap(int(f.Fd()), 0, size, unix.PROT_READ|unix.PROT_WRITE, unix.MAP_SHARED)
+	if err != nil {
+		return fail(err)
+	}
+
+	binary.LittleEndian.PutUint32(data[0:], shmMagic)
+	binary.LittleEndian.PutUint32(data[4:], shmVersion)
+	binary.LittleEndian.PutUint32(data[shmRingLen:], uint32(ring))
+	if err := os.Rename(f.Name(), path); err != nil {
+		unix.Munmap(data)
+		return fail(err)
+	}
+	return &SharedState{b: b, file: f, data: data}, nil
+}
+
+func (s *SharedState) ReadEvent() (Event, error) {
+	event, err := s.b.ReadEvent()
+	if err == nil && s.Redact {
+		s.publish(Redact(event))
+	} else if err == nil {
+		s.publish(event)
+	}
+	return event, err
+}
+
+// publish records event in the shared state.
+func (s *SharedState) publish(event Event) {
+	seq := (*uint64)(unsafe.Pointer(&s.data[shmSeq]))
+	atomic.AddUint64(seq, 1)
+
+	if event.Value != Repeat && int(event.Code) < 96*8 {
+		bit := byte(1) << (event.Code % 8)
+		if event.Value == Press {
+			s.data[shmKeys+event.Code/8] |= bit
+		} else {
+			s.data[shmKeys+event.Code/8] &^= bit
+		}
+	}
+
+	ring := binary.LittleEndian.Uint32(s.data[shmRingLen:])
+	count := binary.LittleEndian.Uint32(s.data[shmCount:])
+	entry := s.data[shmRing+int(count%ring)*shmEntry:]
+	binary.LittleEndian.PutUint64(entry[0:], uint64(event.Time.UnixNano()))
+	binary.LittleEndian.PutUint16(entry[8:], uint16(event.Code))
+	binary.LittleEndian.PutUint32(entry[12:], uint32(event.Value))
+	binary.LittleEndian.PutUint32(s.data[shmCount:], count+1)
+
+	atomic.AddUint64(seq, 1)
+}
+
+// Close unmaps and closes the shared state file and closes the wrapped
+// Backend. The file itself is not removed.
+func (s *SharedState) Close() error {
+	unix.Munmap(s.data)
+	s.file.Close()
+	return s.b.Close()
+}
+
+// SharedStateReader reads a shared state file published by a SharedState.
+type SharedStateReader struct {
+	data []byte
+	ring uint32 // entries in the ring, checked to fit in data
+}
+
+// OpenSharedState maps the shared state file at path for reading.
+func OpenSharedState(path string) (*SharedStateReader, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if fi.Size() < shmRing || fi.Size() > shmRing+shmMaxRing*shmEntry {
+		return nil, errNotSharedState
+	}
+	data, err := unix.Mmap(int(f.Fd()), 0, int(fi.Size()), unix.PROT_READ, unix.MAP_SHARED)
+	if err != nil {
+		return nil, err
+	}
+	ring := binary.LittleEndian.Uint32(data[shmRingLen:])
+	if binary.LittleEndian.Uint32(data[0:]) != shmMagic ||
+		binary.LittleEndian.Uint32(data[4:]) != shmVersion ||
+		ring == 0 || ring > shmMaxRing || shmRing+int(ring)*shmEntry > len(data) {
+		unix.Munmap(data)
+		return nil, errNotSharedState
+	}
+	return &SharedStateReader{data: data, ring: ring}, nil
+}
+
+// read calls f until it runs without an update happening concurrently.
+func (r *SharedStateReader) read(f func()) {
+	seq := (*uint64)(unsafe.Pointer(&r.data[shmSeq]))
+	for {
+		before := atomic.LoadUint64(seq)
+		if before%2 == 0 {
+			f()
+			if atomic.LoadUint64(seq) == before {
+				return
+			}
+		}
+		runtime.Gosched()
+	}
+}
+
+// IsDown checks if the key is pressed.
+func (r *SharedStateReader) IsDown(key KeyCode) bool {
+	if int(key) >= 96*8 {
+		return false
+	}
+	var down bool
+	r.read(func() {
+		down = r.data[shmKeys+key/8]&(1<<(key%8)) != 0
+	})
+	return down
+}
+
+// Recent returns the events in the ring, oldest first.
+func (r *SharedStateReader) Recent() []Event {
+	var events []Event
+	r.read(func() {
+		ring := r.ring
+		count := binary.LittleEndian.Uint32(r.data[shmCount:])
+		n := count
+		if n > ring {
+			n = ring
+		}
+		events = make([]Event, n)
+		for i := range events {
+			entry := r.data[shmRing+int((count-n+uint32(i))%ring)*shmEntry:]
+			events[i] = Event{
+				Time:  time.Unix(0, int64(binary.LittleEndian.Uint64(entry[0:]))),
+				Code:  KeyCode(binary.LittleEndian.Uint16(entry[8:])),
+				Value: int32(binary.LittleEndian.Uint32(entry[12:])),
+			}
+		}
+	})
+	return events
+}
+
+// Close unmaps the shared state file.
+func (r *SharedStateReader) Close() error {
+	return unix.Munmap(r.data)
+}
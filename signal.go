@@ -0,0 +1,62 @@
+package kbd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// Signal returns an Action that sends sig to the process pid, for example to
+// have a running server dump debugging information when a key is pressed:
+//
+//	h.BindString("f12", kbd.Signal(pid, syscall.SIGUSR1))
+//
+// pid must be positive: the Action fails rather than signal a process group
+// (0 or less), or every process it may signal (-1).
+func Signal(pid int, sig syscall.Signal) Action {
+	return func() error {
+		if pid <= 0 {
+			return fmt.Errorf("kbd: bad pid %d", pid)
+		}
+		return syscall.Kill(pid, sig)
+	}
+}
+
+// SignalPidFile is like Signal, but sends sig to the process whose pid is in
+// the file at path, read each time the Action runs, so that it follows
+// restarts of the process.
+func SignalPidFile(path string, sig syscall.Signal) Action {
+	return func() error {
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		pid, err := strconv.Atoi(strings.TrimSpace(string(b)))
+		if err != nil || pid <= 0 {
+			return fmt.Errorf("kbd: no pid in %s", path)
+		}
+		return syscall.Kill(pid, sig)
+	}
+}
+
+// signalNames are the signals that can be named in ParseSignal.
+var signalNames = map[string]syscall.Signal{
+	"HUP": syscall.SIGHUP, "INT": syscall.SIGINT, "QUIT": syscall.SIGQUIT,
+	"KILL": syscall.SIGKILL, "TERM": syscall.SIGTERM, "USR1": syscall.SIGUSR1,
+	"USR2": syscall.SIGUSR2, "STOP": syscall.SIGSTOP, "CONT": syscall.SIGCONT,
+	"WINCH": syscall.SIGWINCH,
+}
+
+// ParseSignal returns the signal named s, such as "USR1", "SIGUSR1" or "10".
+func ParseSignal(s string) (syscall.Signal, error) {
+	name := strings.TrimPrefix(strings.ToUpper(s), "SIG")
+	if sig, ok := signalNames[name]; ok {
+		return sig, nil
+	}
+	if n, err := strconv.Atoi(s); err == nil && n > 0 && n < 65 {
+		return syscall.Signal(n), nil
+	}
+	return 0, fmt.Errorf("kbd: unknown signal %q", s)
+}
@@ -0,0 +1,154 @@
+package kbd
+
+import (
+	"sync"
+	"time"
+)
+
+// Split is a Backend joining the halves of a split keyboard, which appear as
+// two devices, such as the two Bluetooth halves of a Corne or the halves of
+// a Kinesis Advantage 360, into one logical keyboard. It reads both with a
+// Multiplexer, so a Keyboard reading it tracks one set of held keys and
+// modifiers, and detects combos whose keys are on different halves, such as
+// Ctrl on the left and C on the right. On top of that:
+//
+//   - events are read with Name as their Device, so that per-device settings,
+//     such as Remapper.SetDevice, apply to both halves;
+//   - a key held on both halves, such as a Shift on each, is pressed once, and
+//     released when it is released on both;
+//   - the keys held on a half that disconnects are released, with the next
+//     event read, rather than stuck down.
+type Split struct {
+	Name string // Device of the events read; "LEFT+RIGHT" by default
+
+	m    *Multiplexer
+	sub  *BusSubscription // DeviceEvents of m
+	mu   sync.Mutex
+	held map[KeyCode]map[string]bool // halves holding each key
+	lost []string                    // halves removed, whose keys are still held
+	bus  *Bus                        // for DeviceEvents; none if nil
+
+	pending []Event
+}
+
+// NewSplit creates a Split reading the halves at the paths left and right,
+// which may be labels (see ResolveDevice).
+func NewSplit(left, right string) (*Split, error) {
+	m, err := NewMultiplexer()
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range []string{left, right} {
+		if err := m.Add(path); err != nil {
+			m.Close()
+			return nil, err
+		}
+	}
+	bus := NewBus()
+	s := &Split{
+		Name: left + "+" + right,
+		m:    m,
+		sub:  bus.Subscribe(8, Block, DeviceEvent{}),
+		held: map[KeyCode]map[string]bool{},
+	}
+	m.ReportDevices(bus)
+	go s.watch()
+	return s, nil
+}
+
+// watch notes the halves removed from s.m, and passes on their DeviceEvents.
+func (s *Split) watch() {
+	for v := range s.sub.C {
+		event := v.(DeviceEvent)
+		s.mu.Lock()
+		if !event.Added {
+			s.lost = append(s.lost, event.Path)
+		}
+		b := s.bus
+		s.mu.Unlock()
+		if b != nil {
+			b.Publish(event)
+		}
+	}
+}
+
+func (s *Split) ReadEvent() (Event, error) {
+	for len(s.pending) == 0 {
+		event, err := s.m.ReadEvent()
+		if err != nil {
+			return event, err
+		}
+		s.mu.Lock()
+		s.releaseLost()
+		s.join(event)
+		s.mu.Unlock()
+	}
+	event := s.pending[0]
+	s.pending = s.pending[1:]
+	return event, nil
+}
+
+// join queues event, read from one half, as an event of the logical
+// keyboard, if it changes it. s.mu must be held.
+func (s *Split) join(event Event) {
+	half := event.Device
+	event.Device = s.Name
+	halves := s.held[event.Code]
+	switch event.Value {
+	case Press:
+		if len(halves) == 0 {
+			halves = map[string]bool{}
+			s.held[event.Code] = halves
+			s.pending = append(s.pending, event)
+		}
+		halves[half] = true
+	case Release:
+		if !halves[half] {
+			return
+		}
+		delete(halves, half)
+		if len(halves) == 0 {
+			delete(s.held, event.Code)
+			s.pending = append(s.pending, event)
+		}
+	default:
+		s.pending = append(s.pending, event)
+	}
+}
+
+// releaseLost queues releases of the keys held only on halves that were
+// removed. s.mu must be held.
+func (s *Split) releaseLost() {
+	for _, half := range s.lost {
+		for key, halves := range s.held {
+			if halves[half] {
+				s.join(Event{Time: time.Now(), Code: key, Value: Release, Device: half})
+			}
+		}
+	}
+	s.lost = nil
+}
+
+// Add adds a half at path, such as one that was removed and has reconnected.
+func (s *Split) Add(path string) error {
+	return s.m.Add(path)
+}
+
+// Grab takes (or releases) exclusive use of both halves.
+func (s *Split) Grab(grab bool) error {
+	return s.m.Grab(grab)
+}
+
+// ReportDevices publishes a DeviceEvent on b whenever a half is added or
+// removed.
+func (s *Split) ReportDevices(b *Bus) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.bus = b
+}
+
+func (s *Split) Close() error {
+	err := s.m.Close()
+	s.sub.Close()
+	return err
+}
@@ -0,0 +1,96 @@
+package kbd
+
+// State is a snapshot of which keys are down. It is a plain value, so
+// snapshots can be kept and compared without holding any lock, for example by
+// a program that renders a frame at a time.
+type State struct {
+	keys  [(keyMax + 8) / 8]byte // bit per KeyCode
+	Locks Lock                   // lock keys that were on
+	Seq   uint64                 // Seq of the last event applied, as for Keyboard.Seq
+}
+
+// State returns a snapshot of kb's key state.
+func (kb *Keyboard) State() State {
+	kb.mu.Lock()
+	defer kb.mu.Unlock()
+	s := State{Locks: kb.locks, Seq: kb.seq}
+	for key, down := range kb.keys {
+		if down {
+			s.set(key)
+		}
+	}
+	return s
+}
+
+// State returns a snapshot of the shared key state. Lock keys and Seq are not
+// shared, and are left zero.
+func (r *SharedStateReader) State() State {
+	var s State
+	r.read(func() {
+		copy(s.keys[:], r.data[shmKeys:])
+	})
+	return s
+}
+
+// NewState returns a State with keys down, such as for Hotkeys.Match.
+func NewState(keys ...KeyCode) State {
+	var s State
+	for _, key := range keys {
+		s.set(key)
+	}
+	return s
+}
+
+func (s *State) set(key KeyCode) {
+	if key <= keyMax {
+		s.keys[key/8] |= 1 << (key % 8)
+	}
+}
+
+// IsDown checks if key was down. If key is a group, such as AnyShift, it
+// checks if any key in the group was down.
+func (s State) IsDown(key KeyCode) bool {
+	if members, ok := keyGroups[key]; ok {
+		for _, k := range members {
+			if s.IsDown(k) {
+				return true
+			}
+		}
+		return false
+	}
+	return key <= keyMax && s.keys[key/8]&(1<<(key%8)) != 0
+}
+
+// Pressed returns the keys that were down, in order of KeyCode.
+func (s State) Pressed() []KeyCode {
+	var keys []KeyCode
+	for i, b := range s.keys {
+		for bit := uint(0); b != 0 && bit < 8; bit++ {
+			if b&(1<<bit) != 0 {
+				keys = append(keys, KeyCode(i*8)+KeyCode(bit))
+			}
+		}
+	}
+	return keys
+}
+
+// Diff returns the keys down in s that were up in prev (pressed), and those
+// up in s that were down in prev (released), in order of KeyCode. Keys
+// pressed and released again between the two snapshots don't appear.
+func (s State) Diff(prev State) (pressed, released []KeyCode) {
+	for i := range s.keys {
+		changed := s.keys[i] ^ prev.keys[i]
+		for bit := uint(0); changed != 0 && bit < 8; bit++ {
+			if changed&(1<<bit) == 0 {
+				continue
+			}
+			key := KeyCode(i*8) + KeyCode(bit)
+			if s.keys[i]&(1<<bit) != 0 {
+				pressed = append(pressed, key)
+			} else {
+				released = append(released, key)
+			}
+		}
+	}
+	return pressed, released
+}
@@ -0,0 +1,195 @@
+package kbd
+
+// Backpressure selects what a Subscription does with a Frame when its
+// channel is full because the subscriber has not kept up.
+type Backpressure int
+
+// Backpressure policies.
+const (
+	// DropOldest discards the oldest queued Frame to make room for the new
+	// one, as Frames() does. It suits consumers that want recent events.
+	DropOldest Backpressure = iota
+	// DropNewest discards the new Frame, keeping those already queued.
+	DropNewest
+	// Block waits until the subscriber makes room, so no Frame is lost. A
+	// blocked subscriber stalls the Keyboard's read loop, and so every other
+	// subscriber; events may then be dropped by the kernel instead. It suits
+	// loggers that want everything and read promptly.
+	Block
+	// Coalesce merges the new Frame into the queued one, keeping only the
+	// latest event for each key, so the queued Frame always describes the
+	// current state of every key that changed. It suits renderers that only
+	// care about the latest state. The channel of a Coalesce Subscription
+	// holds a single Frame.
+	Coalesce
+)
+
+// Subscription is a channel of the Frames of key changes applied by a
+// Keyboard, with its own buffer and Backpressure policy, so that several
+// consumers can each read every Frame they are able to. Frames are delivered
+// in order; gaps in their Seq show where Frames were dropped (or coalesced).
+// It is a subscription to the Frames on the Keyboard's Bus.
+type Subscription struct {
+	C <-chan Frame
+
+	o *outlet
+}
+
+// frameChannel is the channel of a Subscription.
+type frameChannel chan Frame
+
+func (c frameChannel) trySend(v interface{}) bool {
+	select { // non-blocking channel send
+	case c <- v.(Frame):
+		return true
+	default:
+		return false
+	}
+}
+
+func (c frameChannel) tryReceive() (interface{}, bool) {
+	select { // non-blocking channel recieve to "drain" channel
+	case frame := <-c:
+		return frame, true
+	default:
+		return nil, false
+	}
+}
+
+func (c frameChannel) send(v interface{}, done <-chan struct{}) {
+	select {
+	case c <- v.(Frame):
+	case <-done:
+	}
+}
+
+func (c frameChannel) close() { close(c) }
+
+// Subscribe returns a Subscription to the Frames applied by kb, buffering up
+// to size Frames (at least 1) and handling a full buffer according to policy.
+// The Subscription's channel is closed when kb's read loop ends or the
+// Subscription is closed.
+func (kb *Keyboard) Subscribe(size int, policy Backpressure) *Subscription {
+	if size < 1 || policy == Coalesce {
+		size = 1
+	}
+	c := make(chan Frame, size)
+	return &Subscription{C: c, o: kb.Bus().subscribe(frameChannel(c), policy, Frame(nil))}
+}
+
+// Dropped returns the number of Frames the Subscription has discarded (or
+// merged into another Frame) because its channel was full.
+func (s *Subscription) Dropped() uint64 {
+	return s.o.droppedCount()
+}
+
+// Close ends the Subscription and closes its channel.
+func (s *Subscription) Close() {
+	s.o.unsubscribe()
+}
+
+// EventSubscription is like a Subscription, but delivers every key event
+// read, including repeats, rather than Frames of key changes: the Event of
+// each KeyEvent on the Keyboard's Bus. Coalesce acts like DropOldest.
+type EventSubscription struct {
+	C <-chan Event
+
+	o *outlet
+}
+
+// eventChannel is the channel of an EventSubscription, to which KeyEvents
+// are sent.
+type eventChannel chan Event
+
+func (c eventChannel) trySend(v interface{}) bool {
+	select { // non-blocking channel send
+	case c <- v.(KeyEvent).Event:
+		return true
+	default:
+		return false
+	}
+}
+
+func (c eventChannel) tryReceive() (interface{}, bool) {
+	select { // non-blocking channel recieve to "drain" channel
+	case event := <-c:
+		return KeyEvent{event}, true
+	default:
+		return nil, false
+	}
+}
+
+func (c eventChannel) send(v interface{}, done <-chan struct{}) {
+	select {
+	case c <- v.(KeyEvent).Event:
+	case <-done:
+	}
+}
+
+func (c eventChannel) close() { close(c) }
+
+// SubscribeEvents returns an EventSubscription to the key events read by kb,
+// buffering up to size events (at least 1) and handling a full buffer
+// according to policy. Its channel is closed when kb's read loop ends or the
+// EventSubscription is closed.
+func (kb *Keyboard) SubscribeEvents(size int, policy Backpressure) *EventSubscription {
+	if size < 1 {
+		size = 1
+	}
+	c := make(chan Event, size)
+	return &EventSubscription{C: c, o: kb.Bus().subscribe(eventChannel(c), policy, KeyEvent{})}
+}
+
+// Dropped returns the number of events the EventSubscription has discarded
+// because its channel was full.
+func (s *EventSubscription) Dropped() uint64 {
+	return s.o.droppedCount()
+}
+
+// Close ends the EventSubscription and closes its channel.
+func (s *EventSubscription) Close() {
+	s.o.unsubscribe()
+}
+
+// coalesce merges next into prev, keeping only the latest event for each
+// key. Events stay in the order they were applied.
+func coalesce(prev, next Frame) Frame {
+	changed := map[KeyCode]bool{}
+	for _, event := range next {
+		changed[event.Code] = true
+	}
+	merged := make(Frame, 0, len(prev)+len(next))
+	for _, event := range prev {
+		if !changed[event.Code] {
+			merged = append(merged, event)
+		}
+	}
+	return append(merged, next...)
+}
+
+// publish sends frame to kb's Notifiers, and on its Bus to its
+// Subscriptions. kb.mu must not be held.
+func (kb *Keyboard) publish(frame Frame) {
+	kb.mu.Lock()
+	notifiers := make([]*Notifier, 0, len(kb.notifiers))
+	for n := range kb.notifiers {
+		notifiers = append(notifiers, n)
+	}
+	b := kb.bus
+	kb.mu.Unlock()
+	for _, n := range notifiers {
+		n.update(frame)
+	}
+	b.Publish(frame)
+}
+
+// closeNotifiers ends kb's Notifiers. kb.mu must not be held.
+func (kb *Keyboard) closeNotifiers() {
+	kb.mu.Lock()
+	notifiers := kb.notifiers
+	kb.notifiers = nil
+	kb.mu.Unlock()
+	for n := range notifiers {
+		n.close()
+	}
+}
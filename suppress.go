@@ -0,0 +1,97 @@
+package kbd
+
+import "sync"
+
+// SuppressPolicy is what happens to combos pressed while Hotkeys are
+// suppressed.
+type SuppressPolicy int
+
+// Suppress policies.
+const (
+	// SuppressDrop discards the combos.
+	SuppressDrop SuppressPolicy = iota
+	// SuppressQueue runs their Actions, in order, once suppression ends. At
+	// most maxSuppressQueue are kept; later ones are dropped. Combos bound
+	// with BindHold are dropped, as they are no longer held when it ends.
+	SuppressQueue
+)
+
+// maxSuppressQueue limits the bindings queued by SuppressQueue, so a long
+// suppression doesn't end in a burst of Actions.
+const maxSuppressQueue = 16
+
+// Suppress stops h from running Actions until resume is called, for example
+// while the user types a password, so that no combo typed by accident runs
+// one. Combos pressed in the meantime are handled by h.WhileSuppressed.
+// Suppress may be called several times; suppression ends when every resume
+// has been called. Calling resume again has no effect.
+func (h *Hotkeys) Suppress() (resume func()) {
+	h.mu.Lock()
+	h.suppressed++
+	h.mu.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			h.mu.Lock()
+			h.suppressed--
+			queued := h.takeQueue()
+			h.mu.Unlock()
+			h.run(queued)
+		})
+	}
+}
+
+// SuppressWhile suppresses h, as Suppress does, whenever f returns true, or
+// never if f is nil. f is called on each press of a bound combo, so it can
+// follow state kept elsewhere, such as whether a password field has focus.
+// Combos queued while f returned true run with the next combo pressed once
+// it returns false.
+func (h *Hotkeys) SuppressWhile(f func() bool) {
+	h.mu.Lock()
+	h.while = f
+	h.mu.Unlock()
+}
+
+// Suppressed reports whether h is suppressed by Suppress.
+func (h *Hotkeys) Suppressed() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.suppressed > 0
+}
+
+// unsuppressed returns the bindings to run for matched: matched itself,
+// after any queued while h was suppressed, or nothing if h is suppressed.
+func (h *Hotkeys) unsuppressed(matched []*Binding) []*Binding {
+	h.mu.Lock()
+	suppressed, while := h.suppressed > 0, h.while
+	h.mu.Unlock()
+	if !suppressed && while != nil {
+		suppressed = while()
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if !suppressed {
+		return append(h.takeQueue(), matched...)
+	}
+	if h.WhileSuppressed == SuppressQueue {
+		for _, b := range matched {
+			if b.Hold == 0 && len(h.queue) < maxSuppressQueue {
+				h.queue = append(h.queue, b)
+			}
+		}
+	}
+	return nil
+}
+
+// takeQueue returns and clears the queued bindings if h isn't suppressed by
+// Suppress. h.mu must be held.
+func (h *Hotkeys) takeQueue() []*Binding {
+	if h.suppressed > 0 {
+		return nil
+	}
+	queued := h.queue
+	h.queue = nil
+	return queued
+}
@@ -0,0 +1,364 @@
+package kbd
+
+import (
+	"io"
+	"strconv"
+	"unicode"
+)
+
+// KittyFlags are the progressive enhancements of the kitty keyboard
+// protocol (https://sw.kovidgoyal.net/kitty/keyboard-protocol/), which
+// terminals and the programs in them agree on with "CSI > flags u".
+type KittyFlags uint8
+
+// Kitty keyboard protocol enhancements.
+const (
+	KittyDisambiguate  KittyFlags = 1 << iota // escape codes for keys that are ambiguous otherwise
+	KittyEventTypes                           // report repeats and releases
+	KittyAlternateKeys                        // report the shifted key too
+	KittyAllKeys                              // escape codes for all keys, including text and modifiers
+	KittyText                                 // report the text of keys with KittyAllKeys
+)
+
+// termKey is the escape code of a key that produces no text: "CSI num final",
+// where num is omitted if it is 1 and nothing follows it.
+type termKey struct {
+	num   int
+	final byte
+}
+
+// termKeys are the keys with escape codes, both in the legacy xterm encoding
+// and the kitty keyboard protocol. The keys encoded with 'u' are only sent
+// as escape codes by the kitty protocol; the others are sent by both.
+var termKeys = map[KeyCode]termKey{
+	KeyESC: {27, 'u'}, KeyENTER: {13, 'u'}, KeyTAB: {9, 'u'}, KeyBACKSPACE: {127, 'u'},
+
+	KeyUP: {1, 'A'}, KeyDOWN: {1, 'B'}, KeyRIGHT: {1, 'C'}, KeyLEFT: {1, 'D'},
+	KeyHOME: {1, 'H'}, KeyEND: {1, 'F'}, KeyINSERT: {2, '~'}, KeyDELETE: {3, '~'},
+	KeyPAGEUP: {5, '~'}, KeyPAGEDOWN: {6, '~'},
+
+	KeyF1: {1, 'P'}, KeyF2: {1, 'Q'}, KeyF3: {13, '~'}, KeyF4: {1, 'S'},
+	KeyF5: {15, '~'}, KeyF6: {17, '~'}, KeyF7: {18, '~'}, KeyF8: {19, '~'},
+	KeyF9: {20, '~'}, KeyF10: {21, '~'}, KeyF11: {23, '~'}, KeyF12: {24, '~'},
+	KeyF13: {57376, 'u'}, KeyF14: {57377, 'u'}, KeyF15: {57378, 'u'}, KeyF16: {57379, 'u'},
+	KeyF17: {57380, 'u'}, KeyF18: {57381, 'u'}, KeyF19: {57382, 'u'}, KeyF20: {57383, 'u'},
+	KeyF21: {57384, 'u'}, KeyF22: {57385, 'u'}, KeyF23: {57386, 'u'}, KeyF24: {57387, 'u'},
+
+	KeyCAPSLOCK: {57358, 'u'}, KeySCROLLLOCK: {57359, 'u'}, KeyNUMLOCK: {57360, 'u'},
+	KeySYSRQ: {57361, 'u'}, KeyPAUSE: {57362, 'u'}, KeyCOMPOSE: {57363, 'u'},
+
+	KeyKP0: {57399, 'u'}, KeyKP1: {57400, 'u'}, KeyKP2: {57401, 'u'}, KeyKP3: {57402, 'u'},
+	KeyKP4: {57403, 'u'}, KeyKP5: {57404, 'u'}, KeyKP6: {57405, 'u'}, KeyKP7: {57406, 'u'},
+	KeyKP8: {57407, 'u'}, KeyKP9: {57408, 'u'}, KeyKPDOT: {57409, 'u'}, KeyKPSLASH: {57410, 'u'},
+	KeyKPASTERISK: {57411, 'u'}, KeyKPMINUS: {57412, 'u'}, KeyKPPLUS: {57413, 'u'},
+	KeyKPENTER: {57414, 'u'}, KeyKPEQUAL: {57415, 'u'},
+
+	KeyPLAYCD: {57428, 'u'}, KeyPAUSECD: {57429, 'u'}, KeyPLAYPAUSE: {57430, 'u'},
+	KeySTOPCD: {57432, 'u'}, KeyFASTFORWARD: {57433, 'u'}, KeyREWIND: {57434, 'u'},
+	KeyNEXTSONG: {57435, 'u'}, KeyPREVIOUSSONG: {57436, 'u'}, KeyRECORD: {57437, 'u'},
+	KeyVOLUMEDOWN: {57438, 'u'}, KeyVOLUMEUP: {57439, 'u'}, KeyMUTE: {57440, 'u'},
+
+	KeyLEFTSHIFT: {57441, 'u'}, KeyLEFTCTRL: {57442, 'u'}, KeyLEFTALT: {57443, 'u'},
+	KeyLEFTMETA: {57444, 'u'}, KeyRIGHTSHIFT: {57447, 'u'}, KeyRIGHTCTRL: {57448, 'u'},
+	KeyRIGHTALT: {57449, 'u'}, KeyRIGHTMETA: {57450, 'u'},
+}
+
+// Modifier bits of escape codes, both in the legacy encoding and the kitty
+// protocol, which sends them plus 1.
+const (
+	termShift = 1 << iota
+	termAlt
+	termCtrl
+	termSuper
+	termHyper
+	termMeta
+	termCapsLock
+	termNumLock
+)
+
+// termMods are the modifier bits of the modifier keys.
+var termMods = map[KeyCode]int{
+	KeyLEFTSHIFT: termShift, KeyRIGHTSHIFT: termShift,
+	KeyLEFTALT: termAlt, KeyRIGHTALT: termAlt,
+	KeyLEFTCTRL: termCtrl, KeyRIGHTCTRL: termCtrl,
+	KeyLEFTMETA: termSuper, KeyRIGHTMETA: termSuper,
+}
+
+// TerminalEncoder converts key events into the bytes a terminal sends for
+// them to the program running in it, so that a physical keyboard can be
+// forwarded into a PTY or a terminal multiplexer pane. With no KittyFlags,
+// it uses the legacy xterm encoding, in which keys produce text or escape
+// codes when pressed or repeated, and releases produce nothing; otherwise it
+// uses the kitty keyboard protocol with those enhancements, as the program
+// asked for them. Text is produced with a Keymap.
+//
+// A TerminalEncoder tracks the modifiers and locks from the events it is
+// given, so it should be given all of them. It is not safe for concurrent
+// use.
+type TerminalEncoder struct {
+	Keymap *Keymap
+	Flags  KittyFlags
+
+	held map[KeyCode]bool
+	caps bool
+	num  bool
+}
+
+// NewTerminalEncoder creates a TerminalEncoder producing text with m, or
+// with KeymapUS if m is nil, and encoding keys with flags.
+func NewTerminalEncoder(m *Keymap, flags KittyFlags) *TerminalEncoder {
+	if m == nil {
+		m = KeymapUS
+	}
+	return &TerminalEncoder{Keymap: m, Flags: flags, held: map[KeyCode]bool{}}
+}
+
+// Encode returns the bytes a terminal sends for event, which are none for
+// many events, such as releases in the legacy encoding.
+func (e *TerminalEncoder) Encode(event Event) []byte {
+	key := event.Code
+	switch event.Value {
+	case Press:
+		e.held[key] = true
+		switch key {
+		case KeyCAPSLOCK:
+			e.caps = !e.caps
+		case KeyNUMLOCK:
+			e.num = !e.num
+		}
+	case Release:
+		delete(e.held, key)
+	}
+	mods := e.mods()
+	if e.Flags == 0 {
+		return e.legacy(key, event.Value, mods)
+	}
+	return e.kitty(key, event.Value, mods)
+}
+
+// mods returns the modifier bits of the keys held and locks on.
+func (e *TerminalEncoder) mods() int {
+	var mods int
+	for key := range e.held {
+		mods |= termMods[key]
+	}
+	if e.caps {
+		mods |= termCapsLock
+	}
+	if e.num {
+		mods |= termNumLock
+	}
+	return mods
+}
+
+// text returns the rune key produces with mods, if any.
+func (e *TerminalEncoder) text(key KeyCode, mods int) (rune, bool) {
+	shifted := mods&termShift != 0
+	r, ok := e.Keymap.Rune(key, false)
+	if !ok {
+		return 0, false
+	}
+	if unicode.IsLetter(r) && mods&termCapsLock != 0 {
+		shifted = !shifted
+	}
+	return e.Keymap.Rune(key, shifted)
+}
+
+// legacy encodes key in the legacy xterm encoding.
+func (e *TerminalEncoder) legacy(key KeyCode, value int32, mods int) []byte {
+	if value == Release {
+		return nil
+	}
+	mods &= termShift | termAlt | termCtrl | termSuper
+	var out []byte
+	if r, ok := e.text(key, mods); ok {
+		if mods&termCtrl != 0 {
+			c, ok := ctrlRune(r)
+			if !ok {
+				return nil
+			}
+			r = c
+		}
+		if mods&termAlt != 0 {
+			out = append(out, 0x1b)
+		}
+		return append(out, string(r)...)
+	}
+
+	switch key {
+	case KeyENTER, KeyKPENTER:
+		out = []byte{'\r'}
+	case KeyTAB:
+		if mods&termShift != 0 {
+			return []byte("\x1b[Z")
+		}
+		out = []byte{'\t'}
+	case KeyBACKSPACE:
+		if mods&termCtrl != 0 {
+			out = []byte{0x08}
+		} else {
+			out = []byte{0x7f}
+		}
+	case KeyESC:
+		out = []byte{0x1b}
+	}
+	if out != nil {
+		if mods&termAlt != 0 {
+			out = append([]byte{0x1b}, out...)
+		}
+		return out
+	}
+
+	k, ok := termKeys[key]
+	if !ok || k.final == 'u' {
+		return nil
+	}
+	if key >= KeyF1 && key <= KeyF4 {
+		k = termKey{1, "PQRS"[key-KeyF1]}
+		if mods == 0 {
+			return []byte{0x1b, 'O', k.final}
+		}
+	}
+	return csi(k, mods)
+}
+
+// ctrlRune returns the control character that r produces with Ctrl.
+func ctrlRune(r rune) (rune, bool) {
+	switch {
+	case r >= 'a' && r <= 'z':
+		return r - 'a' + 1, true
+	case r >= 'A' && r <= 'Z':
+		return r - 'A' + 1, true
+	}
+	switch r {
+	case ' ', '@', '2':
+		return 0, true
+	case '[', '3':
+		return 0x1b, true
+	case '\\', '4':
+		return 0x1c, true
+	case ']', '5':
+		return 0x1d, true
+	case '^', '6':
+		return 0x1e, true
+	case '_', '/', '7':
+		return 0x1f, true
+	case '?', '8':
+		return 0x7f, true
+	}
+	return 0, false
+}
+
+// kitty encodes key in the kitty keyboard protocol with e.Flags.
+func (e *TerminalEncoder) kitty(key KeyCode, value int32, mods int) []byte {
+	all := e.Flags&KittyAllKeys != 0
+	if value == Release && e.Flags&KittyEventTypes == 0 {
+		return nil
+	}
+	eventType := 0
+	if e.Flags&KittyEventTypes != 0 {
+		switch value {
+		case Repeat:
+			eventType = 2
+		case Release:
+			eventType = 3
+		}
+	}
+
+	k, functional := termKeys[key]
+	r, isText := e.text(key, mods) // keypad keys are functional and text
+	if !all {
+		if _, mod := termMods[key]; mod || key == KeyCAPSLOCK || key == KeyNUMLOCK {
+			return nil
+		}
+		legacy := isText && mods&(termAlt|termCtrl|termSuper) == 0
+		switch key {
+		case KeyENTER, KeyKPENTER, KeyTAB, KeyBACKSPACE:
+			legacy = mods&(termShift|termAlt|termCtrl|termSuper) == 0
+		}
+		if legacy {
+			if value == Release {
+				return nil
+			}
+			return e.legacy(key, value, mods)
+		}
+	}
+
+	var alt rune
+	if !functional {
+		base, ok := e.Keymap.Rune(key, false)
+		if !ok {
+			return nil
+		}
+		k = termKey{int(base), 'u'}
+		if e.Flags&KittyAlternateKeys != 0 && mods&termShift != 0 {
+			if shifted, ok := e.Keymap.Rune(key, true); ok && shifted != base {
+				alt = shifted
+			}
+		}
+	}
+	var text string
+	if all && e.Flags&KittyText != 0 && isText && value != Release && mods&(termCtrl|termAlt|termSuper) == 0 {
+		text = strconv.Itoa(int(r))
+	}
+	return csiKitty(k, alt, mods, eventType, text)
+}
+
+// csi returns the legacy escape code of k with the modifier bits mods.
+func csi(k termKey, mods int) []byte {
+	out := []byte("\x1b[")
+	if mods != 0 {
+		out = strconv.AppendInt(out, int64(k.num), 10)
+		out = append(out, ';')
+		out = strconv.AppendInt(out, int64(mods+1), 10)
+	} else if k.num != 1 {
+		out = strconv.AppendInt(out, int64(k.num), 10)
+	}
+	return append(out, k.final)
+}
+
+// csiKitty returns the kitty protocol escape code of k: "CSI num[:alt]
+// [;mods[:type]][;text] final".
+func csiKitty(k termKey, alt rune, mods, eventType int, text string) []byte {
+	out := []byte("\x1b[")
+	fields := mods != 0 || eventType != 0 || text != ""
+	if k.num != 1 || alt != 0 || fields {
+		out = strconv.AppendInt(out, int64(k.num), 10)
+	}
+	if alt != 0 {
+		out = append(out, ':')
+		out = strconv.AppendInt(out, int64(alt), 10)
+	}
+	if mods != 0 || eventType != 0 {
+		out = append(out, ';')
+		out = strconv.AppendInt(out, int64(mods+1), 10)
+		if eventType != 0 {
+			out = append(out, ':')
+			out = strconv.AppendInt(out, int64(eventType), 10)
+		}
+	}
+	if text != "" {
+		if mods == 0 && eventType == 0 {
+			out = append(out, ';')
+		}
+		out = append(out, ';')
+		out = append(out, text...)
+	}
+	return append(out, k.final)
+}
+
+// Forward writes the bytes of every event read from b to w, such as a PTY,
+// until reading or writing fails, and returns the error.
+func (e *TerminalEncoder) Forward(w io.Writer, b Backend) error {
+	for {
+		event, err := b.ReadEvent()
+		if err != nil {
+			return err
+		}
+		if out := e.Encode(event); len(out) > 0 {
+			if _, err := w.Write(out); err != nil {
+				return err
+			}
+		}
+	}
+}
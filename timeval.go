@@ -0,0 +1,46 @@
+package kbd
+
+import "time"
+
+// Timeval is a timestamp as the kernel reports it in an input_event: whole
+// seconds and microseconds since the Unix epoch, on the clock chosen for the
+// device (CLOCK_REALTIME unless changed with EVIOCSCLOCKID).
+type Timeval struct {
+	Sec  int64
+	Usec int64
+}
+
+// TimevalOf returns the Timeval of t, truncated to the microsecond.
+func TimevalOf(t time.Time) Timeval {
+	return Timeval{Sec: t.Unix(), Usec: int64(t.Nanosecond() / 1000)}
+}
+
+// Time converts tv to a time.Time. Out of range microseconds, which some
+// drivers produce, are carried into the seconds rather than being lost.
+func (tv Timeval) Time() time.Time {
+	sec, usec := tv.Sec+tv.Usec/1e6, tv.Usec%1e6
+	if usec < 0 {
+		sec, usec = sec-1, usec+1e6
+	}
+	return time.Unix(sec, usec*1000)
+}
+
+// IsZero reports whether tv is unset.
+func (tv Timeval) IsZero() bool {
+	return tv == Timeval{}
+}
+
+// Since returns the time elapsed between other and e, which is negative if
+// other happened after e. Kernel timestamps are compared when both events
+// have them and come from the same Device, so the result is exact to the
+// microsecond the kernel recorded. Events of different devices may be
+// stamped with different clocks, so their Times are compared instead, which
+// are on one clock if the events were read through Align.
+func (e Event) Since(other Event) time.Duration {
+	if !e.Timeval.IsZero() && !other.Timeval.IsZero() && e.Device == other.Device {
+		sec := e.Timeval.Sec - other.Timeval.Sec
+		usec := e.Timeval.Usec - other.Timeval.Usec
+		return time.Duration(sec)*time.Second + time.Duration(usec)*time.Microsecond
+	}
+	return e.Time.Sub(other.Time)
+}
@@ -0,0 +1,120 @@
+package kbd
+
+import "sync"
+
+// DefaultToggle is the combo of a Config's Toggle if it sets none.
+var DefaultToggle = Combo{Mods: [4]KeyCode{AnyCtrl, AnyAlt, 0, 0}, Key: KeyPAUSE}
+
+// Toggle is a Backend that watches the keys read from another Backend for a
+// master combo, which turns whatever reads from it off and on, such as a
+// daemon's bindings and remaps, so that the user can get out of a bad
+// config. Toggle only keeps the state and reports changes to its handler;
+// all events pass through it, except for the combo's key, which is
+// swallowed so that nothing else sees it. Modifiers held with it do pass
+// through. A Toggle starts enabled.
+type Toggle struct {
+	b Backend
+	f func(enabled bool)
+
+	mu      sync.Mutex
+	combo   Combo
+	enabled bool
+	held    map[KeyCode]bool
+	swallow KeyCode // combo key held, whose repeats and release are swallowed
+}
+
+// NewToggle creates a Toggle reading from b, switched by c, which calls f
+// each time it is switched. f is called from ReadEvent. If c has no Key, the
+// Toggle is only switched by SetEnabled.
+func NewToggle(b Backend, c Combo, f func(enabled bool)) *Toggle {
+	return &Toggle{b: b, f: f, combo: c, enabled: true, held: map[KeyCode]bool{}}
+}
+
+// SetCombo replaces the combo that switches t.
+func (t *Toggle) SetCombo(c Combo) {
+	t.mu.Lock()
+	t.combo = c
+	t.mu.Unlock()
+}
+
+// Enabled reports whether t is enabled.
+func (t *Toggle) Enabled() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.enabled
+}
+
+// SetEnabled enables or disables t, calling its handler if that changes it.
+func (t *Toggle) SetEnabled(enabled bool) {
+	t.mu.Lock()
+	changed := t.enabled != enabled
+	t.enabled = enabled
+	t.mu.Unlock()
+	if changed && t.f != nil {
+		t.f(enabled)
+	}
+}
+
+func (t *Toggle) ReadEvent() (Event, error) {
+	for {
+		event, err := t.b.ReadEvent()
+		if err != nil {
+			return event, err
+		}
+		t.mu.Lock()
+		if event.Code == t.swallow && t.swallow != 0 {
+			if event.Value == Release {
+				t.swallow = 0
+			}
+			t.mu.Unlock()
+			continue
+		}
+		switch event.Value {
+		case Press:
+			t.held[event.Code] = true
+		case Release:
+			delete(t.held, event.Code)
+		}
+		pressed := event.Value == Press && t.combo.Key != 0 &&
+			Matches(t.combo.Key, event.Code) && t.combo.modsHeld(t.down)
+		if !pressed {
+			t.mu.Unlock()
+			return event, nil
+		}
+		delete(t.held, event.Code)
+		t.swallow = event.Code
+		enabled := !t.enabled
+		t.mu.Unlock()
+		t.SetEnabled(enabled)
+	}
+}
+
+// down reports whether key, or a member of the group key, is held. t.mu
+// must be held.
+func (t *Toggle) down(key KeyCode) bool {
+	for _, k := range Members(key) {
+		if t.held[k] {
+			return true
+		}
+	}
+	return false
+}
+
+// Grab grabs or releases the underlying Backend if it is a Grabber, so that
+// a Remapper can read from t.
+func (t *Toggle) Grab(grab bool) error {
+	if g, ok := t.b.(Grabber); ok {
+		return g.Grab(grab)
+	}
+	return nil
+}
+
+// Features returns the features of the underlying Backend that t passes on:
+// grabs, releases, health checks and read deadlines.
+func (t *Toggle) Features() Feature {
+	return Features(t.b) & (FeatureGrab | FeatureKeyUp | FeatureInject | FeatureHealth | FeatureDeadline)
+}
+
+func (t *Toggle) Close() error {
+	return t.b.Close()
+}
@@ -0,0 +1,294 @@
+package kbd
+
+import (
+	"os"
+	"sync"
+	"time"
+)
+
+// Gesture is a touchpad gesture recognized by a Touchpad.
+type Gesture int
+
+// Gestures.
+const (
+	SwipeLeft Gesture = iota
+	SwipeRight
+	SwipeUp
+	SwipeDown
+)
+
+func (g Gesture) String() string {
+	switch g {
+	case SwipeLeft:
+		return "SwipeLeft"
+	case SwipeRight:
+		return "SwipeRight"
+	case SwipeUp:
+		return "SwipeUp"
+	case SwipeDown:
+		return "SwipeDown"
+	}
+	return "Gesture(?)"
+}
+
+// GestureEvent is published on a Keyboard's Bus by a Touchpad it reads from
+// when a gesture is made.
+type GestureEvent struct {
+	Gesture Gesture
+	Fingers int       // number of fingers, 3 or more
+	Time    time.Time // when the fingers were lifted
+	Device  string
+}
+
+// Axes and tool keys of touchpads, from "input-event-codes.h".
+const (
+	absX            = 0x00
+	absY            = 0x01
+	absMTSlot       = 0x2f
+	absMTPositionX  = 0x35
+	absMTPositionY  = 0x36
+	absMTTrackingID = 0x39
+	btnToolQuint    = 0x148
+	btnToolTriple   = 0x14e
+	btnToolQuad     = 0x14f
+	absInfoSize     = 24
+	minSwipeFactor  = 0.2 // of the touchpad's size
+	maxSlots        = 10  // contacts tracked
+)
+
+// eviocgAbs returns the EVIOCGABS ioctl for the axis abs.
+func eviocgAbs(abs uintptr) uintptr {
+	return eviocg(0x40+abs, absInfoSize)
+}
+
+// touchSlot is a contact of the multitouch protocol.
+type touchSlot struct {
+	id    int32    // tracking ID; -1 if there is no contact
+	pos   [2]int32 // position
+	start [2]int32 // position when the swipe started
+	swipe bool     // the contact is part of the swipe being made
+}
+
+// Touchpad is a Backend that recognizes swipes of three or more fingers on
+// a touchpad's evdev device, for desktops that lack gestures of their own.
+// Gestures are published as GestureEvents on the Bus of the Keyboard reading
+// from it (see BusPublisher), and the keys they are mapped to in Keys are
+// read as if tapped, so that gestures can be bound with Hotkeys. The
+// fingers are tracked with the multitouch protocol (ABS_MT_SLOT and
+// ABS_MT_POSITION_X/Y), and the swipe is the fingers' mean movement, which
+// must cross a fifth of the touchpad. A swipe starts again when a finger is
+// added, so that a four-finger swipe isn't taken for a three-finger one, and
+// ends when one is lifted. Touchpads without multitouch are read as one
+// contact, with the fingers counted by BTN_TOOL_TRIPLETAP and the like. The
+// touchpad isn't grabbed, so it still moves the pointer.
+type Touchpad struct {
+	// Keys maps gestures to the keys tapped for them, such as KeyBACK for
+	// SwipeLeft in a browser. Gestures not in Keys are only published.
+	Keys map[Gesture]KeyCode
+
+	d      *evdev
+	mt     bool  // the touchpad has multitouch slots
+	width  int32 // ranges of the X and Y positions
+	height int32
+
+	mu  sync.Mutex
+	bus *Bus
+
+	slots   [maxSlots]touchSlot
+	slot    int  // slot the multitouch events are for
+	tool    int  // fingers counted by BTN_TOOL_*, 3 or more, or 0
+	fingers int  // fingers of the swipe being made, or 0
+	lifting bool // a swipe ended, and fingers are still down
+	pending []Event
+}
+
+// OpenTouchpad opens the touchpad device at path.
+func OpenTouchpad(path string) (*Touchpad, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	t := &Touchpad{d: &evdev{file: f}}
+	for i := range t.slots {
+		t.slots[i].id = -1
+	}
+	// struct input_absinfo: value, minimum, maximum, ...
+	var slot, x, y [6]int32
+	xAxis, yAxis := uintptr(absX), uintptr(absY)
+	if ioctlData(f.Fd(), eviocgAbs(absMTSlot), &slot) == nil && slot[2] > 0 {
+		t.mt = true
+		t.slot = int(slot[0])
+		xAxis, yAxis = absMTPositionX, absMTPositionY
+	}
+	err = ioctlData(f.Fd(), eviocgAbs(xAxis), &x)
+	if err == nil {
+		err = ioctlData(f.Fd(), eviocgAbs(yAxis), &y)
+	}
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	t.width, t.height = x[2]-x[1], y[2]-y[1]
+	return t, nil
+}
+
+// PublishOn sets the Bus that GestureEvents are published on.
+func (t *Touchpad) PublishOn(b *Bus) {
+	t.mu.Lock()
+	t.bus = b
+	t.mu.Unlock()
+}
+
+func (t *Touchpad) ReadEvent() (Event, error) {
+	for len(t.pending) == 0 {
+		raw, err := t.d.read()
+		if err != nil {
+			return Event{}, err
+		}
+		t.handle(raw)
+	}
+	event := t.pending[0]
+	t.pending = t.pending[1:]
+	return event, nil
+}
+
+// handle tracks the contacts and fingers from raw, and the swipe at the end
+// of each frame.
+func (t *Touchpad) handle(raw inputEvent) {
+	var s *touchSlot
+	if t.slot >= 0 && t.slot < maxSlots {
+		s = &t.slots[t.slot]
+	}
+	value := int32(raw.Value)
+	switch {
+	case raw.Kind == eventABS && raw.Code == absMTSlot:
+		t.slot = int(value)
+	case raw.Kind == eventABS && raw.Code == absMTTrackingID && s != nil:
+		if value >= 0 && s.id < 0 {
+			s.swipe = false // a new contact, not part of the swipe
+		}
+		s.id = value
+	case raw.Kind == eventABS && raw.Code == absMTPositionX && s != nil:
+		s.pos[0] = value
+	case raw.Kind == eventABS && raw.Code == absMTPositionY && s != nil:
+		s.pos[1] = value
+	case raw.Kind == eventABS && raw.Code == absX && !t.mt:
+		t.slots[0].pos[0] = value
+	case raw.Kind == eventABS && raw.Code == absY && !t.mt:
+		t.slots[0].pos[1] = value
+	case raw.Kind == eventKEY:
+		fingers := 0
+		switch raw.Code {
+		case btnToolTriple:
+			fingers = 3
+		case btnToolQuad:
+			fingers = 4
+		case btnToolQuint:
+			fingers = 5
+		default:
+			return
+		}
+		if raw.Value == Press {
+			t.tool = fingers
+		} else if raw.Value == Release && t.tool == fingers {
+			t.tool = 0
+		}
+	case raw.Kind == eventSYN && raw.Code == synReport:
+		t.frame(t.d.event(raw))
+	}
+}
+
+// frame follows the swipe at the end of a frame, at event: it starts when
+// three or more fingers are down, starts again when a finger is added, and
+// is recognized when a finger is lifted.
+func (t *Touchpad) frame(event Event) {
+	if !t.mt {
+		t.slots[0].id = -1
+		if t.tool > 0 {
+			t.slots[0].id = 0
+		}
+	}
+	n := 0
+	for _, s := range t.slots {
+		if s.id >= 0 {
+			n++
+		}
+	}
+	if t.tool > n { // more fingers than slots
+		n = t.tool
+	}
+
+	switch {
+	case t.lifting:
+		t.lifting = n > 0
+	case n >= 3 && n > t.fingers:
+		t.fingers = n
+		for i := range t.slots {
+			s := &t.slots[i]
+			s.start = s.pos
+			s.swipe = s.id >= 0
+		}
+	case t.fingers > 0 && n < t.fingers:
+		t.gesture(event)
+		t.fingers = 0
+		t.lifting = n > 0
+	}
+}
+
+// gesture recognizes the swipe made by the fingers, which are being lifted
+// at event.
+func (t *Touchpad) gesture(event Event) {
+	var dx, dy, n int32
+	for _, s := range t.slots {
+		if s.swipe {
+			dx += s.pos[0] - s.start[0]
+			dy += s.pos[1] - s.start[1]
+			n++
+		}
+	}
+	if n == 0 {
+		return
+	}
+	dx, dy = dx/n, dy/n
+	ax, ay := abs32(dx), abs32(dy)
+	var g Gesture
+	switch {
+	case ax > 2*ay && float64(ax) >= minSwipeFactor*float64(t.width):
+		g = SwipeRight
+		if dx < 0 {
+			g = SwipeLeft
+		}
+	case ay > 2*ax && float64(ay) >= minSwipeFactor*float64(t.height):
+		g = SwipeDown
+		if dy < 0 {
+			g = SwipeUp
+		}
+	default:
+		return
+	}
+
+	t.mu.Lock()
+	bus := t.bus
+	t.mu.Unlock()
+	if bus != nil {
+		bus.Publish(GestureEvent{Gesture: g, Fingers: t.fingers, Time: event.Time, Device: event.Device})
+	}
+	if key, ok := t.Keys[g]; ok {
+		event.Code = key
+		event.Value = Press
+		t.pending = append(t.pending, event)
+		event.Value = Release
+		t.pending = append(t.pending, event)
+	}
+}
+
+func abs32(x int32) int32 {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+func (t *Touchpad) Close() error {
+	return t.d.Close()
+}
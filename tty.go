@@ -0,0 +1,51 @@
+package kbd
+
+import (
+	"fmt"
+
+	"golang.org/x/sys/unix"
+)
+
+// TerminalWarning is returned by Stop when the terminal's state could not be
+// restored, for example because the terminal was already gone. The Keyboard
+// is stopped anyway. If Recovered is true, the terminal was instead reset to
+// sane settings, like `stty sane` does, so echo and line editing work again.
+type TerminalWarning struct {
+	Err       error
+	Recovered bool
+}
+
+func (w *TerminalWarning) Error() string {
+	if w.Recovered {
+		return fmt.Sprintf("kbd: restoring terminal: %v (reset to sane settings)", w.Err)
+	}
+	return fmt.Sprintf("kbd: restoring terminal: %v", w.Err)
+}
+
+func (w *TerminalWarning) Unwrap() error {
+	return w.Err
+}
+
+// resetTerminal puts the controlling terminal back into the usual "cooked"
+// mode with echo, in the way of `stty sane`. The terminal is opened again,
+// since the Keyboard's own file for it may no longer be usable.
+func resetTerminal() error {
+	fd, err := unix.Open("/dev/tty", unix.O_RDWR|unix.O_NOCTTY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return err
+	}
+	defer unix.Close(fd)
+
+	t, err := unix.IoctlGetTermios(fd, unix.TCGETS)
+	if err != nil {
+		return err
+	}
+	t.Iflag |= unix.BRKINT | unix.ICRNL | unix.IMAXBEL
+	t.Iflag &^= unix.IGNBRK | unix.INLCR | unix.IGNCR | unix.IXOFF
+	t.Oflag |= unix.OPOST | unix.ONLCR
+	t.Lflag |= unix.ISIG | unix.ICANON | unix.IEXTEN | unix.ECHO | unix.ECHOE | unix.ECHOK | unix.ECHOCTL | unix.ECHOKE
+	t.Lflag &^= unix.ECHONL | unix.NOFLSH | unix.TOSTOP
+	t.Cc[unix.VMIN] = 1
+	t.Cc[unix.VTIME] = 0
+	return unix.IoctlSetTermios(fd, unix.TCSETS, t)
+}
@@ -0,0 +1,223 @@
+package kbd
+
+import (
+	"math/rand"
+	"strconv"
+	"time"
+	"unicode"
+)
+
+// TypeRune types r on the virtual keyboard, using the layout of
+// DefaultKeymap(). Newlines are typed with Enter. Runes that no key types are
+// entered as Unicode code points with Ctrl+Shift+U, the hexadecimal code and
+// Space, which works in GTK and IBus input methods.
+func (v *Virtual) TypeRune(r rune) error {
+	return v.typeRune(r, 0)
+}
+
+// typeRune types r, holding its key down for hold.
+func (v *Virtual) typeRune(r rune, hold time.Duration) error {
+	if r == '\n' {
+		return v.tapFor(KeyENTER, false, hold)
+	}
+	if key, shift, ok := DefaultKeymap().Key(r); ok {
+		return v.tapFor(key, shift, hold)
+	}
+
+	if err := v.Press(KeyLEFTCTRL); err != nil {
+		return err
+	}
+	if err := v.Press(KeyLEFTSHIFT); err != nil {
+		return err
+	}
+	err := v.Tap(KeyU)
+	v.Release(KeyLEFTSHIFT)
+	v.Release(KeyLEFTCTRL)
+	if err != nil {
+		return err
+	}
+	for _, digit := range strconv.FormatInt(int64(r), 16) {
+		key, shift, ok := DefaultKeymap().Key(digit)
+		if !ok {
+			key, shift, ok = DefaultKeymap().Key(unicode.ToUpper(digit))
+		}
+		if !ok {
+			continue // every layout has digits and a-f
+		}
+		if err := v.tapFor(key, shift, 0); err != nil {
+			return err
+		}
+	}
+	return v.Tap(KeySPACE)
+}
+
+// Type types each rune of s with TypeRune.
+func (v *Virtual) Type(s string) error {
+	for _, r := range s {
+		if err := v.TypeRune(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// tapFor presses key for hold, with shift held if shift is true.
+func (v *Virtual) tapFor(key KeyCode, shift bool, hold time.Duration) error {
+	if shift {
+		if err := v.Press(KeyLEFTSHIFT); err != nil {
+			return err
+		}
+		defer v.Release(KeyLEFTSHIFT)
+	}
+	if err := v.Press(key); err != nil {
+		return err
+	}
+	time.Sleep(hold)
+	return v.Release(key)
+}
+
+// TypingProfile controls the timing and mistakes of TypeWith, to make typing
+// look like a person's for demo recordings or bot detection research.
+type TypingProfile struct {
+	// Delay is the mean time between keys, and Jitter its standard
+	// deviation; delays follow a normal distribution, or are constant if
+	// Jitter is 0.
+	Delay, Jitter time.Duration
+
+	// Model, if not empty, is a recording of the times between keys of a
+	// person typing (see TypingModel). Delays are then drawn from it, and
+	// Delay and Jitter are ignored.
+	Model []time.Duration
+
+	// Hold is how long each key is held down.
+	Hold time.Duration
+
+	// ErrorRate is the probability that a character is first mistyped as a
+	// neighbouring key, and then corrected with Backspace.
+	ErrorRate float64
+
+	// Rand is the source of randomness; if nil, the global source is used.
+	Rand *rand.Rand
+}
+
+// Typing profiles.
+var (
+	TypingConstant = &TypingProfile{Delay: 100 * time.Millisecond, Hold: 30 * time.Millisecond}
+	TypingHuman    = &TypingProfile{
+		Delay:     180 * time.Millisecond,
+		Jitter:    60 * time.Millisecond,
+		Hold:      80 * time.Millisecond,
+		ErrorRate: 0.02,
+	}
+)
+
+// TypingModel returns the times between the presses in events, such as
+// events read while a person types, for use as a TypingProfile's Model.
+// Pauses of more than 2 seconds are left out.
+func TypingModel(events []Event) []time.Duration {
+	var model []time.Duration
+	var last time.Time
+	for _, e := range events {
+		if e.Value != Press {
+			continue
+		}
+		if d := e.Time.Sub(last); !last.IsZero() && d > 0 && d <= 2*time.Second {
+			model = append(model, d)
+		}
+		last = e.Time
+	}
+	return model
+}
+
+// delay returns a time to wait before the next key.
+func (p *TypingProfile) delay() time.Duration {
+	if len(p.Model) > 0 {
+		return p.Model[p.intn(len(p.Model))]
+	}
+	d := p.Delay
+	if p.Jitter > 0 {
+		d += time.Duration(p.normFloat64() * float64(p.Jitter))
+	}
+	if d < 0 {
+		d = 0
+	}
+	return d
+}
+
+func (p *TypingProfile) intn(n int) int {
+	if p.Rand != nil {
+		return p.Rand.Intn(n)
+	}
+	return rand.Intn(n)
+}
+
+func (p *TypingProfile) float64() float64 {
+	if p.Rand != nil {
+		return p.Rand.Float64()
+	}
+	return rand.Float64()
+}
+
+func (p *TypingProfile) normFloat64() float64 {
+	if p.Rand != nil {
+		return p.Rand.NormFloat64()
+	}
+	return rand.NormFloat64()
+}
+
+// TypeWith types s like Type, with the timing and mistakes of profile p.
+func (v *Virtual) TypeWith(s string, p *TypingProfile) error {
+	for i, r := range s {
+		if i > 0 {
+			time.Sleep(p.delay())
+		}
+		if p.ErrorRate > 0 && p.float64() < p.ErrorRate {
+			if err := v.mistype(r, p); err != nil {
+				return err
+			}
+		}
+		if err := v.typeRune(r, p.Hold); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mistype types a key next to the one for r, and deletes it with Backspace.
+func (v *Virtual) mistype(r rune, p *TypingProfile) error {
+	key, _, ok := DefaultKeymap().Key(r)
+	if !ok {
+		return nil
+	}
+	near := neighbours(key)
+	if len(near) == 0 {
+		return nil
+	}
+	if err := v.tapFor(near[p.intn(len(near))], false, p.Hold); err != nil {
+		return err
+	}
+	time.Sleep(p.delay() * 2) // noticing the mistake
+	if err := v.tapFor(KeyBACKSPACE, false, p.Hold); err != nil {
+		return err
+	}
+	time.Sleep(p.delay())
+	return nil
+}
+
+// neighbours returns the keys that type characters next to key on
+// LayoutANSI.
+func neighbours(key KeyCode) []KeyCode {
+	k, ok := LayoutANSI.Key(key)
+	if !ok {
+		return nil
+	}
+	var near []KeyCode
+	for _, n := range LayoutANSI.Keys {
+		dx := (n.X + n.W/2) - (k.X + k.W/2)
+		dy := (n.Y + n.H/2) - (k.Y + k.H/2)
+		if n.Code != key && dx*dx+dy*dy <= 1.5*1.5 && printable(n.Code) && n.Code != KeySPACE {
+			near = append(near, n.Code)
+		}
+	}
+	return near
+}
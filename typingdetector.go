@@ -0,0 +1,182 @@
+package kbd
+
+import (
+	"sync"
+	"time"
+)
+
+// TypingStarted is published by a TypingDetector when sustained typing
+// starts.
+type TypingStarted struct {
+	Time time.Time
+}
+
+// TypingStopped is published by a TypingDetector when typing stops: after a
+// pause, or when Enter or Escape ends a line, as when a chat message is sent
+// or cancelled.
+type TypingStopped struct {
+	Time time.Time
+}
+
+// TypingDetector is a Backend that watches the keys read from another
+// Backend for natural typing, such as a player writing in a game's chat, as
+// opposed to playing. Typing is detected when the last MinKeys keys were
+// pressed within Window, nearly all of them keys that type characters, and
+// more than two thirds of them different keys; repeatedly pressing the same
+// few keys, like WASD, is not typing. It publishes TypingStarted and
+// TypingStopped on the Bus of the Keyboard reading from it (see
+// BusPublisher), and Typing reports the current state, so that single-key
+// bindings can be disabled with Hotkeys.SuppressWhile(d.Typing).
+type TypingDetector struct {
+	MinKeys int           // 6 if 0
+	Window  time.Duration // 2s if 0
+	Idle    time.Duration // pause after which typing stops; 1.5s if 0
+
+	b Backend
+
+	mu      sync.Mutex
+	bus     *Bus
+	presses []Event // recent presses, within Window
+	typing  bool
+	idle    *time.Timer
+}
+
+// NewTypingDetector creates a TypingDetector reading from b.
+func NewTypingDetector(b Backend) *TypingDetector {
+	return &TypingDetector{b: b}
+}
+
+// PublishOn sets the Bus that TypingStarted and TypingStopped are published
+// on.
+func (d *TypingDetector) PublishOn(b *Bus) {
+	d.mu.Lock()
+	d.bus = b
+	d.mu.Unlock()
+	if bp, ok := d.b.(BusPublisher); ok {
+		bp.PublishOn(b)
+	}
+}
+
+// Typing reports whether the user is typing.
+func (d *TypingDetector) Typing() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.typing
+}
+
+func (d *TypingDetector) ReadEvent() (Event, error) {
+	event, err := d.b.ReadEvent()
+	if err == nil && event.Value == Press {
+		d.press(event)
+	}
+	return event, err
+}
+
+// press updates the typing state for the press event.
+func (d *TypingDetector) press(event Event) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	minKeys, window, idle := d.MinKeys, d.Window, d.Idle
+	if minKeys <= 0 {
+		minKeys = 6
+	}
+	if window <= 0 {
+		window = 2 * time.Second
+	}
+	if idle <= 0 {
+		idle = 1500 * time.Millisecond
+	}
+
+	if event.Code == KeyENTER || event.Code == KeyKPENTER || event.Code == KeyESC {
+		d.presses = d.presses[:0]
+		d.stop(event.Time)
+		return
+	}
+
+	kept := d.presses[:0]
+	for _, p := range d.presses {
+		if event.Time.Sub(p.Time) <= window {
+			kept = append(kept, p)
+		}
+	}
+	d.presses = append(kept, event)
+
+	if d.typing {
+		if typingKey(event.Code) {
+			d.idle.Reset(idle)
+		}
+		return
+	}
+	if !looksLikeTyping(d.presses, minKeys) {
+		return
+	}
+	d.typing = true
+	d.publish(TypingStarted{Time: event.Time})
+	var t *time.Timer
+	t = time.AfterFunc(idle, func() {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		if d.idle == t {
+			d.stop(time.Now())
+		}
+	})
+	d.idle = t
+}
+
+// stop ends typing, if the user was typing. d.mu must be held.
+func (d *TypingDetector) stop(at time.Time) {
+	if !d.typing {
+		return
+	}
+	d.typing = false
+	d.idle.Stop()
+	d.idle = nil
+	d.publish(TypingStopped{Time: at})
+}
+
+// publish publishes v on d's Bus, if it has one. d.mu must be held.
+func (d *TypingDetector) publish(v interface{}) {
+	if d.bus != nil {
+		d.bus.Publish(v)
+	}
+}
+
+// looksLikeTyping reports whether presses are typing: there are at least
+// minKeys presses, and of the last minKeys, at least 80% are of keys that
+// type characters, and more than two thirds (and more than 4) of different
+// keys.
+func looksLikeTyping(presses []Event, minKeys int) bool {
+	if len(presses) < minKeys {
+		return false
+	}
+	presses = presses[len(presses)-minKeys:]
+	chars := 0
+	distinct := map[KeyCode]bool{}
+	for _, p := range presses {
+		if typingKey(p.Code) {
+			chars++
+		}
+		distinct[p.Code] = true
+	}
+	return chars*5 >= len(presses)*4 && len(distinct)*3 > len(presses)*2 && len(distinct) > 4
+}
+
+// typingKey reports whether key types text: it types a character in the
+// default Keymap, or is Backspace.
+func typingKey(key KeyCode) bool {
+	if key == KeyBACKSPACE {
+		return true
+	}
+	_, ok := DefaultKeymap().Rune(key, false)
+	return ok
+}
+
+// Close stops the idle timer and closes the underlying Backend.
+func (d *TypingDetector) Close() error {
+	d.mu.Lock()
+	if d.idle != nil {
+		d.idle.Stop()
+	}
+	d.mu.Unlock()
+	return d.b.Close()
+}
@@ -0,0 +1,111 @@
+package kbd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// UdevRulesDir is the directory InstallUdevRule writes rules to.
+var UdevRulesDir = "/etc/udev/rules.d"
+
+// GenerateUdevRule returns a udev rule giving the members of group read and
+// write access to the input device, so that programs using kbd need not be
+// run with sudo, and a stable symlink to it, `/dev/input/NAME` with
+// symlink as NAME, unless symlink is empty. The device is matched as a
+// DeviceLabel matches it: by its IDs, name, and serial number if it has one.
+// Install the rule with InstallUdevRule, once, as root. group and symlink
+// may only have letters, digits, '_', '-' and '.', and not start with '-' or
+// '.'.
+func GenerateUdevRule(device DeviceInfo, group, symlink string) (string, error) {
+	if !udevSafe(group) {
+		return "", fmt.Errorf("kbd: bad group %q for a udev rule", group)
+	}
+	if symlink != "" && !udevSafe(symlink) {
+		return "", fmt.Errorf("kbd: bad symlink %q for a udev rule", symlink)
+	}
+	match := []string{
+		`SUBSYSTEM=="input"`,
+		`KERNEL=="event*"`,
+		fmt.Sprintf(`ATTRS{id/vendor}=="%04x"`, device.Vendor),
+		fmt.Sprintf(`ATTRS{id/product}=="%04x"`, device.Product),
+		fmt.Sprintf(`ATTRS{name}=="%s"`, udevPattern(device.Name)),
+	}
+	if device.Serial != "" {
+		match = append(match, fmt.Sprintf(`ATTRS{uniq}=="%s"`, udevPattern(device.Serial)))
+	}
+	assign := []string{fmt.Sprintf(`GROUP="%s"`, group), `MODE="0660"`}
+	if symlink != "" {
+		assign = append(assign, fmt.Sprintf(`SYMLINK+="input/%s"`, symlink))
+	}
+	return fmt.Sprintf("# %s (%04x:%04x)\n%s\n", udevPattern(device.Name), device.Vendor, device.Product,
+		strings.Join(append(match, assign...), ", ")), nil
+}
+
+// UinputUdevRule returns a udev rule giving the members of group access to
+// `/dev/uinput`, which Virtual keyboards and Remappers need. Members can then
+// type anything anywhere, so the group should be trusted as much as root.
+// group is checked as for GenerateUdevRule.
+func UinputUdevRule(group string) (string, error) {
+	if !udevSafe(group) {
+		return "", fmt.Errorf("kbd: bad group %q for a udev rule", group)
+	}
+	return fmt.Sprintf("# virtual input devices\n"+
+		`KERNEL=="uinput", SUBSYSTEM=="misc", GROUP="%s", MODE="0660", OPTIONS+="static_node=uinput"`+"\n",
+		group), nil
+}
+
+// udevSafe reports whether s is safe to write in a udev rule, or as the name
+// of a file: it has only letters, digits, '_', '-' and '.', and doesn't start
+// with '-' or '.'.
+func udevSafe(s string) bool {
+	if s == "" || s[0] == '-' || s[0] == '.' {
+		return false
+	}
+	for _, r := range s {
+		ok := r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' ||
+			r == '_' || r == '-' || r == '.'
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// udevPattern makes s a udev pattern matching s, as udev has no escapes:
+// quotes, line breaks and the glob characters match any character instead.
+func udevPattern(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '"', '*', '?', '[', ']', '|', '\\', '\n', '\r':
+			return '?'
+		}
+		return r
+	}, s)
+}
+
+// InstallUdevRule writes rules to the file name (such as "70-kbd.rules") in
+// UdevRulesDir, replacing it, and has udev reload its rules and apply them
+// to the devices already present. It needs root privileges. name must end
+// in ".rules" and is checked as the symlink of GenerateUdevRule, so that it
+// can't name a file outside UdevRulesDir.
+func InstallUdevRule(name, rules string) error {
+	if !udevSafe(name) || !strings.HasSuffix(name, ".rules") {
+		return fmt.Errorf("kbd: bad udev rules file name %q", name)
+	}
+	path := filepath.Join(UdevRulesDir, name)
+	if err := ioutil.WriteFile(path, []byte(rules), 0644); err != nil {
+		return err
+	}
+	if out, err := exec.Command("udevadm", "control", "--reload-rules").CombinedOutput(); err != nil {
+		return fmt.Errorf("kbd: udevadm: %v: %s", err, strings.TrimSpace(string(out)))
+	}
+	out, err := exec.Command("udevadm", "trigger", "--action=change",
+		"--subsystem-match=input", "--subsystem-match=misc").CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("kbd: udevadm: %v: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+package kbd
+
+import (
+	"os"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// uinput ioctls, from "linux/uinput.h".
+const (
+	uiDevCreate  = 0x5501
+	uiDevDestroy = 0x5502
+	uiDevSetup   = 0x405c5503
+	uiSetEvBit   = 0x40045564
+	uiSetKeyBit  = 0x40045565
+	uiSetRelBit  = 0x40045566
+
+	busVirtual = 0x06
+)
+
+type inputID struct {
+	Bustype uint16
+	Vendor  uint16
+	Product uint16
+	Version uint16
+}
+
+type uinputSetup struct {
+	ID           inputID
+	Name         [80]byte
+	FFEffectsMax uint32
+}
+
+// Virtual is a virtual keyboard created through `/dev/uinput`. Keys pressed
+// on it are seen by the whole system as if typed on a real keyboard.
+type Virtual struct {
+	file *os.File
+}
+
+// NewVirtual creates a virtual keyboard named name, able to send every key
+// with a KeyCode.
+func NewVirtual(name string) (*Virtual, error) {
+	f, err := os.OpenFile("/dev/uinput", os.O_WRONLY|unix.O_NONBLOCK, 0)
+	if err != nil {
+		return nil, err
+	}
+	v := &Virtual{file: f}
+
+	err = ioctlInt(f.Fd(), uiSetEvBit, eventKEY)
+	for key := range keyNames {
+		if err != nil {
+			break
+		}
+		if key <= keyMax {
+			err = ioctlInt(f.Fd(), uiSetKeyBit, uintptr(key))
+		}
+	}
+	if err == nil {
+		err = uinputCreate(f, name)
+	}
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return v, nil
+}
+
+// uinputCreate creates the uinput device f named name, once the events it
+// can send have been set.
+func uinputCreate(f *os.File, name string) error {
+	setup := uinputSetup{ID: inputID{Bustype: busVirtual}}
+	copy(setup.Name[:len(setup.Name)-1], name)
+	if err := ioctlData(f.Fd(), uiDevSetup, &setup); err != nil {
+		return err
+	}
+	if err := ioctlInt(f.Fd(), uiDevCreate, 0); err != nil {
+		return err
+	}
+	panicCreated(f, f.Fd(), true)
+	return nil
+}
+
+// Send emits an event with value Press, Release, or Repeat for key.
+func (v *Virtual) Send(key KeyCode, value int32) error {
+	events := []inputEvent{
+		{Kind: eventKEY, Code: uint16(key), Value: uint32(value)},
+		{Kind: eventSYN, Code: synReport},
+	}
+	buf := make([]byte, len(events)*inputEventSize)
+	tv := TimevalOf(time.Now())
+	for i, raw := range events {
+		raw.Sec, raw.Usec = tv.Sec, tv.Usec
+		raw.encode(buf[i*inputEventSize:])
+	}
+	_, err := v.file.Write(buf)
+	return err
+}
+
+// Press presses key.
+func (v *Virtual) Press(key KeyCode) error {
+	return v.Send(key, Press)
+}
+
+// Release releases key.
+func (v *Virtual) Release(key KeyCode) error {
+	return v.Send(key, Release)
+}
+
+// Tap presses and releases key.
+func (v *Virtual) Tap(key KeyCode) error {
+	if err := v.Press(key); err != nil {
+		return err
+	}
+	return v.Release(key)
+}
+
+// Inject sends every event read from b until reading fails, and returns the
+// error. It can be used to replay a stream of events on this system.
+func (v *Virtual) Inject(b Backend) error {
+	for {
+		event, err := b.ReadEvent()
+		if err != nil {
+			return err
+		}
+		if err = v.Send(event.Code, event.Value); err != nil {
+			return err
+		}
+	}
+}
+
+// Close destroys the virtual keyboard.
+func (v *Virtual) Close() error {
+	panicCreated(v.file, 0, false)
+	ioctlInt(v.file.Fd(), uiDevDestroy, 0)
+	return v.file.Close()
+}
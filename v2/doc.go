@@ -0,0 +1,49 @@
+// Package kbd is version 2 of github.com/quillaja/kbd, which reads the state
+// of keys directly from the devices in `/dev/input/` on Linux.
+//
+// Version 2 gathers the API that grew around version 1 behind a smaller
+// surface: a Keyboard is created with options, delivers full Events (with
+// the device, time and sequence number of each key event) rather than bare
+// KeyCodes, and has its buffering chosen per channel. It is built on
+// version 1, so both can be used in one program while it is migrated, and
+// anything not (yet) in version 2 is reachable with Keyboard.Unwrap.
+//
+// Example (obviously no error handling):
+//
+//	kb, _ := kbd.Open("/dev/input/event0", kbd.Headless())
+//	defer kb.Close()
+//
+//	kb.Start()
+//	for ev := range kb.Events() {
+//		switch {
+//		case ev.Code == kbd.KeyA && ev.Value == kbd.Press:
+//			fmt.Println("A down")
+//		case ev.Code == kbd.KeyESC && ev.Value == kbd.Press:
+//			kb.Stop()
+//		}
+//	}
+//	fmt.Println("Error:", kb.Err())
+//
+// # Migrating from version 1
+//
+// The import path changes to github.com/quillaja/kbd/v2; the package name is
+// still kbd. Then:
+//
+//   - Keyboard.Event(), the channel of bare KeyCodes, is gone, so that code
+//     ranging over it fails to compile rather than changing meaning. Range
+//     over Events() instead and use ev.Code, checking ev.Value instead of
+//     calling IsDown: the state read by IsDown may have changed again since
+//     the event, which was the reason for the change. Repeats are delivered
+//     too, with Value Repeat. For a quick port, LegacyEvents() is the
+//     version 1 channel, unchanged.
+//   - Events() and Frames() no longer drop all but the latest value. They
+//     buffer up to 64 values by default, and what happens when the buffer is
+//     full is chosen with the Buffer option.
+//   - Open and New take options instead of there being several functions:
+//     OpenBackend(name, path) is Open(path, WithBackend(name)), and
+//     NewHeadless(b) is New(b, Headless()).
+//   - Everything else, such as Hotkeys or LockChanged, is used as before on
+//     the version 1 Keyboard returned by Unwrap.
+package kbd
+
+//go:generate go run ./internal/genkeys
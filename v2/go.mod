@@ -0,0 +1,14 @@
+module github.com/quillaja/kbd/v2
+
+go 1.18
+
+// Version 2 is built on version 1 with the Bus and Features, which no
+// release has yet. Within this repository, go.work builds it on version 1
+// from the same tree.
+require github.com/quillaja/kbd v0.0.0-20261017021626-9f06c34dccf2
+
+require (
+	github.com/godbus/dbus/v5 v5.1.0 // indirect
+	github.com/pkg/term v0.0.0-20190109203006-aa71e9d9e942 // indirect
+	golang.org/x/sys v0.0.0-20191003212358-c178f38b412c // indirect
+)
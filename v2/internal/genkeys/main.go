@@ -0,0 +1,91 @@
+// Command genkeys writes keys.go, which makes the KeyCode constants of
+// version 1 of the package available in version 2. It reads the constants
+// from the version 1 sources in the parent directory of the v2 module.
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+func main() {
+	dir := ".."
+	if len(os.Args) > 1 {
+		dir = os.Args[1]
+	}
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, dir, func(fi os.FileInfo) bool {
+		return !strings.HasSuffix(fi.Name(), "_test.go")
+	}, 0)
+	if err != nil {
+		log.Fatal(err)
+	}
+	pkg, ok := pkgs["kbd"]
+	if !ok {
+		log.Fatalf("no package kbd in %s", dir)
+	}
+
+	var names []string
+	for _, file := range pkg.Files {
+		for _, decl := range file.Decls {
+			gen, ok := decl.(*ast.GenDecl)
+			if !ok || gen.Tok != token.CONST {
+				continue
+			}
+			typ := "" // type of the constants, carried on by implicit repetition
+			for _, spec := range gen.Specs {
+				vs := spec.(*ast.ValueSpec)
+				if vs.Type != nil {
+					typ = ""
+					if id, ok := vs.Type.(*ast.Ident); ok {
+						typ = id.Name
+					}
+				} else if vs.Values != nil {
+					typ = ""
+				}
+				if typ != "KeyCode" {
+					continue
+				}
+				for _, name := range vs.Names {
+					if name.IsExported() {
+						names = append(names, name.Name)
+					}
+				}
+			}
+		}
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	fmt.Fprintln(&buf, "// Code generated by genkeys from version 1 of the package; DO NOT EDIT.")
+	fmt.Fprintln(&buf)
+	fmt.Fprintln(&buf, "package kbd")
+	fmt.Fprintln(&buf)
+	fmt.Fprintln(&buf, `import v1 "github.com/quillaja/kbd"`)
+	fmt.Fprintln(&buf)
+	fmt.Fprintln(&buf, "// Key codes and key groups, as in version 1.")
+	fmt.Fprintln(&buf, "const (")
+	for _, name := range names {
+		fmt.Fprintf(&buf, "\t%s = v1.%s\n", name, name)
+	}
+	fmt.Fprintln(&buf, ")")
+
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		log.Fatal(err)
+	}
+	out := filepath.Join(".", "keys.go")
+	if err := ioutil.WriteFile(out, src, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,274 @@
+package kbd
+
+import (
+	"time"
+
+	v1 "github.com/quillaja/kbd"
+)
+
+// Types shared with version 1. Values of these types can be passed between
+// the two versions.
+type (
+	Event          = v1.Event
+	Frame          = v1.Frame
+	KeyCode        = v1.KeyCode
+	Timeval        = v1.Timeval
+	State          = v1.State
+	Lock           = v1.Lock
+	LockEvent      = v1.LockEvent
+	Backpressure   = v1.Backpressure
+	Bus            = v1.Bus
+	KeyEvent       = v1.KeyEvent
+	DeviceEvent    = v1.DeviceEvent
+	ErrorEvent     = v1.ErrorEvent
+	Backend        = v1.Backend
+	FrameReader    = v1.FrameReader
+	Grabber        = v1.Grabber
+	LockReader     = v1.LockReader
+	KeyStateReader = v1.KeyStateReader
+	Deadliner      = v1.Deadliner
+	HealthChecker  = v1.HealthChecker
+	EventMasker    = v1.EventMasker
+	DeviceReporter = v1.DeviceReporter
+
+	Feature          = v1.Feature
+	FeatureReporter  = v1.FeatureReporter
+	UnsupportedError = v1.UnsupportedError
+)
+
+// Values for key events.
+const (
+	Release = v1.Release
+	Press   = v1.Press
+	Repeat  = v1.Repeat
+)
+
+// Backpressure policies.
+const (
+	DropOldest = v1.DropOldest
+	DropNewest = v1.DropNewest
+	Block      = v1.Block
+	Coalesce   = v1.Coalesce
+)
+
+// Lock keys.
+const (
+	NumLock    = v1.NumLock
+	CapsLock   = v1.CapsLock
+	ScrollLock = v1.ScrollLock
+)
+
+// Features.
+const (
+	FeatureGrab     = v1.FeatureGrab
+	FeatureInject   = v1.FeatureInject
+	FeatureLocks    = v1.FeatureLocks
+	FeatureKeyUp    = v1.FeatureKeyUp
+	FeatureKeyState = v1.FeatureKeyState
+	FeatureDeadline = v1.FeatureDeadline
+	FeatureMask     = v1.FeatureMask
+	FeatureFrames   = v1.FeatureFrames
+	FeatureHealth   = v1.FeatureHealth
+)
+
+// Errors.
+var (
+	ErrStopped     = v1.ErrStopped
+	ErrNotRunning  = v1.ErrNotRunning
+	ErrNoDeadline  = v1.ErrNoDeadline
+	ErrUnsupported = v1.ErrUnsupported
+)
+
+// Option configures a Keyboard created by Open or New.
+type Option func(*options)
+
+type options struct {
+	backend  string
+	headless bool
+	grab     bool
+	size     int
+	policy   Backpressure
+	interval time.Duration
+	poll     func()
+}
+
+// WithBackend has Open read the device with the Backend registered as name,
+// instead of "evdev".
+func WithBackend(name string) Option {
+	return func(o *options) { o.backend = name }
+}
+
+// Headless creates the Keyboard without using a terminal, for daemons and
+// services that have none. Otherwise the terminal at `/dev/tty` is put in
+// cbreak mode while the Keyboard runs, so keys pressed aren't echoed.
+func Headless() Option {
+	return func(o *options) { o.headless = true }
+}
+
+// Grab takes exclusive use of the device, if its Backend is a Grabber, so
+// that no other program sees its events.
+func Grab() Option {
+	return func(o *options) { o.grab = true }
+}
+
+// Buffer sets the number of values Events() and Frames() buffer (64 by
+// default), and what happens when a buffer is full (DropOldest by default).
+func Buffer(size int, policy Backpressure) Option {
+	return func(o *options) { o.size, o.policy = size, policy }
+}
+
+// Poll runs f from the read loop every interval, even when no keys are
+// pressed. The Backend must be a Deadliner.
+func Poll(interval time.Duration, f func()) Option {
+	return func(o *options) { o.interval, o.poll = interval, f }
+}
+
+// Keyboard allows access to key states and events.
+type Keyboard struct {
+	kb     *v1.Keyboard
+	opts   options
+	events *v1.EventSubscription
+	frames *v1.Subscription
+}
+
+// Open opens the device at path, with the "evdev" Backend unless another is
+// chosen with WithBackend, and creates a Keyboard reading from it.
+func Open(path string, opts ...Option) (*Keyboard, error) {
+	o := newOptions(opts)
+	b, err := v1.OpenDevice(o.backend, path)
+	if err != nil {
+		return nil, err
+	}
+	kb, err := newKeyboard(b, o)
+	if err != nil {
+		b.Close()
+		return nil, err
+	}
+	return kb, nil
+}
+
+// New creates a Keyboard reading events from b.
+func New(b Backend, opts ...Option) (*Keyboard, error) {
+	return newKeyboard(b, newOptions(opts))
+}
+
+func newOptions(opts []Option) options {
+	o := options{backend: "evdev", size: 64, policy: DropOldest}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+func newKeyboard(b Backend, o options) (*Keyboard, error) {
+	if o.grab {
+		if g, ok := b.(Grabber); ok {
+			if err := g.Grab(true); err != nil {
+				return nil, err
+			}
+		}
+	}
+	var kb *v1.Keyboard
+	if o.headless {
+		kb = v1.NewHeadless(b)
+	} else {
+		var err error
+		if kb, err = v1.New(b); err != nil {
+			return nil, err
+		}
+	}
+	if o.interval > 0 {
+		if err := kb.OnPoll(o.interval, o.poll); err != nil {
+			return nil, err
+		}
+	}
+	return &Keyboard{kb: kb, opts: o}, nil
+}
+
+// Start starts reading events. Events() and Frames() are valid after Start,
+// deliver the events read from then on, and are closed when reading ends.
+func (k *Keyboard) Start() error {
+	if err := k.kb.Start(); err != nil {
+		return err
+	}
+	k.events = k.kb.SubscribeEvents(k.opts.size, k.opts.policy)
+	k.frames = k.kb.Subscribe(k.opts.size, k.opts.policy)
+	return nil
+}
+
+// Events returns the channel of key events, including repeats.
+func (k *Keyboard) Events() <-chan Event {
+	if k.events == nil {
+		return nil // not started
+	}
+	return k.events.C
+}
+
+// LegacyEvents returns the channel of version 1's Keyboard.Event(): the
+// KeyCode of each key pressed or released, with a KeyCode not yet received
+// replaced by the next one. It is valid after Start, and lets programs
+// written for version 1 be ported by changing Event() to LegacyEvents(). It
+// is independent of Events(), and so of the Buffer option.
+func (k *Keyboard) LegacyEvents() <-chan KeyCode {
+	return k.kb.Event()
+}
+
+// Frames returns the channel of Frames of key changes; see Frame.
+func (k *Keyboard) Frames() <-chan Frame {
+	if k.frames == nil {
+		return nil // not started
+	}
+	return k.frames.C
+}
+
+// Stop restores the terminal state and stops reading events.
+func (k *Keyboard) Stop() error {
+	return k.kb.Stop()
+}
+
+// Close stops reading events and closes the device and terminal.
+func (k *Keyboard) Close() error {
+	return k.kb.Close()
+}
+
+// Err returns the error that ended reading.
+func (k *Keyboard) Err() error {
+	return k.kb.Err()
+}
+
+// IsDown checks if key is down. If key is a group, such as AnyShift, it
+// checks if any key in the group is down.
+func (k *Keyboard) IsDown(key KeyCode) bool {
+	return k.kb.IsDown(key)
+}
+
+// Pressed returns the keys that are down, in order of KeyCode.
+func (k *Keyboard) Pressed() []KeyCode {
+	return k.kb.Pressed()
+}
+
+// State returns a snapshot of the key state.
+func (k *Keyboard) State() State {
+	return k.kb.State()
+}
+
+// Bus returns the Bus on which the Keyboard publishes its events by kind.
+func (k *Keyboard) Bus() *Bus {
+	return k.kb.Bus()
+}
+
+// HealthCheck checks that the Keyboard is reading, as for version 1.
+func (k *Keyboard) HealthCheck(threshold time.Duration) error {
+	return k.kb.HealthCheck(threshold)
+}
+
+// Features returns the features of the Keyboard's Backend, as for version 1.
+func (k *Keyboard) Features() Feature {
+	return k.kb.Features()
+}
+
+// Unwrap returns the version 1 Keyboard that k is built on, for the parts of
+// the API not (yet) in version 2.
+func (k *Keyboard) Unwrap() *v1.Keyboard {
+	return k.kb
+}
@@ -0,0 +1,258 @@
+// Code generated by genkeys from version 1 of the package; DO NOT EDIT.
+
+package kbd
+
+import v1 "github.com/quillaja/kbd"
+
+// Key codes and key groups, as in version 1.
+const (
+	AnyAlt              = v1.AnyAlt
+	AnyCtrl             = v1.AnyCtrl
+	AnyEnter            = v1.AnyEnter
+	AnyMeta             = v1.AnyMeta
+	AnyShift            = v1.AnyShift
+	Key0                = v1.Key0
+	Key1                = v1.Key1
+	Key102ND            = v1.Key102ND
+	Key2                = v1.Key2
+	Key3                = v1.Key3
+	Key4                = v1.Key4
+	Key5                = v1.Key5
+	Key6                = v1.Key6
+	Key7                = v1.Key7
+	Key8                = v1.Key8
+	Key9                = v1.Key9
+	KeyA                = v1.KeyA
+	KeyAGAIN            = v1.KeyAGAIN
+	KeyALL_APPLICATIONS = v1.KeyALL_APPLICATIONS
+	KeyALTERASE         = v1.KeyALTERASE
+	KeyAPOSTROPHE       = v1.KeyAPOSTROPHE
+	KeyB                = v1.KeyB
+	KeyBACK             = v1.KeyBACK
+	KeyBACKSLASH        = v1.KeyBACKSLASH
+	KeyBACKSPACE        = v1.KeyBACKSPACE
+	KeyBASSBOOST        = v1.KeyBASSBOOST
+	KeyBATTERY          = v1.KeyBATTERY
+	KeyBLUETOOTH        = v1.KeyBLUETOOTH
+	KeyBOOKMARKS        = v1.KeyBOOKMARKS
+	KeyBRIGHTNESSDOWN   = v1.KeyBRIGHTNESSDOWN
+	KeyBRIGHTNESSUP     = v1.KeyBRIGHTNESSUP
+	KeyBRIGHTNESS_AUTO  = v1.KeyBRIGHTNESS_AUTO
+	KeyBRIGHTNESS_CYCLE = v1.KeyBRIGHTNESS_CYCLE
+	KeyC                = v1.KeyC
+	KeyCALC             = v1.KeyCALC
+	KeyCAMERA           = v1.KeyCAMERA
+	KeyCANCEL           = v1.KeyCANCEL
+	KeyCAPSLOCK         = v1.KeyCAPSLOCK
+	KeyCHAT             = v1.KeyCHAT
+	KeyCLOSE            = v1.KeyCLOSE
+	KeyCLOSECD          = v1.KeyCLOSECD
+	KeyCOFFEE           = v1.KeyCOFFEE
+	KeyCOMMA            = v1.KeyCOMMA
+	KeyCOMPOSE          = v1.KeyCOMPOSE
+	KeyCOMPUTER         = v1.KeyCOMPUTER
+	KeyCONFIG           = v1.KeyCONFIG
+	KeyCONNECT          = v1.KeyCONNECT
+	KeyCOPY             = v1.KeyCOPY
+	KeyCUT              = v1.KeyCUT
+	KeyCYCLEWINDOWS     = v1.KeyCYCLEWINDOWS
+	KeyD                = v1.KeyD
+	KeyDELETE           = v1.KeyDELETE
+	KeyDELETEFILE       = v1.KeyDELETEFILE
+	KeyDISPLAY_OFF      = v1.KeyDISPLAY_OFF
+	KeyDOCUMENTS        = v1.KeyDOCUMENTS
+	KeyDOT              = v1.KeyDOT
+	KeyDOWN             = v1.KeyDOWN
+	KeyE                = v1.KeyE
+	KeyEDIT             = v1.KeyEDIT
+	KeyEJECTCD          = v1.KeyEJECTCD
+	KeyEJECTCLOSECD     = v1.KeyEJECTCLOSECD
+	KeyEMAIL            = v1.KeyEMAIL
+	KeyEND              = v1.KeyEND
+	KeyENTER            = v1.KeyENTER
+	KeyEQUAL            = v1.KeyEQUAL
+	KeyESC              = v1.KeyESC
+	KeyEXIT             = v1.KeyEXIT
+	KeyF                = v1.KeyF
+	KeyF1               = v1.KeyF1
+	KeyF10              = v1.KeyF10
+	KeyF11              = v1.KeyF11
+	KeyF12              = v1.KeyF12
+	KeyF13              = v1.KeyF13
+	KeyF14              = v1.KeyF14
+	KeyF15              = v1.KeyF15
+	KeyF16              = v1.KeyF16
+	KeyF17              = v1.KeyF17
+	KeyF18              = v1.KeyF18
+	KeyF19              = v1.KeyF19
+	KeyF2               = v1.KeyF2
+	KeyF20              = v1.KeyF20
+	KeyF21              = v1.KeyF21
+	KeyF22              = v1.KeyF22
+	KeyF23              = v1.KeyF23
+	KeyF24              = v1.KeyF24
+	KeyF3               = v1.KeyF3
+	KeyF4               = v1.KeyF4
+	KeyF5               = v1.KeyF5
+	KeyF6               = v1.KeyF6
+	KeyF7               = v1.KeyF7
+	KeyF8               = v1.KeyF8
+	KeyF9               = v1.KeyF9
+	KeyFASTFORWARD      = v1.KeyFASTFORWARD
+	KeyFILE             = v1.KeyFILE
+	KeyFINANCE          = v1.KeyFINANCE
+	KeyFIND             = v1.KeyFIND
+	KeyFORWARD          = v1.KeyFORWARD
+	KeyFORWARDMAIL      = v1.KeyFORWARDMAIL
+	KeyFRONT            = v1.KeyFRONT
+	KeyG                = v1.KeyG
+	KeyGRAVE            = v1.KeyGRAVE
+	KeyH                = v1.KeyH
+	KeyHANGEUL          = v1.KeyHANGEUL
+	KeyHANJA            = v1.KeyHANJA
+	KeyHELP             = v1.KeyHELP
+	KeyHENKAN           = v1.KeyHENKAN
+	KeyHIRAGANA         = v1.KeyHIRAGANA
+	KeyHOME             = v1.KeyHOME
+	KeyHOMEPAGE         = v1.KeyHOMEPAGE
+	KeyHP               = v1.KeyHP
+	KeyI                = v1.KeyI
+	KeyINSERT           = v1.KeyINSERT
+	KeyISO              = v1.KeyISO
+	KeyJ                = v1.KeyJ
+	KeyK                = v1.KeyK
+	KeyKATAKANA         = v1.KeyKATAKANA
+	KeyKATAKANAHIRAGANA = v1.KeyKATAKANAHIRAGANA
+	KeyKBDILLUMDOWN     = v1.KeyKBDILLUMDOWN
+	KeyKBDILLUMTOGGLE   = v1.KeyKBDILLUMTOGGLE
+	KeyKBDILLUMUP       = v1.KeyKBDILLUMUP
+	KeyKP0              = v1.KeyKP0
+	KeyKP1              = v1.KeyKP1
+	KeyKP2              = v1.KeyKP2
+	KeyKP3              = v1.KeyKP3
+	KeyKP4              = v1.KeyKP4
+	KeyKP5              = v1.KeyKP5
+	KeyKP6              = v1.KeyKP6
+	KeyKP7              = v1.KeyKP7
+	KeyKP8              = v1.KeyKP8
+	KeyKP9              = v1.KeyKP9
+	KeyKPASTERISK       = v1.KeyKPASTERISK
+	KeyKPCOMMA          = v1.KeyKPCOMMA
+	KeyKPDOT            = v1.KeyKPDOT
+	KeyKPENTER          = v1.KeyKPENTER
+	KeyKPEQUAL          = v1.KeyKPEQUAL
+	KeyKPJPCOMMA        = v1.KeyKPJPCOMMA
+	KeyKPLEFTPAREN      = v1.KeyKPLEFTPAREN
+	KeyKPMINUS          = v1.KeyKPMINUS
+	KeyKPPLUS           = v1.KeyKPPLUS
+	KeyKPPLUSMINUS      = v1.KeyKPPLUSMINUS
+	KeyKPRIGHTPAREN     = v1.KeyKPRIGHTPAREN
+	KeyKPSLASH          = v1.KeyKPSLASH
+	KeyL                = v1.KeyL
+	KeyLEFT             = v1.KeyLEFT
+	KeyLEFTALT          = v1.KeyLEFTALT
+	KeyLEFTBRACE        = v1.KeyLEFTBRACE
+	KeyLEFTCTRL         = v1.KeyLEFTCTRL
+	KeyLEFTMETA         = v1.KeyLEFTMETA
+	KeyLEFTSHIFT        = v1.KeyLEFTSHIFT
+	KeyLINEFEED         = v1.KeyLINEFEED
+	KeyM                = v1.KeyM
+	KeyMACRO            = v1.KeyMACRO
+	KeyMAIL             = v1.KeyMAIL
+	KeyMEDIA            = v1.KeyMEDIA
+	KeyMENU             = v1.KeyMENU
+	KeyMICMUTE          = v1.KeyMICMUTE
+	KeyMINUS            = v1.KeyMINUS
+	KeyMOVE             = v1.KeyMOVE
+	KeyMSDOS            = v1.KeyMSDOS
+	KeyMUHENKAN         = v1.KeyMUHENKAN
+	KeyMUTE             = v1.KeyMUTE
+	KeyN                = v1.KeyN
+	KeyNEW              = v1.KeyNEW
+	KeyNEXTSONG         = v1.KeyNEXTSONG
+	KeyNUMLOCK          = v1.KeyNUMLOCK
+	KeyO                = v1.KeyO
+	KeyOPEN             = v1.KeyOPEN
+	KeyP                = v1.KeyP
+	KeyPAGEDOWN         = v1.KeyPAGEDOWN
+	KeyPAGEUP           = v1.KeyPAGEUP
+	KeyPASTE            = v1.KeyPASTE
+	KeyPAUSE            = v1.KeyPAUSE
+	KeyPAUSECD          = v1.KeyPAUSECD
+	KeyPHONE            = v1.KeyPHONE
+	KeyPLAY             = v1.KeyPLAY
+	KeyPLAYCD           = v1.KeyPLAYCD
+	KeyPLAYPAUSE        = v1.KeyPLAYPAUSE
+	KeyPOWER            = v1.KeyPOWER
+	KeyPREVIOUSSONG     = v1.KeyPREVIOUSSONG
+	KeyPRINT            = v1.KeyPRINT
+	KeyPROG1            = v1.KeyPROG1
+	KeyPROG2            = v1.KeyPROG2
+	KeyPROG3            = v1.KeyPROG3
+	KeyPROG4            = v1.KeyPROG4
+	KeyPROPS            = v1.KeyPROPS
+	KeyQ                = v1.KeyQ
+	KeyQUESTION         = v1.KeyQUESTION
+	KeyR                = v1.KeyR
+	KeyRECORD           = v1.KeyRECORD
+	KeyREDO             = v1.KeyREDO
+	KeyREFRESH          = v1.KeyREFRESH
+	KeyREPLY            = v1.KeyREPLY
+	KeyRESERVED         = v1.KeyRESERVED
+	KeyREWIND           = v1.KeyREWIND
+	KeyRFKILL           = v1.KeyRFKILL
+	KeyRIGHT            = v1.KeyRIGHT
+	KeyRIGHTALT         = v1.KeyRIGHTALT
+	KeyRIGHTBRACE       = v1.KeyRIGHTBRACE
+	KeyRIGHTCTRL        = v1.KeyRIGHTCTRL
+	KeyRIGHTMETA        = v1.KeyRIGHTMETA
+	KeyRIGHTSHIFT       = v1.KeyRIGHTSHIFT
+	KeyRO               = v1.KeyRO
+	KeyROTATE_DISPLAY   = v1.KeyROTATE_DISPLAY
+	KeyRedacted         = v1.KeyRedacted
+	KeyS                = v1.KeyS
+	KeySAVE             = v1.KeySAVE
+	KeySCALE            = v1.KeySCALE
+	KeySCROLLDOWN       = v1.KeySCROLLDOWN
+	KeySCROLLLOCK       = v1.KeySCROLLLOCK
+	KeySCROLLUP         = v1.KeySCROLLUP
+	KeySEARCH           = v1.KeySEARCH
+	KeySEMICOLON        = v1.KeySEMICOLON
+	KeySEND             = v1.KeySEND
+	KeySENDFILE         = v1.KeySENDFILE
+	KeySETUP            = v1.KeySETUP
+	KeySHOP             = v1.KeySHOP
+	KeySLASH            = v1.KeySLASH
+	KeySLEEP            = v1.KeySLEEP
+	KeySOUND            = v1.KeySOUND
+	KeySPACE            = v1.KeySPACE
+	KeySPORT            = v1.KeySPORT
+	KeySTOP             = v1.KeySTOP
+	KeySTOPCD           = v1.KeySTOPCD
+	KeySUSPEND          = v1.KeySUSPEND
+	KeySWITCHVIDEOMODE  = v1.KeySWITCHVIDEOMODE
+	KeySYSRQ            = v1.KeySYSRQ
+	KeyT                = v1.KeyT
+	KeyTAB              = v1.KeyTAB
+	KeyU                = v1.KeyU
+	KeyUNDO             = v1.KeyUNDO
+	KeyUNKNOWN          = v1.KeyUNKNOWN
+	KeyUP               = v1.KeyUP
+	KeyUWB              = v1.KeyUWB
+	KeyV                = v1.KeyV
+	KeyVIDEO_NEXT       = v1.KeyVIDEO_NEXT
+	KeyVIDEO_PREV       = v1.KeyVIDEO_PREV
+	KeyVOLUMEDOWN       = v1.KeyVOLUMEDOWN
+	KeyVOLUMEUP         = v1.KeyVOLUMEUP
+	KeyW                = v1.KeyW
+	KeyWAKEUP           = v1.KeyWAKEUP
+	KeyWLAN             = v1.KeyWLAN
+	KeyWWAN             = v1.KeyWWAN
+	KeyWWW              = v1.KeyWWW
+	KeyX                = v1.KeyX
+	KeyXFER             = v1.KeyXFER
+	KeyY                = v1.KeyY
+	KeyYEN              = v1.KeyYEN
+	KeyZ                = v1.KeyZ
+	KeyZENKAKUHANKAKU   = v1.KeyZENKAKUHANKAKU
+)
@@ -0,0 +1,57 @@
+package kbd
+
+import "time"
+
+// SetReleaseTimeout sets the maximum time a key may be held down. If no
+// release is read for a key within d of its press, a release is made up and
+// applied as if it had been read: the key is forced to the "up" state, and
+// the release is delivered on Event() and Frames(), to Subscriptions and on
+// the Bus, with Synthetic set so that it can be told apart from a real one.
+// This protects against keys "sticking" when a device glitches or drops a
+// release. A d of 0 (the default) disables the watchdog.
+func (kb *Keyboard) SetReleaseTimeout(d time.Duration) {
+	kb.mu.Lock()
+	defer kb.mu.Unlock()
+	kb.timeout = d
+	if d <= 0 {
+		for key, t := range kb.timers {
+			t.Stop()
+			delete(kb.timers, key)
+		}
+	}
+}
+
+// watch starts (on press) or stops (on release) the release timer for the
+// key of event. kb.mu must be held.
+func (kb *Keyboard) watch(event Event) {
+	key := event.Code
+	if t, ok := kb.timers[key]; ok {
+		t.Stop()
+		delete(kb.timers, key)
+	}
+	if event.Value != Press || kb.timeout <= 0 {
+		return
+	}
+
+	var t *time.Timer
+	t = time.AfterFunc(kb.timeout, func() {
+		kb.applying.Lock()
+		defer kb.applying.Unlock()
+		kb.mu.Lock()
+		current := kb.timers[key] == t // not replaced or stopped
+		if current {
+			delete(kb.timers, key)
+		}
+		kb.mu.Unlock()
+		if current {
+			kb.applyLocked(Frame{{
+				Time:      time.Now(),
+				Code:      key,
+				Value:     Release,
+				Device:    event.Device,
+				Synthetic: true,
+			}})
+		}
+	})
+	kb.timers[key] = t
+}
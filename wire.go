@@ -0,0 +1,76 @@
+package kbd
+
+import (
+	"encoding/binary"
+	"io"
+	"time"
+)
+
+// wireEvent is the encoding of an Event on a stream: little endian, 14 bytes.
+type wireEvent struct {
+	Time  int64 // nanoseconds since the Unix epoch
+	Code  uint16
+	Value int32
+}
+
+// EventWriter writes Events to a stream, such as a network connection, in
+// the package's wire format.
+type EventWriter struct {
+	// Redact, if set, passes events through Redact before writing them, for
+	// recordings and tees that must not reveal what was typed.
+	Redact bool
+
+	w io.Writer
+}
+
+// NewEventWriter returns an EventWriter writing to w.
+func NewEventWriter(w io.Writer) *EventWriter {
+	return &EventWriter{w: w}
+}
+
+// Write writes event to the stream.
+func (w *EventWriter) Write(event Event) error {
+	if w.Redact {
+		event = Redact(event)
+	}
+	return binary.Write(w.w, binary.LittleEndian, wireEvent{
+		Time:  event.Time.UnixNano(),
+		Code:  uint16(event.Code),
+		Value: event.Value,
+	})
+}
+
+// EventReader reads Events written by an EventWriter. It is a Backend, so a
+// Keyboard can be created from a remote stream of events.
+type EventReader struct {
+	r io.Reader
+}
+
+// NewEventReader returns an EventReader reading from r.
+func NewEventReader(r io.Reader) *EventReader {
+	return &EventReader{r: r}
+}
+
+// ReadEvent reads the next Event from the stream.
+func (r *EventReader) ReadEvent() (Event, error) {
+	var w wireEvent
+	if err := binary.Read(r.r, binary.LittleEndian, &w); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			decodeErrors.inc() // the stream ended within an event
+		}
+		return Event{}, err
+	}
+	return Event{
+		Time:  time.Unix(0, w.Time),
+		Code:  KeyCode(w.Code),
+		Value: w.Value,
+	}, nil
+}
+
+// Close closes the stream, if it is an io.Closer.
+func (r *EventReader) Close() error {
+	if c, ok := r.r.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}